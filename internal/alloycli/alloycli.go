@@ -24,7 +24,11 @@ func Command() *cobra.Command {
 	cmd.AddCommand(
 		convertCommand(),
 		fmtCommand(),
+		graphCommand(),
+		lspCommand(),
+		planCommand(),
 		RunCommand(),
+		testCommand(),
 		toolsCommand(),
 		validateCommand(),
 	)
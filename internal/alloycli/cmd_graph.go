@@ -0,0 +1,271 @@
+package alloycli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
+	"github.com/grafana/alloy/internal/service"
+	"github.com/grafana/alloy/internal/service/cluster"
+	"github.com/grafana/alloy/internal/service/http"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+	"github.com/grafana/alloy/internal/service/otel"
+	"github.com/grafana/alloy/internal/service/remotecfg"
+	"github.com/grafana/alloy/internal/service/ui"
+	"github.com/grafana/alloy/syntax/diag"
+)
+
+func graphCommand() *cobra.Command {
+	g := &alloyGraph{
+		format:       "dot",
+		minStability: featuregate.StabilityGenerallyAvailable,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "graph [flags] path",
+		Short: "Print the dependency graph of a configuration",
+		Long: `The graph subcommand loads the configuration at path without running any
+component and prints its dependency graph.
+
+The bodies of declare blocks and the templates of foreach blocks are shown
+as nested modules. Nodes which are part of a dependency cycle and components
+which are not connected to any other block are highlighted.
+
+If path is a directory, all *.alloy files in that directory are loaded.
+
+graph exits with a non-zero exit code if the configuration has errors, such as
+dependency cycles. The graph is printed regardless.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return g.Run(os.Stdout, args[0])
+		},
+	}
+
+	cmd.Flags().StringVarP(&g.format, "format", "f", g.format, "Output format of the graph. Supported formats: dot, mermaid, json.")
+	cmd.Flags().Var(&g.minStability, "stability.level", fmt.Sprintf("Minimum stability level of features to enable. Supported values: %s", strings.Join(featuregate.AllowedValues(), ", ")))
+	cmd.Flags().BoolVar(&g.enableCommunityComps, "feature.community-components.enabled", g.enableCommunityComps, "Enable community components.")
+
+	return cmd
+}
+
+type alloyGraph struct {
+	format               string
+	minStability         featuregate.Stability
+	enableCommunityComps bool
+}
+
+func (g *alloyGraph) Run(w io.Writer, configPath string) error {
+	var write func(io.Writer, *runtime.Graph) error
+	switch g.format {
+	case "dot":
+		write = writeGraphDOT
+	case "mermaid":
+		write = writeGraphMermaid
+	case "json":
+		write = writeGraphJSON
+	default:
+		return fmt.Errorf("unsupported graph format %q", g.format)
+	}
+
+	sources, err := loadSourceFiles(configPath, "alloy", false, "")
+	if err != nil {
+		return fmt.Errorf("reading config path %q: %w", configPath, err)
+	}
+	source, err := runtime.ParseSources(sources)
+	if err != nil {
+		return err
+	}
+
+	graph, diags := runtime.LoadGraph(runtime.Options{
+		MinStability:         g.minStability,
+		EnableCommunityComps: g.enableCommunityComps,
+		Services:             offlineServices(),
+		ComponentRegistry:    component.NewDefaultRegistry(g.minStability, g.enableCommunityComps),
+	}, source, configPath)
+
+	if err := write(w, graph); err != nil {
+		return err
+	}
+
+	for _, id := range graph.Orphans {
+		fmt.Fprintf(os.Stderr, "warning: component %q is not connected to any other block\n", id)
+	}
+	if diags.HasErrors() {
+		p := diag.NewPrinter(diag.PrinterConfig{
+			Color:              !color.NoColor,
+			ContextLinesBefore: 1,
+			ContextLinesAfter:  1,
+		})
+		_ = p.Fprint(os.Stderr, sources, diags)
+		return errors.New("the configuration has errors")
+	}
+	return nil
+}
+
+// graphLayout groups the nodes of a graph by the module holding them, and
+// records which nodes are highlighted.
+type graphLayout struct {
+	modules map[string][]runtime.GraphNode
+	cycle   map[string]struct{}
+	orphan  map[string]struct{}
+}
+
+func newGraphLayout(g *runtime.Graph) *graphLayout {
+	l := &graphLayout{
+		modules: make(map[string][]runtime.GraphNode),
+		cycle:   make(map[string]struct{}),
+		orphan:  make(map[string]struct{}),
+	}
+	for _, n := range g.Nodes {
+		l.modules[n.Module] = append(l.modules[n.Module], n)
+	}
+	for _, c := range g.Cycles {
+		for _, id := range c {
+			l.cycle[id] = struct{}{}
+		}
+	}
+	for _, id := range g.Orphans {
+		l.orphan[id] = struct{}{}
+	}
+	return l
+}
+
+// walk calls fn for every node of module, and calls enter and leave around
+// the nodes of the modules nested in module.
+func (l *graphLayout) walk(module string, depth int, fn func(n runtime.GraphNode, depth int), enter func(n runtime.GraphNode, depth int), leave func(depth int)) {
+	for _, n := range l.modules[module] {
+		fn(n, depth)
+		if _, ok := l.modules[n.ID]; ok {
+			enter(n, depth)
+			l.walk(n.ID, depth+1, fn, enter, leave)
+			leave(depth)
+		}
+	}
+}
+
+func writeGraphDOT(w io.Writer, g *runtime.Graph) error {
+	var (
+		l  = newGraphLayout(g)
+		sb strings.Builder
+	)
+
+	sb.WriteString("digraph alloy {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=box];\n")
+
+	l.walk("", 1,
+		func(n runtime.GraphNode, depth int) {
+			attrs := []string{"label=" + dotQuote(n.LocalID)}
+			if _, ok := l.cycle[n.ID]; ok {
+				attrs = append(attrs, "color=red")
+			}
+			if _, ok := l.orphan[n.ID]; ok {
+				attrs = append(attrs, "style=dashed")
+			}
+			fmt.Fprintf(&sb, "%s%s [%s];\n", strings.Repeat("\t", depth), dotQuote(n.ID), strings.Join(attrs, ", "))
+		},
+		func(n runtime.GraphNode, depth int) {
+			indent := strings.Repeat("\t", depth)
+			fmt.Fprintf(&sb, "%ssubgraph %s {\n", indent, dotQuote("cluster_"+n.ID))
+			fmt.Fprintf(&sb, "%s\tlabel=%s;\n", indent, dotQuote(n.LocalID))
+		},
+		func(depth int) {
+			fmt.Fprintf(&sb, "%s}\n", strings.Repeat("\t", depth))
+		},
+	)
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "\t%s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func writeGraphMermaid(w io.Writer, g *runtime.Graph) error {
+	var (
+		l   = newGraphLayout(g)
+		sb  strings.Builder
+		ids = make(map[string]string, len(g.Nodes))
+
+		cycle, orphan []string
+	)
+	// Mermaid IDs can't hold all the characters of node IDs.
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	sb.WriteString("flowchart LR\n")
+	l.walk("", 1,
+		func(n runtime.GraphNode, depth int) {
+			fmt.Fprintf(&sb, "%s%s[%s]\n", strings.Repeat("    ", depth), ids[n.ID], mermaidQuote(n.LocalID))
+			if _, ok := l.cycle[n.ID]; ok {
+				cycle = append(cycle, ids[n.ID])
+			}
+			if _, ok := l.orphan[n.ID]; ok {
+				orphan = append(orphan, ids[n.ID])
+			}
+		},
+		func(n runtime.GraphNode, depth int) {
+			fmt.Fprintf(&sb, "%ssubgraph %s_body [%s]\n", strings.Repeat("    ", depth), ids[n.ID], mermaidQuote(n.LocalID))
+		},
+		func(depth int) {
+			fmt.Fprintf(&sb, "%send\n", strings.Repeat("    ", depth))
+		},
+	)
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "    %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	if len(cycle) > 0 {
+		sb.WriteString("    classDef cycle stroke:#d00,stroke-width:2px\n")
+		fmt.Fprintf(&sb, "    class %s cycle\n", strings.Join(cycle, ","))
+	}
+	if len(orphan) > 0 {
+		sb.WriteString("    classDef orphan stroke-dasharray:5 5\n")
+		fmt.Fprintf(&sb, "    class %s orphan\n", strings.Join(orphan, ","))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+func writeGraphJSON(w io.Writer, g *runtime.Graph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// offlineServices returns the services a configuration can configure. They
+// are only used to load configurations without running them.
+func offlineServices() []service.Service {
+	return []service.Service{
+		&cluster.Service{},
+		&http.Service{},
+		&labelstore.Service{},
+		&livedebugging.Service{},
+		&otel.Service{},
+		&remotecfg.Service{},
+		&ui.Service{},
+	}
+}
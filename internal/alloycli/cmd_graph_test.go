@@ -0,0 +1,101 @@
+package alloycli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
+)
+
+var testGraph = &runtime.Graph{
+	Nodes: []runtime.GraphNode{
+		{ID: "declare.pipeline", LocalID: "declare.pipeline", Kind: runtime.GraphNodeDeclare},
+		{ID: "declare.pipeline/argument.input", LocalID: "argument.input", Kind: runtime.GraphNodeArgument, Module: "declare.pipeline"},
+		{ID: "declare.pipeline/loki.process.default", LocalID: "loki.process.default", Kind: runtime.GraphNodeComponent, Module: "declare.pipeline"},
+		{ID: "pipeline.default", LocalID: "pipeline.default", Kind: runtime.GraphNodeCustomComponent},
+		{ID: "prometheus.exporter.self.default", LocalID: "prometheus.exporter.self.default", Kind: runtime.GraphNodeComponent},
+	},
+	Edges: []runtime.GraphEdge{
+		{From: "declare.pipeline/loki.process.default", To: "declare.pipeline/argument.input"},
+		{From: "pipeline.default", To: "declare.pipeline"},
+	},
+	Orphans: []string{"prometheus.exporter.self.default"},
+}
+
+func TestWriteGraphDOT(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeGraphDOT(&out, testGraph))
+	require.Equal(t, `digraph alloy {
+	rankdir=LR;
+	node [shape=box];
+	"declare.pipeline" [label="declare.pipeline"];
+	subgraph "cluster_declare.pipeline" {
+		label="declare.pipeline";
+		"declare.pipeline/argument.input" [label="argument.input"];
+		"declare.pipeline/loki.process.default" [label="loki.process.default"];
+	}
+	"pipeline.default" [label="pipeline.default"];
+	"prometheus.exporter.self.default" [label="prometheus.exporter.self.default", style=dashed];
+	"declare.pipeline/loki.process.default" -> "declare.pipeline/argument.input";
+	"pipeline.default" -> "declare.pipeline";
+}
+`, out.String())
+}
+
+func TestWriteGraphMermaid(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeGraphMermaid(&out, testGraph))
+	require.Equal(t, `flowchart LR
+    n0["declare.pipeline"]
+    subgraph n0_body ["declare.pipeline"]
+        n1["argument.input"]
+        n2["loki.process.default"]
+    end
+    n3["pipeline.default"]
+    n4["prometheus.exporter.self.default"]
+    n2 --> n1
+    n3 --> n0
+    classDef orphan stroke-dasharray:5 5
+    class n4 orphan
+`, out.String())
+}
+
+func TestGraphRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.alloy")
+	require.NoError(t, os.WriteFile(path, []byte(`
+prometheus.exporter.self "default" { }
+
+prometheus.scrape "default" {
+	targets    = prometheus.exporter.self.default.targets
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/v1/push"
+	}
+}
+`), 0644))
+
+	var out bytes.Buffer
+	g := &alloyGraph{format: "json", minStability: featuregate.StabilityGenerallyAvailable}
+	require.NoError(t, g.Run(&out, path))
+
+	var graph runtime.Graph
+	require.NoError(t, json.Unmarshal(out.Bytes(), &graph))
+	require.Len(t, graph.Nodes, 3)
+	require.Equal(t, []runtime.GraphEdge{
+		{From: "prometheus.scrape.default", To: "prometheus.exporter.self.default"},
+		{From: "prometheus.scrape.default", To: "prometheus.remote_write.default"},
+	}, graph.Edges)
+	require.Empty(t, graph.Orphans)
+
+	g.format = "svg"
+	require.EqualError(t, g.Run(&out, path), `unsupported graph format "svg"`)
+}
@@ -0,0 +1,81 @@
+package alloycli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/go-kit/log"
+	"github.com/spf13/cobra"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/lsp"
+	"github.com/grafana/alloy/internal/service/cluster"
+	"github.com/grafana/alloy/internal/service/http"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+	"github.com/grafana/alloy/internal/service/otel"
+	"github.com/grafana/alloy/internal/service/remotecfg"
+	"github.com/grafana/alloy/internal/service/ui"
+)
+
+func lspCommand() *cobra.Command {
+	l := &alloyLSP{
+		minStability: featuregate.StabilityGenerallyAvailable,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "lsp [flags]",
+		Short: "Run a language server for configuration files",
+		Long: `The lsp subcommand runs a Language Server Protocol server for Alloy
+configuration files. The server communicates with the editor over stdin and
+stdout.
+
+The server reports the diagnostics of alloy validate when a file is opened or
+saved, completes component, block and attribute names, jumps to the definition
+of references, shows the documentation of components, blocks and attributes on
+hover and formats files like alloy fmt.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return l.Run()
+		},
+	}
+
+	cmd.Flags().Var(&l.minStability, "stability.level", fmt.Sprintf("Minimum stability level of features to enable. Supported values: %s", strings.Join(featuregate.AllowedValues(), ", ")))
+	cmd.Flags().BoolVar(&l.enableCommunityComps, "feature.community-components.enabled", l.enableCommunityComps, "Enable community components.")
+
+	return cmd
+}
+
+type alloyLSP struct {
+	minStability         featuregate.Stability
+	enableCommunityComps bool
+}
+
+func (l *alloyLSP) Run() error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	srv := lsp.NewServer(lsp.Options{
+		ComponentRegistry: component.NewDefaultRegistry(l.minStability, l.enableCommunityComps),
+		ComponentNames:    component.AllNames(),
+		ServiceDefinitions: getServiceDefinitions(
+			&cluster.Service{},
+			&http.Service{},
+			&labelstore.Service{},
+			&livedebugging.Service{},
+			&otel.Service{},
+			&remotecfg.Service{},
+			&ui.Service{},
+		),
+		MinStability: l.minStability,
+		// Stdout is used to talk to the editor.
+		Logger: log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)),
+	})
+	return srv.Serve(ctx, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,128 @@
+package alloycli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/pipelinetest"
+	"github.com/grafana/alloy/internal/runtime/logging"
+)
+
+func testCommand() *cobra.Command {
+	t := &alloyTest{
+		minStability: featuregate.StabilityGenerallyAvailable,
+		timeout:      pipelinetest.DefaultOptions.Timeout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "test [flags] path test_file...",
+		Short: "Test pipelines with fixture inputs and expected outputs",
+		Long: `The test subcommand runs the tests in the given test files against the
+configuration at path.
+
+Each test replaces components of the configuration with fixtures. Input
+fixtures send log entries, metric samples, targets or spans to the components
+an input component forwards to. Output fixtures capture the data which reaches
+an output component and compare it to the expected data.
+
+If path is a directory, all *.alloy files in that directory are loaded.
+
+test exits with a non-zero exit code if any test fails, and prints the
+differences between the expected and the received data.`,
+		Args:         cobra.MinimumNArgs(2),
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return t.Run(args[0], args[1:])
+		},
+	}
+
+	cmd.Flags().StringVar(&t.run, "run", t.run, "Only run the tests whose name matches the regular expression")
+	cmd.Flags().DurationVar(&t.timeout, "timeout", t.timeout, "Maximum duration to wait for the expected outputs of a test")
+	cmd.Flags().BoolVarP(&t.verbose, "verbose", "v", t.verbose, "Write the logs of the configuration to stderr")
+	cmd.Flags().Var(&t.minStability, "stability.level", fmt.Sprintf("Minimum stability level of features to enable. Supported values: %s", strings.Join(featuregate.AllowedValues(), ", ")))
+	cmd.Flags().BoolVar(&t.enableCommunityComps, "feature.community-components.enabled", t.enableCommunityComps, "Enable community components.")
+
+	return cmd
+}
+
+type alloyTest struct {
+	run                  string
+	timeout              time.Duration
+	verbose              bool
+	minStability         featuregate.Stability
+	enableCommunityComps bool
+}
+
+func (t *alloyTest) Run(configPath string, testPaths []string) error {
+	filter, err := regexp.Compile(t.run)
+	if err != nil {
+		return fmt.Errorf("invalid --run expression: %w", err)
+	}
+
+	sources, err := loadSourceFiles(configPath, "alloy", false, "")
+	if err != nil {
+		return fmt.Errorf("reading config path %q: %w", configPath, err)
+	}
+
+	var tests []pipelinetest.Test
+	for _, path := range testPaths {
+		bb, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := pipelinetest.ParseFile(bb)
+		if err != nil {
+			return fmt.Errorf("parsing test file %q: %w", path, err)
+		}
+		for _, test := range f.Tests {
+			if filter.MatchString(test.Name) {
+				tests = append(tests, test)
+			}
+		}
+	}
+
+	// The level and format of the logs are set by the logging block of the
+	// configuration.
+	l := logging.NewNop()
+	if t.verbose {
+		l, err = logging.NewDeferred(os.Stderr)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	runner := pipelinetest.NewRunner(pipelinetest.Options{
+		ComponentRegistry: component.NewDefaultRegistry(t.minStability, t.enableCommunityComps),
+		MinStability:      t.minStability,
+		Logger:            l,
+		Timeout:           t.timeout,
+	})
+
+	var failed int
+	for _, test := range tests {
+		res := runner.Run(ctx, sources, configPath, test)
+		pipelinetest.Report(os.Stdout, []pipelinetest.Result{res})
+		if res.Failed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(tests))
+	}
+	fmt.Fprintf(os.Stdout, "PASS (%d tests)\n", len(tests))
+	return nil
+}
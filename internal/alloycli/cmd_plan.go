@@ -0,0 +1,166 @@
+package alloycli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
+	"github.com/grafana/alloy/syntax/diag"
+)
+
+func planCommand() *cobra.Command {
+	p := &alloyPlan{
+		format:       "text",
+		minStability: featuregate.StabilityGenerallyAvailable,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "plan [flags] --current path --next path",
+		Short: "Show the changes loading a configuration would make",
+		Long: `The plan subcommand shows which components loading the configuration at
+the --next path would create, update or delete, compared to the configuration
+at the --current path. Neither configuration is run.
+
+For updated components, plan lists the arguments which changed and the
+components which are evaluated again if the exports of the component change.
+
+If a path is a directory, all *.alloy files in that directory are loaded.
+
+plan exits with a non-zero exit code if either configuration has errors.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return p.Run(os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVar(&p.current, "current", p.current, "Path of the current configuration")
+	cmd.Flags().StringVar(&p.next, "next", p.next, "Path of the configuration to plan")
+	cmd.Flags().StringVarP(&p.format, "format", "f", p.format, "Output format of the plan. Supported formats: text, json.")
+	cmd.Flags().Var(&p.minStability, "stability.level", fmt.Sprintf("Minimum stability level of features to enable. Supported values: %s", strings.Join(featuregate.AllowedValues(), ", ")))
+	cmd.Flags().BoolVar(&p.enableCommunityComps, "feature.community-components.enabled", p.enableCommunityComps, "Enable community components.")
+	_ = cmd.MarkFlagRequired("current")
+	_ = cmd.MarkFlagRequired("next")
+
+	return cmd
+}
+
+type alloyPlan struct {
+	current, next        string
+	format               string
+	minStability         featuregate.Stability
+	enableCommunityComps bool
+}
+
+func (p *alloyPlan) Run(w io.Writer) error {
+	var write func(io.Writer, *runtime.Plan) error
+	switch p.format {
+	case "text":
+		write = writePlanText
+	case "json":
+		write = writePlanJSON
+	default:
+		return fmt.Errorf("unsupported plan format %q", p.format)
+	}
+
+	currentSources, err := loadSourceFiles(p.current, "alloy", false, "")
+	if err != nil {
+		return fmt.Errorf("reading config path %q: %w", p.current, err)
+	}
+	current, err := runtime.ParseSources(currentSources)
+	if err != nil {
+		return err
+	}
+	nextSources, err := loadSourceFiles(p.next, "alloy", false, "")
+	if err != nil {
+		return fmt.Errorf("reading config path %q: %w", p.next, err)
+	}
+	next, err := runtime.ParseSources(nextSources)
+	if err != nil {
+		return err
+	}
+
+	plan, diags := runtime.PlanSources(runtime.Options{
+		MinStability:         p.minStability,
+		EnableCommunityComps: p.enableCommunityComps,
+		Services:             offlineServices(),
+		ComponentRegistry:    component.NewDefaultRegistry(p.minStability, p.enableCommunityComps),
+	}, current, next, p.next)
+	if diags.HasErrors() {
+		sources := maps.Clone(currentSources)
+		maps.Copy(sources, nextSources)
+
+		printer := diag.NewPrinter(diag.PrinterConfig{
+			Color:              !color.NoColor,
+			ContextLinesBefore: 1,
+			ContextLinesAfter:  1,
+		})
+		_ = printer.Fprint(os.Stderr, sources, diags)
+		return errors.New("the configuration has errors")
+	}
+
+	return write(w, plan)
+}
+
+func writePlanText(w io.Writer, plan *runtime.Plan) error {
+	var (
+		sb     strings.Builder
+		counts = make(map[runtime.PlanAction]int)
+	)
+	for _, c := range plan.Changes {
+		counts[c.Action]++
+
+		var sign string
+		switch c.Action {
+		case runtime.PlanCreate:
+			sign = "+"
+		case runtime.PlanUpdate:
+			sign = "~"
+		case runtime.PlanDelete:
+			sign = "-"
+		}
+		fmt.Fprintf(&sb, "%s %s %s", sign, c.Action, c.ID)
+
+		var details []string
+		if len(c.Arguments) > 0 {
+			details = append(details, "arguments: "+strings.Join(c.Arguments, ", "))
+		}
+		if c.DefinitionChanged {
+			details = append(details, "definition changed")
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(&sb, " (%s)", strings.Join(details, "; "))
+		}
+		sb.WriteString("\n")
+
+		if len(c.Dependants) > 0 {
+			fmt.Fprintf(&sb, "    propagates to: %s\n", strings.Join(c.Dependants, ", "))
+		}
+	}
+
+	if len(plan.Changes) == 0 {
+		sb.WriteString("No changes.\n")
+	} else {
+		fmt.Fprintf(&sb, "\nPlan: %d to create, %d to update, %d to delete.\n",
+			counts[runtime.PlanCreate], counts[runtime.PlanUpdate], counts[runtime.PlanDelete])
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writePlanJSON(w io.Writer, plan *runtime.Plan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
@@ -0,0 +1,62 @@
+package alloycli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+)
+
+func TestPlanRun(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.alloy")
+	require.NoError(t, os.WriteFile(current, []byte(`
+prometheus.exporter.self "default" { }
+
+prometheus.scrape "default" {
+	targets    = prometheus.exporter.self.default.targets
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/v1/push"
+	}
+}
+`), 0644))
+	next := filepath.Join(dir, "next.alloy")
+	require.NoError(t, os.WriteFile(next, []byte(`
+prometheus.exporter.self "default" { }
+
+prometheus.scrape "default" {
+	targets         = prometheus.exporter.self.default.targets
+	forward_to      = [prometheus.remote_write.default.receiver]
+	scrape_interval = "10s"
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://mimir:9009/api/v1/push"
+	}
+}
+`), 0644))
+
+	var out bytes.Buffer
+	p := &alloyPlan{current: current, next: next, format: "text", minStability: featuregate.StabilityGenerallyAvailable}
+	require.NoError(t, p.Run(&out))
+	require.Equal(t, `~ update prometheus.remote_write.default (arguments: endpoint)
+    propagates to: prometheus.scrape.default
+~ update prometheus.scrape.default (arguments: scrape_interval)
+
+Plan: 0 to create, 2 to update, 0 to delete.
+`, out.String())
+
+	out.Reset()
+	p.next = current
+	require.NoError(t, p.Run(&out))
+	require.Equal(t, "No changes.\n", out.String())
+}
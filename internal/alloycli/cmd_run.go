@@ -289,6 +289,7 @@ func (fr *alloyRun) Run(cmd *cobra.Command, configPath string) error {
 	var (
 		reload func() (map[string][]byte, error)
 		ready  func() bool
+		plan   func(sources map[string][]byte) (*alloy_runtime.Plan, error)
 	)
 
 	clusterService, err := buildClusterService(ClusterOptions{
@@ -335,6 +336,9 @@ func (fr *alloyRun) Run(cmd *cobra.Command, configPath string) error {
 			_, err := reload()
 			return err
 		},
+		PlanFunc: func(sources map[string][]byte) (*alloy_runtime.Plan, error) {
+			return plan(sources)
+		},
 
 		HTTPListenAddr:   fr.httpListenAddr,
 		MemoryListenAddr: fr.inMemoryAddr,
@@ -412,6 +416,25 @@ func (fr *alloyRun) Run(cmd *cobra.Command, configPath string) error {
 
 		return sources, nil
 	}
+	plan = func(sources map[string][]byte) (*alloy_runtime.Plan, error) {
+		if sources == nil {
+			var err error
+			sources, err = loadSourceFiles(configPath, fr.configFormat, fr.configBypassConversionErrors, fr.configExtraArgs)
+			if err != nil {
+				return nil, fmt.Errorf("reading config path %q: %w", configPath, err)
+			}
+		}
+
+		alloySource, err := alloy_runtime.ParseSources(sources)
+		if err != nil {
+			return nil, err
+		}
+		p, diags := f.Plan(alloySource, configPath)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		return p, nil
+	}
 
 	// Alloy controller
 	{
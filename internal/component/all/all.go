@@ -38,10 +38,14 @@ import (
 	_ "github.com/grafana/alloy/internal/component/faro/receiver"                            // Import faro.receiver
 	_ "github.com/grafana/alloy/internal/component/local/file"                               // Import local.file
 	_ "github.com/grafana/alloy/internal/component/local/file_match"                         // Import local.file_match
+	_ "github.com/grafana/alloy/internal/component/loki/buffer"                              // Import loki.buffer
+	_ "github.com/grafana/alloy/internal/component/loki/dedupe"                              // Import loki.dedupe
 	_ "github.com/grafana/alloy/internal/component/loki/echo"                                // Import loki.echo
 	_ "github.com/grafana/alloy/internal/component/loki/enrich"                              // Import loki.enrich
+	_ "github.com/grafana/alloy/internal/component/loki/metrics"                             // Import loki.metrics
 	_ "github.com/grafana/alloy/internal/component/loki/process"                             // Import loki.process
 	_ "github.com/grafana/alloy/internal/component/loki/relabel"                             // Import loki.relabel
+	_ "github.com/grafana/alloy/internal/component/loki/route"                               // Import loki.route
 	_ "github.com/grafana/alloy/internal/component/loki/rules/kubernetes"                    // Import loki.rules.kubernetes
 	_ "github.com/grafana/alloy/internal/component/loki/secretfilter"                        // Import loki.secretfilter
 	_ "github.com/grafana/alloy/internal/component/loki/source/api"                          // Import loki.source.api
@@ -128,6 +132,8 @@ import (
 	_ "github.com/grafana/alloy/internal/component/otelcol/receiver/vcenter"                 // Import otelcol.receiver.vcenter
 	_ "github.com/grafana/alloy/internal/component/otelcol/receiver/zipkin"                  // Import otelcol.receiver.zipkin
 	_ "github.com/grafana/alloy/internal/component/otelcol/storage/file"                     // Import otelcol.storage.file
+	_ "github.com/grafana/alloy/internal/component/prometheus/aggregate"                     // Import prometheus.aggregate
+	_ "github.com/grafana/alloy/internal/component/prometheus/cardinality_limit"             // Import prometheus.cardinality_limit
 	_ "github.com/grafana/alloy/internal/component/prometheus/echo"                          // Import prometheus.echo
 	_ "github.com/grafana/alloy/internal/component/prometheus/enrich"                        // Import prometheus.enrich
 	_ "github.com/grafana/alloy/internal/component/prometheus/exporter/apache"               // Import prometheus.exporter.apache
@@ -166,6 +172,8 @@ import (
 	_ "github.com/grafana/alloy/internal/component/prometheus/receive_http"                  // Import prometheus.receive_http
 	_ "github.com/grafana/alloy/internal/component/prometheus/relabel"                       // Import prometheus.relabel
 	_ "github.com/grafana/alloy/internal/component/prometheus/remotewrite"                   // Import prometheus.remote_write
+	_ "github.com/grafana/alloy/internal/component/prometheus/route"                         // Import prometheus.route
+	_ "github.com/grafana/alloy/internal/component/prometheus/rules"                         // Import prometheus.rules
 	_ "github.com/grafana/alloy/internal/component/prometheus/scrape"                        // Import prometheus.scrape
 	_ "github.com/grafana/alloy/internal/component/prometheus/write/queue"                   // Import prometheus.write.queue
 	_ "github.com/grafana/alloy/internal/component/pyroscope/ebpf"                           // Import pyroscope.ebpf
@@ -8,8 +8,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
-	"github.com/golang/snappy"
 	"github.com/grafana/loki/pkg/push"
 	"github.com/prometheus/common/model"
 
@@ -136,15 +134,14 @@ func (b *batch) age() time.Duration {
 	return time.Since(b.createdAt)
 }
 
-// encode the batch as snappy-compressed push request, and returns
-// the encoded bytes and the number of encoded entries
-func (b *batch) encode() ([]byte, int, error) {
+// encode the batch as push request with enc, and returns the encoded bytes
+// and the number of encoded entries
+func (b *batch) encode(enc *encoder) ([]byte, int, error) {
 	req, entriesCount := b.createPushRequest()
-	buf, err := proto.Marshal(req)
+	buf, err := enc.encode(req)
 	if err != nil {
 		return nil, 0, err
 	}
-	buf = snappy.Encode(nil, buf)
 	return buf, entriesCount, nil
 }
 
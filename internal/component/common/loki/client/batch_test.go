@@ -123,7 +123,10 @@ func TestBatch_encode(t *testing.T) {
 		t.Run(testName, func(t *testing.T) {
 			t.Parallel()
 
-			_, entriesCount, err := testData.inputBatch.encode()
+			enc, err := newEncoder("", "")
+			require.NoError(t, err)
+
+			_, entriesCount, err := testData.inputBatch.encode(enc)
 			require.NoError(t, err)
 			assert.Equal(t, testData.expectedEntriesCount, entriesCount)
 		})
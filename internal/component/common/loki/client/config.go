@@ -1,6 +1,7 @@
 package client
 
 import (
+	"maps"
 	"time"
 
 	"github.com/grafana/dskit/backoff"
@@ -35,6 +36,61 @@ type Config struct {
 
 	// QueueConfig controls how shards and queues are configured for endpoint.
 	QueueConfig QueueConfig
+
+	// Protocol is the protocol used to encode batches. Empty means
+	// ProtocolLokiProtobuf.
+	Protocol string
+
+	// Compression is the compression applied to encoded batches. Empty means
+	// the default compression of Protocol.
+	Compression string
+
+	// Tenants overrides settings for specific tenants, keyed by tenant ID.
+	// Each tenant other than TenantID gets its own shards and queues, whether
+	// it's in Tenants or not, so that a slow or failing tenant doesn't delay
+	// the others. See MaxTenants for the tenants which aren't in Tenants.
+	Tenants map[string]TenantConfig
+
+	// MaxTenants is the maximum number of tenants not in Tenants with their
+	// own shards. The entries of the other tenants are sent by the shards of
+	// TenantID.
+	MaxTenants int
+}
+
+// TenantConfig overrides the settings of an endpoint for a single tenant.
+type TenantConfig struct {
+	// URL replaces the URL of the endpoint when set.
+	URL flagext.URLValue
+
+	// Client replaces the HTTP client configuration of the endpoint when set.
+	Client *config.HTTPClientConfig
+
+	// Headers are added to the headers of the endpoint. They take precedence
+	// over the headers of the endpoint with the same name.
+	Headers map[string]string
+}
+
+// forTenant returns the configuration used to send the entries of tenantID.
+func (c Config) forTenant(tenantID string) Config {
+	tc, ok := c.Tenants[tenantID]
+	if !ok {
+		return c
+	}
+
+	res := c
+	res.Tenants = nil
+	if tc.URL.URL != nil {
+		res.URL = tc.URL
+	}
+	if tc.Client != nil {
+		res.Client = *tc.Client
+	}
+	if len(tc.Headers) > 0 {
+		res.Headers = make(map[string]string, len(c.Headers)+len(tc.Headers))
+		maps.Copy(res.Headers, c.Headers)
+		maps.Copy(res.Headers, tc.Headers)
+	}
+	return res
 }
 
 // QueueConfig controls how shards and queues are configured for endpoints.
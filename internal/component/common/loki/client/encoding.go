@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/klauspost/compress/zstd"
+	promql_parser "github.com/prometheus/prometheus/promql/parser"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+// Protocols which can be used to send batches.
+const (
+	// ProtocolLokiProtobuf is the protobuf encoding of the Loki push API.
+	ProtocolLokiProtobuf = "loki_protobuf"
+	// ProtocolLokiJSON is the JSON encoding of the Loki push API.
+	ProtocolLokiJSON = "loki_json"
+	// ProtocolOTLPHTTP is the protobuf encoding of OTLP/HTTP logs.
+	ProtocolOTLPHTTP = "otlp_http"
+)
+
+// Compressions which can be applied to encoded batches.
+const (
+	CompressionSnappy = "snappy"
+	CompressionGzip   = "gzip"
+	CompressionZstd   = "zstd"
+	CompressionNone   = "none"
+)
+
+// ValidateEncoding returns an error if protocol can't be used with
+// compression. Empty values stand for the defaults.
+func ValidateEncoding(protocol, compression string) error {
+	protocol, compression = encodingWithDefaults(protocol, compression)
+
+	switch protocol {
+	case ProtocolLokiProtobuf:
+		// Loki always expects snappy compressed protobuf requests.
+		if compression != CompressionSnappy {
+			return fmt.Errorf("protocol %q only supports %q compression", protocol, CompressionSnappy)
+		}
+		return nil
+	case ProtocolLokiJSON, ProtocolOTLPHTTP:
+	default:
+		return fmt.Errorf("unknown protocol %q, must be one of %q, %q or %q", protocol, ProtocolLokiProtobuf, ProtocolLokiJSON, ProtocolOTLPHTTP)
+	}
+
+	switch compression {
+	case CompressionGzip, CompressionZstd, CompressionNone:
+		return nil
+	case CompressionSnappy:
+		return fmt.Errorf("protocol %q doesn't support %q compression", protocol, compression)
+	default:
+		return fmt.Errorf("unknown compression %q, must be one of %q, %q, %q or %q", compression, CompressionSnappy, CompressionGzip, CompressionZstd, CompressionNone)
+	}
+}
+
+// encodingWithDefaults returns protocol and compression with their defaults
+// applied. Snappy is the default compression of the Loki protobuf protocol,
+// and gzip the default of the others.
+func encodingWithDefaults(protocol, compression string) (string, string) {
+	if protocol == "" {
+		protocol = ProtocolLokiProtobuf
+	}
+	if compression == "" {
+		compression = CompressionGzip
+		if protocol == ProtocolLokiProtobuf {
+			compression = CompressionSnappy
+		}
+	}
+	return protocol, compression
+}
+
+// encoder encodes push requests with a protocol and compression.
+type encoder struct {
+	protocol    string
+	compression string
+	zstd        *zstd.Encoder
+}
+
+func newEncoder(protocol, compression string) (*encoder, error) {
+	if err := ValidateEncoding(protocol, compression); err != nil {
+		return nil, err
+	}
+	protocol, compression = encodingWithDefaults(protocol, compression)
+
+	e := &encoder{protocol: protocol, compression: compression}
+	if compression == CompressionZstd {
+		var err error
+		// A nil writer is fine as only EncodeAll is used, which is safe for
+		// concurrent use.
+		if e.zstd, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// contentType returns the Content-Type header of encoded requests.
+func (e *encoder) contentType() string {
+	if e.protocol == ProtocolLokiJSON {
+		return "application/json"
+	}
+	return "application/x-protobuf"
+}
+
+// contentEncoding returns the Content-Encoding header of encoded requests.
+func (e *encoder) contentEncoding() string {
+	if e.compression == CompressionNone {
+		return ""
+	}
+	return e.compression
+}
+
+// encode encodes and compresses req.
+func (e *encoder) encode(req *push.PushRequest) ([]byte, error) {
+	var (
+		buf []byte
+		err error
+	)
+	switch e.protocol {
+	case ProtocolLokiJSON:
+		buf, err = encodeLokiJSON(req)
+	case ProtocolOTLPHTTP:
+		buf, err = encodeOTLP(req)
+	default:
+		buf, err = proto.Marshal(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.compression {
+	case CompressionSnappy:
+		return snappy.Encode(nil, buf), nil
+	case CompressionGzip:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(buf); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case CompressionZstd:
+		return e.zstd.EncodeAll(buf, nil), nil
+	default:
+		return buf, nil
+	}
+}
+
+type jsonPushRequest struct {
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][]any           `json:"values"`
+}
+
+// encodeLokiJSON encodes req in the JSON format of the Loki push API.
+func encodeLokiJSON(req *push.PushRequest) ([]byte, error) {
+	res := jsonPushRequest{Streams: make([]jsonStream, 0, len(req.Streams))}
+	for _, stream := range req.Streams {
+		ls, err := promql_parser.ParseMetric(stream.Labels)
+		if err != nil {
+			return nil, err
+		}
+
+		s := jsonStream{
+			Stream: ls.Map(),
+			Values: make([][]any, 0, len(stream.Entries)),
+		}
+		for _, entry := range stream.Entries {
+			value := []any{strconv.FormatInt(entry.Timestamp.UnixNano(), 10), entry.Line}
+			if len(entry.StructuredMetadata) > 0 {
+				metadata := make(map[string]string, len(entry.StructuredMetadata))
+				for _, l := range entry.StructuredMetadata {
+					metadata[l.Name] = l.Value
+				}
+				value = append(value, metadata)
+			}
+			s.Values = append(s.Values, value)
+		}
+		res.Streams = append(res.Streams, s)
+	}
+	return json.Marshal(res)
+}
+
+// encodeOTLP encodes req as an OTLP logs export request. The labels of each
+// stream are used as resource attributes, and the structured metadata of each
+// entry as log attributes.
+func encodeOTLP(req *push.PushRequest) ([]byte, error) {
+	logs := plog.NewLogs()
+	for _, stream := range req.Streams {
+		ls, err := promql_parser.ParseMetric(stream.Labels)
+		if err != nil {
+			return nil, err
+		}
+
+		rl := logs.ResourceLogs().AppendEmpty()
+		resourceAttrs := rl.Resource().Attributes()
+		resourceAttrs.EnsureCapacity(ls.Len())
+		for name, value := range ls.Map() {
+			resourceAttrs.PutStr(name, value)
+		}
+
+		records := rl.ScopeLogs().AppendEmpty().LogRecords()
+		records.EnsureCapacity(len(stream.Entries))
+		for _, entry := range stream.Entries {
+			record := records.AppendEmpty()
+			record.SetTimestamp(pcommon.NewTimestampFromTime(entry.Timestamp))
+			record.Body().SetStr(entry.Line)
+			for _, l := range entry.StructuredMetadata {
+				record.Attributes().PutStr(l.Name, l.Value)
+			}
+		}
+	}
+	return plogotlp.NewExportRequestFromLogs(logs).MarshalProto()
+}
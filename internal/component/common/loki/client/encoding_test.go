@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+var testPushRequest = &push.PushRequest{
+	Streams: []push.Stream{{
+		Labels: `{job="app", level="info"}`,
+		Entries: []push.Entry{{
+			Timestamp:          time.Unix(1, 500),
+			Line:               "hello",
+			StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}},
+		}},
+	}},
+}
+
+func TestValidateEncoding(t *testing.T) {
+	tests := []struct {
+		protocol, compression string
+		expectedErr           string
+	}{
+		{protocol: "", compression: ""},
+		{protocol: ProtocolLokiProtobuf, compression: CompressionSnappy},
+		{protocol: ProtocolLokiJSON, compression: CompressionNone},
+		{protocol: ProtocolOTLPHTTP, compression: CompressionZstd},
+		{protocol: ProtocolLokiProtobuf, compression: CompressionGzip, expectedErr: `protocol "loki_protobuf" only supports "snappy" compression`},
+		{protocol: ProtocolOTLPHTTP, compression: CompressionSnappy, expectedErr: `protocol "otlp_http" doesn't support "snappy" compression`},
+		{protocol: "loki_xml", expectedErr: `unknown protocol "loki_xml"`},
+		{protocol: ProtocolLokiJSON, compression: "lz4", expectedErr: `unknown compression "lz4"`},
+	}
+	for _, tc := range tests {
+		err := ValidateEncoding(tc.protocol, tc.compression)
+		if tc.expectedErr == "" {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, tc.expectedErr)
+		}
+	}
+}
+
+func TestEncoder_LokiProtobuf(t *testing.T) {
+	enc, err := newEncoder("", "")
+	require.NoError(t, err)
+	require.Equal(t, "application/x-protobuf", enc.contentType())
+	require.Equal(t, "snappy", enc.contentEncoding())
+
+	buf, err := enc.encode(testPushRequest)
+	require.NoError(t, err)
+	decoded, err := snappy.Decode(nil, buf)
+	require.NoError(t, err)
+
+	var req push.PushRequest
+	require.NoError(t, proto.Unmarshal(decoded, &req))
+	require.Equal(t, testPushRequest.Streams[0].Labels, req.Streams[0].Labels)
+	require.Equal(t, "hello", req.Streams[0].Entries[0].Line)
+}
+
+func TestEncoder_LokiJSON(t *testing.T) {
+	enc, err := newEncoder(ProtocolLokiJSON, "")
+	require.NoError(t, err)
+	require.Equal(t, "application/json", enc.contentType())
+	require.Equal(t, "gzip", enc.contentEncoding())
+
+	buf, err := enc.encode(testPushRequest)
+	require.NoError(t, err)
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	require.JSONEq(t, `{
+		"streams": [{
+			"stream": {"job": "app", "level": "info"},
+			"values": [["1000000500", "hello", {"trace_id": "abc"}]]
+		}]
+	}`, string(decoded))
+}
+
+func TestEncoder_OTLP(t *testing.T) {
+	enc, err := newEncoder(ProtocolOTLPHTTP, CompressionZstd)
+	require.NoError(t, err)
+	require.Equal(t, "application/x-protobuf", enc.contentType())
+	require.Equal(t, "zstd", enc.contentEncoding())
+
+	buf, err := enc.encode(testPushRequest)
+	require.NoError(t, err)
+	dec, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+	defer dec.Close()
+	decoded, err := dec.DecodeAll(buf, nil)
+	require.NoError(t, err)
+
+	req := plogotlp.NewExportRequest()
+	require.NoError(t, req.UnmarshalProto(decoded))
+	rl := req.Logs().ResourceLogs().At(0)
+	require.Equal(t, map[string]any{"job": "app", "level": "info"}, rl.Resource().Attributes().AsRaw())
+
+	record := rl.ScopeLogs().At(0).LogRecords().At(0)
+	require.Equal(t, "hello", record.Body().Str())
+	require.Equal(t, time.Unix(1, 500).UnixNano(), record.Timestamp().AsTime().UnixNano())
+	require.Equal(t, map[string]any{"trace_id": "abc"}, record.Attributes().AsRaw())
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -24,18 +25,52 @@ type endpoint struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	shards  *shards
-	backoff *backoff.Backoff
+	shards        *shards
+	backoff       *backoff.Backoff
+	markerHandler internal.MarkerHandler
+
+	// tenantShards holds the shards of each tenant other than the default
+	// tenant of the endpoint. The shards of the tenants with their own
+	// settings are created with the endpoint and kept until it stops. The
+	// shards of up to MaxTenants other tenants are created when their first
+	// entry is enqueued, and stopped once they're idle for tenantIdleTimeout.
+	tenantMut    sync.Mutex
+	tenantShards map[string]*tenantShards
+	stopped      bool
+	wg           sync.WaitGroup
+
+	// evicting tracks the shards of idle tenants which are being stopped.
+	evicting sync.WaitGroup
 }
 
+type tenantShards struct {
+	shards   *shards
+	lastUsed time.Time
+}
+
+// tenantIdleTimeout is how long the shards of a tenant without its own
+// settings are kept without receiving entries.
+var tenantIdleTimeout = 5 * time.Minute
+
 func newEndpoint(metrics *metrics, cfg Config, logger log.Logger, markerHandler internal.MarkerHandler) (*endpoint, error) {
 	logger = log.With(logger, "component", "endpoint", "host", cfg.URL.Host)
 
-	shards, err := newShards(metrics, logger, markerHandler, cfg)
+	defaultShards, err := newShards(metrics, logger, markerHandler, cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	tenants := make(map[string]*tenantShards, len(cfg.Tenants))
+	for tenantID := range cfg.Tenants {
+		tenantCfg := cfg.forTenant(tenantID)
+		tenantLogger := log.With(logger, "tenant", tenantID, "tenant_host", tenantCfg.URL.Host)
+		s, err := newShards(metrics, tenantLogger, markerHandler, tenantCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for tenant %q: %w", tenantID, err)
+		}
+		tenants[tenantID] = &tenantShards{shards: s}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	c := &endpoint{
 		cfg:     cfg,
@@ -44,14 +79,21 @@ func newEndpoint(metrics *metrics, cfg Config, logger log.Logger, markerHandler
 		entries: make(chan loki.Entry),
 		ctx:     ctx,
 		cancel:  cancel,
-		shards:  shards,
+		shards:  defaultShards,
 		backoff: backoff.New(ctx, backoff.Config{
 			MinBackoff: 5 * time.Millisecond,
 			MaxBackoff: 50 * time.Millisecond,
 		}),
+		markerHandler: markerHandler,
+		tenantShards:  tenants,
 	}
 
 	c.shards.start(cfg.QueueConfig.MinShards)
+	for _, t := range c.tenantShards {
+		t.shards.start(cfg.QueueConfig.MinShards)
+	}
+	metrics.tenantShards.WithLabelValues(cfg.URL.Host).Add(float64(len(tenants)))
+	c.wg.Go(c.evictIdleTenants)
 	return c, nil
 }
 
@@ -64,11 +106,21 @@ func (e *endpoint) enqueue(entry loki.Entry, segmentNum int) error {
 	defer e.backoff.Reset()
 
 	tenantID := getTenantID(e.cfg, entry)
-	for !e.shards.enqueue(tenantID, entry, segmentNum) {
+	s, overflow := e.shardsFor(tenantID)
+	if overflow {
+		e.metrics.tenantShardsOverflowEntries.WithLabelValues(e.cfg.URL.Host).Inc()
+	}
+
+	for !s.enqueue(tenantID, entry, segmentNum) {
+		if next, _ := e.shardsFor(tenantID); next != s {
+			// The shards of the tenant were evicted in the meantime.
+			s = next
+			continue
+		}
 		if !e.cfg.QueueConfig.BlockOnOverflow {
-			level.Warn(e.logger).Log("msg", "dropping entry", "err", errQueueIsFull)
-			e.metrics.droppedEntries.WithLabelValues(e.cfg.URL.Host, tenantID, reasonQueueIsFull).Inc()
-			e.metrics.droppedBytes.WithLabelValues(e.cfg.URL.Host, tenantID, reasonQueueIsFull).Add(float64(entry.Size()))
+			level.Warn(e.logger).Log("msg", "dropping entry", "tenant", tenantID, "err", errQueueIsFull)
+			e.metrics.droppedEntries.WithLabelValues(s.cfg.URL.Host, tenantID, reasonQueueIsFull).Inc()
+			e.metrics.droppedBytes.WithLabelValues(s.cfg.URL.Host, tenantID, reasonQueueIsFull).Add(float64(entry.Size()))
 			return errQueueIsFull
 		}
 
@@ -81,9 +133,93 @@ func (e *endpoint) enqueue(entry loki.Entry, segmentNum int) error {
 	return nil
 }
 
+// shardsFor returns the shards the entries of tenantID are sent by, creating
+// them if it's the first entry of the tenant. overflow is true if the tenant
+// doesn't get its own shards because MaxTenants is reached.
+func (e *endpoint) shardsFor(tenantID string) (s *shards, overflow bool) {
+	if tenantID == e.cfg.TenantID {
+		return e.shards, false
+	}
+
+	e.tenantMut.Lock()
+	defer e.tenantMut.Unlock()
+
+	if t, ok := e.tenantShards[tenantID]; ok {
+		t.lastUsed = time.Now()
+		return t.shards, false
+	}
+	if e.stopped {
+		// The default shards are stopped as well, so the entry is rejected.
+		return e.shards, false
+	}
+	if len(e.tenantShards)-len(e.cfg.Tenants) >= e.cfg.MaxTenants {
+		// The entries of the tenant are still sent with its tenant ID, but
+		// share the default shards.
+		return e.shards, true
+	}
+
+	// The tenant has the same settings as the endpoint, which were already
+	// validated when the default shards were created.
+	s, err := newShards(e.metrics, log.With(e.logger, "tenant", tenantID), e.markerHandler, e.cfg.forTenant(tenantID))
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to create shards for tenant, using the default shards", "tenant", tenantID, "err", err)
+		return e.shards, false
+	}
+	s.start(e.cfg.QueueConfig.MinShards)
+	e.tenantShards[tenantID] = &tenantShards{shards: s, lastUsed: time.Now()}
+	e.metrics.tenantShards.WithLabelValues(e.cfg.URL.Host).Inc()
+	return s, false
+}
+
+// evictIdleTenants stops the shards of the tenants without their own settings
+// which didn't receive entries for tenantIdleTimeout, until the endpoint
+// stops. The shards are stopped concurrently in the background, as each of
+// them can take up to DrainTimeout to flush its remaining entries.
+func (e *endpoint) evictIdleTenants() {
+	ticker := time.NewTicker(tenantIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.tenantMut.Lock()
+			for tenantID, t := range e.tenantShards {
+				if _, ok := e.cfg.Tenants[tenantID]; ok || now.Sub(t.lastUsed) < tenantIdleTimeout {
+					continue
+				}
+				delete(e.tenantShards, tenantID)
+				e.metrics.tenantShards.WithLabelValues(e.cfg.URL.Host).Dec()
+				e.metrics.evictedTenantShards.WithLabelValues(e.cfg.URL.Host).Inc()
+
+				// The remaining entries of the tenant are flushed when its
+				// shards stop.
+				e.evicting.Go(t.shards.stop)
+			}
+			e.tenantMut.Unlock()
+		}
+	}
+}
+
 func (e *endpoint) stop() {
 	e.cancel()
-	e.shards.stop()
+	e.wg.Wait()
+
+	e.tenantMut.Lock()
+	e.stopped = true
+	e.tenantMut.Unlock()
+
+	// The shards of the tenants evicted before the endpoint stopped are
+	// stopped concurrently with the others.
+	var wg sync.WaitGroup
+	wg.Go(e.shards.stop)
+	for _, t := range e.tenantShards {
+		wg.Go(t.shards.stop)
+	}
+	wg.Go(e.evicting.Wait)
+	wg.Wait()
+	e.metrics.tenantShards.WithLabelValues(e.cfg.URL.Host).Sub(float64(len(e.tenantShards)))
 }
 
 // getEndpointName computes the specific name for each endpoint config. The name is either the configured Name setting in Config,
@@ -2,6 +2,7 @@ package client
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -528,3 +530,229 @@ func TestEndpointBlockOnOverflow(t *testing.T) {
 		require.NoError(t, e.enqueue(entry4, 0))
 	})
 }
+
+func TestEndpointTenants(t *testing.T) {
+	type receivedReq struct {
+		server        string
+		tenantID      string
+		authorization string
+		team          string
+	}
+	receivedReqsChan := make(chan receivedReq, 10)
+	newServer := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			receivedReqsChan <- receivedReq{
+				server:        name,
+				tenantID:      req.Header.Get("X-Scope-OrgID"),
+				authorization: req.Header.Get("Authorization"),
+				team:          req.Header.Get("X-Team"),
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	defaultServer, tenantServer := newServer("default"), newServer("tenant")
+
+	var defaultURL, tenantURL flagext.URLValue
+	require.NoError(t, defaultURL.Set(defaultServer.URL))
+	require.NoError(t, tenantURL.Set(tenantServer.URL))
+
+	reg := prometheus.NewRegistry()
+	e, err := newEndpoint(newMetrics(reg), Config{
+		URL:       defaultURL,
+		BatchSize: 1024,
+		BatchWait: 10 * time.Millisecond,
+		Timeout:   time.Second,
+		Client:    config.DefaultHTTPClientConfig,
+		Headers:   map[string]string{"X-Team": "default"},
+		QueueConfig: QueueConfig{
+			Capacity:     1024,
+			MinShards:    1,
+			DrainTimeout: time.Second,
+		},
+		Tenants: map[string]TenantConfig{
+			"team-a": {
+				Client: &config.HTTPClientConfig{
+					Authorization: &config.Authorization{Type: "Bearer", Credentials: "token-a"},
+				},
+				Headers: map[string]string{"X-Team": "a"},
+			},
+			"team-b": {URL: tenantURL},
+		},
+		MaxTenants: 10,
+	}, log.NewNopLogger(), internal.NewNopMarkerHandler())
+	require.NoError(t, err)
+	defer e.stop()
+
+	for _, tenantID := range []string{"team-a", "team-b", "team-c"} {
+		require.NoError(t, e.enqueue(loki.Entry{
+			Labels: model.LabelSet{ReservedLabelTenantID: model.LabelValue(tenantID)},
+			Entry:  push.Entry{Timestamp: time.Now(), Line: "line"},
+		}, 0))
+	}
+
+	var received []receivedReq
+	for range 3 {
+		select {
+		case req := <-receivedReqsChan:
+			received = append(received, req)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for requests")
+		}
+	}
+	require.ElementsMatch(t, []receivedReq{
+		{server: "default", tenantID: "team-a", authorization: "Bearer token-a", team: "a"},
+		{server: "tenant", tenantID: "team-b", team: "default"},
+		{server: "default", tenantID: "team-c", team: "default"},
+	}, received)
+
+	// Tenants without their own settings get their own shards as well.
+	e.tenantMut.Lock()
+	require.Len(t, e.tenantShards, 3)
+	require.Contains(t, e.tenantShards, "team-c")
+	e.tenantMut.Unlock()
+
+	// Metrics are reported for the host each tenant is sent to.
+	expectedMetrics := strings.NewReplacer("__DEFAULT__", defaultURL.Host, "__TENANT__", tenantURL.Host).Replace(`
+		# HELP loki_write_sent_entries_total Number of log entries sent to the ingester.
+		# TYPE loki_write_sent_entries_total counter
+		loki_write_sent_entries_total{host="__DEFAULT__",tenant="team-a"} 1
+		loki_write_sent_entries_total{host="__DEFAULT__",tenant="team-c"} 1
+		loki_write_sent_entries_total{host="__TENANT__",tenant="team-b"} 1
+	`)
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "loki_write_sent_entries_total") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestEndpointEvictIdleTenants(t *testing.T) {
+	defaultTimeout := tenantIdleTimeout
+	tenantIdleTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { tenantIdleTimeout = defaultTimeout })
+
+	receivedReqsChan := make(chan util.RemoteWriteRequest, 10)
+	server := util.NewRemoteWriteServer(receivedReqsChan, http.StatusNoContent)
+	t.Cleanup(server.Close)
+
+	var url flagext.URLValue
+	require.NoError(t, url.Set(server.URL))
+
+	reg := prometheus.NewRegistry()
+	e, err := newEndpoint(newMetrics(reg), Config{
+		URL:       url,
+		BatchSize: 1024,
+		BatchWait: 10 * time.Millisecond,
+		Timeout:   time.Second,
+		Client:    config.DefaultHTTPClientConfig,
+		QueueConfig: QueueConfig{
+			Capacity:     1024,
+			MinShards:    1,
+			DrainTimeout: time.Second,
+		},
+		Tenants:    map[string]TenantConfig{"configured": {}},
+		MaxTenants: 10,
+	}, log.NewNopLogger(), internal.NewNopMarkerHandler())
+	require.NoError(t, err)
+	defer e.stop()
+
+	entry := loki.Entry{
+		Labels: model.LabelSet{ReservedLabelTenantID: "dynamic"},
+		Entry:  push.Entry{Timestamp: time.Now(), Line: "line"},
+	}
+	require.NoError(t, e.enqueue(entry, 0))
+	select {
+	case req := <-receivedReqsChan:
+		require.Equal(t, "dynamic", req.TenantID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for request")
+	}
+
+	// The shards of the idle tenant are stopped, but not the ones of the
+	// tenant with its own settings.
+	expectedMetrics := strings.NewReplacer("__HOST__", url.Host).Replace(`
+		# HELP loki_write_tenant_shards Number of tenants with their own shards.
+		# TYPE loki_write_tenant_shards gauge
+		loki_write_tenant_shards{host="__HOST__"} 1
+		# HELP loki_write_tenant_shards_evicted_total Number of times the shards of an idle tenant were stopped.
+		# TYPE loki_write_tenant_shards_evicted_total counter
+		loki_write_tenant_shards_evicted_total{host="__HOST__"} 1
+	`)
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "loki_write_tenant_shards", "loki_write_tenant_shards_evicted_total") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	e.tenantMut.Lock()
+	require.NotContains(t, e.tenantShards, "dynamic")
+	require.Contains(t, e.tenantShards, "configured")
+	e.tenantMut.Unlock()
+
+	// The shards of the tenant are created again when it sends entries.
+	require.NoError(t, e.enqueue(entry, 0))
+	select {
+	case req := <-receivedReqsChan:
+		require.Equal(t, "dynamic", req.TenantID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for request")
+	}
+}
+
+func TestEndpointMaxTenants(t *testing.T) {
+	receivedReqsChan := make(chan util.RemoteWriteRequest, 10)
+	server := util.NewRemoteWriteServer(receivedReqsChan, http.StatusNoContent)
+	t.Cleanup(server.Close)
+
+	var url flagext.URLValue
+	require.NoError(t, url.Set(server.URL))
+
+	reg := prometheus.NewRegistry()
+	e, err := newEndpoint(newMetrics(reg), Config{
+		URL:       url,
+		BatchSize: 1024,
+		BatchWait: 10 * time.Millisecond,
+		Timeout:   time.Second,
+		Client:    config.DefaultHTTPClientConfig,
+		QueueConfig: QueueConfig{
+			Capacity:     1024,
+			MinShards:    1,
+			DrainTimeout: time.Second,
+		},
+		Tenants:    map[string]TenantConfig{"configured": {}},
+		MaxTenants: 1,
+	}, log.NewNopLogger(), internal.NewNopMarkerHandler())
+	require.NoError(t, err)
+	defer e.stop()
+
+	for _, tenantID := range []string{"configured", "first", "second", "second"} {
+		require.NoError(t, e.enqueue(loki.Entry{
+			Labels: model.LabelSet{ReservedLabelTenantID: model.LabelValue(tenantID)},
+			Entry:  push.Entry{Timestamp: time.Now(), Line: "line"},
+		}, 0))
+	}
+
+	// The entries of the tenants over the limit are still sent with their
+	// tenant ID.
+	received := make(map[string]struct{})
+	for len(received) < 3 {
+		select {
+		case req := <-receivedReqsChan:
+			received[req.TenantID] = struct{}{}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for requests")
+		}
+	}
+	require.Equal(t, map[string]struct{}{"configured": {}, "first": {}, "second": {}}, received)
+
+	// Only the first tenant without its own settings gets its own shards.
+	e.tenantMut.Lock()
+	require.Len(t, e.tenantShards, 2)
+	require.Contains(t, e.tenantShards, "first")
+	require.NotContains(t, e.tenantShards, "second")
+	e.tenantMut.Unlock()
+
+	expectedMetrics := strings.NewReplacer("__HOST__", url.Host).Replace(`
+		# HELP loki_write_tenant_shards_overflow_entries_total Number of log entries sent by the default shards because the maximum number of tenants with their own shards was reached.
+		# TYPE loki_write_tenant_shards_overflow_entries_total counter
+		loki_write_tenant_shards_overflow_entries_total{host="__HOST__"} 2
+	`)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "loki_write_tenant_shards_overflow_entries_total"))
+}
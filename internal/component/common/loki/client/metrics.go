@@ -29,6 +29,9 @@ type metrics struct {
 	mutatedBytes                 *prometheus.CounterVec
 	requestDuration              *prometheus.HistogramVec
 	batchRetries                 *prometheus.CounterVec
+	tenantShards                 *prometheus.GaugeVec
+	evictedTenantShards          *prometheus.CounterVec
+	tenantShardsOverflowEntries  *prometheus.CounterVec
 	countersWithHostTenant       []*prometheus.CounterVec
 	countersWithHostTenantReason []*prometheus.CounterVec
 }
@@ -72,6 +75,18 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 		Name: "loki_write_batch_retries_total",
 		Help: "Number of times batches has had to be retried.",
 	}, []string{labelHost, labelTenant})
+	m.tenantShards = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loki_write_tenant_shards",
+		Help: "Number of tenants with their own shards.",
+	}, []string{labelHost})
+	m.evictedTenantShards = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_write_tenant_shards_evicted_total",
+		Help: "Number of times the shards of an idle tenant were stopped.",
+	}, []string{labelHost})
+	m.tenantShardsOverflowEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_write_tenant_shards_overflow_entries_total",
+		Help: "Number of log entries sent by the default shards because the maximum number of tenants with their own shards was reached.",
+	}, []string{labelHost})
 
 	m.countersWithHostTenant = []*prometheus.CounterVec{
 		m.batchRetries, m.encodedBytes, m.sentBytes, m.sentEntries,
@@ -91,6 +106,9 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 		m.mutatedBytes = util.MustRegisterOrGet(reg, m.mutatedBytes).(*prometheus.CounterVec)
 		m.requestDuration = util.MustRegisterOrGet(reg, m.requestDuration).(*prometheus.HistogramVec)
 		m.batchRetries = util.MustRegisterOrGet(reg, m.batchRetries).(*prometheus.CounterVec)
+		m.tenantShards = util.MustRegisterOrGet(reg, m.tenantShards).(*prometheus.GaugeVec)
+		m.evictedTenantShards = util.MustRegisterOrGet(reg, m.evictedTenantShards).(*prometheus.CounterVec)
+		m.tenantShardsOverflowEntries = util.MustRegisterOrGet(reg, m.tenantShardsOverflowEntries).(*prometheus.CounterVec)
 	}
 
 	return &m
@@ -220,11 +220,17 @@ func newShards(metrics *metrics, logger log.Logger, markerHandler SentDataMarker
 
 	client.Timeout = cfg.Timeout
 
+	enc, err := newEncoder(cfg.Protocol, cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+
 	return &shards{
 		cfg:           cfg,
 		logger:        logger,
 		metrics:       metrics,
 		client:        client,
+		encoder:       enc,
 		markerHandler: markerHandler,
 		tenants:       make(map[string]struct{}),
 	}, nil
@@ -239,6 +245,7 @@ type shards struct {
 	logger        log.Logger
 	metrics       *metrics
 	client        *http.Client
+	encoder       *encoder
 	markerHandler SentDataMarkerHandler
 
 	mut     sync.Mutex
@@ -393,7 +400,7 @@ func (s *shards) initBatchMetrics(tenantID string) {
 // sendBatch encodes a batch and sends it to Loki with retry logic.
 func (s *shards) sendBatch(tenantID string, batch *batch) {
 	defer batch.reportAsSentData(s.markerHandler)
-	buf, entriesCount, err := batch.encode()
+	buf, entriesCount, err := batch.encode(s.encoder)
 
 	if err != nil {
 		level.Error(s.logger).Log("msg", "error encoding batch", "error", err)
@@ -454,11 +461,6 @@ func (s *shards) sendBatch(tenantID string, batch *batch) {
 
 var userAgent = useragent.Get()
 
-const (
-	contentType     = "application/x-protobuf"
-	contentEncoding = "snappy"
-)
-
 // send performs the HTTP POST request to send a batch to Loki.
 func (s *shards) send(ctx context.Context, tenantID string, buf []byte) (int, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
@@ -469,8 +471,10 @@ func (s *shards) send(ctx context.Context, tenantID string, buf []byte) (int, er
 	}
 
 	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("Content-Encoding", contentEncoding)
+	req.Header.Set("Content-Type", s.encoder.contentType())
+	if contentEncoding := s.encoder.contentEncoding(); contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
 
 	// If the tenant ID is not empty alloy is running in multi-tenant mode, so
 	// we should send it to Loki
@@ -0,0 +1,242 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/units"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.buffer",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+const (
+	// segmentSize is the size at which a new segment file is started. The
+	// segments are removed once all their entries are forwarded.
+	segmentSize = 8 * 1024 * 1024
+
+	// syncInterval is how often the buffer is flushed to disk.
+	syncInterval = time.Second
+
+	dropReasonFull   = "buffer_full"
+	dropReasonTooOld = "too_old"
+)
+
+// Arguments holds values which are used to configure the loki.buffer
+// component.
+type Arguments struct {
+	// Where the buffered log entries should be forwarded to.
+	ForwardTo []loki.LogsReceiver `alloy:"forward_to,attr"`
+
+	// The maximum size of the entries waiting to be forwarded.
+	MaxSize units.Base2Bytes `alloy:"max_size,attr,optional"`
+
+	// How long entries can wait to be forwarded before they're dropped. Zero
+	// means forever.
+	MaxAge time.Duration `alloy:"max_age,attr,optional"`
+}
+
+// DefaultArguments provides the default arguments for the loki.buffer
+// component.
+var DefaultArguments = Arguments{
+	MaxSize: units.Gibibyte,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (a *Arguments) Validate() error {
+	if a.MaxSize <= 0 {
+		return errors.New("max_size must be greater than 0")
+	}
+	if a.MaxAge < 0 {
+		return errors.New("max_age must not be negative")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the loki.buffer component.
+type Exports struct {
+	Receiver loki.LogsReceiver `alloy:"receiver,attr"`
+}
+
+var (
+	_ component.Component     = (*Component)(nil)
+	_ component.LiveDebugging = (*Component)(nil)
+)
+
+// Component implements the loki.buffer component.
+type Component struct {
+	opts    component.Options
+	metrics *metrics
+	queue   *diskQueue
+
+	mut      sync.RWMutex
+	args     Arguments
+	receiver loki.LogsReceiver
+	fanout   *loki.Fanout
+
+	debugDataPublisher livedebugging.DebugDataPublisher
+}
+
+// New creates a new loki.buffer component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	debugDataPublisher, err := o.GetServiceData(livedebugging.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(o.DataPath, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+	// Entries buffered before a restart are forwarded again, starting with the
+	// oldest one which wasn't forwarded yet.
+	queue, err := openDiskQueue(o.DataPath, segmentSize, int64(args.MaxSize), o.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buffer: %w", err)
+	}
+
+	c := &Component{
+		opts:               o,
+		metrics:            newMetrics(o.Registerer),
+		queue:              queue,
+		fanout:             loki.NewFanout(args.ForwardTo),
+		debugDataPublisher: debugDataPublisher.(livedebugging.DebugDataPublisher),
+	}
+	c.metrics.size.Set(float64(queue.Size()))
+
+	// Create and immediately export the receiver which remains the same for
+	// the component's lifetime.
+	c.receiver = loki.NewLogsReceiver(loki.WithComponentID(o.ID))
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		if err := c.queue.Close(); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to close buffer", "err", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Go(func() { c.forward(ctx) })
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.metrics.entriesReceived.Inc()
+			err := c.queue.Append(entry, time.Now())
+			switch {
+			case errors.Is(err, errQueueFull):
+				c.metrics.entriesDropped.WithLabelValues(dropReasonFull).Inc()
+				level.Debug(c.opts.Logger).Log("msg", "dropping entry because the buffer is full", "labels", entry.Labels.String())
+			case err != nil:
+				level.Error(c.opts.Logger).Log("msg", "failed to buffer entry", "err", err)
+			}
+			c.metrics.size.Set(float64(c.queue.Size()))
+		case <-ticker.C:
+			if err := c.queue.Sync(); err != nil {
+				level.Error(c.opts.Logger).Log("msg", "failed to sync buffer", "err", err)
+			}
+		}
+	}
+}
+
+// forward forwards the buffered entries in order until ctx is canceled.
+// Entries are only removed from the buffer once they're forwarded.
+func (c *Component) forward(ctx context.Context) {
+	componentID := livedebugging.ComponentID(c.opts.ID)
+	for {
+		entry, bufferedAt, pos, err := c.queue.Next(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to read buffered entry", "err", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(syncInterval):
+				continue
+			}
+		}
+
+		c.mut.RLock()
+		maxAge := c.args.MaxAge
+		c.mut.RUnlock()
+
+		if maxAge > 0 && time.Since(bufferedAt) > maxAge {
+			c.metrics.entriesDropped.WithLabelValues(dropReasonTooOld).Inc()
+			level.Debug(c.opts.Logger).Log("msg", "dropping entry which was buffered for too long", "labels", entry.Labels.String(), "buffered_at", bufferedAt)
+		} else {
+			c.debugDataPublisher.PublishIfActive(livedebugging.NewData(
+				componentID,
+				livedebugging.LokiLog,
+				1,
+				func() string {
+					return fmt.Sprintf("entry: %s, labels: %s, buffered_at: %s", entry.Line, entry.Labels.String(), bufferedAt.Format(time.RFC3339Nano))
+				},
+			))
+			if err := c.fanout.Send(ctx, entry); err != nil {
+				// The entry is forwarded again after a restart.
+				return
+			}
+			c.metrics.entriesForwarded.Inc()
+		}
+
+		c.queue.Ack(pos)
+		c.metrics.size.Set(float64(c.queue.Size()))
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.args = newArgs
+	c.queue.SetMaxSize(int64(newArgs.MaxSize))
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// LiveDebugging implements component.LiveDebugging.
+func (c *Component) LiveDebugging() {}
@@ -0,0 +1,196 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		max_size   = "10MiB"
+		max_age    = "1h"
+	`), &args))
+	require.Equal(t, 10*units.MiB, args.MaxSize)
+	require.Equal(t, time.Hour, args.MaxAge)
+
+	require.EqualError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		max_size   = 0
+	`), &args), "max_size must be greater than 0")
+	require.EqualError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		max_age    = "-1s"
+	`), &args), "max_age must not be negative")
+}
+
+func TestBuffer(t *testing.T) {
+	var (
+		reg = prometheus.NewRegistry()
+		ch  = loki.NewLogsReceiver()
+	)
+	args := DefaultArguments
+	args.ForwardTo = []loki.LogsReceiver{ch}
+	c := newTestComponent(t, t.TempDir(), reg, args)
+	go c.Run(t.Context())
+
+	ts := time.Now()
+	for i := range 3 {
+		c.receiver.Chan() <- newEntry(ts, model.LabelSet{"pod": "a"}, push.LabelsAdapter{{Name: "trace_id", Value: "1"}}, fmt.Sprintf("line %d", i))
+	}
+	for i := range 3 {
+		e := receive(t, ch)
+		require.Equal(t, fmt.Sprintf("line %d", i), e.Line)
+		require.Equal(t, model.LabelSet{"pod": "a"}, e.Labels)
+		require.Equal(t, push.LabelsAdapter{{Name: "trace_id", Value: "1"}}, e.StructuredMetadata)
+		require.True(t, ts.Equal(e.Timestamp))
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP loki_buffer_entries_forwarded_total Total number of buffered log entries forwarded
+			# TYPE loki_buffer_entries_forwarded_total counter
+			loki_buffer_entries_forwarded_total 3
+			# HELP loki_buffer_entries_received_total Total number of log entries received
+			# TYPE loki_buffer_entries_received_total counter
+			loki_buffer_entries_received_total 3
+			# HELP loki_buffer_size_bytes Size of the buffered log entries which weren't forwarded yet
+			# TYPE loki_buffer_size_bytes gauge
+			loki_buffer_size_bytes 0
+		`), "loki_buffer_entries_forwarded_total", "loki_buffer_entries_received_total", "loki_buffer_size_bytes") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBuffer_Replay(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		ch  = loki.NewLogsReceiver()
+	)
+	args := DefaultArguments
+	args.ForwardTo = []loki.LogsReceiver{ch}
+
+	// Nothing reads from ch, so the entries stay in the buffer.
+	ctx, cancel := context.WithCancel(t.Context())
+	c := newTestComponent(t, dir, prometheus.NewRegistry(), args)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.Run(ctx)
+	}()
+	for i := range 3 {
+		c.receiver.Chan() <- newEntry(time.Now(), model.LabelSet{"pod": "a"}, nil, fmt.Sprintf("line %d", i))
+	}
+	// The first entry is forwarded.
+	require.Equal(t, "line 0", receive(t, ch).Line)
+	require.Eventually(t, func() bool {
+		c.queue.mut.Lock()
+		defer c.queue.mut.Unlock()
+		return c.queue.acked.offset > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	// The entries which weren't forwarded are forwarded after a restart.
+	c = newTestComponent(t, dir, prometheus.NewRegistry(), args)
+	go c.Run(t.Context())
+	for _, expected := range []string{"line 1", "line 2"} {
+		require.Equal(t, expected, receive(t, ch).Line)
+	}
+}
+
+func TestBuffer_Limits(t *testing.T) {
+	var (
+		reg = prometheus.NewRegistry()
+		ch  = loki.NewLogsReceiver()
+	)
+	entry := newEntry(time.Now(), model.LabelSet{"pod": "a"}, nil, "line")
+	payload, err := encodeEntry(entry, time.Now())
+	require.NoError(t, err)
+
+	args := DefaultArguments
+	args.ForwardTo = []loki.LogsReceiver{ch}
+	args.MaxSize = units.Base2Bytes(2 * (frameHeaderSize + len(payload)))
+	args.MaxAge = time.Hour
+	c := newTestComponent(t, t.TempDir(), reg, args)
+
+	// The buffer only fits two entries.
+	for range 2 {
+		require.NoError(t, c.queue.Append(entry, time.Now().Add(-2*time.Hour)))
+	}
+	require.ErrorIs(t, c.queue.Append(entry, time.Now()), errQueueFull)
+
+	// Entries buffered for longer than max_age are dropped, which frees space
+	// for new entries.
+	go c.Run(t.Context())
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP loki_buffer_entries_dropped_total Total number of log entries dropped, by reason
+			# TYPE loki_buffer_entries_dropped_total counter
+			loki_buffer_entries_dropped_total{reason="too_old"} 2
+		`), "loki_buffer_entries_dropped_total") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	c.receiver.Chan() <- newEntry(time.Now(), model.LabelSet{"pod": "a"}, nil, "new line")
+	require.Equal(t, "new line", receive(t, ch).Line)
+}
+
+func receive(t *testing.T, ch loki.LogsReceiver) loki.Entry {
+	t.Helper()
+	select {
+	case e := <-ch.Chan():
+		return e
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for log entry")
+		return loki.Entry{}
+	}
+}
+
+func newTestComponent(t *testing.T, dataPath string, reg prometheus.Registerer, args Arguments) *Component {
+	t.Helper()
+	c, err := New(component.Options{
+		Logger:         util.TestAlloyLogger(t),
+		Registerer:     reg,
+		DataPath:       dataPath,
+		OnStateChange:  func(e component.Exports) {},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+	return c
+}
+
+func newEntry(ts time.Time, lbls model.LabelSet, metadata push.LabelsAdapter, line string) loki.Entry {
+	return loki.Entry{
+		Labels: lbls,
+		Entry: push.Entry{
+			Timestamp:          ts,
+			Line:               line,
+			StructuredMetadata: metadata,
+		},
+	}
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case livedebugging.ServiceName:
+		return livedebugging.NewLiveDebugging(), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
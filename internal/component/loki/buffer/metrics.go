@@ -0,0 +1,45 @@
+package buffer
+
+import (
+	"github.com/grafana/alloy/internal/util"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	entriesReceived  prometheus_client.Counter
+	entriesForwarded prometheus_client.Counter
+	entriesDropped   *prometheus_client.CounterVec
+	size             prometheus_client.Gauge
+}
+
+// newMetrics creates a new set of metrics. If reg is non-nil, the metrics
+// will also be registered.
+func newMetrics(reg prometheus_client.Registerer) *metrics {
+	var m metrics
+
+	m.entriesReceived = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_buffer_entries_received_total",
+		Help: "Total number of log entries received",
+	})
+	m.entriesForwarded = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_buffer_entries_forwarded_total",
+		Help: "Total number of buffered log entries forwarded",
+	})
+	m.entriesDropped = prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+		Name: "loki_buffer_entries_dropped_total",
+		Help: "Total number of log entries dropped, by reason",
+	}, []string{"reason"})
+	m.size = prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+		Name: "loki_buffer_size_bytes",
+		Help: "Size of the buffered log entries which weren't forwarded yet",
+	})
+
+	if reg != nil {
+		m.entriesReceived = util.MustRegisterOrGet(reg, m.entriesReceived).(prometheus_client.Counter)
+		m.entriesForwarded = util.MustRegisterOrGet(reg, m.entriesForwarded).(prometheus_client.Counter)
+		m.entriesDropped = util.MustRegisterOrGet(reg, m.entriesDropped).(*prometheus_client.CounterVec)
+		m.size = util.MustRegisterOrGet(reg, m.size).(prometheus_client.Gauge)
+	}
+
+	return &m
+}
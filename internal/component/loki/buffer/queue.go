@@ -0,0 +1,547 @@
+package buffer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+const (
+	// frameHeaderSize is the size of the header of each record in a segment:
+	// the length of the record and its CRC32 checksum.
+	frameHeaderSize = 8
+
+	positionFileName = "position"
+	segmentsDirName  = "segments"
+)
+
+var (
+	errQueueFull = errors.New("buffer is full")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// position identifies a record in the queue by the segment it's stored in
+// and its offset within that segment.
+type position struct {
+	segment int
+	offset  int64
+}
+
+type segment struct {
+	index int
+	size  int64
+}
+
+// diskQueue is a FIFO queue of log entries persisted in a directory.
+//
+// Entries are appended to segment files, and read back in order. Records
+// which were read are only forgotten once they're acknowledged, and the
+// position of the last acknowledged record is persisted by Sync. After a
+// restart, reading resumes from that position, so entries which weren't
+// acknowledged are read again.
+type diskQueue struct {
+	dir         string
+	segmentSize int64
+	logger      log.Logger
+
+	// notify is signaled whenever a record is appended.
+	notify chan struct{}
+
+	mut       sync.Mutex
+	maxSize   int64
+	segments  []segment // Sorted by index. The last segment is the head.
+	totalSize int64
+	head      *os.File
+	acked     position
+	synced    position
+
+	// Only accessed by the reader.
+	readPos  position
+	readFile *os.File
+}
+
+// openDiskQueue opens the queue stored in dir, creating it if it doesn't
+// exist.
+func openDiskQueue(dir string, segmentSize, maxSize int64, logger log.Logger) (*diskQueue, error) {
+	segmentsDir := filepath.Join(dir, segmentsDirName)
+	if err := os.MkdirAll(segmentsDir, 0750); err != nil {
+		return nil, err
+	}
+
+	q := &diskQueue{
+		dir:         dir,
+		segmentSize: segmentSize,
+		logger:      logger,
+		notify:      make(chan struct{}, 1),
+		maxSize:     maxSize,
+	}
+
+	pos, err := readPosition(filepath.Join(dir, positionFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read buffer position: %w", err)
+	}
+	if err := q.loadSegments(pos); err != nil {
+		return nil, err
+	}
+
+	// Start reading from the persisted position, or from the first segment if
+	// the segment of the persisted position doesn't exist anymore.
+	if i := q.segmentIndex(pos.segment); i < 0 {
+		pos = position{segment: q.segments[0].index}
+	} else if pos.offset > q.segments[i].size {
+		// The end of the segment may have been lost before it was synced.
+		pos.offset = q.segments[i].size
+	}
+	q.readPos, q.acked, q.synced = pos, pos, pos
+
+	return q, nil
+}
+
+// loadSegments loads the existing segments, removing the ones which were
+// fully acknowledged before pos, and opens the head segment.
+func (q *diskQueue) loadSegments(pos position) error {
+	dirEntries, err := os.ReadDir(filepath.Join(q.dir, segmentsDirName))
+	if err != nil {
+		return err
+	}
+	for _, de := range dirEntries {
+		index, err := strconv.Atoi(de.Name())
+		if err != nil || de.IsDir() {
+			level.Warn(q.logger).Log("msg", "ignoring unexpected file in buffer directory", "file", de.Name())
+			continue
+		}
+		if index < pos.segment {
+			if err := os.Remove(q.segmentPath(index)); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, segment{index: index, size: info.Size()})
+	}
+	slices.SortFunc(q.segments, func(a, b segment) int { return a.index - b.index })
+
+	if len(q.segments) == 0 {
+		return q.createHead(max(pos.segment, 1))
+	}
+
+	// The last record of the head may have been partially written.
+	head := &q.segments[len(q.segments)-1]
+	f, err := os.OpenFile(q.segmentPath(head.index), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	validSize, err := validRecordsSize(f, head.size)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if validSize < head.size {
+		level.Warn(q.logger).Log("msg", "truncating partially written records at the end of the buffer", "segment", head.index, "bytes", head.size-validSize)
+		if err := f.Truncate(validSize); err != nil {
+			f.Close()
+			return err
+		}
+		head.size = validSize
+	}
+	if _, err := f.Seek(validSize, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	q.head = f
+
+	for _, s := range q.segments {
+		q.totalSize += s.size
+	}
+	return nil
+}
+
+// validRecordsSize returns the size of the valid records at the start of a
+// segment of the given size.
+func validRecordsSize(f *os.File, size int64) (int64, error) {
+	var offset int64
+	for offset < size {
+		_, n, err := readRecord(f, offset, size)
+		if errors.Is(err, errCorruptedRecord) {
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset += n
+	}
+	return offset, nil
+}
+
+func (q *diskQueue) segmentPath(index int) string {
+	return filepath.Join(q.dir, segmentsDirName, fmt.Sprintf("%08d", index))
+}
+
+// segmentIndex returns the position of the segment with the given index in
+// q.segments, or -1.
+func (q *diskQueue) segmentIndex(index int) int {
+	i, found := slices.BinarySearchFunc(q.segments, index, func(s segment, index int) int { return s.index - index })
+	if !found {
+		return -1
+	}
+	return i
+}
+
+// createHead creates a new head segment. q.mut must be held, except when
+// opening the queue.
+func (q *diskQueue) createHead(index int) error {
+	f, err := os.OpenFile(q.segmentPath(index), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	q.head = f
+	q.segments = append(q.segments, segment{index: index})
+	return nil
+}
+
+// SetMaxSize changes the maximum size of the queue. Entries which are already
+// queued are kept if the queue is larger than the new size.
+func (q *diskQueue) SetMaxSize(maxSize int64) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	q.maxSize = maxSize
+}
+
+// Size returns the size of the records which weren't acknowledged yet.
+func (q *diskQueue) Size() int64 {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return q.pendingSize()
+}
+
+// pendingSize returns the size of the records which weren't acknowledged yet.
+// The acknowledged records of the first segment still take space on disk
+// until the segment is removed. q.mut must be held.
+func (q *diskQueue) pendingSize() int64 {
+	size := q.totalSize
+	if q.segments[0].index == q.acked.segment {
+		size -= q.acked.offset
+	}
+	return size
+}
+
+// Append appends an entry to the queue. It returns errQueueFull if the entry
+// doesn't fit in the queue.
+func (q *diskQueue) Append(e loki.Entry, now time.Time) error {
+	payload, err := encodeEntry(e, now)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	frame = append(frame, payload...)
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if q.pendingSize()+int64(len(frame)) > q.maxSize {
+		return errQueueFull
+	}
+
+	head := &q.segments[len(q.segments)-1]
+	if head.size > 0 && head.size+int64(len(frame)) > q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		head = &q.segments[len(q.segments)-1]
+	}
+
+	n, err := q.head.Write(frame)
+	head.size += int64(n)
+	q.totalSize += int64(n)
+	if err != nil {
+		// Remove the partially written record, so that it isn't read.
+		if truncErr := q.head.Truncate(head.size - int64(n)); truncErr == nil {
+			head.size -= int64(n)
+			q.totalSize -= int64(n)
+			_, _ = q.head.Seek(head.size, io.SeekStart)
+		}
+		return err
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rotate syncs and closes the head segment and creates a new one. q.mut must
+// be held.
+func (q *diskQueue) rotate() error {
+	if err := q.head.Sync(); err != nil {
+		return err
+	}
+	if err := q.head.Close(); err != nil {
+		return err
+	}
+	return q.createHead(q.segments[len(q.segments)-1].index + 1)
+}
+
+// Next returns the next entry of the queue, when it was appended, and the
+// position to acknowledge once the entry is processed. Next blocks until an
+// entry is available or ctx is canceled. It must not be called concurrently.
+func (q *diskQueue) Next(ctx context.Context) (loki.Entry, time.Time, position, error) {
+	for {
+		q.mut.Lock()
+		// The segment being read is never removed, since only the segments
+		// before the acknowledged position are.
+		i := q.segmentIndex(q.readPos.segment)
+		size, isHead := q.segments[i].size, i == len(q.segments)-1
+		var next position
+		if !isHead {
+			next = position{segment: q.segments[i+1].index}
+		}
+		q.mut.Unlock()
+
+		if q.readPos.offset >= size {
+			if !isHead {
+				q.closeReadFile()
+				q.readPos = next
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return loki.Entry{}, time.Time{}, position{}, ctx.Err()
+			case <-q.notify:
+				continue
+			}
+		}
+
+		if q.readFile == nil {
+			f, err := os.Open(q.segmentPath(q.readPos.segment))
+			if err != nil {
+				return loki.Entry{}, time.Time{}, position{}, err
+			}
+			q.readFile = f
+		}
+
+		payload, n, err := readRecord(q.readFile, q.readPos.offset, size)
+		if errors.Is(err, errCorruptedRecord) {
+			level.Error(q.logger).Log("msg", "skipping corrupted records in buffer", "segment", q.readPos.segment, "offset", q.readPos.offset, "err", err)
+			q.readPos.offset = size
+			continue
+		} else if err != nil {
+			return loki.Entry{}, time.Time{}, position{}, err
+		}
+		q.readPos.offset += n
+
+		entry, bufferedAt, err := decodeEntry(payload)
+		if err != nil {
+			level.Error(q.logger).Log("msg", "skipping undecodable record in buffer", "segment", q.readPos.segment, "offset", q.readPos.offset-n, "err", err)
+			continue
+		}
+		return entry, bufferedAt, q.readPos, nil
+	}
+}
+
+func (q *diskQueue) closeReadFile() {
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+	}
+}
+
+// Ack acknowledges all the records up to pos, and removes the segments which
+// only hold acknowledged records.
+func (q *diskQueue) Ack(pos position) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	q.acked = pos
+	for len(q.segments) > 1 && q.segments[0].index < pos.segment {
+		s := q.segments[0]
+		if err := os.Remove(q.segmentPath(s.index)); err != nil {
+			level.Error(q.logger).Log("msg", "failed to remove buffer segment", "segment", s.index, "err", err)
+			return
+		}
+		q.segments = q.segments[1:]
+		q.totalSize -= s.size
+	}
+}
+
+// Sync flushes the head segment to disk and persists the acknowledged
+// position.
+func (q *diskQueue) Sync() error {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	if err := q.head.Sync(); err != nil {
+		return err
+	}
+	if q.acked == q.synced {
+		return nil
+	}
+	if err := writePosition(filepath.Join(q.dir, positionFileName), q.acked); err != nil {
+		return err
+	}
+	q.synced = q.acked
+	return nil
+}
+
+// Close syncs and closes the queue.
+func (q *diskQueue) Close() error {
+	err := q.Sync()
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	q.closeReadFile()
+	return errors.Join(err, q.head.Close())
+}
+
+var errCorruptedRecord = errors.New("corrupted record")
+
+// readRecord reads the record at offset in a segment of the given size, and
+// returns its payload and the size of the record including its header.
+func readRecord(r io.ReaderAt, offset, size int64) ([]byte, int64, error) {
+	if size-offset < frameHeaderSize {
+		return nil, 0, fmt.Errorf("%w: truncated header", errCorruptedRecord)
+	}
+	var header [frameHeaderSize]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return nil, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if size-offset-frameHeaderSize < length {
+		return nil, 0, fmt.Errorf("%w: truncated payload", errCorruptedRecord)
+	}
+	payload := make([]byte, length)
+	if _, err := r.ReadAt(payload, offset+frameHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, fmt.Errorf("%w: checksum mismatch", errCorruptedRecord)
+	}
+	return payload, frameHeaderSize + length, nil
+}
+
+// encodeEntry encodes when an entry was buffered, its labels, and the entry
+// itself.
+func encodeEntry(e loki.Entry, bufferedAt time.Time) ([]byte, error) {
+	entry, err := e.Entry.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 8+binary.MaxVarintLen64+len(entry))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(bufferedAt.UnixNano()))
+	buf = binary.AppendUvarint(buf, uint64(len(e.Labels)))
+	for name, value := range e.Labels {
+		buf = appendString(buf, string(name))
+		buf = appendString(buf, string(value))
+	}
+	return append(buf, entry...), nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func decodeEntry(b []byte) (loki.Entry, time.Time, error) {
+	if len(b) < 8 {
+		return loki.Entry{}, time.Time{}, errors.New("record too short")
+	}
+	bufferedAt := time.Unix(0, int64(binary.BigEndian.Uint64(b)))
+	b = b[8:]
+
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return loki.Entry{}, time.Time{}, errors.New("invalid labels count")
+	}
+	b = b[n:]
+
+	labels := make(model.LabelSet, count)
+	for range count {
+		var name, value string
+		var err error
+		if name, b, err = readString(b); err != nil {
+			return loki.Entry{}, time.Time{}, err
+		}
+		if value, b, err = readString(b); err != nil {
+			return loki.Entry{}, time.Time{}, err
+		}
+		labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+
+	var entry push.Entry
+	if err := entry.Unmarshal(b); err != nil {
+		return loki.Entry{}, time.Time{}, err
+	}
+	return loki.Entry{Labels: labels, Entry: entry}, bufferedAt, nil
+}
+
+func readString(b []byte) (string, []byte, error) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b)-n) < length {
+		return "", nil, errors.New("invalid string")
+	}
+	return string(b[n : n+int(length)]), b[n+int(length):], nil
+}
+
+func readPosition(path string) (position, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return position{}, nil
+	} else if err != nil {
+		return position{}, err
+	}
+	if len(b) != 16 {
+		return position{}, fmt.Errorf("invalid position file %s", path)
+	}
+	return position{
+		segment: int(binary.BigEndian.Uint64(b[0:8])),
+		offset:  int64(binary.BigEndian.Uint64(b[8:16])),
+	}, nil
+}
+
+// writePosition atomically replaces the position file.
+func writePosition(path string, pos position) error {
+	b := make([]byte, 0, 16)
+	b = binary.BigEndian.AppendUint64(b, uint64(pos.segment))
+	b = binary.BigEndian.AppendUint64(b, uint64(pos.offset))
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
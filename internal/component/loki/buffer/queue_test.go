@@ -0,0 +1,132 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/util"
+)
+
+func TestDiskQueue_Segments(t *testing.T) {
+	dir := t.TempDir()
+	entry := newEntry(time.Unix(1, 0), model.LabelSet{"pod": "a"}, nil, "line 0")
+	payload, err := encodeEntry(entry, time.Now())
+	require.NoError(t, err)
+	recordSize := int64(frameHeaderSize + len(payload))
+
+	// Each segment holds two records.
+	q, err := openDiskQueue(dir, 2*recordSize, 100*recordSize, util.TestLogger(t))
+	require.NoError(t, err)
+	for i := range 5 {
+		require.NoError(t, q.Append(newEntry(time.Unix(1, 0), model.LabelSet{"pod": "a"}, nil, fmt.Sprintf("line %d", i)), time.Now()))
+	}
+	require.Equal(t, []string{"00000001", "00000002", "00000003"}, segmentFiles(t, dir))
+	require.Equal(t, 5*recordSize, q.Size())
+
+	// Segments are removed once all their records are acknowledged.
+	for i := range 3 {
+		e, _, pos, err := q.Next(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("line %d", i), e.Line)
+		q.Ack(pos)
+	}
+	require.Equal(t, []string{"00000002", "00000003"}, segmentFiles(t, dir))
+	require.Equal(t, 2*recordSize, q.Size())
+	require.NoError(t, q.Close())
+
+	// Reading resumes after the last acknowledged record.
+	q, err = openDiskQueue(dir, 2*recordSize, 100*recordSize, util.TestLogger(t))
+	require.NoError(t, err)
+	for i := 3; i < 5; i++ {
+		e, _, pos, err := q.Next(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("line %d", i), e.Line)
+		q.Ack(pos)
+	}
+	require.Equal(t, int64(0), q.Size())
+
+	// Next blocks until a record is appended.
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, _, _, err = q.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = q.Append(newEntry(time.Unix(1, 0), model.LabelSet{"pod": "a"}, nil, "line 5"), time.Now())
+	}()
+	e, _, _, err := q.Next(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "line 5", e.Line)
+	require.NoError(t, q.Close())
+}
+
+func TestDiskQueue_PartialRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := openDiskQueue(dir, segmentSize, segmentSize, util.TestLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, q.Append(newEntry(time.Unix(1, 0), model.LabelSet{"pod": "a"}, nil, "line 0"), time.Now()))
+	require.NoError(t, q.Append(newEntry(time.Unix(1, 0), model.LabelSet{"pod": "a"}, nil, "line 1"), time.Now()))
+	size := q.Size()
+	require.NoError(t, q.Close())
+
+	// Simulate a crash while the last record was written.
+	path := filepath.Join(dir, segmentsDirName, "00000001")
+	require.NoError(t, os.Truncate(path, size-3))
+
+	q, err = openDiskQueue(dir, segmentSize, segmentSize, util.TestLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, q.Append(newEntry(time.Unix(1, 0), model.LabelSet{"pod": "a"}, nil, "line 2"), time.Now()))
+
+	var lines []string
+	for range 2 {
+		e, _, pos, err := q.Next(t.Context())
+		require.NoError(t, err)
+		lines = append(lines, e.Line)
+		q.Ack(pos)
+	}
+	require.Equal(t, []string{"line 0", "line 2"}, lines)
+	require.NoError(t, q.Close())
+}
+
+func TestEncodeEntry(t *testing.T) {
+	bufferedAt := time.Unix(10, 5)
+	entry := loki.Entry{
+		Labels: model.LabelSet{"pod": "a", "namespace": "b"},
+	}
+	entry.Timestamp = time.Unix(1, 2).UTC()
+	entry.Line = "line"
+
+	b, err := encodeEntry(entry, bufferedAt)
+	require.NoError(t, err)
+
+	decoded, decodedBufferedAt, err := decodeEntry(b)
+	require.NoError(t, err)
+	require.Equal(t, entry.Labels, decoded.Labels)
+	require.Equal(t, entry.Line, decoded.Line)
+	require.True(t, entry.Timestamp.Equal(decoded.Timestamp))
+	require.True(t, bufferedAt.Equal(decodedBufferedAt))
+
+	_, _, err = decodeEntry(b[:10])
+	require.Error(t, err)
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, segmentsDirName))
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
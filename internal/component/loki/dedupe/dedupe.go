@@ -0,0 +1,261 @@
+package dedupe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.dedupe",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.dedupe
+// component.
+type Arguments struct {
+	// Where the deduplicated log entries should be forwarded to.
+	ForwardTo []loki.LogsReceiver `alloy:"forward_to,attr"`
+
+	// How long a log entry is remembered after it was last seen.
+	Window time.Duration `alloy:"window,attr,optional"`
+
+	// The maximum number of log entries to remember.
+	MaxEntries int `alloy:"max_entries,attr,optional"`
+
+	// The labels to hash. All labels are hashed if empty.
+	Labels []string `alloy:"labels,attr,optional"`
+
+	// The structured metadata to hash. No structured metadata is hashed if
+	// empty.
+	StructuredMetadata []string `alloy:"structured_metadata,attr,optional"`
+}
+
+// DefaultArguments provides the default arguments for the loki.dedupe
+// component.
+var DefaultArguments = Arguments{
+	Window:     time.Minute,
+	MaxEntries: 100_000,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (a *Arguments) Validate() error {
+	if a.Window <= 0 {
+		return errors.New("window must be greater than 0")
+	}
+	if a.MaxEntries <= 0 {
+		return errors.New("max_entries must be greater than 0")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the loki.dedupe component.
+type Exports struct {
+	Receiver loki.LogsReceiver `alloy:"receiver,attr"`
+}
+
+var (
+	_ component.Component     = (*Component)(nil)
+	_ component.LiveDebugging = (*Component)(nil)
+)
+
+// Component implements the loki.dedupe component.
+type Component struct {
+	opts    component.Options
+	metrics *metrics
+
+	mut      sync.RWMutex
+	args     Arguments
+	receiver loki.LogsReceiver
+	fanout   *loki.Fanout
+
+	// seen holds the time each hashed log entry was last seen at.
+	seen *lru.Cache[uint64, time.Time]
+
+	debugDataPublisher livedebugging.DebugDataPublisher
+}
+
+// New creates a new loki.dedupe component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	debugDataPublisher, err := o.GetServiceData(livedebugging.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:               o,
+		metrics:            newMetrics(o.Registerer),
+		fanout:             loki.NewFanout(args.ForwardTo),
+		debugDataPublisher: debugDataPublisher.(livedebugging.DebugDataPublisher),
+	}
+
+	// Create and immediately export the receiver which remains the same for
+	// the component's lifetime.
+	c.receiver = loki.NewLogsReceiver(loki.WithComponentID(o.ID))
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	// Call to Update() to set up the cache once at the start.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	componentID := livedebugging.ComponentID(c.opts.ID)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.metrics.entriesProcessed.Inc()
+			duplicate := c.isDuplicate(entry, time.Now())
+
+			count := uint64(1)
+			if duplicate {
+				count = 0 // duplicates are dropped, so they aren't counted
+			}
+			c.debugDataPublisher.PublishIfActive(livedebugging.NewData(
+				componentID,
+				livedebugging.LokiLog,
+				count,
+				func() string {
+					return fmt.Sprintf("entry: %s, labels: %s, duplicate: %t", entry.Line, entry.Labels.String(), duplicate)
+				},
+			))
+
+			if duplicate {
+				c.metrics.entriesDropped.Inc()
+				level.Debug(c.opts.Logger).Log("msg", "dropping duplicate entry", "labels", entry.Labels.String())
+				continue
+			}
+
+			c.metrics.entriesOutgoing.Inc()
+			if err := c.fanout.Send(ctx, entry); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// The hashes of the remembered entries depend on the labels and
+	// structured metadata which are hashed, so they're forgotten if those
+	// change.
+	if c.seen == nil || !slices.Equal(c.args.Labels, newArgs.Labels) || !slices.Equal(c.args.StructuredMetadata, newArgs.StructuredMetadata) {
+		seen, err := lru.New[uint64, time.Time](newArgs.MaxEntries)
+		if err != nil {
+			return err
+		}
+		c.seen = seen
+	} else if c.args.MaxEntries != newArgs.MaxEntries {
+		evicted := c.seen.Resize(newArgs.MaxEntries)
+		if evicted > 0 {
+			level.Debug(c.opts.Logger).Log("msg", "resizing the cache lead to evicting of items", "len_items_evicted", evicted)
+		}
+	}
+	c.metrics.cacheSize.Set(float64(c.seen.Len()))
+	c.args = newArgs
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	return nil
+}
+
+// isDuplicate reports whether an entry with the same hash was seen within the
+// window before now, and remembers e as seen at now.
+func (c *Component) isDuplicate(e loki.Entry, now time.Time) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	hash := c.hash(e)
+	last, found := c.seen.Get(hash)
+	c.seen.Add(hash, now)
+	c.metrics.cacheSize.Set(float64(c.seen.Len()))
+
+	return found && now.Sub(last) < c.args.Window
+}
+
+var separator = []byte{0xff}
+
+// hash returns the hash of the timestamp, line, and of the configured labels
+// and structured metadata of e.
+func (c *Component) hash(e loki.Entry) uint64 {
+	var (
+		h  = xxhash.New()
+		ts [8]byte
+	)
+	binary.LittleEndian.PutUint64(ts[:], uint64(e.Timestamp.UnixNano()))
+	_, _ = h.Write(ts[:])
+
+	if len(c.args.Labels) == 0 {
+		names := make([]model.LabelName, 0, len(e.Labels))
+		for name := range e.Labels {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			writeLabel(h, string(name), string(e.Labels[name]))
+		}
+	} else {
+		for _, name := range c.args.Labels {
+			if value, ok := e.Labels[model.LabelName(name)]; ok {
+				writeLabel(h, name, string(value))
+			}
+		}
+	}
+	for _, name := range c.args.StructuredMetadata {
+		for _, l := range e.StructuredMetadata {
+			if l.Name == name {
+				writeLabel(h, l.Name, l.Value)
+			}
+		}
+	}
+
+	_, _ = h.Write(separator)
+	_, _ = h.WriteString(e.Line)
+	return h.Sum64()
+}
+
+func writeLabel(h *xxhash.Digest, name, value string) {
+	_, _ = h.WriteString(name)
+	_, _ = h.Write(separator)
+	_, _ = h.WriteString(value)
+	_, _ = h.Write(separator)
+}
+
+// LiveDebugging implements component.LiveDebugging.
+func (c *Component) LiveDebugging() {}
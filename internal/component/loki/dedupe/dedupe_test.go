@@ -0,0 +1,219 @@
+package dedupe
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to          = []
+		window              = "30s"
+		labels              = ["namespace", "pod"]
+		structured_metadata = ["trace_id"]
+	`), &args))
+	require.Equal(t, 30*time.Second, args.Window)
+	require.Equal(t, DefaultArguments.MaxEntries, args.MaxEntries)
+
+	require.EqualError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		window     = "0s"
+	`), &args), "window must be greater than 0")
+	require.EqualError(t, syntax.Unmarshal([]byte(`
+		forward_to  = []
+		max_entries = 0
+	`), &args), "max_entries must be greater than 0")
+}
+
+func TestIsDuplicate(t *testing.T) {
+	ts := time.Unix(1, 0)
+	now := time.Unix(100, 0)
+
+	tests := []struct {
+		name     string
+		args     Arguments
+		first    loki.Entry
+		second   loki.Entry
+		after    time.Duration
+		expected bool
+	}{
+		{
+			name:     "same entry",
+			first:    newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			second:   newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			expected: true,
+		},
+		{
+			name:     "outside of the window",
+			first:    newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			second:   newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			after:    time.Minute,
+			expected: false,
+		},
+		{
+			name:     "different timestamp",
+			first:    newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			second:   newEntry(ts.Add(time.Nanosecond), model.LabelSet{"pod": "a"}, nil, "line"),
+			expected: false,
+		},
+		{
+			name:     "different line",
+			first:    newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			second:   newEntry(ts, model.LabelSet{"pod": "a"}, nil, "other line"),
+			expected: false,
+		},
+		{
+			name:     "different labels",
+			first:    newEntry(ts, model.LabelSet{"pod": "a"}, nil, "line"),
+			second:   newEntry(ts, model.LabelSet{"pod": "b"}, nil, "line"),
+			expected: false,
+		},
+		{
+			name:     "different unselected labels",
+			args:     Arguments{Labels: []string{"namespace"}},
+			first:    newEntry(ts, model.LabelSet{"namespace": "dev", "collector": "a"}, nil, "line"),
+			second:   newEntry(ts, model.LabelSet{"namespace": "dev", "collector": "b"}, nil, "line"),
+			expected: true,
+		},
+		{
+			name:     "different selected labels",
+			args:     Arguments{Labels: []string{"namespace"}},
+			first:    newEntry(ts, model.LabelSet{"namespace": "dev"}, nil, "line"),
+			second:   newEntry(ts, model.LabelSet{"namespace": "prod"}, nil, "line"),
+			expected: false,
+		},
+		{
+			name:     "different unselected structured metadata",
+			first:    newEntry(ts, nil, push.LabelsAdapter{{Name: "trace_id", Value: "a"}}, "line"),
+			second:   newEntry(ts, nil, push.LabelsAdapter{{Name: "trace_id", Value: "b"}}, "line"),
+			expected: true,
+		},
+		{
+			name:     "different selected structured metadata",
+			args:     Arguments{StructuredMetadata: []string{"trace_id"}},
+			first:    newEntry(ts, nil, push.LabelsAdapter{{Name: "trace_id", Value: "a"}}, "line"),
+			second:   newEntry(ts, nil, push.LabelsAdapter{{Name: "trace_id", Value: "b"}}, "line"),
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := DefaultArguments
+			args.Labels = tt.args.Labels
+			args.StructuredMetadata = tt.args.StructuredMetadata
+			c := newTestComponent(t, prometheus.NewRegistry(), args)
+
+			require.False(t, c.isDuplicate(tt.first, now))
+			require.Equal(t, tt.expected, c.isDuplicate(tt.second, now.Add(tt.after)))
+		})
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	args := DefaultArguments
+	args.MaxEntries = 1
+	c := newTestComponent(t, prometheus.NewRegistry(), args)
+
+	var (
+		now = time.Now()
+		a   = newEntry(time.Unix(1, 0), nil, nil, "a")
+		b   = newEntry(time.Unix(1, 0), nil, nil, "b")
+	)
+	require.False(t, c.isDuplicate(a, now))
+	require.False(t, c.isDuplicate(b, now))
+	// a was evicted to remember b.
+	require.False(t, c.isDuplicate(a, now))
+}
+
+func TestDedupe(t *testing.T) {
+	var (
+		reg = prometheus.NewRegistry()
+		ch  = loki.NewLogsReceiver()
+	)
+	args := DefaultArguments
+	args.ForwardTo = []loki.LogsReceiver{ch}
+	c := newTestComponent(t, reg, args)
+	go c.Run(t.Context())
+
+	ts := time.Now()
+	entries := []loki.Entry{
+		newEntry(ts, model.LabelSet{"pod": "a"}, nil, "first"),
+		newEntry(ts, model.LabelSet{"pod": "a"}, nil, "first"),
+		newEntry(ts, model.LabelSet{"pod": "a"}, nil, "second"),
+	}
+	go func() {
+		for _, e := range entries {
+			c.receiver.Chan() <- e
+		}
+	}()
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case e := <-ch.Chan():
+			require.Equal(t, expected, e.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for log entry")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP loki_dedupe_duplicates_dropped_total Total number of duplicate log entries dropped
+			# TYPE loki_dedupe_duplicates_dropped_total counter
+			loki_dedupe_duplicates_dropped_total 1
+			# HELP loki_dedupe_entries_processed_total Total number of log entries processed
+			# TYPE loki_dedupe_entries_processed_total counter
+			loki_dedupe_entries_processed_total 3
+			# HELP loki_dedupe_entries_written_total Total number of log entries forwarded
+			# TYPE loki_dedupe_entries_written_total counter
+			loki_dedupe_entries_written_total 2
+		`), "loki_dedupe_duplicates_dropped_total", "loki_dedupe_entries_processed_total", "loki_dedupe_entries_written_total") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func newTestComponent(t *testing.T, reg prometheus.Registerer, args Arguments) *Component {
+	t.Helper()
+	c, err := New(component.Options{
+		Logger:         util.TestAlloyLogger(t),
+		Registerer:     reg,
+		OnStateChange:  func(e component.Exports) {},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+	return c
+}
+
+func newEntry(ts time.Time, lbls model.LabelSet, metadata push.LabelsAdapter, line string) loki.Entry {
+	return loki.Entry{
+		Labels: lbls,
+		Entry: push.Entry{
+			Timestamp:          ts,
+			Line:               line,
+			StructuredMetadata: metadata,
+		},
+	}
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case livedebugging.ServiceName:
+		return livedebugging.NewLiveDebugging(), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
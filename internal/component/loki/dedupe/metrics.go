@@ -0,0 +1,45 @@
+package dedupe
+
+import (
+	"github.com/grafana/alloy/internal/util"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	entriesProcessed prometheus_client.Counter
+	entriesOutgoing  prometheus_client.Counter
+	entriesDropped   prometheus_client.Counter
+	cacheSize        prometheus_client.Gauge
+}
+
+// newMetrics creates a new set of metrics. If reg is non-nil, the metrics
+// will also be registered.
+func newMetrics(reg prometheus_client.Registerer) *metrics {
+	var m metrics
+
+	m.entriesProcessed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_dedupe_entries_processed_total",
+		Help: "Total number of log entries processed",
+	})
+	m.entriesOutgoing = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_dedupe_entries_written_total",
+		Help: "Total number of log entries forwarded",
+	})
+	m.entriesDropped = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_dedupe_duplicates_dropped_total",
+		Help: "Total number of duplicate log entries dropped",
+	})
+	m.cacheSize = prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+		Name: "loki_dedupe_cache_size",
+		Help: "Number of log entries remembered to detect duplicates",
+	})
+
+	if reg != nil {
+		m.entriesProcessed = util.MustRegisterOrGet(reg, m.entriesProcessed).(prometheus_client.Counter)
+		m.entriesOutgoing = util.MustRegisterOrGet(reg, m.entriesOutgoing).(prometheus_client.Counter)
+		m.entriesDropped = util.MustRegisterOrGet(reg, m.entriesDropped).(prometheus_client.Counter)
+		m.cacheSize = util.MustRegisterOrGet(reg, m.cacheSize).(prometheus_client.Gauge)
+	}
+
+	return &m
+}
@@ -51,13 +51,22 @@ type Component struct {
 	mut      sync.RWMutex
 	args     Arguments
 	receiver loki.LogsReceiver
+	capture  func(loki.Entry)
 }
 
 // New creates a new loki.echo component.
 func New(o component.Options, args Arguments) (*Component, error) {
+	return NewWithCapture(o, args, nil)
+}
+
+// NewWithCapture creates a new loki.echo component which also passes the
+// entries it receives to capture, so that callers such as alloy test can read
+// them back.
+func NewWithCapture(o component.Options, args Arguments, capture func(loki.Entry)) (*Component, error) {
 	c := &Component{
 		opts:     o,
 		receiver: loki.NewLogsReceiver(loki.WithComponentID(o.ID)),
+		capture:  capture,
 	}
 
 	// Call to Update() once at the start.
@@ -85,6 +94,9 @@ func (c *Component) Run(ctx context.Context) error {
 				structured_metadata = []byte("{}")
 			}
 			level.Info(c.opts.Logger).Log("receiver", c.opts.ID, "entry", entry.Line, "entry_timestamp", entry.Timestamp, "labels", entry.Labels.String(), "structured_metadata", string(structured_metadata))
+			if c.capture != nil {
+				c.capture(entry)
+			}
 		}
 	}
 }
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/grafana/alloy/internal/util"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	entriesProcessed   prometheus_client.Counter
+	entriesMatched     *prometheus_client.CounterVec
+	samplesFailed      *prometheus_client.CounterVec
+	samplesAppended    prometheus_client.Counter
+	evaluationFailures prometheus_client.Counter
+	series             *prometheus_client.GaugeVec
+}
+
+// newMetrics creates a new set of metrics. If reg is non-nil, the metrics
+// will also be registered.
+func newMetrics(reg prometheus_client.Registerer) *metrics {
+	var m metrics
+
+	m.entriesProcessed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_metrics_entries_processed_total",
+		Help: "Total number of log entries processed",
+	})
+	m.entriesMatched = prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+		Name: "loki_metrics_entries_matched_total",
+		Help: "Total number of log entries matching the stream selector of a metric",
+	}, []string{"metric"})
+	m.samplesFailed = prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+		Name: "loki_metrics_extraction_failures_total",
+		Help: "Total number of samples dropped because their extraction from a log entry failed",
+	}, []string{"metric"})
+	m.samplesAppended = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_metrics_samples_appended_total",
+		Help: "Total number of samples appended, including stale markers",
+	})
+	m.evaluationFailures = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_metrics_evaluation_failures_total",
+		Help: "Total number of evaluations which failed or whose samples failed to be appended",
+	})
+	m.series = prometheus_client.NewGaugeVec(prometheus_client.GaugeOpts{
+		Name: "loki_metrics_series",
+		Help: "Number of series with samples in the range of the query of a metric",
+	}, []string{"metric"})
+
+	if reg != nil {
+		m.entriesProcessed = util.MustRegisterOrGet(reg, m.entriesProcessed).(prometheus_client.Counter)
+		m.entriesMatched = util.MustRegisterOrGet(reg, m.entriesMatched).(*prometheus_client.CounterVec)
+		m.samplesFailed = util.MustRegisterOrGet(reg, m.samplesFailed).(*prometheus_client.CounterVec)
+		m.samplesAppended = util.MustRegisterOrGet(reg, m.samplesAppended).(prometheus_client.Counter)
+		m.evaluationFailures = util.MustRegisterOrGet(reg, m.evaluationFailures).(prometheus_client.Counter)
+		m.series = util.MustRegisterOrGet(reg, m.series).(*prometheus_client.GaugeVec)
+	}
+
+	return &m
+}
@@ -0,0 +1,321 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/component/prometheus"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.metrics",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.metrics
+// component.
+type Arguments struct {
+	// Where the computed samples should be appended to.
+	ForwardTo []storage.Appendable `alloy:"forward_to,attr"`
+
+	// How often the queries are evaluated.
+	Interval time.Duration `alloy:"interval,attr,optional"`
+
+	Metrics []Metric `alloy:"metric,block,optional"`
+}
+
+// Metric configures a metric computed from a LogQL metric query.
+type Metric struct {
+	// The name of the metric.
+	Name string `alloy:"name,attr"`
+
+	// The LogQL metric query to evaluate.
+	Query string `alloy:"query,attr"`
+
+	// Labels to add to the samples of the metric.
+	Labels map[string]string `alloy:"labels,attr,optional"`
+}
+
+// DefaultArguments provides the default arguments for the loki.metrics
+// component.
+var DefaultArguments = Arguments{
+	Interval: 15 * time.Second,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (a *Arguments) Validate() error {
+	if a.Interval <= 0 {
+		return errors.New("interval must be greater than 0")
+	}
+
+	names := make(map[string]struct{}, len(a.Metrics))
+	for _, m := range a.Metrics {
+		if !model.LegacyValidation.IsValidMetricName(m.Name) {
+			return fmt.Errorf("invalid metric name %q", m.Name)
+		}
+		if _, ok := names[m.Name]; ok {
+			return fmt.Errorf("metric %q is defined more than once", m.Name)
+		}
+		names[m.Name] = struct{}{}
+
+		for name := range m.Labels {
+			if !model.LegacyValidation.IsValidLabelName(name) || name == model.MetricNameLabel {
+				return fmt.Errorf("metric %q: invalid label name %q", m.Name, name)
+			}
+		}
+		q, err := parseQuery(m.Query)
+		if err != nil {
+			return fmt.Errorf("metric %q: invalid query: %w", m.Name, err)
+		}
+		// Overriding a grouping label would merge the groups into one series.
+		if q.grouping != nil && !q.grouping.Without {
+			for _, name := range q.grouping.Groups {
+				if _, ok := m.Labels[name]; ok {
+					return fmt.Errorf("metric %q: label %q is a grouping label of the query", m.Name, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the loki.metrics component.
+type Exports struct {
+	Receiver loki.LogsReceiver `alloy:"receiver,attr"`
+}
+
+var (
+	_ component.Component     = (*Component)(nil)
+	_ component.LiveDebugging = (*Component)(nil)
+)
+
+// Component implements the loki.metrics component.
+type Component struct {
+	opts    component.Options
+	metrics *metrics
+
+	receiver loki.LogsReceiver
+	fanout   *prometheus.Fanout
+
+	mut        sync.Mutex
+	args       Arguments
+	evaluators []*evaluator
+	// stale holds the samples marking the series of removed metrics as stale,
+	// which are appended by the next evaluation.
+	stale []sample
+
+	intervalChanged chan struct{}
+
+	debugDataPublisher livedebugging.DebugDataPublisher
+}
+
+// New creates a new loki.metrics component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	debugDataPublisher, err := o.GetServiceData(livedebugging.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	service, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:               o,
+		metrics:            newMetrics(o.Registerer),
+		fanout:             prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+		intervalChanged:    make(chan struct{}, 1),
+		debugDataPublisher: debugDataPublisher.(livedebugging.DebugDataPublisher),
+	}
+
+	// Create and immediately export the receiver which remains the same for
+	// the component's lifetime.
+	c.receiver = loki.NewLogsReceiver(loki.WithComponentID(o.ID))
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			c.observe(entry, time.Now())
+		case now := <-ticker.C:
+			c.evaluate(ctx, now)
+		case <-c.intervalChanged:
+			ticker.Reset(c.interval())
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Keep the state of the metrics which didn't change.
+	existing := make(map[string]*evaluator, len(c.evaluators))
+	for _, e := range c.evaluators {
+		existing[e.metric.Name] = e
+	}
+
+	evaluators := make([]*evaluator, 0, len(newArgs.Metrics))
+	for _, m := range newArgs.Metrics {
+		old, ok := existing[m.Name]
+		delete(existing, m.Name)
+		if ok && old.metric.Query == m.Query && maps.Equal(old.metric.Labels, m.Labels) {
+			evaluators = append(evaluators, old)
+			continue
+		}
+
+		e, err := newEvaluator(m)
+		if err != nil {
+			return fmt.Errorf("metric %q: %w", m.Name, err)
+		}
+		if ok {
+			// The series of the previous query which aren't returned by the new
+			// one are marked as stale by the next evaluation.
+			e.emitted = old.emitted
+		}
+		evaluators = append(evaluators, e)
+	}
+	for name, e := range existing {
+		c.stale = append(c.stale, e.stale()...)
+		c.metrics.entriesMatched.DeleteLabelValues(name)
+		c.metrics.samplesFailed.DeleteLabelValues(name)
+		c.metrics.series.DeleteLabelValues(name)
+	}
+
+	intervalChanged := c.args.Interval != newArgs.Interval
+	c.evaluators = evaluators
+	c.args = newArgs
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	if intervalChanged {
+		select {
+		case c.intervalChanged <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// LiveDebugging implements component.LiveDebugging.
+func (c *Component) LiveDebugging() {}
+
+func (c *Component) interval() time.Duration {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.args.Interval
+}
+
+// observe extracts the samples of entry for all metrics.
+func (c *Component) observe(entry loki.Entry, now time.Time) {
+	c.metrics.entriesProcessed.Inc()
+	stream := streamLabels(entry.Labels)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for _, e := range c.evaluators {
+		matched, failed := e.observe(stream, entry.Line, entry.Timestamp, entry.StructuredMetadata, now)
+		if matched {
+			c.metrics.entriesMatched.WithLabelValues(e.metric.Name).Inc()
+		}
+		if failed > 0 {
+			c.metrics.samplesFailed.WithLabelValues(e.metric.Name).Add(float64(failed))
+		}
+	}
+}
+
+// evaluate evaluates the queries of all metrics at now and appends the
+// results.
+func (c *Component) evaluate(ctx context.Context, now time.Time) {
+	c.mut.Lock()
+	samples := c.stale
+	c.stale = nil
+	for _, e := range c.evaluators {
+		res, err := e.evaluate(now)
+		if err != nil {
+			c.metrics.evaluationFailures.Inc()
+			level.Error(c.opts.Logger).Log("msg", "failed to evaluate metric", "metric", e.metric.Name, "err", err)
+		}
+		samples = append(samples, res...)
+		c.metrics.series.WithLabelValues(e.metric.Name).Set(float64(e.seriesCount()))
+	}
+	c.mut.Unlock()
+
+	if len(samples) == 0 {
+		return
+	}
+
+	ts := now.UnixMilli()
+	app := c.fanout.Appender(ctx)
+	for _, s := range samples {
+		if _, err := app.Append(0, s.labels, ts, s.value); err != nil {
+			level.Warn(c.opts.Logger).Log("msg", "failed to append sample", "labels", s.labels.String(), "err", err)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		c.metrics.evaluationFailures.Inc()
+		level.Error(c.opts.Logger).Log("msg", "failed to commit samples", "err", err)
+		return
+	}
+	c.metrics.samplesAppended.Add(float64(len(samples)))
+
+	c.debugDataPublisher.PublishIfActive(livedebugging.NewData(
+		livedebugging.ComponentID(c.opts.ID),
+		livedebugging.PrometheusMetric,
+		uint64(len(samples)),
+		func() string {
+			var sb strings.Builder
+			for i, s := range samples {
+				if i > 0 {
+					sb.WriteString("\n")
+				}
+				fmt.Fprintf(&sb, "%s %g", s.labels.String(), s.value)
+			}
+			return sb.String()
+		},
+	))
+}
@@ -0,0 +1,303 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/internal/util/testappender"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		metric {
+			name   = "app_errors"
+			query  = "sum by (status) (count_over_time({app=\"x\"} |= \"error\" | logfmt [1m]))"
+			labels = { team = "a" }
+		}
+	`), &args))
+	require.Equal(t, DefaultArguments.Interval, args.Interval)
+	require.Len(t, args.Metrics, 1)
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{
+			config: `interval = "0s"`,
+			err:    "interval must be greater than 0",
+		},
+		{
+			config: `metric {
+				name  = "app-errors"
+				query = "count_over_time({app=\"x\"}[1m])"
+			}`,
+			err: `invalid metric name "app-errors"`,
+		},
+		{
+			config: `metric {
+				name  = "a"
+				query = "count_over_time({app=\"x\"}[1m])"
+			}
+			metric {
+				name  = "a"
+				query = "rate({app=\"x\"}[1m])"
+			}`,
+			err: `metric "a" is defined more than once`,
+		},
+		{
+			config: `metric {
+				name   = "a"
+				query  = "count_over_time({app=\"x\"}[1m])"
+				labels = { "__name__" = "b" }
+			}`,
+			err: `metric "a": invalid label name "__name__"`,
+		},
+		{
+			config: `metric {
+				name   = "a"
+				query  = "sum by (level) (count_over_time({app=\"x\"} | logfmt [1m]))"
+				labels = { level = "x" }
+			}`,
+			err: `metric "a": label "level" is a grouping label of the query`,
+		},
+		{
+			config: `metric {
+				name  = "a"
+				query = "topk(1, count_over_time({app=\"x\"}[1m]))"
+			}`,
+			err: `metric "a": invalid query: unsupported vector aggregation topk, must be one of sum, avg, min, max or count`,
+		},
+		{
+			config: `metric {
+				name  = "a"
+				query = "count_over_time({app=\"x\"}[1m]) * 2"
+			}`,
+			err: `metric "a": invalid query: unsupported query, must be a range aggregation optionally wrapped in a sum, avg, min, max or count aggregation`,
+		},
+		{
+			config: `metric {
+				name  = "a"
+				query = "count_over_time({app=\"x\"}[1m] offset 1m)"
+			}`,
+			err: `metric "a": invalid query: offset modifiers aren't supported`,
+		},
+		{
+			config: `metric {
+				name  = "a"
+				query = "quantile_over_time(0.99, {app=\"x\"} | unwrap latency [1m])"
+			}`,
+			err: `metric "a": invalid query: unsupported range aggregation quantile_over_time`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.err, func(t *testing.T) {
+			var args Arguments
+			err := syntax.Unmarshal([]byte("forward_to = []\n"+tc.config), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	entries := []struct {
+		labels model.LabelSet
+		line   string
+		at     time.Time
+	}{
+		{model.LabelSet{"app": "x", "pod": "a"}, "status=500 latency=0.5", now.Add(-90 * time.Second)},
+		{model.LabelSet{"app": "x", "pod": "a"}, "status=500 latency=2", now.Add(-30 * time.Second)},
+		{model.LabelSet{"app": "x", "pod": "a"}, "status=200 latency=1", now.Add(-20 * time.Second)},
+		{model.LabelSet{"app": "x", "pod": "b"}, "status=500 latency=4", now.Add(-10 * time.Second)},
+		{model.LabelSet{"app": "x", "pod": "b"}, "status=500 latency=oops", now.Add(-10 * time.Second)},
+		{model.LabelSet{"app": "y", "pod": "c"}, "status=500 latency=8", now.Add(-10 * time.Second)},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			query: `count_over_time({app="x"}[1m])`,
+			expected: []string{
+				`{__name__="m", app="x", pod="a"} 2`,
+				`{__name__="m", app="x", pod="b"} 2`,
+			},
+		},
+		{
+			query: `sum by (status) (count_over_time({app="x"} |= "status=500" | logfmt [1m]))`,
+			expected: []string{
+				`{__name__="m", status="500"} 3`,
+			},
+		},
+		{
+			query: `sum(rate({app=~".+"}[2m]))`,
+			expected: []string{
+				`{__name__="m"} 0.05`,
+			},
+		},
+		{
+			query: `bytes_over_time({app="y"}[1m])`,
+			expected: []string{
+				`{__name__="m", app="y", pod="c"} 20`,
+			},
+		},
+		{
+			query: `max_over_time({app="x"} | logfmt | unwrap latency [1m]) by (app)`,
+			expected: []string{
+				`{__name__="m", app="x"} 4`,
+			},
+		},
+		{
+			query: `avg without (pod) (sum_over_time({app="x"} | logfmt | unwrap latency [1m]))`,
+			expected: []string{
+				`{__name__="m", app="x", status="200"} 1`,
+				`{__name__="m", app="x", status="500"} 3`,
+			},
+		},
+		{
+			query: `count(first_over_time({app="x"} | logfmt | unwrap latency | __error__="" [2m]) by (pod))`,
+			expected: []string{
+				`{__name__="m"} 2`,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			e, err := newEvaluator(Metric{Name: "m", Query: tc.query})
+			require.NoError(t, err)
+			for _, entry := range entries {
+				e.observe(streamLabels(entry.labels), entry.line, entry.at, nil, entry.at)
+			}
+			samples, err := e.evaluate(now)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, formatSamples(samples))
+		})
+	}
+}
+
+func TestEvaluate_Stale(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e, err := newEvaluator(Metric{Name: "m", Query: `count_over_time({app="x"}[1m])`, Labels: map[string]string{"team": "a"}})
+	require.NoError(t, err)
+
+	e.observe(streamLabels(model.LabelSet{"app": "x", "pod": "a"}), "line", now, nil, now)
+	e.observe(streamLabels(model.LabelSet{"app": "x", "pod": "b"}), "line", now, nil, now.Add(30*time.Second))
+	samples, err := e.evaluate(now.Add(45 * time.Second))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`{__name__="m", app="x", pod="a", team="a"} 1`,
+		`{__name__="m", app="x", pod="b", team="a"} 1`,
+	}, formatSamples(samples))
+	require.Equal(t, 2, e.seriesCount())
+
+	// The samples of pod a are out of the range of the query.
+	samples, err = e.evaluate(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`{__name__="m", app="x", pod="b", team="a"} 1`,
+		`{__name__="m", app="x", pod="a", team="a"} stale`,
+	}, formatSamples(samples))
+	require.Equal(t, 1, e.seriesCount())
+
+	require.Equal(t, []string{
+		`{__name__="m", app="x", pod="b", team="a"} stale`,
+	}, formatSamples(e.stale()))
+}
+
+func TestEvaluate_LabelsCollision(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e, err := newEvaluator(Metric{Name: "m", Query: `count_over_time({app="x"}[1m])`, Labels: map[string]string{"pod": "all"}})
+	require.NoError(t, err)
+
+	e.observe(streamLabels(model.LabelSet{"app": "x", "pod": "a"}), "line", now, nil, now)
+	samples, err := e.evaluate(now)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{__name__="m", app="x", pod="all"} 1`}, formatSamples(samples))
+
+	// Overriding the pod label gives the series of both pods the same labels.
+	e.observe(streamLabels(model.LabelSet{"app": "x", "pod": "b"}), "line", now, nil, now)
+	samples, err = e.evaluate(now)
+	require.EqualError(t, err, `the labels of the metric give several series the labels {__name__="m", app="x", pod="all"}`)
+	require.Equal(t, []string{`{__name__="m", app="x", pod="all"} stale`}, formatSamples(samples))
+	require.Empty(t, e.stale())
+}
+
+func TestMetrics(t *testing.T) {
+	app := testappender.NewCollectingAppender()
+
+	args := DefaultArguments
+	args.Interval = 10 * time.Millisecond
+	args.ForwardTo = []storage.Appendable{testappender.ConstantAppendable{Inner: app}}
+	args.Metrics = []Metric{{Name: "app_errors", Query: `sum by (app) (count_over_time({app="x"} |= "error" [1m]))`}}
+
+	c, err := New(component.Options{
+		ID:             "loki.metrics.test",
+		Logger:         util.TestAlloyLogger(t),
+		Registerer:     prom.NewRegistry(),
+		OnStateChange:  func(e component.Exports) {},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+	go c.Run(t.Context())
+
+	for _, line := range []string{"error", "ok", "another error"} {
+		c.receiver.Chan() <- loki.Entry{
+			Labels: model.LabelSet{"app": "x"},
+			Entry:  push.Entry{Timestamp: time.Now(), Line: line},
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		s := app.LatestSampleFor(`{__name__="app_errors", app="x"}`)
+		return s != nil && s.Value == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Removing the metric marks its series as stale.
+	args.Metrics = nil
+	require.NoError(t, c.Update(args))
+	require.Eventually(t, func() bool {
+		s := app.LatestSampleFor(`{__name__="app_errors", app="x"}`)
+		return value.IsStaleNaN(s.Value)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func formatSamples(samples []sample) []string {
+	res := make([]string, 0, len(samples))
+	for _, s := range samples {
+		if value.IsStaleNaN(s.value) {
+			res = append(res, s.labels.String()+" stale")
+			continue
+		}
+		res = append(res, fmt.Sprintf("%s %g", s.labels.String(), math.Round(s.value*1000)/1000))
+	}
+	return res
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case labelstore.ServiceName:
+		return labelstore.New(nil, prom.DefaultRegisterer), nil
+	case livedebugging.ServiceName:
+		return livedebugging.NewLiveDebugging(), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
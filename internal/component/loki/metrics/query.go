@@ -0,0 +1,395 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	lokipush "github.com/grafana/loki/pkg/push"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+)
+
+// query is a parsed LogQL metric query.
+//
+// Only range aggregations, optionally wrapped in a single vector aggregation,
+// are supported. Binary operations, literals and functions such as
+// label_replace need the results of the whole query at once and aren't
+// supported.
+type query struct {
+	expr     syntax.SampleExpr
+	matchers []*labels.Matcher
+
+	// rangeOp and rng are the operation and range of the range aggregation.
+	rangeOp string
+	rng     time.Duration
+
+	// vectorOp and grouping are the operation and grouping of the vector
+	// aggregation. vectorOp is empty if the query has no vector aggregation.
+	vectorOp string
+	grouping *syntax.Grouping
+}
+
+// parseQuery parses and validates a LogQL metric query.
+func parseQuery(s string) (*query, error) {
+	expr, err := syntax.ParseSampleExpr(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &query{expr: expr}
+
+	rangeExpr, ok := expr.(*syntax.RangeAggregationExpr)
+	if vectorExpr, isVector := expr.(*syntax.VectorAggregationExpr); isVector {
+		switch vectorExpr.Operation {
+		case syntax.OpTypeSum, syntax.OpTypeAvg, syntax.OpTypeMin, syntax.OpTypeMax, syntax.OpTypeCount:
+		default:
+			return nil, fmt.Errorf("unsupported vector aggregation %s, must be one of sum, avg, min, max or count", vectorExpr.Operation)
+		}
+		q.vectorOp = vectorExpr.Operation
+		// The label names must be sorted to compute the hash of groups.
+		q.grouping = &syntax.Grouping{Groups: slices.Sorted(slices.Values(vectorExpr.Grouping.Groups)), Without: vectorExpr.Grouping.Without}
+		rangeExpr, ok = vectorExpr.Left.(*syntax.RangeAggregationExpr)
+	}
+	if !ok {
+		return nil, errors.New("unsupported query, must be a range aggregation optionally wrapped in a sum, avg, min, max or count aggregation")
+	}
+
+	switch rangeExpr.Operation {
+	case syntax.OpRangeTypeCount, syntax.OpRangeTypeRate, syntax.OpRangeTypeBytes, syntax.OpRangeTypeBytesRate,
+		syntax.OpRangeTypeSum, syntax.OpRangeTypeAvg, syntax.OpRangeTypeMin, syntax.OpRangeTypeMax,
+		syntax.OpRangeTypeFirst, syntax.OpRangeTypeLast:
+	default:
+		return nil, fmt.Errorf("unsupported range aggregation %s", rangeExpr.Operation)
+	}
+	if rangeExpr.Left.Offset != 0 {
+		return nil, errors.New("offset modifiers aren't supported")
+	}
+	if rangeExpr.Left.Interval < time.Second {
+		return nil, errors.New("range must be at least 1s")
+	}
+	q.rangeOp = rangeExpr.Operation
+	q.rng = rangeExpr.Left.Interval
+	q.matchers = rangeExpr.Left.Left.Matchers()
+
+	// Build an extractor once to report errors in the pipeline.
+	if _, err := q.extractor(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// extractor returns a new sample extractor for the query.
+func (q *query) extractor() (log.SampleExtractor, error) {
+	extractors, err := q.expr.Extractors()
+	if err != nil {
+		return nil, err
+	}
+	if len(extractors) != 1 {
+		return nil, fmt.Errorf("expected a single extractor, got %d", len(extractors))
+	}
+	return extractors[0], nil
+}
+
+// sample is a sample computed by an evaluator.
+type sample struct {
+	labels labels.Labels
+	value  float64
+}
+
+// bucket aggregates the values extracted from the log entries received during
+// one second.
+type bucket struct {
+	ts                                int64
+	count, sum, min, max, first, last float64
+}
+
+type series struct {
+	labels  labels.Labels
+	buckets []bucket
+}
+
+// evaluator evaluates a query over the log entries it observes.
+//
+// The values extracted from log entries are aggregated by the second at which
+// the log entries were received, so that memory usage depends on the number of
+// series and the range of the query rather than on the number of log entries.
+type evaluator struct {
+	metric Metric
+	query  *query
+
+	extractor log.SampleExtractor
+	series    map[uint64]*series
+
+	// emitted holds the series emitted by the last evaluation, so that they can
+	// be marked as stale once they're gone.
+	emitted map[uint64]labels.Labels
+}
+
+func newEvaluator(m Metric) (*evaluator, error) {
+	q, err := parseQuery(m.Query)
+	if err != nil {
+		return nil, err
+	}
+	extractor, err := q.extractor()
+	if err != nil {
+		return nil, err
+	}
+	return &evaluator{
+		metric:    m,
+		query:     q,
+		extractor: extractor,
+		series:    make(map[uint64]*series),
+		emitted:   make(map[uint64]labels.Labels),
+	}, nil
+}
+
+// observe extracts the samples of a log entry received at now. It reports
+// whether the log entry matched the stream selector of the query, and the
+// number of samples dropped because their extraction failed.
+func (e *evaluator) observe(stream labels.Labels, line string, ts time.Time, metadata lokipush.LabelsAdapter, now time.Time) (matched bool, failed int) {
+	for _, m := range e.query.matchers {
+		if !m.Matches(stream.Get(m.Name)) {
+			return false, 0
+		}
+	}
+
+	samples, ok := e.extractor.ForStream(stream).ProcessString(ts.UnixNano(), line, metadataLabels(metadata))
+	if !ok {
+		return true, 0
+	}
+
+	sec := now.Unix()
+	for _, s := range samples {
+		lbls := s.Labels.Labels()
+		if lbls.Has(logqlmodel.ErrorLabel) {
+			failed++
+			continue
+		}
+
+		hash := s.Labels.Hash()
+		ser, ok := e.series[hash]
+		if !ok {
+			ser = &series{labels: lbls}
+			e.series[hash] = ser
+		}
+		ser.add(sec, s.Value)
+	}
+	return true, failed
+}
+
+func (s *series) add(ts int64, v float64) {
+	if n := len(s.buckets); n > 0 && s.buckets[n-1].ts >= ts {
+		// Samples received during the same second, or after the clock jumped
+		// backwards, are added to the latest bucket.
+		b := &s.buckets[n-1]
+		b.count++
+		b.sum += v
+		b.min = math.Min(b.min, v)
+		b.max = math.Max(b.max, v)
+		b.last = v
+		return
+	}
+	s.buckets = append(s.buckets, bucket{ts: ts, count: 1, sum: v, min: v, max: v, first: v, last: v})
+}
+
+// evaluate computes the results of the query at now, and returns them with
+// stale markers for the series which were returned by the previous
+// evaluation but not by this one.
+//
+// An error is returned if the labels of the metric give several results the
+// same labels, in which case only stale markers for all the series returned
+// by the previous evaluation are returned.
+func (e *evaluator) evaluate(now time.Time) ([]sample, error) {
+	windowStart := now.Unix() - int64(e.query.rng/time.Second)
+
+	results := make([]sample, 0, len(e.series))
+	for hash, ser := range e.series {
+		i := 0
+		for i < len(ser.buckets) && ser.buckets[i].ts <= windowStart {
+			i++
+		}
+		ser.buckets = ser.buckets[i:]
+		if len(ser.buckets) == 0 {
+			delete(e.series, hash)
+			continue
+		}
+		results = append(results, sample{labels: ser.labels, value: e.rangeValue(ser.buckets)})
+	}
+
+	if e.query.vectorOp != "" {
+		results = aggregate(e.query.vectorOp, e.query.grouping, results)
+	}
+
+	// The extractor caches a pipeline for each stream it processed. It's
+	// replaced to forget about streams which aren't received anymore.
+	if extractor, err := e.query.extractor(); err == nil {
+		e.extractor = extractor
+	}
+
+	emitted := make(map[uint64]labels.Labels, len(results))
+	for i, r := range results {
+		lb := labels.NewBuilder(r.labels)
+		for name, v := range e.metric.Labels {
+			lb.Set(name, v)
+		}
+		lb.Set(model.MetricNameLabel, e.metric.Name)
+		results[i].labels = lb.Labels()
+
+		hash := results[i].labels.Hash()
+		if _, ok := emitted[hash]; ok {
+			stale := staleSamples(e.emitted, nil)
+			e.emitted = nil
+			return stale, fmt.Errorf("the labels of the metric give several series the labels %s", results[i].labels)
+		}
+		emitted[hash] = results[i].labels
+	}
+	slices.SortFunc(results, func(a, b sample) int { return labels.Compare(a.labels, b.labels) })
+	results = append(results, staleSamples(e.emitted, emitted)...)
+	e.emitted = emitted
+
+	return results, nil
+}
+
+// stale returns stale markers for all series emitted by the last evaluation.
+func (e *evaluator) stale() []sample {
+	return staleSamples(e.emitted, nil)
+}
+
+// seriesCount returns the number of series which have samples in the range
+// of the query.
+func (e *evaluator) seriesCount() int {
+	return len(e.series)
+}
+
+func (e *evaluator) rangeValue(buckets []bucket) float64 {
+	var count, sum float64
+	minimum, maximum := math.Inf(1), math.Inf(-1)
+	for _, b := range buckets {
+		count += b.count
+		sum += b.sum
+		minimum = math.Min(minimum, b.min)
+		maximum = math.Max(maximum, b.max)
+	}
+
+	switch e.query.rangeOp {
+	case syntax.OpRangeTypeCount:
+		return count
+	case syntax.OpRangeTypeRate, syntax.OpRangeTypeBytesRate:
+		return sum / e.query.rng.Seconds()
+	case syntax.OpRangeTypeAvg:
+		return sum / count
+	case syntax.OpRangeTypeMin:
+		return minimum
+	case syntax.OpRangeTypeMax:
+		return maximum
+	case syntax.OpRangeTypeFirst:
+		return buckets[0].first
+	case syntax.OpRangeTypeLast:
+		return buckets[len(buckets)-1].last
+	default: // bytes_over_time and sum_over_time
+		return sum
+	}
+}
+
+// aggregate applies a vector aggregation to samples. The label names of
+// grouping must be sorted.
+func aggregate(op string, grouping *syntax.Grouping, samples []sample) []sample {
+	type group struct {
+		labels labels.Labels
+		value  float64
+		count  float64
+	}
+
+	var (
+		groups = make(map[uint64]*group)
+		order  []uint64
+		buf    []byte
+	)
+	for _, s := range samples {
+		var hash uint64
+		if grouping.Without {
+			hash, buf = s.labels.HashWithoutLabels(buf, grouping.Groups...)
+		} else {
+			hash, buf = s.labels.HashForLabels(buf, grouping.Groups...)
+		}
+
+		g, ok := groups[hash]
+		if !ok {
+			lb := labels.NewBuilder(s.labels)
+			if grouping.Without {
+				lb.Del(grouping.Groups...)
+				lb.Del(model.MetricNameLabel)
+			} else {
+				lb.Keep(grouping.Groups...)
+			}
+			g = &group{labels: lb.Labels(), value: s.value}
+			groups[hash] = g
+			order = append(order, hash)
+		} else {
+			switch op {
+			case syntax.OpTypeSum, syntax.OpTypeAvg:
+				g.value += s.value
+			case syntax.OpTypeMin:
+				g.value = math.Min(g.value, s.value)
+			case syntax.OpTypeMax:
+				g.value = math.Max(g.value, s.value)
+			}
+		}
+		g.count++
+	}
+
+	res := make([]sample, 0, len(groups))
+	for _, hash := range order {
+		g := groups[hash]
+		switch op {
+		case syntax.OpTypeAvg:
+			g.value /= g.count
+		case syntax.OpTypeCount:
+			g.value = g.count
+		}
+		res = append(res, sample{labels: g.labels, value: g.value})
+	}
+	return res
+}
+
+// staleSamples returns stale markers for the series of prev which aren't in
+// cur.
+func staleSamples(prev, cur map[uint64]labels.Labels) []sample {
+	var res []sample
+	for hash, lbls := range prev {
+		if _, ok := cur[hash]; !ok {
+			res = append(res, sample{labels: lbls, value: math.Float64frombits(value.StaleNaN)})
+		}
+	}
+	slices.SortFunc(res, func(a, b sample) int { return labels.Compare(a.labels, b.labels) })
+	return res
+}
+
+// streamLabels converts the labels of a log entry to Prometheus labels.
+func streamLabels(ls model.LabelSet) labels.Labels {
+	b := labels.NewScratchBuilder(len(ls))
+	for name, value := range ls {
+		b.Add(string(name), string(value))
+	}
+	b.Sort()
+	return b.Labels()
+}
+
+func metadataLabels(metadata lokipush.LabelsAdapter) labels.Labels {
+	if len(metadata) == 0 {
+		return labels.EmptyLabels()
+	}
+	b := labels.NewScratchBuilder(len(metadata))
+	for _, l := range metadata {
+		b.Add(l.Name, l.Value)
+	}
+	b.Sort()
+	return b.Labels()
+}
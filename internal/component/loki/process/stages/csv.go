@@ -0,0 +1,302 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/log"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+// Config Errors
+const (
+	ErrCSVColumnsOrHeaderRequired = "either columns or header must be set"
+	ErrCSVColumnsAndHeader        = "columns and header can't be set together"
+	ErrCSVInvalidDelimiter        = "delimiter must be a single character"
+	ErrCSVInvalidQuote            = "quote must be empty or a single character other than the delimiter"
+	ErrEmptyCSVStageSource        = "empty source"
+	ErrMalformedCSV               = "malformed csv"
+)
+
+const (
+	malformedCSVDropReason = "malformed_csv"
+
+	// Maximum number of streams for which we keep the header.
+	maxCSVHeadersCacheSize = 10000
+)
+
+// CSVConfig represents a CSV Stage configuration
+type CSVConfig struct {
+	Columns       []string `alloy:"columns,attr,optional"`
+	Header        bool     `alloy:"header,attr,optional"`
+	Delimiter     string   `alloy:"delimiter,attr,optional"`
+	Quote         string   `alloy:"quote,attr,optional"`
+	Source        *string  `alloy:"source,attr,optional"`
+	DropMalformed bool     `alloy:"drop_malformed,attr,optional"`
+}
+
+// DefaultCSVConfig sets the defaults.
+var DefaultCSVConfig = CSVConfig{
+	Delimiter: ",",
+	Quote:     `"`,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (c *CSVConfig) SetToDefault() {
+	*c = DefaultCSVConfig
+}
+
+// validateCSVConfig validates a csv config and returns its delimiter and
+// quote characters. The quote character is 0 if quoting is disabled.
+func validateCSVConfig(c *CSVConfig) (delimiter, quote rune, err error) {
+	if len(c.Columns) == 0 && !c.Header {
+		return 0, 0, errors.New(ErrCSVColumnsOrHeaderRequired)
+	}
+	if len(c.Columns) > 0 && c.Header {
+		return 0, 0, errors.New(ErrCSVColumnsAndHeader)
+	}
+
+	if c.Source != nil && *c.Source == "" {
+		return 0, 0, errors.New(ErrEmptyCSVStageSource)
+	}
+
+	if utf8.RuneCountInString(c.Delimiter) != 1 {
+		return 0, 0, errors.New(ErrCSVInvalidDelimiter)
+	}
+	delimiter, _ = utf8.DecodeRuneInString(c.Delimiter)
+
+	switch utf8.RuneCountInString(c.Quote) {
+	case 0:
+	case 1:
+		quote, _ = utf8.DecodeRuneInString(c.Quote)
+		if quote == delimiter {
+			return 0, 0, errors.New(ErrCSVInvalidQuote)
+		}
+	default:
+		return 0, 0, errors.New(ErrCSVInvalidQuote)
+	}
+	return delimiter, quote, nil
+}
+
+// csvStage sets extracted data from the fields of CSV lines
+type csvStage struct {
+	cfg       *CSVConfig
+	delimiter rune
+	quote     rune
+	logger    log.Logger
+	dropCount *prometheus.CounterVec
+
+	// headers holds the header line and columns of the most recently seen
+	// streams if the columns are read from headers.
+	headers *lru.Cache[model.Fingerprint, csvHeader]
+}
+
+type csvHeader struct {
+	line    string
+	columns []string
+}
+
+// newCSVStage creates a new csv pipeline stage from a config.
+func newCSVStage(logger log.Logger, cfg CSVConfig, registerer prometheus.Registerer) (Stage, error) {
+	delimiter, quote, err := validateCSVConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := lru.New[model.Fingerprint, csvHeader](maxCSVHeadersCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &csvStage{
+		cfg:       &cfg,
+		delimiter: delimiter,
+		quote:     quote,
+		logger:    log.With(logger, "component", "stage", "type", "csv"),
+		dropCount: getDropCountMetric(registerer),
+		headers:   headers,
+	}, nil
+}
+
+func (c *csvStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			err := c.processEntry(e.Labels, e.Extracted, &e.Line)
+			if err != nil && c.cfg.DropMalformed {
+				c.dropCount.WithLabelValues(malformedCSVDropReason).Inc()
+				continue
+			}
+			out <- e
+		}
+	}()
+	return out
+}
+
+func (c *csvStage) processEntry(labels model.LabelSet, extracted map[string]any, entry *string) error {
+	// If a source key is provided, the csv stage should process it
+	// from the extracted map, otherwise should fall back to the entry
+	input := entry
+
+	if c.cfg.Source != nil {
+		if _, ok := extracted[*c.cfg.Source]; !ok {
+			if Debug {
+				level.Debug(c.logger).Log("msg", "source does not exist in the set of extracted values", "source", *c.cfg.Source)
+			}
+			return nil
+		}
+
+		value, err := getString(extracted[*c.cfg.Source])
+		if err != nil {
+			if Debug {
+				level.Debug(c.logger).Log("msg", "failed to convert source value to string", "source", *c.cfg.Source, "err", err, "type", reflect.TypeOf(extracted[*c.cfg.Source]))
+			}
+			return nil
+		}
+
+		input = &value
+	}
+
+	if input == nil {
+		if Debug {
+			level.Debug(c.logger).Log("msg", "cannot parse a nil entry")
+		}
+		return nil
+	}
+
+	fields, err := splitCSV(*input, c.delimiter, c.quote)
+	if err != nil {
+		if Debug {
+			level.Debug(c.logger).Log("msg", "failed to parse log line", "err", err)
+		}
+		return errors.New(ErrMalformedCSV)
+	}
+
+	columns := c.cfg.Columns
+	if c.cfg.Header {
+		// The first line of each stream which doesn't look like data, and any
+		// line equal to it such as the header of a rotated file, is a header.
+		// Until the header of a stream is read, for example when a file is
+		// read from the middle after a restart, its lines can't be extracted.
+		fp := labels.Fingerprint()
+		header, ok := c.headers.Get(fp)
+		switch {
+		case ok && header.line == *input:
+			return nil
+		case !ok && looksLikeCSVData(fields):
+			if Debug {
+				level.Debug(c.logger).Log("msg", "skipping line of a stream whose header wasn't read")
+			}
+			return errors.New(ErrMalformedCSV)
+		case !ok:
+			c.headers.Add(fp, csvHeader{line: *input, columns: fields})
+			return nil
+		}
+		columns = header.columns
+	}
+
+	if len(fields) != len(columns) {
+		if Debug {
+			level.Debug(c.logger).Log("msg", "number of fields doesn't match the number of columns", "fields", len(fields), "columns", len(columns))
+		}
+		return errors.New(ErrMalformedCSV)
+	}
+
+	for i, column := range columns {
+		// Columns without a name aren't extracted.
+		if column == "" {
+			continue
+		}
+		extracted[column] = fields[i]
+	}
+	if Debug {
+		level.Debug(c.logger).Log("msg", "extracted data debug in csv stage", "extracted_data", fmt.Sprintf("%v", extracted))
+	}
+	return nil
+}
+
+// looksLikeCSVData reports whether the fields of a line can't be a header
+// because one of them is empty, a number or a timestamp.
+func looksLikeCSVData(fields []string) bool {
+	for _, f := range fields {
+		if f == "" {
+			return true
+		}
+		if _, err := strconv.ParseFloat(f, 64); err == nil {
+			return true
+		}
+		if _, err := time.Parse(time.RFC3339Nano, f); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCSV splits a CSV line into its fields. Fields can be enclosed in quote
+// characters to hold delimiters, and quote characters are escaped in quoted
+// fields by doubling them. Quoting is disabled if quote is 0.
+func splitCSV(line string, delimiter, quote rune) ([]string, error) {
+	var (
+		fields []string
+		field  strings.Builder
+		rest   = line
+	)
+	for {
+		if quote == 0 || !strings.HasPrefix(rest, string(quote)) {
+			// Unquoted field.
+			i := strings.IndexRune(rest, delimiter)
+			if i < 0 {
+				return append(fields, rest), nil
+			}
+			fields = append(fields, rest[:i])
+			rest = rest[i+utf8.RuneLen(delimiter):]
+			continue
+		}
+
+		// Quoted field.
+		field.Reset()
+		rest = rest[utf8.RuneLen(quote):]
+		for {
+			i := strings.IndexRune(rest, quote)
+			if i < 0 {
+				return nil, errors.New("unterminated quoted field")
+			}
+			field.WriteString(rest[:i])
+			rest = rest[i+utf8.RuneLen(quote):]
+			if !strings.HasPrefix(rest, string(quote)) {
+				break
+			}
+			// Escaped quote character.
+			field.WriteRune(quote)
+			rest = rest[utf8.RuneLen(quote):]
+		}
+		fields = append(fields, field.String())
+
+		if rest == "" {
+			return fields, nil
+		}
+		if !strings.HasPrefix(rest, string(delimiter)) {
+			unexpected, _, _ := strings.Cut(rest, string(delimiter))
+			return nil, fmt.Errorf("unexpected %q after quoted field", unexpected)
+		}
+		rest = rest[utf8.RuneLen(delimiter):]
+	}
+}
+
+// Name implements Stage
+func (c *csvStage) Name() string {
+	return StageTypeCSV
+}
+
+// Cleanup implements Stage.
+func (*csvStage) Cleanup() {
+	// no-op
+}
@@ -0,0 +1,295 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/util"
+)
+
+var testCSVAlloyColumns = `
+stage.csv {
+    columns = ["time", "", "user", "message"]
+}
+`
+
+var testCSVAlloyTSVWithSource = `
+stage.json {
+    expressions = { "payload" = "" }
+}
+
+stage.csv {
+    columns   = ["user", "message"]
+    delimiter = "\t"
+    quote     = "'"
+    source    = "payload"
+}`
+
+func TestPipeline_CSV(t *testing.T) {
+	t.Parallel()
+	logger := util.TestAlloyLogger(t)
+
+	tests := map[string]struct {
+		config          string
+		entry           string
+		expectedExtract map[string]any
+	}{
+		"successfully run a pipeline with 1 csv stage without source": {
+			testCSVAlloyColumns,
+			`2012-11-01T22:08:41+00:00,INFO,marco,"hello, ""world"""`,
+			map[string]any{
+				"time":    "2012-11-01T22:08:41+00:00",
+				"user":    "marco",
+				"message": `hello, "world"`,
+			},
+		},
+		"successfully run a pipeline with a tsv stage with source": {
+			testCSVAlloyTSVWithSource,
+			`{"payload": "marco\t'it''s\ta tab'"}`,
+			map[string]any{
+				"payload": "marco\t'it''s\ta tab'",
+				"user":    "marco",
+				"message": "it's\ta tab",
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			pl, err := NewPipeline(logger, loadConfig(testData.config), nil, prometheus.DefaultRegisterer, featuregate.StabilityGenerallyAvailable)
+			require.NoError(t, err, "Expected pipeline creation to not result in error")
+			out := processEntries(pl, newEntry(nil, nil, testData.entry, time.Now()))[0]
+			assert.Equal(t, testData.expectedExtract, out.Extracted)
+		})
+	}
+}
+
+func TestCSVConfig_validate(t *testing.T) {
+	t.Parallel()
+
+	var emptyString = ""
+
+	tests := map[string]struct {
+		config *CSVConfig
+		err    error
+	}{
+		"no columns": {
+			&CSVConfig{Delimiter: ",", Quote: `"`},
+			errors.New(ErrCSVColumnsOrHeaderRequired),
+		},
+		"columns and header": {
+			&CSVConfig{Columns: []string{"a"}, Header: true, Delimiter: ",", Quote: `"`},
+			errors.New(ErrCSVColumnsAndHeader),
+		},
+		"empty source": {
+			&CSVConfig{Columns: []string{"a"}, Delimiter: ",", Quote: `"`, Source: &emptyString},
+			errors.New(ErrEmptyCSVStageSource),
+		},
+		"long delimiter": {
+			&CSVConfig{Columns: []string{"a"}, Delimiter: ",,", Quote: `"`},
+			errors.New(ErrCSVInvalidDelimiter),
+		},
+		"quote is the delimiter": {
+			&CSVConfig{Columns: []string{"a"}, Delimiter: ",", Quote: ","},
+			errors.New(ErrCSVInvalidQuote),
+		},
+		"no quote": {
+			&CSVConfig{Columns: []string{"a"}, Delimiter: ";"},
+			nil,
+		},
+		"header": {
+			&CSVConfig{Header: true, Delimiter: ",", Quote: `"`},
+			nil,
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := validateCSVConfig(tt.config)
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err.Error())
+		})
+	}
+}
+
+func TestSplitCSV(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		line        string
+		delimiter   rune
+		quote       rune
+		expected    []string
+		expectedErr string
+	}{
+		"empty line": {
+			line:     "",
+			expected: []string{""},
+		},
+		"empty fields": {
+			line:     ",a,,",
+			expected: []string{"", "a", "", ""},
+		},
+		"quoted fields": {
+			line:     `"a,b","","c""d",e`,
+			expected: []string{"a,b", "", `c"d`, "e"},
+		},
+		"quote inside unquoted field": {
+			line:     `a"b,c`,
+			expected: []string{`a"b`, "c"},
+		},
+		"quoting disabled": {
+			line:     `"a,b"`,
+			quote:    -1,
+			expected: []string{`"a`, `b"`},
+		},
+		"multibyte delimiter": {
+			line:      `a→"b→c"→d`,
+			delimiter: '→',
+			expected:  []string{"a", "b→c", "d"},
+		},
+		"unterminated quoted field": {
+			line:        `a,"b`,
+			expectedErr: "unterminated quoted field",
+		},
+		"text after quoted field": {
+			line:        `"a"b,c`,
+			expectedErr: `unexpected "b" after quoted field`,
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			delimiter, quote := tt.delimiter, tt.quote
+			if delimiter == 0 {
+				delimiter = ','
+			}
+			switch quote {
+			case 0:
+				quote = '"'
+			case -1:
+				quote = 0
+			}
+
+			fields, err := splitCSV(tt.line, delimiter, quote)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fields)
+		})
+	}
+}
+
+func TestCSVStage_Header(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultCSVConfig
+	cfg.Header = true
+	s, err := newCSVStage(util.TestAlloyLogger(t), cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	var (
+		a = model.LabelSet{"filename": "a.csv"}
+		b = model.LabelSet{"filename": "b.csv"}
+	)
+	out := processEntries(s,
+		newEntry(nil, a, "user,message", time.Now()),
+		newEntry(nil, b, "message,user", time.Now()),
+		newEntry(nil, a, "marco,hello", time.Now()),
+		newEntry(nil, b, "hi,john", time.Now()),
+		// The header of a rotated file.
+		newEntry(nil, a, "user,message", time.Now()),
+		newEntry(nil, a, "marco,bye", time.Now()),
+	)
+	require.Len(t, out, 6)
+
+	expected := []map[string]any{
+		{},
+		{},
+		{"user": "marco", "message": "hello"},
+		{"user": "john", "message": "hi"},
+		{},
+		{"user": "marco", "message": "bye"},
+	}
+	for i, e := range out {
+		require.Equal(t, expected[i], e.Extracted, "entry %d", i)
+	}
+}
+
+func TestCSVStage_HeaderResume(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	cfg := DefaultCSVConfig
+	cfg.Header = true
+	cfg.DropMalformed = true
+	s, err := newCSVStage(util.TestAlloyLogger(t), cfg, registry)
+	require.NoError(t, err)
+
+	// The file is read from the middle, so its header comes after the
+	// rotation.
+	labels := model.LabelSet{"filename": "a.csv"}
+	out := processEntries(s,
+		newEntry(nil, labels, "2024-01-01T10:00:00Z,marco,1", time.Now()),
+		newEntry(nil, labels, "2024-01-01T10:00:01Z,john,2", time.Now()),
+		newEntry(nil, labels, ",,", time.Now()),
+		newEntry(nil, labels, "time,user,count", time.Now()),
+		newEntry(nil, labels, "2024-01-01T10:00:02Z,marco,3", time.Now()),
+	)
+	require.Len(t, out, 2)
+	require.Equal(t, map[string]any{}, out[0].Extracted)
+	require.Equal(t, map[string]any{"time": "2024-01-01T10:00:02Z", "user": "marco", "count": "3"}, out[1].Extracted)
+	require.Equal(t, 3.0, testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(malformedCSVDropReason)))
+}
+
+func TestCSVStage_HeaderCacheSize(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultCSVConfig
+	cfg.Header = true
+	s, err := newCSVStage(util.TestAlloyLogger(t), cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	entries := make([]Entry, 0, maxCSVHeadersCacheSize+1)
+	for i := range maxCSVHeadersCacheSize + 1 {
+		entries = append(entries, newEntry(nil, model.LabelSet{"filename": model.LabelValue(fmt.Sprintf("%d.csv", i))}, "user,message", time.Now()))
+	}
+	processEntries(s, entries...)
+	require.Equal(t, maxCSVHeadersCacheSize, s.(*csvStage).headers.Len())
+}
+
+func TestCSVStage_DropMalformed(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	cfg := DefaultCSVConfig
+	cfg.Columns = []string{"user", "message"}
+	cfg.DropMalformed = true
+	s, err := newCSVStage(util.TestAlloyLogger(t), cfg, registry)
+	require.NoError(t, err)
+
+	out := processEntries(s,
+		newEntry(nil, nil, `marco,"hello`, time.Now()),
+		newEntry(nil, nil, `marco,hello,world`, time.Now()),
+		newEntry(nil, nil, `marco,hello`, time.Now()),
+	)
+	require.Len(t, out, 1)
+	require.Equal(t, map[string]any{"user": "marco", "message": "hello"}, out[0].Extracted)
+	require.Equal(t, 2.0, testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(malformedCSVDropReason)))
+}
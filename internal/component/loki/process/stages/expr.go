@@ -0,0 +1,444 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/util/zapadapter"
+)
+
+var (
+	ErrEmptyExprStageConfig = errors.New("expr stage config must contain at least one of `statements` or `drop_conditions`")
+)
+
+const (
+	defaultExprDropReason = "expr_stage"
+	exprErrorDropReason   = "expr_error"
+)
+
+// ExprConfig contains the configuration for an exprStage.
+type ExprConfig struct {
+	Statements     []string       `alloy:"statements,attr,optional"`
+	DropConditions []string       `alloy:"drop_conditions,attr,optional"`
+	ErrorMode      ottl.ErrorMode `alloy:"error_mode,attr,optional"`
+	DropReason     string         `alloy:"drop_counter_reason,attr,optional"`
+}
+
+// DefaultExprConfig sets the defaults.
+var DefaultExprConfig = ExprConfig{
+	ErrorMode:  ottl.IgnoreError,
+	DropReason: defaultExprDropReason,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (c *ExprConfig) SetToDefault() {
+	*c = DefaultExprConfig
+}
+
+// exprStage runs OTTL statements against log entries, and drops the entries
+// matching OTTL conditions.
+type exprStage struct {
+	logger         log.Logger
+	cfg            ExprConfig
+	statements     ottl.StatementSequence[*Entry]
+	dropConditions ottl.ConditionSequence[*Entry]
+	dropCount      *prometheus.CounterVec
+}
+
+func newExprStage(logger log.Logger, cfg ExprConfig, registerer prometheus.Registerer) (Stage, error) {
+	if len(cfg.Statements) == 0 && len(cfg.DropConditions) == 0 {
+		return nil, ErrEmptyExprStageConfig
+	}
+	if cfg.DropReason == "" {
+		cfg.DropReason = defaultExprDropReason
+	}
+
+	logger = log.With(logger, "component", "stage", "type", "expr")
+
+	// OTTL logs every entry it evaluates at the debug level, so debug logs are
+	// only forwarded when debugging is enabled.
+	zapLogger := zapadapter.New(logger)
+	if !Debug {
+		zapLogger = zapLogger.WithOptions(zap.IncreaseLevel(zap.InfoLevel))
+	}
+	settings := component.TelemetrySettings{Logger: zapLogger}
+
+	parser, err := ottl.NewParser(ottlfuncs.StandardFuncs[*Entry](), parseExprPath, settings)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := parser.ParseStatements(cfg.Statements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse statements: %w", err)
+	}
+	conditions, err := parser.ParseConditions(cfg.DropConditions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse drop_conditions: %w", err)
+	}
+
+	return &exprStage{
+		logger:         logger,
+		cfg:            cfg,
+		statements:     ottl.NewStatementSequence(statements, settings, ottl.WithStatementSequenceErrorMode[*Entry](cfg.ErrorMode)),
+		dropConditions: ottl.NewConditionSequence(conditions, settings, ottl.WithConditionSequenceErrorMode[*Entry](cfg.ErrorMode)),
+		dropCount:      getDropCountMetric(registerer),
+	}, nil
+}
+
+// Run implements Stage
+func (s *exprStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			if reason, drop := s.process(&e); drop {
+				s.dropCount.WithLabelValues(reason).Inc()
+				continue
+			}
+			out <- e
+		}
+	}()
+	return out
+}
+
+// process runs the statements against e and then evaluates the drop
+// conditions. It returns whether e must be dropped, and why. Errors are only
+// returned by OTTL when error_mode is propagate, in which case the entry is
+// dropped.
+func (s *exprStage) process(e *Entry) (string, bool) {
+	ctx := context.Background()
+
+	if len(s.cfg.Statements) > 0 {
+		if err := s.statements.Execute(ctx, e); err != nil {
+			if Debug {
+				level.Debug(s.logger).Log("msg", "dropping entry after failing to execute statements", "err", err)
+			}
+			return exprErrorDropReason, true
+		}
+	}
+
+	if len(s.cfg.DropConditions) > 0 {
+		drop, err := s.dropConditions.Eval(ctx, e)
+		if err != nil {
+			if Debug {
+				level.Debug(s.logger).Log("msg", "dropping entry after failing to evaluate drop_conditions", "err", err)
+			}
+			return exprErrorDropReason, true
+		}
+		if drop {
+			return s.cfg.DropReason, true
+		}
+	}
+	return "", false
+}
+
+// Name implements Stage
+func (s *exprStage) Name() string {
+	return StageTypeExpr
+}
+
+// Cleanup implements Stage.
+func (*exprStage) Cleanup() {
+	// no-op
+}
+
+// parseExprPath returns the accessor of an OTTL path. The supported paths are
+// line, timestamp, and labels, structured_metadata and extracted, which can
+// be indexed with a single key.
+func parseExprPath(path ottl.Path[*Entry]) (ottl.GetSetter[*Entry], error) {
+	if path == nil {
+		return nil, errors.New("path cannot be nil")
+	}
+	if next := path.Next(); next != nil {
+		return nil, fmt.Errorf("path %q has more than one segment", path.String())
+	}
+
+	keys := path.Keys()
+	if len(keys) > 1 {
+		return nil, fmt.Errorf("path %q can only be indexed with a single key", path.String())
+	}
+	var key ottl.Key[*Entry]
+	if len(keys) == 1 {
+		key = keys[0]
+	}
+
+	switch path.Name() {
+	case "line":
+		if key != nil {
+			return nil, fmt.Errorf("path %q can't be indexed", path.String())
+		}
+		return accessLine(), nil
+	case "timestamp":
+		if key != nil {
+			return nil, fmt.Errorf("path %q can't be indexed", path.String())
+		}
+		return accessTimestamp(), nil
+	case "labels":
+		if key != nil {
+			return accessLabelsKey(key), nil
+		}
+		return accessLabels(), nil
+	case "structured_metadata":
+		if key != nil {
+			return accessStructuredMetadataKey(key), nil
+		}
+		return accessStructuredMetadata(), nil
+	case "extracted":
+		if key != nil {
+			return accessExtractedKey(key), nil
+		}
+		return accessExtracted(), nil
+	default:
+		return nil, fmt.Errorf("unknown path %q, expected one of line, timestamp, labels, structured_metadata or extracted", path.String())
+	}
+}
+
+// exprKeyString returns the value of a key, which must be a string.
+func exprKeyString(ctx context.Context, e *Entry, key ottl.Key[*Entry]) (string, error) {
+	s, err := key.String(ctx, e)
+	if err != nil {
+		return "", err
+	}
+	if s != nil {
+		return *s, nil
+	}
+
+	getter, err := key.ExpressionGetter(ctx, e)
+	if err != nil {
+		return "", err
+	}
+	if getter != nil {
+		v, err := getter.Get(ctx, e)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	}
+	return "", errors.New("keys must be strings")
+}
+
+func accessLine() ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(_ context.Context, e *Entry) (any, error) {
+			return e.Line, nil
+		},
+		Setter: func(_ context.Context, e *Entry, val any) error {
+			line, err := getString(val)
+			if err != nil {
+				return err
+			}
+			e.Line = line
+			return nil
+		},
+	}
+}
+
+func accessTimestamp() ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(_ context.Context, e *Entry) (any, error) {
+			return e.Timestamp, nil
+		},
+		Setter: func(_ context.Context, e *Entry, val any) error {
+			ts, ok := val.(time.Time)
+			if !ok {
+				return fmt.Errorf("can't set timestamp to %v of type %T", val, val)
+			}
+			e.Timestamp = ts
+			return nil
+		},
+	}
+}
+
+func accessLabels() ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(_ context.Context, e *Entry) (any, error) {
+			m := pcommon.NewMap()
+			m.EnsureCapacity(len(e.Labels))
+			for name, value := range e.Labels {
+				m.PutStr(string(name), string(value))
+			}
+			return m, nil
+		},
+		Setter: func(_ context.Context, e *Entry, val any) error {
+			m, ok := val.(pcommon.Map)
+			if !ok {
+				return fmt.Errorf("can't set labels to %v of type %T", val, val)
+			}
+			labels := make(model.LabelSet, m.Len())
+			for name, value := range m.All() {
+				if err := setExprLabel(labels, name, value.AsString()); err != nil {
+					return err
+				}
+			}
+			clear(e.Labels)
+			for name, value := range labels {
+				e.Labels[name] = value
+			}
+			return nil
+		},
+	}
+}
+
+func accessLabelsKey(key ottl.Key[*Entry]) ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(ctx context.Context, e *Entry) (any, error) {
+			name, err := exprKeyString(ctx, e, key)
+			if err != nil {
+				return nil, err
+			}
+			value, ok := e.Labels[model.LabelName(name)]
+			if !ok {
+				return nil, nil
+			}
+			return string(value), nil
+		},
+		Setter: func(ctx context.Context, e *Entry, val any) error {
+			name, err := exprKeyString(ctx, e, key)
+			if err != nil {
+				return err
+			}
+			value, err := getString(val)
+			if err != nil {
+				return err
+			}
+			return setExprLabel(e.Labels, name, value)
+		},
+	}
+}
+
+func setExprLabel(labels model.LabelSet, name, value string) error {
+	labelName, labelValue := model.LabelName(name), model.LabelValue(value)
+	if !labelName.IsValid() {
+		return fmt.Errorf("invalid label name %q", name)
+	}
+	if !labelValue.IsValid() {
+		return fmt.Errorf("invalid value %q for label %q", value, name)
+	}
+	labels[labelName] = labelValue
+	return nil
+}
+
+func accessStructuredMetadata() ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(_ context.Context, e *Entry) (any, error) {
+			m := pcommon.NewMap()
+			m.EnsureCapacity(len(e.StructuredMetadata))
+			for _, l := range e.StructuredMetadata {
+				m.PutStr(l.Name, l.Value)
+			}
+			return m, nil
+		},
+		Setter: func(_ context.Context, e *Entry, val any) error {
+			m, ok := val.(pcommon.Map)
+			if !ok {
+				return fmt.Errorf("can't set structured_metadata to %v of type %T", val, val)
+			}
+			metadata := make(push.LabelsAdapter, 0, m.Len())
+			for name, value := range m.All() {
+				metadata = append(metadata, push.LabelAdapter{Name: name, Value: value.AsString()})
+			}
+			e.StructuredMetadata = metadata
+			return nil
+		},
+	}
+}
+
+func accessStructuredMetadataKey(key ottl.Key[*Entry]) ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(ctx context.Context, e *Entry) (any, error) {
+			name, err := exprKeyString(ctx, e, key)
+			if err != nil {
+				return nil, err
+			}
+			i := slices.IndexFunc(e.StructuredMetadata, func(l push.LabelAdapter) bool { return l.Name == name })
+			if i < 0 {
+				return nil, nil
+			}
+			return e.StructuredMetadata[i].Value, nil
+		},
+		Setter: func(ctx context.Context, e *Entry, val any) error {
+			name, err := exprKeyString(ctx, e, key)
+			if err != nil {
+				return err
+			}
+			value, err := getString(val)
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(e.StructuredMetadata, func(l push.LabelAdapter) bool { return l.Name == name })
+			if i >= 0 {
+				e.StructuredMetadata[i].Value = value
+				return nil
+			}
+			e.StructuredMetadata = append(e.StructuredMetadata, push.LabelAdapter{Name: name, Value: value})
+			return nil
+		},
+	}
+}
+
+func accessExtracted() ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(_ context.Context, e *Entry) (any, error) {
+			m := pcommon.NewMap()
+			if err := m.FromRaw(e.Extracted); err != nil {
+				return nil, err
+			}
+			return m, nil
+		},
+		Setter: func(_ context.Context, e *Entry, val any) error {
+			m, ok := val.(pcommon.Map)
+			if !ok {
+				return fmt.Errorf("can't set extracted to %v of type %T", val, val)
+			}
+			clear(e.Extracted)
+			for k, v := range m.AsRaw() {
+				e.Extracted[k] = v
+			}
+			return nil
+		},
+	}
+}
+
+func accessExtractedKey(key ottl.Key[*Entry]) ottl.StandardGetSetter[*Entry] {
+	return ottl.StandardGetSetter[*Entry]{
+		Getter: func(ctx context.Context, e *Entry) (any, error) {
+			name, err := exprKeyString(ctx, e, key)
+			if err != nil {
+				return nil, err
+			}
+			return e.Extracted[name], nil
+		},
+		Setter: func(ctx context.Context, e *Entry, val any) error {
+			name, err := exprKeyString(ctx, e, key)
+			if err != nil {
+				return err
+			}
+			switch v := val.(type) {
+			case pcommon.Map:
+				val = v.AsRaw()
+			case pcommon.Slice:
+				val = v.AsRaw()
+			case pcommon.Value:
+				val = v.AsRaw()
+			}
+			e.Extracted[name] = val
+			return nil
+		},
+	}
+}
@@ -0,0 +1,180 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/util"
+)
+
+var testExprAlloy = `
+stage.logfmt {
+    mapping = { "level" = "", "user" = "", "duration" = "" }
+}
+
+stage.expr {
+    statements = [
+        ` + "`" + `set(labels["level"], extracted["level"])` + "`" + `,
+        ` + "`" + `set(structured_metadata["user"], extracted["user"]) where extracted["user"] != nil` + "`" + `,
+        ` + "`" + `set(line, Concat([line, "slow=true"], " ")) where Int(extracted["duration"]) > 1000` + "`" + `,
+    ]
+    drop_conditions = [
+        ` + "`" + `extracted["level"] == "debug" and labels["namespace"] != "prod"` + "`" + `,
+    ]
+}`
+
+func TestPipeline_Expr(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	pl, err := NewPipeline(util.TestAlloyLogger(t), loadConfig(testExprAlloy), nil, registry, featuregate.StabilityGenerallyAvailable)
+	require.NoError(t, err)
+
+	out := processEntries(pl,
+		newEntry(nil, model.LabelSet{"namespace": "dev"}, "level=debug user=john duration=10", time.Now()),
+		newEntry(nil, model.LabelSet{"namespace": "prod"}, "level=debug user=john duration=10", time.Now()),
+		newEntry(nil, model.LabelSet{"namespace": "dev"}, "level=info duration=1500", time.Now()),
+	)
+	require.Len(t, out, 2)
+
+	require.Equal(t, model.LabelSet{"namespace": "prod", "level": "debug"}, out[0].Labels)
+	require.Equal(t, push.LabelsAdapter{{Name: "user", Value: "john"}}, out[0].StructuredMetadata)
+	require.Equal(t, "level=debug user=john duration=10", out[0].Line)
+
+	require.Equal(t, model.LabelSet{"namespace": "dev", "level": "info"}, out[1].Labels)
+	require.Empty(t, out[1].StructuredMetadata)
+	require.Equal(t, "level=info duration=1500 slow=true", out[1].Line)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(defaultExprDropReason)))
+}
+
+func TestExprStage_Paths(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		statements []string
+		expected   Entry
+	}{
+		"delete label from map": {
+			statements: []string{`delete_key(labels, "app")`},
+			expected:   newEntry(map[string]any{"count": 3.0}, model.LabelSet{}, "line", ts),
+		},
+		"set label from number": {
+			statements: []string{`set(labels["count"], extracted["count"])`},
+			expected:   newEntry(map[string]any{"count": 3.0}, model.LabelSet{"app": "api", "count": "3"}, "line", ts),
+		},
+		"set extracted": {
+			statements: []string{`set(extracted["app"], labels["app"])`, `delete_key(extracted, "count")`},
+			expected:   newEntry(map[string]any{"app": "api"}, model.LabelSet{"app": "api"}, "line", ts),
+		},
+		"set extracted map": {
+			statements: []string{`set(extracted["parsed"], ParseJSON("{\"a\": 1}"))`},
+			expected:   newEntry(map[string]any{"count": 3.0, "parsed": map[string]any{"a": 1.0}}, model.LabelSet{"app": "api"}, "line", ts),
+		},
+		"set timestamp": {
+			statements: []string{`set(timestamp, Time("2025-01-01", "%Y-%m-%d"))`},
+			expected:   newEntry(map[string]any{"count": 3.0}, model.LabelSet{"app": "api"}, "line", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+		"keep structured metadata keys": {
+			statements: []string{`set(structured_metadata["a"], "1")`, `set(structured_metadata["b"], "2")`, `keep_keys(structured_metadata, ["b"])`},
+			expected: func() Entry {
+				e := newEntry(map[string]any{"count": 3.0}, model.LabelSet{"app": "api"}, "line", ts)
+				e.StructuredMetadata = push.LabelsAdapter{{Name: "b", Value: "2"}}
+				return e
+			}(),
+		},
+		"invalid label name is ignored": {
+			statements: []string{`set(labels[""], "value")`},
+			expected:   newEntry(map[string]any{"count": 3.0}, model.LabelSet{"app": "api"}, "line", ts),
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultExprConfig
+			cfg.Statements = tt.statements
+			s, err := newExprStage(util.TestAlloyLogger(t), cfg, prometheus.NewRegistry())
+			require.NoError(t, err)
+
+			out := processEntries(s, newEntry(map[string]any{"count": 3.0}, model.LabelSet{"app": "api"}, "line", ts))
+			require.Len(t, out, 1)
+			require.Equal(t, tt.expected.Labels, out[0].Labels)
+			require.Equal(t, tt.expected.Extracted, out[0].Extracted)
+			require.Equal(t, tt.expected.StructuredMetadata, out[0].StructuredMetadata)
+			require.Equal(t, tt.expected.Line, out[0].Line)
+			require.True(t, tt.expected.Timestamp.Equal(out[0].Timestamp))
+		})
+	}
+}
+
+func TestExprStage_ErrorMode(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	cfg := DefaultExprConfig
+	cfg.Statements = []string{`set(labels[""], "value")`}
+	cfg.ErrorMode = ottl.PropagateError
+	s, err := newExprStage(util.TestAlloyLogger(t), cfg, registry)
+	require.NoError(t, err)
+
+	out := processEntries(s, newEntry(nil, model.LabelSet{}, "line", time.Now()))
+	require.Empty(t, out)
+	require.Equal(t, 1.0, testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(exprErrorDropReason)))
+}
+
+func TestExprConfig_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config      ExprConfig
+		expectedErr string
+	}{
+		"empty config": {
+			config:      DefaultExprConfig,
+			expectedErr: ErrEmptyExprStageConfig.Error(),
+		},
+		"unknown path": {
+			config:      ExprConfig{DropConditions: []string{`attributes["level"] == "debug"`}},
+			expectedErr: `unknown path "attributes[level]"`,
+		},
+		"too many keys": {
+			config:      ExprConfig{DropConditions: []string{`labels["a"]["b"] == "debug"`}},
+			expectedErr: "can only be indexed with a single key",
+		},
+		"indexed line": {
+			config:      ExprConfig{DropConditions: []string{`line["a"] == "debug"`}},
+			expectedErr: `path "line[a]" can't be indexed`,
+		},
+		"unknown function": {
+			config:      ExprConfig{Statements: []string{`unknown(line)`}},
+			expectedErr: "failed to parse statements",
+		},
+		"valid": {
+			config: ExprConfig{
+				Statements:     []string{`set(extracted["a"], "b")`},
+				DropConditions: []string{`IsMatch(line, "^debug")`},
+			},
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			_, err := newExprStage(util.TestAlloyLogger(t), tt.config, prometheus.NewRegistry())
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package stages
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/pkg/push"
+	json "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+var (
+	ErrEmptyLookupStageSource     = errors.New("source cannot be empty")
+	ErrEmptyLookupStageKeyColumn  = errors.New("key_column cannot be empty")
+	ErrLookupStagePathOrContent   = errors.New("exactly one of path or content must be set")
+	ErrUnknownLookupStageFormat   = errors.New("format must be either csv or json")
+	ErrInvalidLookupStageInterval = errors.New("refresh_interval must be greater than 0")
+)
+
+const (
+	LookupFormatCSV  = "csv"
+	LookupFormatJSON = "json"
+)
+
+// LookupConfig represents a lookup stage configuration
+type LookupConfig struct {
+	Source             string        `alloy:"source,attr"`
+	KeyColumn          string        `alloy:"key_column,attr"`
+	Path               string        `alloy:"path,attr,optional"`
+	Content            string        `alloy:"content,attr,optional"`
+	Format             string        `alloy:"format,attr,optional"`
+	Labels             []string      `alloy:"labels,attr,optional"`
+	StructuredMetadata []string      `alloy:"structured_metadata,attr,optional"`
+	RefreshInterval    time.Duration `alloy:"refresh_interval,attr,optional"`
+}
+
+// DefaultLookupConfig sets the defaults.
+var DefaultLookupConfig = LookupConfig{
+	Format:          LookupFormatCSV,
+	RefreshInterval: time.Minute,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (c *LookupConfig) SetToDefault() {
+	*c = DefaultLookupConfig
+}
+
+func validateLookupConfig(c LookupConfig) error {
+	if c.Source == "" {
+		return ErrEmptyLookupStageSource
+	}
+	if c.KeyColumn == "" {
+		return ErrEmptyLookupStageKeyColumn
+	}
+	if (c.Path == "") == (c.Content == "") {
+		return ErrLookupStagePathOrContent
+	}
+	switch c.Format {
+	case LookupFormatCSV, LookupFormatJSON:
+	default:
+		return ErrUnknownLookupStageFormat
+	}
+	if c.Path != "" && c.RefreshInterval <= 0 {
+		return ErrInvalidLookupStageInterval
+	}
+	return nil
+}
+
+// lookupTable maps the values of the key column to the other columns of
+// their row.
+type lookupTable map[string]map[string]string
+
+func newLookupStage(logger log.Logger, config LookupConfig) (Stage, error) {
+	if err := validateLookupConfig(config); err != nil {
+		return nil, err
+	}
+
+	s := &lookupStage{
+		logger: log.With(logger, "component", "stage", "type", "lookup"),
+		cfg:    config,
+	}
+
+	var (
+		table lookupTable
+		err   error
+	)
+	if config.Path != "" {
+		table, s.fileState, err = s.loadFile()
+	} else {
+		table, err = parseLookupTable(strings.NewReader(config.Content), config.Format, config.KeyColumn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lookup table: %w", err)
+	}
+	s.table.Store(&table)
+
+	return s, nil
+}
+
+type lookupStage struct {
+	logger log.Logger
+	cfg    LookupConfig
+	table  atomic.Pointer[lookupTable]
+
+	// fileState identifies the version of the file the table was loaded from.
+	fileState lookupFileState
+}
+
+type lookupFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Run implements Stage
+func (l *lookupStage) Run(in chan Entry) chan Entry {
+	if l.cfg.Path == "" {
+		return RunWith(in, l.process)
+	}
+
+	done := make(chan struct{})
+	go l.reloadLoop(done)
+
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		defer close(done)
+		for e := range in {
+			out <- l.process(e)
+		}
+	}()
+	return out
+}
+
+// reloadLoop reloads the table when the file it was loaded from changes,
+// until done is closed. Entries are processed with the previous table until
+// the new one is loaded.
+func (l *lookupStage) reloadLoop(done chan struct{}) {
+	ticker := time.NewTicker(l.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(l.cfg.Path)
+			if err != nil {
+				level.Error(l.logger).Log("msg", "failed to check lookup table file, keeping the previous table", "path", l.cfg.Path, "err", err)
+				continue
+			}
+			if (lookupFileState{modTime: fi.ModTime(), size: fi.Size()}) == l.fileState {
+				continue
+			}
+
+			table, state, err := l.loadFile()
+			if err != nil {
+				level.Error(l.logger).Log("msg", "failed to reload lookup table, keeping the previous table", "path", l.cfg.Path, "err", err)
+				continue
+			}
+			l.table.Store(&table)
+			l.fileState = state
+			level.Info(l.logger).Log("msg", "reloaded lookup table", "path", l.cfg.Path, "rows", len(table))
+		}
+	}
+}
+
+func (l *lookupStage) loadFile() (lookupTable, lookupFileState, error) {
+	f, err := os.Open(l.cfg.Path)
+	if err != nil {
+		return nil, lookupFileState{}, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, lookupFileState{}, err
+	}
+	table, err := parseLookupTable(f, l.cfg.Format, l.cfg.KeyColumn)
+	if err != nil {
+		return nil, lookupFileState{}, err
+	}
+	return table, lookupFileState{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+func (l *lookupStage) process(e Entry) Entry {
+	value, ok := e.Extracted[l.cfg.Source]
+	if !ok {
+		if Debug {
+			level.Debug(l.logger).Log("msg", "source does not exist in the set of extracted values", "source", l.cfg.Source)
+		}
+		return e
+	}
+	key, err := getString(value)
+	if err != nil {
+		if Debug {
+			level.Debug(l.logger).Log("msg", "failed to convert source value to string", "source", l.cfg.Source, "err", err, "type", reflect.TypeOf(value))
+		}
+		return e
+	}
+
+	row, ok := (*l.table.Load())[key]
+	if !ok {
+		if Debug {
+			level.Debug(l.logger).Log("msg", "key not found in lookup table", "key", key)
+		}
+		return e
+	}
+
+	for column, value := range row {
+		e.Extracted[column] = value
+	}
+	for _, column := range l.cfg.Labels {
+		value, ok := row[column]
+		if !ok {
+			continue
+		}
+		labelName, labelValue := model.LabelName(column), model.LabelValue(value)
+		if !labelName.IsValid() || !labelValue.IsValid() {
+			if Debug {
+				level.Debug(l.logger).Log("msg", "invalid label from lookup table", "column", column, "value", value)
+			}
+			continue
+		}
+		e.Labels[labelName] = labelValue
+	}
+	for _, column := range l.cfg.StructuredMetadata {
+		if value, ok := row[column]; ok {
+			e.StructuredMetadata = append(e.StructuredMetadata, push.LabelAdapter{Name: column, Value: value})
+		}
+	}
+	return e
+}
+
+// parseLookupTable reads a table in the given format and indexes its rows by
+// keyColumn. CSV tables must start with a header row holding the names of
+// the columns. JSON tables must be arrays of objects. Rows without a value
+// for keyColumn are ignored, and later rows replace earlier rows with the
+// same key.
+func parseLookupTable(r io.Reader, format, keyColumn string) (lookupTable, error) {
+	var rows []map[string]string
+	switch format {
+	case LookupFormatCSV:
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.New("missing header row")
+		}
+		header := records[0]
+		if !slices.Contains(header, keyColumn) {
+			return nil, fmt.Errorf("key column %q not found in header row", keyColumn)
+		}
+		for _, record := range records[1:] {
+			row := make(map[string]string, len(header))
+			for i, column := range header {
+				row[column] = record[i]
+			}
+			rows = append(rows, row)
+		}
+
+	case LookupFormatJSON:
+		var objects []map[string]any
+		if err := json.NewDecoder(r).Decode(&objects); err != nil {
+			return nil, err
+		}
+		for i, object := range objects {
+			row := make(map[string]string, len(object))
+			for column, value := range object {
+				s, err := getString(value)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: column %q: %w", i, column, err)
+				}
+				row[column] = s
+			}
+			rows = append(rows, row)
+		}
+
+	default:
+		return nil, ErrUnknownLookupStageFormat
+	}
+
+	table := make(lookupTable, len(rows))
+	for _, row := range rows {
+		key, ok := row[keyColumn]
+		if !ok || key == "" {
+			continue
+		}
+		delete(row, keyColumn)
+		table[key] = row
+	}
+	return table, nil
+}
+
+// Name implements Stage
+func (l *lookupStage) Name() string {
+	return StageTypeLookup
+}
+
+// Cleanup implements Stage.
+func (*lookupStage) Cleanup() {
+	// no-op
+}
@@ -0,0 +1,187 @@
+package stages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/util"
+)
+
+var testLookupAlloyCSV = `
+stage.regex {
+    expression = "^user=(?P<user>\\S+)"
+}
+
+stage.lookup {
+    source              = "user"
+    key_column          = "user"
+    content             = "user,team,tier\njohn,payments,gold\nmary,search,silver\n"
+    labels              = ["team"]
+    structured_metadata = ["tier"]
+}`
+
+func TestPipeline_Lookup(t *testing.T) {
+	t.Parallel()
+
+	pl, err := NewPipeline(util.TestAlloyLogger(t), loadConfig(testLookupAlloyCSV), nil, prometheus.DefaultRegisterer, featuregate.StabilityGenerallyAvailable)
+	require.NoError(t, err)
+
+	out := processEntries(pl,
+		newEntry(nil, model.LabelSet{"app": "api"}, "user=john msg=hello", time.Now()),
+		newEntry(nil, model.LabelSet{"app": "api"}, "user=jane msg=hello", time.Now()),
+	)
+	require.Len(t, out, 2)
+
+	require.Equal(t, model.LabelSet{"app": "api", "team": "payments"}, out[0].Labels)
+	require.Equal(t, push.LabelsAdapter{{Name: "tier", Value: "gold"}}, out[0].StructuredMetadata)
+	require.Equal(t, "payments", out[0].Extracted["team"])
+	require.Equal(t, "gold", out[0].Extracted["tier"])
+
+	require.Equal(t, model.LabelSet{"app": "api"}, out[1].Labels)
+	require.Empty(t, out[1].StructuredMetadata)
+	require.NotContains(t, out[1].Extracted, "team")
+}
+
+func TestLookupConfig_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config LookupConfig
+		err    error
+	}{
+		"empty source": {
+			LookupConfig{KeyColumn: "user", Content: "user", Format: LookupFormatCSV},
+			ErrEmptyLookupStageSource,
+		},
+		"empty key column": {
+			LookupConfig{Source: "user", Content: "user", Format: LookupFormatCSV},
+			ErrEmptyLookupStageKeyColumn,
+		},
+		"no path or content": {
+			LookupConfig{Source: "user", KeyColumn: "user", Format: LookupFormatCSV},
+			ErrLookupStagePathOrContent,
+		},
+		"path and content": {
+			LookupConfig{Source: "user", KeyColumn: "user", Path: "users.csv", Content: "user", Format: LookupFormatCSV, RefreshInterval: time.Minute},
+			ErrLookupStagePathOrContent,
+		},
+		"unknown format": {
+			LookupConfig{Source: "user", KeyColumn: "user", Content: "user", Format: "yaml"},
+			ErrUnknownLookupStageFormat,
+		},
+		"invalid refresh interval": {
+			LookupConfig{Source: "user", KeyColumn: "user", Path: "users.csv", Format: LookupFormatCSV},
+			ErrInvalidLookupStageInterval,
+		},
+		"valid": {
+			LookupConfig{Source: "user", KeyColumn: "user", Content: "user", Format: LookupFormatJSON},
+			nil,
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.err, validateLookupConfig(tt.config))
+		})
+	}
+}
+
+func TestParseLookupTable(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content     string
+		format      string
+		expected    lookupTable
+		expectedErr string
+	}{
+		"csv": {
+			content: "team,user\npayments,john\nsearch,\nsearch,mary\ncore,john\n",
+			format:  LookupFormatCSV,
+			expected: lookupTable{
+				"john": {"team": "core"},
+				"mary": {"team": "search"},
+			},
+		},
+		"csv without key column": {
+			content:     "team,name\npayments,john\n",
+			format:      LookupFormatCSV,
+			expectedErr: `key column "user" not found in header row`,
+		},
+		"empty csv": {
+			content:     "",
+			format:      LookupFormatCSV,
+			expectedErr: "missing header row",
+		},
+		"json": {
+			content: `[{"user": "john", "team": "payments", "id": 7}, {"team": "search"}]`,
+			format:  LookupFormatJSON,
+			expected: lookupTable{
+				"john": {"team": "payments", "id": "7"},
+			},
+		},
+		"json with nested value": {
+			content:     `[{"user": "john", "team": {"name": "payments"}}]`,
+			format:      LookupFormatJSON,
+			expectedErr: `row 0: column "team": can't convert map[name:payments] to string`,
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			table, err := parseLookupTable(strings.NewReader(tt.content), tt.format, "user")
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, table)
+		})
+	}
+}
+
+func TestLookupStage_Reload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "users.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"user": "john", "team": "payments"}]`), 0644))
+
+	s, err := newLookupStage(util.TestAlloyLogger(t), LookupConfig{
+		Source:          "user",
+		KeyColumn:       "user",
+		Path:            path,
+		Format:          LookupFormatJSON,
+		Labels:          []string{"team"},
+		RefreshInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	in := make(chan Entry)
+	out := s.Run(in)
+	defer close(in)
+
+	process := func() model.LabelSet {
+		in <- newEntry(map[string]any{"user": "john"}, model.LabelSet{}, "", time.Now())
+		return (<-out).Labels
+	}
+	require.Equal(t, model.LabelSet{"team": "payments"}, process())
+
+	// An invalid table is ignored.
+	require.NoError(t, os.WriteFile(path, []byte(`[{"user": `), 0644))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, model.LabelSet{"team": "payments"}, process())
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"user": "john", "team": "search"}]`), 0644))
+	require.Eventually(t, func() bool {
+		return process()["team"] == "search"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -16,10 +16,12 @@ import (
 // exactly one is set.
 type StageConfig struct {
 	CRIConfig                    *CRIConfig                    `alloy:"cri,block,optional"`
+	CSVConfig                    *CSVConfig                    `alloy:"csv,block,optional"`
 	DecolorizeConfig             *DecolorizeConfig             `alloy:"decolorize,block,optional"`
 	DockerConfig                 *DockerConfig                 `alloy:"docker,block,optional"`
 	DropConfig                   *DropConfig                   `alloy:"drop,block,optional"`
 	EventLogMessageConfig        *EventLogMessageConfig        `alloy:"eventlogmessage,block,optional"`
+	ExprConfig                   *ExprConfig                   `alloy:"expr,block,optional"`
 	GeoIPConfig                  *GeoIPConfig                  `alloy:"geoip,block,optional"`
 	JSONConfig                   *JSONConfig                   `alloy:"json,block,optional"`
 	LabelAllowConfig             *LabelAllowConfig             `alloy:"label_keep,block,optional"`
@@ -27,6 +29,7 @@ type StageConfig struct {
 	LabelsConfig                 *LabelsConfig                 `alloy:"labels,block,optional"`
 	LimitConfig                  *LimitConfig                  `alloy:"limit,block,optional"`
 	LogfmtConfig                 *LogfmtConfig                 `alloy:"logfmt,block,optional"`
+	LookupConfig                 *LookupConfig                 `alloy:"lookup,block,optional"`
 	LuhnFilterConfig             *LuhnFilterConfig             `alloy:"luhn,block,optional"`
 	MatchConfig                  *MatchConfig                  `alloy:"match,block,optional"`
 	MetricsConfig                *MetricsConfig                `alloy:"metrics,block,optional"`
@@ -44,7 +47,9 @@ type StageConfig struct {
 	TenantConfig                 *TenantConfig                 `alloy:"tenant,block,optional"`
 	TruncateConfig               *TruncateConfig               `alloy:"truncate,block,optional"`
 	TimestampConfig              *TimestampConfig              `alloy:"timestamp,block,optional"`
+	UnpackConfig                 *UnpackConfig                 `alloy:"unpack,block,optional"`
 	WindowsEventConfig           *WindowsEventConfig           `alloy:"windowsevent,block,optional"`
+	XMLConfig                    *XMLConfig                    `alloy:"xml,block,optional"`
 }
 
 // Pipeline pass down a log entry to each stage for mutation and/or label extraction.
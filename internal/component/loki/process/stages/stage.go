@@ -14,11 +14,13 @@ import (
 // TODO(@tpaschalis) Let's use this as the list of stages we need to port over.
 const (
 	StageTypeCRI        = "cri"
+	StageTypeCSV        = "csv"
 	StageTypeDecolorize = "decolorize"
 	StageTypeDocker     = "docker"
 	StageTypeDrop       = "drop"
 	//TODO(thampiotr): Add support for eventlogmessage stage
 	StageTypeEventLogMessage        = "eventlogmessage"
+	StageTypeExpr                   = "expr"
 	StageTypeGeoIP                  = "geoip"
 	StageTypeJSON                   = "json"
 	StageTypeLabel                  = "labels"
@@ -26,6 +28,7 @@ const (
 	StageTypeLabelDrop              = "labeldrop"
 	StageTypeLimit                  = "limit"
 	StageTypeLogfmt                 = "logfmt"
+	StageTypeLookup                 = "lookup"
 	StageTypeLuhn                   = "luhn"
 	StageTypeMatch                  = "match"
 	StageTypeMetric                 = "metrics"
@@ -44,7 +47,9 @@ const (
 	StageTypeTenant                 = "tenant"
 	StageTypeTimestamp              = "timestamp"
 	StageTypeTruncate               = "truncate"
+	StageTypeUnpack                 = "unpack"
 	StageTypeWindowsEvent           = "windowsevent"
+	StageTypeXML                    = "xml"
 )
 
 // Processor takes an existing set of labels, timestamp and log entry and returns either a possibly mutated
@@ -229,6 +234,28 @@ func New(logger log.Logger, jobName *string, cfg StageConfig, registerer prometh
 		if err != nil {
 			return nil, err
 		}
+	case cfg.UnpackConfig != nil:
+		s = newUnpackStage(logger, *cfg.UnpackConfig)
+	case cfg.CSVConfig != nil:
+		s, err = newCSVStage(logger, *cfg.CSVConfig, registerer)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.ExprConfig != nil:
+		s, err = newExprStage(logger, *cfg.ExprConfig, registerer)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.LookupConfig != nil:
+		s, err = newLookupStage(logger, *cfg.LookupConfig)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.XMLConfig != nil:
+		s, err = newXMLStage(logger, *cfg.XMLConfig, registerer)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		panic(fmt.Sprintf("unreachable; should have decoded into one of the StageConfig fields: %+v", cfg))
 	}
@@ -0,0 +1,96 @@
+package stages
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/loki/pkg/push"
+	json "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+// UnpackConfig contains the configuration for an unpackStage
+type UnpackConfig struct {
+	StructuredMetadata []string `alloy:"structured_metadata,attr,optional"`
+}
+
+// newUnpackStage creates an unpackStage from config
+func newUnpackStage(logger log.Logger, config UnpackConfig) Stage {
+	return &unpackStage{
+		logger: log.With(logger, "component", "stage", "type", "unpack"),
+		cfg:    &config,
+	}
+}
+
+// unpackStage restores the log line and labels of log lines packed by a packStage
+type unpackStage struct {
+	logger log.Logger
+	cfg    *UnpackConfig
+}
+
+func (m *unpackStage) Run(in chan Entry) chan Entry {
+	return RunWith(in, m.unpack)
+}
+
+func (m *unpackStage) unpack(e Entry) Entry {
+	// Packed lines are JSON objects, skip anything else without trying to parse it
+	if len(e.Line) == 0 || e.Line[0] != '{' {
+		return e
+	}
+
+	if json.Get([]byte(e.Line), packedEntryKey).ValueType() != json.StringValue {
+		if Debug {
+			level.Debug(m.logger).Log("msg", "line has no string '"+packedEntryKey+"' key and won't be unpacked")
+		}
+		return e
+	}
+	var packed Packed
+	if err := json.UnmarshalFromString(e.Line, &packed); err != nil {
+		if Debug {
+			level.Debug(m.logger).Log("msg", "line isn't a packed line and won't be unpacked", "err", err)
+		}
+		return e
+	}
+
+	if e.Labels == nil {
+		e.Labels = model.LabelSet{}
+	}
+	if e.Extracted == nil {
+		e.Extracted = map[string]any{}
+	}
+	// Restore the labels in order, so that structured metadata is added in a
+	// deterministic order.
+	for _, k := range slices.Sorted(maps.Keys(packed.Labels)) {
+		v := packed.Labels[k]
+		// Make the restored values available to later stages, even if they
+		// can't be used as labels.
+		e.Extracted[k] = v
+
+		labelName, labelValue := model.LabelName(k), model.LabelValue(v)
+		if !labelName.IsValid() || !labelValue.IsValid() {
+			level.Debug(m.logger).Log("msg", "invalid label unpacked, it is kept in the extracted map only", "label", k)
+			continue
+		}
+		if slices.Contains(m.cfg.StructuredMetadata, k) {
+			e.StructuredMetadata = append(e.StructuredMetadata, push.LabelAdapter{Name: k, Value: v})
+			continue
+		}
+		e.Labels[labelName] = labelValue
+	}
+	e.Line = packed.Entry
+
+	return e
+}
+
+// Name implements Stage
+func (m *unpackStage) Name() string {
+	return StageTypeUnpack
+}
+
+// Cleanup implements Stage.
+func (*unpackStage) Cleanup() {
+	// no-op
+}
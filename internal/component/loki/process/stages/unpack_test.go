@@ -0,0 +1,158 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/util"
+)
+
+var testUnpackAlloy = `
+stage.pack {
+		labels           = ["pod", "container"]
+		ingest_timestamp = false
+}
+stage.unpack {
+		structured_metadata = ["pod"]
+}`
+
+// TestUnpackPipeline is used to verify that unpacking a packed line restores
+// the original line and labels.
+func TestUnpackPipeline(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	plName := "test_unpack_pipeline"
+	logger := util.TestAlloyLogger(t)
+	pl, err := NewPipeline(logger, loadConfig(testUnpackAlloy), &plName, registry, featuregate.StabilityGenerallyAvailable)
+	require.NoError(t, err)
+
+	lbls := model.LabelSet{
+		"pod":       "foo-xsfs3",
+		"container": "foo",
+		"namespace": "dev",
+	}
+	testTime := time.Now()
+
+	out := processEntries(pl, newEntry(nil, lbls, testMatchLogLineApp1, testTime))[0]
+
+	assert.Equal(t, testMatchLogLineApp1, out.Line)
+	assert.Equal(t, model.LabelSet{"container": "foo", "namespace": "dev"}, out.Labels)
+	assert.Equal(t, push.LabelsAdapter{{Name: "pod", Value: "foo-xsfs3"}}, out.StructuredMetadata)
+	assert.Equal(t, testTime, out.Timestamp)
+}
+
+func TestUnpackStage(t *testing.T) {
+	tests := []struct {
+		name                       string
+		config                     UnpackConfig
+		line                       string
+		labels                     model.LabelSet
+		expectedLine               string
+		expectedLabels             model.LabelSet
+		expectedStructuredMetadata push.LabelsAdapter
+		expectedExtracted          map[string]any
+	}{
+		{
+			name:              "not json",
+			line:              "test line 1",
+			labels:            model.LabelSet{"foo": "bar"},
+			expectedLine:      "test line 1",
+			expectedLabels:    model.LabelSet{"foo": "bar"},
+			expectedExtracted: map[string]any{},
+		},
+		{
+			name:              "invalid json",
+			line:              "{test line 1",
+			labels:            model.LabelSet{"foo": "bar"},
+			expectedLine:      "{test line 1",
+			expectedLabels:    model.LabelSet{"foo": "bar"},
+			expectedExtracted: map[string]any{},
+		},
+		{
+			name:              "json without entry",
+			line:              `{"foo":"baz","msg":"test line 1"}`,
+			labels:            model.LabelSet{"foo": "bar"},
+			expectedLine:      `{"foo":"baz","msg":"test line 1"}`,
+			expectedLabels:    model.LabelSet{"foo": "bar"},
+			expectedExtracted: map[string]any{},
+		},
+		{
+			name:              "non-string value",
+			line:              `{"count":1,"` + packedEntryKey + `":"test line 1"}`,
+			labels:            model.LabelSet{"foo": "bar"},
+			expectedLine:      `{"count":1,"` + packedEntryKey + `":"test line 1"}`,
+			expectedLabels:    model.LabelSet{"foo": "bar"},
+			expectedExtracted: map[string]any{},
+		},
+		{
+			name:              "entry only",
+			line:              `{"` + packedEntryKey + `":"test line 1"}`,
+			labels:            model.LabelSet{"foo": "bar"},
+			expectedLine:      "test line 1",
+			expectedLabels:    model.LabelSet{"foo": "bar"},
+			expectedExtracted: map[string]any{},
+		},
+		{
+			name:         "restore labels",
+			line:         `{"bar":"baz","foo":"qux","` + packedEntryKey + `":"{\"msg\":\"test line 1\"}"}`,
+			labels:       model.LabelSet{"foo": "bar"},
+			expectedLine: `{"msg":"test line 1"}`,
+			expectedLabels: model.LabelSet{
+				"foo": "qux",
+				"bar": "baz",
+			},
+			expectedExtracted: map[string]any{
+				"foo": "qux",
+				"bar": "baz",
+			},
+		},
+		{
+			name: "restore to structured metadata",
+			config: UnpackConfig{
+				StructuredMetadata: []string{"foo", "bar"},
+			},
+			line:           `{"bar":"baz","foo":"qux","user":"john","` + packedEntryKey + `":"test line 1"}`,
+			labels:         model.LabelSet{"foo": "bar"},
+			expectedLine:   "test line 1",
+			expectedLabels: model.LabelSet{"foo": "bar", "user": "john"},
+			expectedStructuredMetadata: push.LabelsAdapter{
+				{Name: "bar", Value: "baz"},
+				{Name: "foo", Value: "qux"},
+			},
+			expectedExtracted: map[string]any{
+				"foo":  "qux",
+				"bar":  "baz",
+				"user": "john",
+			},
+		},
+		{
+			name:           "invalid label name",
+			line:           `{"":"baz","` + packedEntryKey + `":"test line 1"}`,
+			labels:         model.LabelSet{"foo": "bar"},
+			expectedLine:   "test line 1",
+			expectedLabels: model.LabelSet{"foo": "bar"},
+			expectedExtracted: map[string]any{
+				"": "baz",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := util.TestAlloyLogger(t)
+			m := newUnpackStage(logger, tt.config)
+			out := processEntries(m, newEntry(map[string]any{}, tt.labels, tt.line, time.Unix(1, 0)))
+
+			assert.Equal(t, tt.expectedLine, out[0].Line)
+			assert.Equal(t, tt.expectedLabels, out[0].Labels)
+			assert.Equal(t, tt.expectedStructuredMetadata, out[0].StructuredMetadata)
+			assert.Equal(t, tt.expectedExtracted, out[0].Extracted)
+			assert.Equal(t, time.Unix(1, 0), out[0].Timestamp)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package stages
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+// Config Errors
+const (
+	ErrXPathExpressionsRequired = "XPath expression is required"
+	ErrCouldNotCompileXPath     = "could not compile XPath expression"
+	ErrEmptyXMLStageSource      = "empty source"
+	ErrMalformedXML             = "malformed xml"
+)
+
+const malformedXMLDropReason = "malformed_xml"
+
+// XMLConfig represents an XML Stage configuration
+type XMLConfig struct {
+	Expressions   map[string]string `alloy:"expressions,attr"`
+	Source        *string           `alloy:"source,attr,optional"`
+	DropMalformed bool              `alloy:"drop_malformed,attr,optional"`
+}
+
+// validateXMLConfig validates an xml config and returns a map of necessary XPath expressions.
+func validateXMLConfig(c *XMLConfig) (map[string]*xpath.Expr, error) {
+	if len(c.Expressions) == 0 {
+		return nil, errors.New(ErrXPathExpressionsRequired)
+	}
+
+	if c.Source != nil && *c.Source == "" {
+		return nil, errors.New(ErrEmptyXMLStageSource)
+	}
+
+	expressions := map[string]*xpath.Expr{}
+
+	for n, e := range c.Expressions {
+		var err error
+		expr := e
+		// If there is no expression, use the name as the expression.
+		if e == "" {
+			expr = n
+		}
+		expressions[n], err = xpath.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrCouldNotCompileXPath, err)
+		}
+	}
+	return expressions, nil
+}
+
+// xmlStage sets extracted data using XPath expressions
+type xmlStage struct {
+	cfg         *XMLConfig
+	expressions map[string]*xpath.Expr
+	logger      log.Logger
+	dropCount   *prometheus.CounterVec
+}
+
+// newXMLStage creates a new xml pipeline stage from a config.
+func newXMLStage(logger log.Logger, cfg XMLConfig, registerer prometheus.Registerer) (Stage, error) {
+	expressions, err := validateXMLConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &xmlStage{
+		cfg:         &cfg,
+		expressions: expressions,
+		logger:      log.With(logger, "component", "stage", "type", "xml"),
+		dropCount:   getDropCountMetric(registerer),
+	}, nil
+}
+
+func (x *xmlStage) Run(in chan Entry) chan Entry {
+	out := make(chan Entry)
+	go func() {
+		defer close(out)
+		for e := range in {
+			err := x.processEntry(e.Extracted, &e.Line)
+			if err != nil && x.cfg.DropMalformed {
+				x.dropCount.WithLabelValues(malformedXMLDropReason).Inc()
+				continue
+			}
+			out <- e
+		}
+	}()
+	return out
+}
+
+func (x *xmlStage) processEntry(extracted map[string]any, entry *string) error {
+	// If a source key is provided, the xml stage should process it
+	// from the extracted map, otherwise should fall back to the entry
+	input := entry
+
+	if x.cfg.Source != nil {
+		if _, ok := extracted[*x.cfg.Source]; !ok {
+			if Debug {
+				level.Debug(x.logger).Log("msg", "source does not exist in the set of extracted values", "source", *x.cfg.Source)
+			}
+			return nil
+		}
+
+		value, err := getString(extracted[*x.cfg.Source])
+		if err != nil {
+			if Debug {
+				level.Debug(x.logger).Log("msg", "failed to convert source value to string", "source", *x.cfg.Source, "err", err, "type", reflect.TypeOf(extracted[*x.cfg.Source]))
+			}
+			return nil
+		}
+
+		input = &value
+	}
+
+	if input == nil {
+		if Debug {
+			level.Debug(x.logger).Log("msg", "cannot parse a nil entry")
+		}
+		return nil
+	}
+
+	doc, err := xmlquery.Parse(strings.NewReader(*input))
+	if err == nil && doc.SelectElement("*") == nil {
+		err = errors.New("no root element")
+	}
+	if err != nil {
+		if Debug {
+			level.Debug(x.logger).Log("msg", "failed to parse log line", "err", err)
+		}
+		return errors.New(ErrMalformedXML)
+	}
+
+	for n, e := range x.expressions {
+		switch r := e.Evaluate(xmlquery.CreateXPathNavigator(doc)).(type) {
+		case *xpath.NodeIterator:
+			// Only the value of the first selected node is extracted.
+			if !r.MoveNext() {
+				if Debug {
+					level.Debug(x.logger).Log("msg", "XPath expression selected no node", "name", n)
+				}
+				continue
+			}
+			extracted[n] = r.Current().Value()
+		case float64, string, bool:
+			extracted[n] = r
+		default:
+			if Debug {
+				level.Debug(x.logger).Log("msg", "unexpected XPath expression result", "name", n, "type", reflect.TypeOf(r))
+			}
+		}
+	}
+	if Debug {
+		level.Debug(x.logger).Log("msg", "extracted data debug in xml stage", "extracted_data", fmt.Sprintf("%v", extracted))
+	}
+	return nil
+}
+
+// Name implements Stage
+func (x *xmlStage) Name() string {
+	return StageTypeXML
+}
+
+// Cleanup implements Stage.
+func (*xmlStage) Cleanup() {
+	// no-op
+}
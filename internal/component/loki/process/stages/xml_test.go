@@ -0,0 +1,172 @@
+package stages
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/util"
+)
+
+var testXMLAlloySingleStageWithoutSource = `
+stage.xml {
+    expressions = {
+        "user"     = "/event/user",
+        "id"       = "/event/@id",
+        "duration" = "number(/event/duration)",
+        "failed"   = "/event/status = 'failed'",
+        "step"     = "/event/steps/step",
+        "unknown"  = "/event/unknown",
+    }
+}
+`
+
+var testXMLAlloyMultiStageWithSource = `
+stage.json {
+    expressions = { "payload" = "" }
+}
+
+stage.xml {
+    expressions = { "user" = "/event/user" }
+    source      = "payload"
+}`
+
+var testXMLLogLine = `<event id="42"><user>marco</user><duration>125</duration><status>failed</status><steps><step>first</step><step>second</step></steps></event>`
+
+func TestPipeline_XML(t *testing.T) {
+	t.Parallel()
+	logger := util.TestAlloyLogger(t)
+
+	tests := map[string]struct {
+		config          string
+		entry           string
+		expectedExtract map[string]any
+	}{
+		"successfully run a pipeline with 1 xml stage without source": {
+			testXMLAlloySingleStageWithoutSource,
+			testXMLLogLine,
+			map[string]any{
+				"user":     "marco",
+				"id":       "42",
+				"duration": float64(125),
+				"failed":   true,
+				"step":     "first",
+			},
+		},
+		"successfully run a pipeline with an xml stage with source": {
+			testXMLAlloyMultiStageWithSource,
+			`{"payload": "<event><user>marco</user></event>"}`,
+			map[string]any{
+				"payload": "<event><user>marco</user></event>",
+				"user":    "marco",
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			pl, err := NewPipeline(logger, loadConfig(testData.config), nil, prometheus.DefaultRegisterer, featuregate.StabilityGenerallyAvailable)
+			require.NoError(t, err, "Expected pipeline creation to not result in error")
+			out := processEntries(pl, newEntry(nil, nil, testData.entry, time.Now()))[0]
+			assert.Equal(t, testData.expectedExtract, out.Extracted)
+		})
+	}
+}
+
+func TestXMLConfig_validate(t *testing.T) {
+	t.Parallel()
+
+	var emptyString = ""
+
+	tests := map[string]struct {
+		config *XMLConfig
+		err    error
+	}{
+		"no expressions": {
+			&XMLConfig{},
+			errors.New(ErrXPathExpressionsRequired),
+		},
+		"invalid expression": {
+			&XMLConfig{Expressions: map[string]string{"extr1": "/event["}},
+			errors.New(ErrCouldNotCompileXPath),
+		},
+		"empty source": {
+			&XMLConfig{Expressions: map[string]string{"extr1": "/event"}, Source: &emptyString},
+			errors.New(ErrEmptyXMLStageSource),
+		},
+		"valid": {
+			&XMLConfig{Expressions: map[string]string{"extr1": "/event", "event": ""}},
+			nil,
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			_, err := validateXMLConfig(tt.config)
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.err.Error())
+		})
+	}
+}
+
+func TestXMLParser_Parse(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entry           string
+		dropMalformed   bool
+		expectedExtract map[string]any
+		expectedDropped bool
+	}{
+		"malformed xml is kept": {
+			entry:           `<event><user>marco</event>`,
+			expectedExtract: map[string]any{},
+		},
+		"malformed xml is dropped": {
+			entry:           `<event><user>marco</event>`,
+			dropMalformed:   true,
+			expectedDropped: true,
+		},
+		"plain text is dropped": {
+			entry:           `marco`,
+			dropMalformed:   true,
+			expectedDropped: true,
+		},
+		"valid xml": {
+			entry:           `<event><user>marco</user></event>`,
+			dropMalformed:   true,
+			expectedExtract: map[string]any{"user": "marco"},
+		},
+	}
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			t.Parallel()
+			registry := prometheus.NewRegistry()
+			s, err := newXMLStage(util.TestAlloyLogger(t), XMLConfig{
+				Expressions:   map[string]string{"user": "/event/user"},
+				DropMalformed: tt.dropMalformed,
+			}, registry)
+			require.NoError(t, err)
+
+			out := processEntries(s, newEntry(nil, nil, tt.entry, time.Now()))
+			if tt.expectedDropped {
+				require.Empty(t, out)
+				require.Equal(t, 1.0, testutil.ToFloat64(getDropCountMetric(registry).WithLabelValues(malformedXMLDropReason)))
+				return
+			}
+			require.Len(t, out, 1)
+			require.Equal(t, tt.expectedExtract, out[0].Extracted)
+		})
+	}
+}
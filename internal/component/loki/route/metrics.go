@@ -0,0 +1,39 @@
+package route
+
+import (
+	"github.com/grafana/alloy/internal/util"
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	entriesReceived prometheus_client.Counter
+	entriesRouted   *prometheus_client.CounterVec
+	entriesDropped  prometheus_client.Counter
+}
+
+// newMetrics creates a new set of metrics. If reg is non-nil, the metrics
+// will also be registered.
+func newMetrics(reg prometheus_client.Registerer) *metrics {
+	var m metrics
+
+	m.entriesReceived = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_route_entries_received_total",
+		Help: "Total number of log entries received",
+	})
+	m.entriesRouted = prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+		Name: "loki_route_entries_routed_total",
+		Help: "Total number of log entries forwarded by each route",
+	}, []string{"route"})
+	m.entriesDropped = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "loki_route_entries_dropped_total",
+		Help: "Total number of log entries dropped because they matched no route",
+	})
+
+	if reg != nil {
+		m.entriesReceived = util.MustRegisterOrGet(reg, m.entriesReceived).(prometheus_client.Counter)
+		m.entriesRouted = util.MustRegisterOrGet(reg, m.entriesRouted).(*prometheus_client.CounterVec)
+		m.entriesDropped = util.MustRegisterOrGet(reg, m.entriesDropped).(prometheus_client.Counter)
+	}
+
+	return &m
+}
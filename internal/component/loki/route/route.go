@@ -0,0 +1,315 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/loki/logql"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.route",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Mode controls which routes a log entry is forwarded to.
+type Mode string
+
+const (
+	// ModeFirstMatch forwards log entries to the first matching route only.
+	ModeFirstMatch Mode = "first_match"
+	// ModeAllMatches forwards log entries to every matching route.
+	ModeAllMatches Mode = "all_matches"
+)
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(string(m)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *Mode) UnmarshalText(text []byte) error {
+	s := Mode(text)
+	switch s {
+	case ModeFirstMatch, ModeAllMatches:
+		*m = s
+	default:
+		return fmt.Errorf("unknown mode %q, must be one of %q or %q", s, ModeFirstMatch, ModeAllMatches)
+	}
+	return nil
+}
+
+// defaultRouteName is the name used in metrics for the default route.
+const defaultRouteName = "default"
+
+// Arguments holds values which are used to configure the loki.route
+// component.
+type Arguments struct {
+	// Mode controls whether log entries go to the first matching route or to
+	// all of them.
+	Mode Mode `alloy:"mode,attr,optional"`
+
+	// The routes to match log entries against, in order.
+	Routes []Route `alloy:"route,block,optional"`
+
+	// Where log entries which don't match any route are forwarded to.
+	Default *DefaultRoute `alloy:"default,block,optional"`
+}
+
+// Route forwards the log entries matching a LogQL selector.
+type Route struct {
+	Name      string              `alloy:"name,attr"`
+	Selector  string              `alloy:"selector,attr"`
+	ForwardTo []loki.LogsReceiver `alloy:"forward_to,attr"`
+}
+
+// DefaultRoute forwards the log entries which don't match any route.
+type DefaultRoute struct {
+	ForwardTo []loki.LogsReceiver `alloy:"forward_to,attr"`
+}
+
+// DefaultArguments provides the default arguments for the loki.route
+// component.
+var DefaultArguments = Arguments{
+	Mode: ModeFirstMatch,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (a *Arguments) Validate() error {
+	if len(a.Routes) == 0 && a.Default == nil {
+		return errors.New("at least one route or a default route must be configured")
+	}
+
+	names := make(map[string]struct{}, len(a.Routes))
+	for i, r := range a.Routes {
+		if r.Name == "" {
+			return fmt.Errorf("route %d: name must not be empty", i)
+		}
+		if r.Name == defaultRouteName {
+			return fmt.Errorf("route %d: name %q is reserved for the default route", i, defaultRouteName)
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("route %d: duplicate route name %q", i, r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		if _, err := newMatcher(r.Selector); err != nil {
+			return fmt.Errorf("route %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the loki.route component.
+type Exports struct {
+	Receiver loki.LogsReceiver `alloy:"receiver,attr"`
+}
+
+var (
+	_ component.Component     = (*Component)(nil)
+	_ component.LiveDebugging = (*Component)(nil)
+)
+
+// Component implements the loki.route component.
+type Component struct {
+	opts    component.Options
+	metrics *metrics
+
+	receiver loki.LogsReceiver
+
+	mut    sync.RWMutex
+	mode   Mode
+	routes []*route
+	def    *route
+
+	debugDataPublisher livedebugging.DebugDataPublisher
+}
+
+// route is a configured Route, ready to match and forward log entries.
+type route struct {
+	name    string
+	matcher *matcher
+	fanout  *loki.Fanout
+}
+
+// New creates a new loki.route component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	debugDataPublisher, err := o.GetServiceData(livedebugging.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:               o,
+		metrics:            newMetrics(o.Registerer),
+		debugDataPublisher: debugDataPublisher.(livedebugging.DebugDataPublisher),
+	}
+
+	// Create and immediately export the receiver which remains the same for
+	// the component's lifetime.
+	c.receiver = loki.NewLogsReceiver(loki.WithComponentID(o.ID))
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	// Call to Update() to set the routes once at the start.
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.receiver.Chan():
+			if err := c.route(ctx, entry); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// route forwards entry to the routes it matches. It returns an error if ctx
+// is cancelled while forwarding.
+func (c *Component) route(ctx context.Context, entry loki.Entry) error {
+	// Hold the read lock while forwarding so that entries are never sent to
+	// receivers which were removed by a concurrent Update.
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	c.metrics.entriesReceived.Inc()
+
+	var matched []string
+	for _, r := range c.routes {
+		if !r.matcher.Matches(entry) {
+			continue
+		}
+		matched = append(matched, r.name)
+		c.metrics.entriesRouted.WithLabelValues(r.name).Inc()
+		if err := r.fanout.Send(ctx, entry); err != nil {
+			return err
+		}
+		if c.mode == ModeFirstMatch {
+			break
+		}
+	}
+
+	if len(matched) == 0 {
+		if c.def == nil {
+			c.metrics.entriesDropped.Inc()
+			level.Debug(c.opts.Logger).Log("msg", "dropping entry which doesn't match any route", "labels", entry.Labels.String())
+		} else {
+			matched = append(matched, c.def.name)
+			c.metrics.entriesRouted.WithLabelValues(c.def.name).Inc()
+			if err := c.def.fanout.Send(ctx, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.debugDataPublisher.PublishIfActive(livedebugging.NewData(
+		livedebugging.ComponentID(c.opts.ID),
+		livedebugging.LokiLog,
+		uint64(len(matched)),
+		func() string {
+			return fmt.Sprintf("entry: %s, labels: %s => routes: %v", entry.Line, entry.Labels.String(), matched)
+		},
+	))
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	routes := make([]*route, 0, len(newArgs.Routes))
+	for _, r := range newArgs.Routes {
+		m, err := newMatcher(r.Selector)
+		if err != nil {
+			return fmt.Errorf("route %q: %w", r.Name, err)
+		}
+		routes = append(routes, &route{
+			name:    r.Name,
+			matcher: m,
+			fanout:  loki.NewFanout(r.ForwardTo),
+		})
+	}
+	var def *route
+	if newArgs.Default != nil {
+		def = &route{
+			name:   defaultRouteName,
+			fanout: loki.NewFanout(newArgs.Default.ForwardTo),
+		}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.mode = newArgs.Mode
+	c.routes = routes
+	c.def = def
+
+	return nil
+}
+
+// LiveDebugging implements component.LiveDebugging.
+func (c *Component) LiveDebugging() {}
+
+// matcher matches log entries against a LogQL selector, the same way the
+// match stage of loki.process does.
+type matcher struct {
+	matchers []*labels.Matcher
+	filter   logql.Filter
+}
+
+func newMatcher(selector string) (*matcher, error) {
+	if selector == "" {
+		return nil, errors.New("selector must not be empty")
+	}
+	expr, err := logql.ParseExpr(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector syntax: %w", err)
+	}
+	filter, err := expr.Filter()
+	if err != nil {
+		return nil, fmt.Errorf("invalid line filter: %w", err)
+	}
+	return &matcher{
+		matchers: expr.Matchers(),
+		filter:   filter,
+	}, nil
+}
+
+// Matches reports whether the labels and line of e match the selector.
+func (m *matcher) Matches(e loki.Entry) bool {
+	for _, lm := range m.matchers {
+		if !lm.Matches(string(e.Labels[model.LabelName(lm.Name)])) {
+			return false
+		}
+	}
+	return m.filter == nil || m.filter([]byte(e.Line))
+}
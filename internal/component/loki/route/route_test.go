@@ -0,0 +1,292 @@
+package route
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			config: `
+				mode = "all_matches"
+				route {
+					name       = "errors"
+					selector   = "{app=\"foo\"} |= \"error\""
+					forward_to = []
+				}
+				default {
+					forward_to = []
+				}`,
+		},
+		{
+			name:        "no routes",
+			config:      ``,
+			expectedErr: "at least one route or a default route must be configured",
+		},
+		{
+			name: "unknown mode",
+			config: `
+				mode = "random"
+				default {
+					forward_to = []
+				}`,
+			expectedErr: `unknown mode "random", must be one of "first_match" or "all_matches"`,
+		},
+		{
+			name: "duplicate name",
+			config: `
+				route {
+					name       = "foo"
+					selector   = "{app=\"foo\"}"
+					forward_to = []
+				}
+				route {
+					name       = "foo"
+					selector   = "{app=\"bar\"}"
+					forward_to = []
+				}`,
+			expectedErr: `route 1: duplicate route name "foo"`,
+		},
+		{
+			name: "reserved name",
+			config: `
+				route {
+					name       = "default"
+					selector   = "{app=\"foo\"}"
+					forward_to = []
+				}`,
+			expectedErr: `route 0: name "default" is reserved for the default route`,
+		},
+		{
+			name: "invalid selector",
+			config: `
+				route {
+					name       = "foo"
+					selector   = "app=foo"
+					forward_to = []
+				}`,
+			expectedErr: `route "foo": invalid selector syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args Arguments
+			err := syntax.Unmarshal([]byte(tt.config), &args)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestRouting(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    Mode
+		def     bool
+		entries []loki.Entry
+
+		// The lines received by the foo, errors and default receivers.
+		expectedFoo, expectedErrors, expectedDefault []string
+
+		expectedMetrics string
+	}{
+		{
+			name: "first match",
+			mode: ModeFirstMatch,
+			def:  true,
+			entries: []loki.Entry{
+				newEntry(model.LabelSet{"app": "foo"}, "error: foo"),
+				newEntry(model.LabelSet{"app": "bar"}, "error: bar"),
+				newEntry(model.LabelSet{"app": "bar"}, "info: bar"),
+			},
+			expectedFoo:     []string{"error: foo"},
+			expectedErrors:  []string{"error: bar"},
+			expectedDefault: []string{"info: bar"},
+			expectedMetrics: `
+				# HELP loki_route_entries_dropped_total Total number of log entries dropped because they matched no route
+				# TYPE loki_route_entries_dropped_total counter
+				loki_route_entries_dropped_total 0
+				# HELP loki_route_entries_received_total Total number of log entries received
+				# TYPE loki_route_entries_received_total counter
+				loki_route_entries_received_total 3
+				# HELP loki_route_entries_routed_total Total number of log entries forwarded by each route
+				# TYPE loki_route_entries_routed_total counter
+				loki_route_entries_routed_total{route="default"} 1
+				loki_route_entries_routed_total{route="errors"} 1
+				loki_route_entries_routed_total{route="foo"} 1
+			`,
+		},
+		{
+			name: "all matches without default",
+			mode: ModeAllMatches,
+			entries: []loki.Entry{
+				newEntry(model.LabelSet{"app": "foo"}, "error: foo"),
+				newEntry(model.LabelSet{"app": "bar"}, "error: bar"),
+				newEntry(model.LabelSet{"app": "bar"}, "info: bar"),
+			},
+			expectedFoo:    []string{"error: foo"},
+			expectedErrors: []string{"error: foo", "error: bar"},
+			expectedMetrics: `
+				# HELP loki_route_entries_dropped_total Total number of log entries dropped because they matched no route
+				# TYPE loki_route_entries_dropped_total counter
+				loki_route_entries_dropped_total 1
+				# HELP loki_route_entries_received_total Total number of log entries received
+				# TYPE loki_route_entries_received_total counter
+				loki_route_entries_received_total 3
+				# HELP loki_route_entries_routed_total Total number of log entries forwarded by each route
+				# TYPE loki_route_entries_routed_total counter
+				loki_route_entries_routed_total{route="errors"} 2
+				loki_route_entries_routed_total{route="foo"} 1
+			`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				foo, errs, def = loki.NewLogsReceiver(), loki.NewLogsReceiver(), loki.NewLogsReceiver()
+				reg            = prometheus.NewRegistry()
+			)
+			args := Arguments{
+				Mode: tt.mode,
+				Routes: []Route{
+					{Name: "foo", Selector: `{app="foo"}`, ForwardTo: []loki.LogsReceiver{foo}},
+					{Name: "errors", Selector: `{app=~".+"} |= "error"`, ForwardTo: []loki.LogsReceiver{errs}},
+				},
+			}
+			if tt.def {
+				args.Default = &DefaultRoute{ForwardTo: []loki.LogsReceiver{def}}
+			}
+
+			c, err := New(component.Options{
+				Logger:         util.TestAlloyLogger(t),
+				Registerer:     reg,
+				OnStateChange:  func(e component.Exports) {},
+				GetServiceData: getServiceData,
+			}, args)
+			require.NoError(t, err)
+			go c.Run(t.Context())
+
+			var gotFoo, gotErrors, gotDefault []string
+			for _, e := range tt.entries {
+				c.receiver.Chan() <- e
+				// Receive what the entry was routed to before sending the next
+				// one, as receivers are unbuffered.
+				received := 0
+				for received < expectedRoutes(e.Line, tt.expectedFoo, tt.expectedErrors, tt.expectedDefault) {
+					select {
+					case got := <-foo.Chan():
+						gotFoo = append(gotFoo, got.Line)
+					case got := <-errs.Chan():
+						gotErrors = append(gotErrors, got.Line)
+					case got := <-def.Chan():
+						gotDefault = append(gotDefault, got.Line)
+					case <-time.After(5 * time.Second):
+						require.FailNow(t, "timed out waiting for log entry", e.Line)
+					}
+					received++
+				}
+			}
+
+			require.Equal(t, tt.expectedFoo, gotFoo)
+			require.Equal(t, tt.expectedErrors, gotErrors)
+			require.Equal(t, tt.expectedDefault, gotDefault)
+			require.Eventually(t, func() bool {
+				return testutil.GatherAndCompare(reg, strings.NewReader(tt.expectedMetrics)) == nil
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	foo, bar := loki.NewLogsReceiver(), loki.NewLogsReceiver()
+
+	c, err := New(component.Options{
+		Logger:         util.TestAlloyLogger(t),
+		Registerer:     prometheus.NewRegistry(),
+		OnStateChange:  func(e component.Exports) {},
+		GetServiceData: getServiceData,
+	}, Arguments{
+		Mode:   ModeFirstMatch,
+		Routes: []Route{{Name: "foo", Selector: `{app="foo"}`, ForwardTo: []loki.LogsReceiver{foo}}},
+	})
+	require.NoError(t, err)
+	go c.Run(t.Context())
+
+	c.receiver.Chan() <- newEntry(model.LabelSet{"app": "foo"}, "first")
+	require.Equal(t, "first", receive(t, foo).Line)
+
+	require.NoError(t, c.Update(Arguments{
+		Mode:   ModeFirstMatch,
+		Routes: []Route{{Name: "foo", Selector: `{app="foo"}`, ForwardTo: []loki.LogsReceiver{bar}}},
+	}))
+
+	c.receiver.Chan() <- newEntry(model.LabelSet{"app": "foo"}, "second")
+	require.Equal(t, "second", receive(t, bar).Line)
+}
+
+// expectedRoutes returns the number of lists line appears in.
+func expectedRoutes(line string, lists ...[]string) int {
+	n := 0
+	for _, l := range lists {
+		for _, s := range l {
+			if s == line {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func receive(t *testing.T, r loki.LogsReceiver) loki.Entry {
+	t.Helper()
+	select {
+	case e := <-r.Chan():
+		return e
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for log entry")
+		return loki.Entry{}
+	}
+}
+
+func newEntry(lbls model.LabelSet, line string) loki.Entry {
+	return loki.Entry{
+		Labels: lbls,
+		Entry: push.Entry{
+			Timestamp: time.Now(),
+			Line:      line,
+		},
+	}
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case livedebugging.ServiceName:
+		return livedebugging.NewLiveDebugging(), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
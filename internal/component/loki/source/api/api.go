@@ -32,12 +32,14 @@ func init() {
 }
 
 type Arguments struct {
-	Server               *fnet.ServerConfig  `alloy:",squash"`
-	ForwardTo            []loki.LogsReceiver `alloy:"forward_to,attr"`
-	Labels               map[string]string   `alloy:"labels,attr,optional"`
-	RelabelRules         relabel.Rules       `alloy:"relabel_rules,attr,optional"`
-	UseIncomingTimestamp bool                `alloy:"use_incoming_timestamp,attr,optional"`
-	MaxSendMessageSize   units.Base2Bytes    `alloy:"max_send_message_size,attr,optional"`
+	Server                  *fnet.ServerConfig  `alloy:",squash"`
+	ForwardTo               []loki.LogsReceiver `alloy:"forward_to,attr"`
+	Labels                  map[string]string   `alloy:"labels,attr,optional"`
+	RelabelRules            relabel.Rules       `alloy:"relabel_rules,attr,optional"`
+	UseIncomingTimestamp    bool                `alloy:"use_incoming_timestamp,attr,optional"`
+	MaxSendMessageSize      units.Base2Bytes    `alloy:"max_send_message_size,attr,optional"`
+	EnableOTLP              bool                `alloy:"enable_otlp,attr,optional"`
+	EnableElasticsearchBulk bool                `alloy:"enable_elasticsearch_bulk,attr,optional"`
 }
 
 // SetToDefault implements syntax.Defaulter.
@@ -148,6 +150,8 @@ func (c *Component) Update(args component.Arguments) error {
 	c.server.SetLabels(newArgs.labelSet())
 	c.server.SetRelabelRules(newArgs.RelabelRules)
 	c.server.SetKeepTimestamp(newArgs.UseIncomingTimestamp)
+	c.server.SetEnableOTLP(newArgs.EnableOTLP)
+	c.server.SetEnableElasticsearchBulk(newArgs.EnableElasticsearchBulk)
 
 	return nil
 }
@@ -0,0 +1,256 @@
+package lokipush
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	lokipush "github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+const (
+	elasticsearchIndex       = "__elasticsearch_index"
+	elasticsearchFieldPrefix = "__elasticsearch_field_"
+
+	// elasticsearchVersion is the Elasticsearch version reported to clients.
+	// Clients such as Filebeat and Logstash refuse to send documents to
+	// servers which don't look like a recent Elasticsearch.
+	elasticsearchVersion = "8.11.0"
+
+	// elasticsearchPathPrefix is the path clients must be configured with to
+	// use the Elasticsearch endpoints.
+	elasticsearchPathPrefix = "/elasticsearch"
+)
+
+// elasticsearchBulkItem is the result of a single action of a bulk request.
+type elasticsearchBulkItem struct {
+	Index  string                  `json:"_index,omitempty"`
+	Status int                     `json:"status"`
+	Result string                  `json:"result,omitempty"`
+	Error  *elasticsearchBulkError `json:"error,omitempty"`
+}
+
+type elasticsearchBulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// handleElasticsearchInfo responds to the requests clients send to check the
+// version of the Elasticsearch cluster before sending documents.
+func (s *PushAPIServer) handleElasticsearchInfo(w http.ResponseWriter, r *http.Request) {
+	if !s.getEnableElasticsearchBulk() {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeElasticsearchResponse(w, s.logger, map[string]any{
+		"name":         "alloy",
+		"cluster_name": "alloy",
+		"version": map[string]any{
+			"number":                              elasticsearchVersion,
+			"build_flavor":                        "default",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// handleElasticsearchBulk handles Elasticsearch bulk API requests. Only the
+// documents of index and create actions are converted to entries, other
+// actions are reported as failed.
+func (s *PushAPIServer) handleElasticsearchBulk(w http.ResponseWriter, r *http.Request) {
+	if !s.getEnableElasticsearchBulk() {
+		http.NotFound(w, r)
+		return
+	}
+
+	start := time.Now()
+	body, err := readBody(r, s.maxSendMessageSize)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to read incoming bulk request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, _ := tenant.TenantID(r.Context())
+	entries, items, err := elasticsearchBulkToEntries(body, mux.Vars(r)["index"], s.getLabels(), s.getRelabelRules(), s.getKeepTimestamp(), tenantID)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to parse incoming bulk request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !s.sendEntries(r, entries) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	hasErrors := false
+	for _, item := range items {
+		for _, result := range item {
+			hasErrors = hasErrors || result.Error != nil
+		}
+	}
+	writeElasticsearchResponse(w, s.logger, map[string]any{
+		"took":   time.Since(start).Milliseconds(),
+		"errors": hasErrors,
+		"items":  items,
+	})
+}
+
+// elasticsearchBulkToEntries converts the documents of a bulk request body to
+// entries. It also returns the result of each action in the body.
+//
+// The message field of a document is used as the log line, or the whole
+// document if it has no message field. The index and the scalar fields of a
+// document are available to the relabeling rules as labels prefixed with __.
+// The names of nested fields are joined with _.
+func elasticsearchBulkToEntries(body []byte, defaultIndex string, addLabels model.LabelSet, relabelRules []*relabel.Config, keepTimestamp bool, tenantID string) ([]loki.Entry, []map[string]elasticsearchBulkItem, error) {
+	var (
+		entries []loki.Entry
+		items   []map[string]elasticsearchBulkItem
+	)
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	for {
+		var action map[string]struct {
+			Index string `json:"_index"`
+		}
+		if err := dec.Decode(&action); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode bulk action: %w", err)
+		}
+		if len(action) != 1 {
+			return nil, nil, fmt.Errorf("bulk action must have exactly one key, got %d", len(action))
+		}
+
+		for op, meta := range action {
+			index := meta.Index
+			if index == "" {
+				index = defaultIndex
+			}
+
+			switch op {
+			case "index", "create":
+			case "delete":
+				// Delete actions have no document.
+				items = append(items, unsupportedBulkItem(op, index))
+				continue
+			case "update":
+				var ignored json.RawMessage
+				if err := dec.Decode(&ignored); err != nil {
+					return nil, nil, fmt.Errorf("failed to decode document: %w", err)
+				}
+				items = append(items, unsupportedBulkItem(op, index))
+				continue
+			default:
+				return nil, nil, fmt.Errorf("unknown bulk action %q", op)
+			}
+
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, nil, fmt.Errorf("failed to decode document: %w", err)
+			}
+			var doc map[string]any
+			rawDec := json.NewDecoder(bytes.NewReader(raw))
+			rawDec.UseNumber()
+			if err := rawDec.Decode(&doc); err != nil {
+				items = append(items, map[string]elasticsearchBulkItem{op: {
+					Index:  index,
+					Status: http.StatusBadRequest,
+					Error:  &elasticsearchBulkError{Type: "document_parsing_exception", Reason: err.Error()},
+				}})
+				continue
+			}
+
+			items = append(items, map[string]elasticsearchBulkItem{op: {Index: index, Status: http.StatusCreated, Result: "created"}})
+
+			lb := labels.NewBuilder(labels.EmptyLabels())
+			if index != "" {
+				lb.Set(elasticsearchIndex, index)
+			}
+			addElasticsearchFields(lb, "", doc)
+
+			filtered, metadata, keep := processLabels(lb, addLabels, relabelRules, tenantID)
+			if !keep {
+				continue
+			}
+
+			e := loki.Entry{
+				Labels: filtered,
+				Entry: lokipush.Entry{
+					Timestamp:          time.Now(),
+					Line:               string(raw),
+					StructuredMetadata: metadata,
+				},
+			}
+			if message, ok := doc["message"].(string); ok {
+				e.Line = message
+			}
+			if keepTimestamp {
+				if ts, ok := doc["@timestamp"].(string); ok {
+					if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+						e.Timestamp = parsed
+					}
+				}
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, items, nil
+}
+
+// addElasticsearchFields sets a label for each scalar field of doc. Arrays are
+// skipped.
+func addElasticsearchFields(lb *labels.Builder, prefix string, doc map[string]any) {
+	for k, v := range doc {
+		name := prefix + strutil.SanitizeLabelName(k)
+		switch v := v.(type) {
+		case map[string]any:
+			addElasticsearchFields(lb, name+"_", v)
+		case string:
+			lb.Set(elasticsearchFieldPrefix+name, v)
+		case json.Number:
+			lb.Set(elasticsearchFieldPrefix+name, v.String())
+		case bool:
+			lb.Set(elasticsearchFieldPrefix+name, fmt.Sprint(v))
+		}
+	}
+}
+
+func unsupportedBulkItem(op, index string) map[string]elasticsearchBulkItem {
+	return map[string]elasticsearchBulkItem{op: {
+		Index:  index,
+		Status: http.StatusBadRequest,
+		Error:  &elasticsearchBulkError{Type: "illegal_argument_exception", Reason: fmt.Sprintf("%s actions are not supported", op)},
+	}}
+}
+
+func writeElasticsearchResponse(w http.ResponseWriter, logger log.Logger, resp any) {
+	// Recent Elasticsearch clients check this header to make sure they're
+	// connected to Elasticsearch.
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Warn(logger).Log("msg", "failed to write Elasticsearch response", "err", err)
+	}
+}
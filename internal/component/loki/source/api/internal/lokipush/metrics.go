@@ -11,11 +11,17 @@ func newMetircs(reg prometheus.Registerer) *metrics {
 			Name: "loki_source_api_entries_written",
 			Help: "Total number of entries written.",
 		}),
+		entriesDroppedWithoutLabels: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_source_api_entries_dropped_without_labels",
+			Help: "Total number of OTLP and Elasticsearch entries dropped because they had no labels left after relabeling.",
+		}),
 	}
 	m.entriesWritten = util.MustRegisterOrGet(reg, m.entriesWritten).(prometheus.Counter)
+	m.entriesDroppedWithoutLabels = util.MustRegisterOrGet(reg, m.entriesDroppedWithoutLabels).(prometheus.Counter)
 	return m
 }
 
 type metrics struct {
-	entriesWritten prometheus.Counter
+	entriesWritten              prometheus.Counter
+	entriesDroppedWithoutLabels prometheus.Counter
 }
@@ -0,0 +1,169 @@
+package lokipush
+
+import (
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/grafana/dskit/tenant"
+	lokipush "github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+const (
+	otlpResourceAttributePrefix = "__otlp_resource_attribute_"
+	otlpAttributePrefix         = "__otlp_attribute_"
+	otlpScopeName               = "__otlp_scope_name"
+	otlpSeverityText            = "__otlp_severity_text"
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// handleOTLP handles OTLP/HTTP logs export requests encoded as protobuf or
+// JSON.
+func (s *PushAPIServer) handleOTLP(w http.ResponseWriter, r *http.Request) {
+	if !s.getEnableOTLP() {
+		http.NotFound(w, r)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+		http.Error(w, "unsupported content type, expected application/x-protobuf or application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readBody(r, s.maxSendMessageSize)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to read incoming OTLP request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := plogotlp.NewExportRequest()
+	if contentType == contentTypeJSON {
+		err = req.UnmarshalJSON(body)
+	} else {
+		err = req.UnmarshalProto(body)
+	}
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "failed to parse incoming OTLP request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, _ := tenant.TenantID(r.Context())
+	entries := otlpToEntries(req.Logs(), s.getLabels(), s.getRelabelRules(), s.getKeepTimestamp(), tenantID)
+	if !s.sendEntries(r, entries) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	resp := plogotlp.NewExportResponse()
+	var respBody []byte
+	if contentType == contentTypeJSON {
+		respBody, err = resp.MarshalJSON()
+	} else {
+		respBody, err = resp.MarshalProto()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(respBody); err != nil {
+		level.Warn(s.logger).Log("msg", "failed to write OTLP response", "err", err)
+	}
+}
+
+// otlpToEntries converts OTLP log records to entries.
+//
+// The body of a log record is used as the log line. Resource attributes, log
+// attributes, the scope name and the severity text are available to the
+// relabeling rules as labels prefixed with __. Log attributes, the trace ID,
+// the span ID and the severity text are also added to the structured metadata.
+func otlpToEntries(logs plog.Logs, addLabels model.LabelSet, relabelRules []*relabel.Config, keepTimestamp bool, tenantID string) []loki.Entry {
+	var entries []loki.Entry
+
+	rls := logs.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		resourceLabels := labels.NewScratchBuilder(0)
+		rls.At(i).Resource().Attributes().Range(func(k string, v pcommon.Value) bool {
+			resourceLabels.Add(otlpResourceAttributePrefix+strutil.SanitizeLabelName(k), v.AsString())
+			return true
+		})
+		resourceLabels.Sort()
+		resource := resourceLabels.Labels()
+
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			scopeName := sls.At(j).Scope().Name()
+
+			records := sls.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				record := records.At(k)
+
+				lb := labels.NewBuilder(resource)
+				if scopeName != "" {
+					lb.Set(otlpScopeName, scopeName)
+				}
+				if severity := record.SeverityText(); severity != "" {
+					lb.Set(otlpSeverityText, severity)
+				}
+
+				var metadata lokipush.LabelsAdapter
+				record.Attributes().Range(func(k string, v pcommon.Value) bool {
+					name := strutil.SanitizeLabelName(k)
+					lb.Set(otlpAttributePrefix+name, v.AsString())
+					metadata = append(metadata, lokipush.LabelAdapter{Name: name, Value: v.AsString()})
+					return true
+				})
+				if traceID := record.TraceID(); !traceID.IsEmpty() {
+					metadata = append(metadata, lokipush.LabelAdapter{Name: "trace_id", Value: traceID.String()})
+				}
+				if spanID := record.SpanID(); !spanID.IsEmpty() {
+					metadata = append(metadata, lokipush.LabelAdapter{Name: "span_id", Value: spanID.String()})
+				}
+				if severity := record.SeverityText(); severity != "" {
+					metadata = append(metadata, lokipush.LabelAdapter{Name: "severity_text", Value: severity})
+				}
+
+				filtered, extraMetadata, keep := processLabels(lb, addLabels, relabelRules, tenantID)
+				if !keep {
+					continue
+				}
+
+				e := loki.Entry{
+					Labels: filtered,
+					Entry: lokipush.Entry{
+						Timestamp:          time.Now(),
+						Line:               record.Body().AsString(),
+						StructuredMetadata: appendMetadata(metadata, extraMetadata),
+					},
+				}
+				if keepTimestamp {
+					switch {
+					case record.Timestamp() != 0:
+						e.Timestamp = record.Timestamp().AsTime()
+					case record.ObservedTimestamp() != 0:
+						e.Timestamp = record.ObservedTimestamp().AsTime()
+					}
+				}
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries
+}
@@ -2,6 +2,9 @@ package lokipush
 
 import (
 	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -27,6 +30,11 @@ import (
 	"github.com/grafana/alloy/internal/runtime/logging/level"
 )
 
+// structuredMetadataPrefix is the prefix of the labels which are converted to
+// structured metadata after relabeling by the OTLP and Elasticsearch
+// endpoints.
+const structuredMetadataPrefix = "__structured_metadata_"
+
 type PushAPIServer struct {
 	logger       log.Logger
 	serverConfig *fnet.ServerConfig
@@ -42,6 +50,9 @@ type PushAPIServer struct {
 	relabelRules       []*relabel.Config
 	keepTimestamp      bool
 	maxSendMessageSize int64
+
+	enableOTLP              bool
+	enableElasticsearchBulk bool
 }
 
 func NewPushAPIServer(logger log.Logger,
@@ -109,6 +120,17 @@ func (s *PushAPIServer) Run() error {
 		router.Path("/ready").Methods("GET").Handler(http.HandlerFunc(s.ready))
 		router.Path("/loki/api/v1/push").Methods("POST").Handler(tenantHeaderExtractor(http.HandlerFunc(s.handleLoki)))
 		router.Path("/loki/api/v1/raw").Methods("POST").Handler(tenantHeaderExtractor(http.HandlerFunc(s.handlePlaintext)))
+
+		// The OTLP and Elasticsearch endpoints are always mounted so that they
+		// can be enabled without restarting the server. They respond with 404
+		// while disabled. The Elasticsearch endpoints are mounted under a
+		// prefix so that they don't take over the root of the server.
+		router.Path("/v1/logs").Methods("POST").Handler(tenantHeaderExtractor(http.HandlerFunc(s.handleOTLP)))
+		router.Path("/otlp/v1/logs").Methods("POST").Handler(tenantHeaderExtractor(http.HandlerFunc(s.handleOTLP)))
+		router.Path(elasticsearchPathPrefix).Methods("GET", "HEAD").Handler(http.HandlerFunc(s.handleElasticsearchInfo))
+		router.Path(elasticsearchPathPrefix+"/").Methods("GET", "HEAD").Handler(http.HandlerFunc(s.handleElasticsearchInfo))
+		router.Path(elasticsearchPathPrefix+"/_bulk").Methods("POST", "PUT").Handler(tenantHeaderExtractor(http.HandlerFunc(s.handleElasticsearchBulk)))
+		router.Path(elasticsearchPathPrefix+"/{index}/_bulk").Methods("POST", "PUT").Handler(tenantHeaderExtractor(http.HandlerFunc(s.handleElasticsearchBulk)))
 	})
 	return err
 }
@@ -187,6 +209,30 @@ func (s *PushAPIServer) getRelabelRules() []*relabel.Config {
 	return newRules
 }
 
+func (s *PushAPIServer) SetEnableOTLP(enable bool) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.enableOTLP = enable
+}
+
+func (s *PushAPIServer) getEnableOTLP() bool {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.enableOTLP
+}
+
+func (s *PushAPIServer) SetEnableElasticsearchBulk(enable bool) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.enableElasticsearchBulk = enable
+}
+
+func (s *PushAPIServer) getEnableElasticsearchBulk() bool {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.enableElasticsearchBulk
+}
+
 func (s *PushAPIServer) handleLoki(w http.ResponseWriter, r *http.Request) {
 	req, err := loghttp2.ParsePushRequest(r, int(s.maxSendMessageSize))
 	if err != nil {
@@ -328,6 +374,131 @@ func (s *PushAPIServer) handlePlaintext(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// sendEntries sends entries to the handler. Entries without labels are
+// dropped, as Loki rejects them. It returns false if the request was canceled
+// or the server was shut down before the entries were sent.
+func (s *PushAPIServer) sendEntries(r *http.Request, entries []loki.Entry) bool {
+	entries = s.dropEntriesWithoutLabels(entries)
+	if len(entries) == 0 {
+		return true
+	}
+	select {
+	case s.handler.Chan() <- entries:
+	case <-r.Context().Done():
+		return false
+	case <-s.forceShutdown:
+		return false
+	}
+	s.metrics.entriesWritten.Add(float64(len(entries)))
+	return true
+}
+
+// dropEntriesWithoutLabels removes the entries which have no labels left
+// after relabeling from entries. The tenant ID label doesn't count, as it's
+// removed before the entries are sent to Loki.
+func (s *PushAPIServer) dropEntriesWithoutLabels(entries []loki.Entry) []loki.Entry {
+	kept := entries[:0]
+	for _, e := range entries {
+		for name := range e.Labels {
+			if name != client.ReservedLabelTenantID {
+				kept = append(kept, e)
+				break
+			}
+		}
+	}
+
+	if dropped := len(entries) - len(kept); dropped > 0 {
+		level.Debug(s.logger).Log("msg", "dropped entries without labels after relabeling", "count", dropped)
+		s.metrics.entriesDroppedWithoutLabels.Add(float64(dropped))
+	}
+	return kept
+}
+
+// processLabels adds the configured labels to lb and applies the relabeling
+// rules of the OTLP and Elasticsearch endpoints. Labels prefixed with
+// structuredMetadataPrefix after relabeling are returned as structured
+// metadata, and other labels prefixed with __ are removed. keep is false if
+// the entry must be dropped.
+//
+// The Loki push endpoint doesn't use processLabels: it keeps dropping all the
+// labels prefixed with __, as it always did.
+func processLabels(lb *labels.Builder, addLabels model.LabelSet, relabelRules []*relabel.Config, tenantID string) (filtered model.LabelSet, metadata lokipush.LabelsAdapter, keep bool) {
+	// Add configured labels
+	for k, v := range addLabels {
+		lb.Set(string(k), string(v))
+	}
+
+	// Apply relabeling
+	processed, keep := relabel.Process(lb.Labels(), relabelRules...)
+	if !keep || processed.Len() == 0 {
+		return nil, nil, false
+	}
+
+	// Convert to model.LabelSet
+	filtered = model.LabelSet{}
+	processed.Range(func(l labels.Label) {
+		if name, ok := strings.CutPrefix(l.Name, structuredMetadataPrefix); ok {
+			if name != "" {
+				metadata = append(metadata, lokipush.LabelAdapter{Name: name, Value: l.Value})
+			}
+			return
+		}
+		if strings.HasPrefix(l.Name, "__") {
+			return
+		}
+		filtered[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	})
+
+	// Add tenant ID to the filtered labels if it is set
+	if tenantID != "" {
+		filtered[model.LabelName(client.ReservedLabelTenantID)] = model.LabelValue(tenantID)
+	}
+
+	return filtered, metadata, true
+}
+
+// appendMetadata returns the structured metadata of an entry with extra
+// appended, without modifying the backing array of metadata.
+func appendMetadata(metadata, extra lokipush.LabelsAdapter) lokipush.LabelsAdapter {
+	if len(extra) == 0 {
+		return metadata
+	}
+	res := make(lokipush.LabelsAdapter, 0, len(metadata)+len(extra))
+	res = append(res, metadata...)
+	return append(res, extra...)
+}
+
+// readBody reads the possibly compressed body of r, up to maxSize bytes.
+func readBody(r *http.Request, maxSize int64) ([]byte, error) {
+	var body io.Reader
+	switch contentEncoding := r.Header.Get("Content-Encoding"); contentEncoding {
+	case "", "identity":
+		body = r.Body
+	case "gzip":
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	case "deflate":
+		flateReader := flate.NewReader(r.Body)
+		defer flateReader.Close()
+		body = flateReader
+	default:
+		return nil, fmt.Errorf("Content-Encoding %q not supported", contentEncoding)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, fmt.Errorf("request body too large: limit is %d bytes", maxSize)
+	}
+	return b, nil
+}
+
 // NOTE: This code is copied from Promtail (https://github.com/grafana/loki/commit/47e2c5884f443667e64764f3fc3948f8f11abbb8) with changes kept to the minimum.
 // Only the HTTP handler functions are copied to allow for Alloy-specific server configuration and lifecycle management.
 func (s *PushAPIServer) ready(w http.ResponseWriter, _ *http.Request) {
@@ -6,11 +6,13 @@ package lokipush
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -20,8 +22,12 @@ import (
 	"github.com/grafana/loki/pkg/push"
 	"github.com/phayes/freeport"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 
 	"github.com/grafana/alloy/internal/component/common/loki"
 	"github.com/grafana/alloy/internal/component/common/loki/client"
@@ -104,9 +110,12 @@ regex = "dropme"
 	defer pc.Stop()
 
 	// Send some logs
+	// Unlike the OTLP and Elasticsearch endpoints, the push API drops
+	// __structured_metadata_ labels like any other label prefixed with __.
 	labels := model.LabelSet{
-		"stream":             "stream1",
-		"__anotherdroplabel": "dropme",
+		"stream":                        "stream1",
+		"__anotherdroplabel":            "dropme",
+		"__structured_metadata_dropped": "dropme",
 	}
 	for i := range 100 {
 		pc.Chan() <- loki.Entry{
@@ -520,6 +529,208 @@ func TestReady(t *testing.T) {
 	t.Cleanup(pt.Shutdown)
 }
 
+func TestOTLPPushTarget(t *testing.T) {
+	pt, port, eh := createPushServer(t, log.NewNopLogger())
+	defer pt.Shutdown()
+
+	pt.SetLabels(model.LabelSet{"pushserver": "otlp"})
+	pt.SetKeepTimestamp(true)
+	pt.SetRelabelRules(parseRelabelRules(t, `
+		rule {
+			source_labels = ["__otlp_resource_attribute_service_name"]
+			target_label  = "service_name"
+		}
+		rule {
+			source_labels = ["__otlp_resource_attribute_host_name"]
+			target_label  = "__structured_metadata_host"
+		}
+	`))
+
+	ts := time.Unix(10, 0).UTC()
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "checkout")
+	rl.Resource().Attributes().PutStr("host.name", "host-1")
+	record := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	record.SetSeverityText("INFO")
+	record.SetTraceID(pcommon.TraceID{1})
+	record.Body().SetStr("hello")
+	record.Attributes().PutStr("user.id", "42")
+
+	url := fmt.Sprintf("http://%s:%d/v1/logs", localhost, port)
+	req := plogotlp.NewExportRequestFromLogs(logs)
+
+	// The endpoint isn't served until it's enabled.
+	b, err := req.MarshalProto()
+	require.NoError(t, err)
+	resp, err := http.Post(url, "application/x-protobuf", bytes.NewReader(b))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	pt.SetEnableOTLP(true)
+	resp, err = http.Post(url, "application/x-protobuf", bytes.NewReader(b))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	b, err = req.MarshalJSON()
+	require.NoError(t, err)
+	resp, err = http.Post(url, "application/json", bytes.NewReader(b))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Eventually(t, func() bool { return len(eh.Received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	for _, e := range eh.Received() {
+		require.Equal(t, model.LabelSet{"pushserver": "otlp", "service_name": "checkout"}, e.Labels)
+		require.Equal(t, "hello", e.Line)
+		require.True(t, ts.Equal(e.Timestamp))
+		require.Equal(t, push.LabelsAdapter{
+			{Name: "user_id", Value: "42"},
+			{Name: "trace_id", Value: "01000000000000000000000000000000"},
+			{Name: "severity_text", Value: "INFO"},
+			{Name: "host", Value: "host-1"},
+		}, e.StructuredMetadata)
+	}
+}
+
+func TestElasticsearchBulkPushTarget(t *testing.T) {
+	pt, port, eh := createPushServer(t, log.NewNopLogger())
+	defer pt.Shutdown()
+
+	pt.SetEnableElasticsearchBulk(true)
+	pt.SetKeepTimestamp(true)
+	pt.SetRelabelRules(parseRelabelRules(t, `
+		rule {
+			source_labels = ["__elasticsearch_index"]
+			target_label  = "index"
+		}
+		rule {
+			source_labels = ["__elasticsearch_field_host_name"]
+			target_label  = "host"
+		}
+		rule {
+			source_labels = ["__elasticsearch_field_log_level"]
+			target_label  = "__structured_metadata_level"
+		}
+	`))
+
+	// The root of the server isn't taken over by the Elasticsearch endpoints.
+	resp, err := http.Get(fmt.Sprintf("http://%s:%d/", localhost, port))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Clients check the version of the cluster before sending documents.
+	resp, err = http.Get(fmt.Sprintf("http://%s:%d/elasticsearch", localhost, port))
+	require.NoError(t, err)
+	info, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "Elasticsearch", resp.Header.Get("X-Elastic-Product"))
+	require.Contains(t, string(info), `"number":"8.11.0"`)
+
+	body := `{"index":{"_index":"logs-app"}}
+{"@timestamp":"2024-01-02T03:04:05.123Z","message":"hello","host":{"name":"host-1"},"log.level":"info"}
+{"create":{}}
+{"msg":"no message field"}
+{"delete":{"_index":"logs-app","_id":"1"}}
+`
+	resp, err = http.Post(fmt.Sprintf("http://%s:%d/elasticsearch/default-index/_bulk", localhost, port), "application/x-ndjson", strings.NewReader(body))
+	require.NoError(t, err)
+	result, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// took varies between runs.
+	var bulkResp map[string]any
+	require.NoError(t, json.Unmarshal(result, &bulkResp))
+	require.Contains(t, bulkResp, "took")
+	delete(bulkResp, "took")
+	result, err = json.Marshal(bulkResp)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"errors": true,
+		"items": [
+			{"index": {"_index": "logs-app", "status": 201, "result": "created"}},
+			{"create": {"_index": "default-index", "status": 201, "result": "created"}},
+			{"delete": {"_index": "logs-app", "status": 400, "error": {"type": "illegal_argument_exception", "reason": "delete actions are not supported"}}}
+		]
+	}`, string(result))
+
+	require.Eventually(t, func() bool { return len(eh.Received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	received := eh.Received()
+
+	require.Equal(t, model.LabelSet{"index": "logs-app", "host": "host-1"}, received[0].Labels)
+	require.Equal(t, "hello", received[0].Line)
+	require.Equal(t, push.LabelsAdapter{{Name: "level", Value: "info"}}, received[0].StructuredMetadata)
+	require.True(t, time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC).Equal(received[0].Timestamp))
+
+	require.Equal(t, model.LabelSet{"index": "default-index"}, received[1].Labels)
+	require.Equal(t, `{"msg":"no message field"}`, received[1].Line)
+
+	// Malformed bodies are rejected.
+	resp, err = http.Post(fmt.Sprintf("http://%s:%d/elasticsearch/_bulk", localhost, port), "application/x-ndjson", strings.NewReader(`{"index":{}}`+"\n{"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestOTLPPushTargetDropsEntriesWithoutLabels(t *testing.T) {
+	pt, port, eh := createPushServer(t, log.NewNopLogger())
+	defer pt.Shutdown()
+
+	pt.SetEnableOTLP(true)
+	pt.SetRelabelRules(parseRelabelRules(t, `
+		rule {
+			source_labels = ["__otlp_resource_attribute_service_name"]
+			target_label  = "service_name"
+		}
+	`))
+
+	// Only the first record has a label left after relabeling.
+	logs := plog.NewLogs()
+	for _, attrs := range []map[string]string{{"service.name": "checkout"}, {"host.name": "host-1"}} {
+		rl := logs.ResourceLogs().AppendEmpty()
+		for k, v := range attrs {
+			rl.Resource().Attributes().PutStr(k, v)
+		}
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr(attrs["service.name"])
+	}
+	b, err := plogotlp.NewExportRequestFromLogs(logs).MarshalProto()
+	require.NoError(t, err)
+
+	// The tenant ID label alone doesn't keep an entry.
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s:%d/v1/logs", localhost, port), bytes.NewReader(b))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Scope-OrgID", "tenant1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Eventually(t, func() bool { return len(eh.Received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, model.LabelSet{
+		"service_name":               "checkout",
+		client.ReservedLabelTenantID: "tenant1",
+	}, eh.Received()[0].Labels)
+	require.Equal(t, 1.0, testutil.ToFloat64(pt.metrics.entriesDroppedWithoutLabels))
+	require.Equal(t, 1.0, testutil.ToFloat64(pt.metrics.entriesWritten))
+}
+
+func parseRelabelRules(t *testing.T, text string) frelabel.Rules {
+	t.Helper()
+	var args struct {
+		Rules []*frelabel.Config `alloy:"rule,block,optional"`
+	}
+	require.NoError(t, syntax.Unmarshal([]byte(text), &args))
+	return args.Rules
+}
+
 func getFreePort(t *testing.T) int {
 	port, err := freeport.GetFreePort()
 	require.NoError(t, err)
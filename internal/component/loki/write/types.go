@@ -10,8 +10,10 @@ import (
 	"github.com/alecthomas/units"
 	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/common/config"
 
 	types "github.com/grafana/alloy/internal/component/common/config"
+	"github.com/grafana/alloy/syntax/alloytypes"
 )
 
 // EndpointOptions describes an individual location to send logs to.
@@ -29,6 +31,10 @@ type EndpointOptions struct {
 	RetryOnHTTP429    bool                    `alloy:"retry_on_http_429,attr,optional"`
 	HTTPClientConfig  *types.HTTPClientConfig `alloy:",squash"`
 	QueueConfig       QueueConfig             `alloy:"queue_config,block,optional"`
+	Tenants           []TenantOptions         `alloy:"tenant,block,optional"`
+	MaxTenants        int                     `alloy:"max_tenants,attr,optional"`
+	Protocol          string                  `alloy:"protocol,attr,optional"`
+	Compression       string                  `alloy:"compression,attr,optional"`
 }
 
 // GetDefaultEndpointOptions defines the default settings for sending logs to a
@@ -47,6 +53,8 @@ func GetDefaultEndpointOptions() EndpointOptions {
 		HTTPClientConfig:  types.CloneDefaultHTTPClientConfig(),
 		RetryOnHTTP429:    true,
 		QueueConfig:       defaultQueueConfig,
+		Protocol:          client.ProtocolLokiProtobuf,
+		MaxTenants:        100,
 	}
 
 	return defaultEndpointOptions
@@ -63,14 +71,97 @@ func (r *EndpointOptions) Validate() error {
 		return fmt.Errorf("failed to parse remote url %q: %w", r.URL, err)
 	}
 
+	if err := client.ValidateEncoding(r.Protocol, r.Compression); err != nil {
+		return err
+	}
+
 	// We must explicitly Validate because HTTPClientConfig is squashed and it won't run otherwise
 	if r.HTTPClientConfig != nil {
-		return r.HTTPClientConfig.Validate()
+		if err := r.HTTPClientConfig.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if r.MaxTenants < 0 {
+		return fmt.Errorf("max_tenants must not be negative")
+	}
+
+	tenantIDs := make(map[string]struct{}, len(r.Tenants))
+	for _, t := range r.Tenants {
+		if _, ok := tenantIDs[t.ID]; ok {
+			return fmt.Errorf("duplicate tenant block for tenant %q", t.ID)
+		}
+		tenantIDs[t.ID] = struct{}{}
 	}
 
 	return nil
 }
 
+// TenantOptions overrides the settings of an endpoint for a single tenant.
+type TenantOptions struct {
+	ID              string               `alloy:"id,attr"`
+	URL             string               `alloy:"url,attr,optional"`
+	Headers         map[string]string    `alloy:"headers,attr,optional"`
+	BasicAuth       *types.BasicAuth     `alloy:"basic_auth,block,optional"`
+	Authorization   *types.Authorization `alloy:"authorization,block,optional"`
+	BearerToken     alloytypes.Secret    `alloy:"bearer_token,attr,optional"`
+	BearerTokenFile string               `alloy:"bearer_token_file,attr,optional"`
+}
+
+// Validate implements syntax.Validator.
+func (t *TenantOptions) Validate() error {
+	if t.ID == "" {
+		return fmt.Errorf("tenant id must not be empty")
+	}
+	if t.URL != "" {
+		if _, err := url.Parse(t.URL); err != nil {
+			return fmt.Errorf("failed to parse url %q of tenant %q: %w", t.URL, t.ID, err)
+		}
+	}
+
+	authCount := 0
+	for _, set := range []bool{t.BasicAuth != nil, t.Authorization != nil, t.BearerToken != "", t.BearerTokenFile != ""} {
+		if set {
+			authCount++
+		}
+	}
+	if authCount > 1 {
+		return fmt.Errorf("at most one of basic_auth, authorization, bearer_token & bearer_token_file must be configured for tenant %q", t.ID)
+	}
+
+	return nil
+}
+
+// convert returns the client configuration of the tenant. The credentials of
+// the tenant replace those of the endpoint, whose other HTTP client settings
+// are kept.
+func (t TenantOptions) convert(endpointClient *types.HTTPClientConfig) client.TenantConfig {
+	var tc client.TenantConfig
+	if t.URL != "" {
+		u, _ := url.Parse(t.URL)
+		tc.URL = flagext.URLValue{URL: u}
+	}
+	tc.Headers = t.Headers
+
+	if t.BasicAuth != nil || t.Authorization != nil || t.BearerToken != "" || t.BearerTokenFile != "" {
+		cfg := endpointClient.Convert()
+		cfg.BasicAuth = t.BasicAuth.Convert()
+		cfg.Authorization = t.Authorization.Convert()
+		cfg.OAuth2 = nil
+		cfg.BearerToken = ""
+		cfg.BearerTokenFile = ""
+		switch {
+		case t.BearerToken != "":
+			cfg.Authorization = &config.Authorization{Type: "Bearer", Credentials: config.Secret(t.BearerToken)}
+		case t.BearerTokenFile != "":
+			cfg.Authorization = &config.Authorization{Type: "Bearer", CredentialsFile: t.BearerTokenFile}
+		}
+		tc.Client = cfg
+	}
+
+	return tc
+}
+
 // QueueConfig controls how shards and queue are configured for endpoint.
 type QueueConfig struct {
 	Capacity        units.Base2Bytes `alloy:"capacity,attr,optional"`
@@ -111,6 +202,9 @@ func (args Arguments) convertEndpointConfigs() []client.Config {
 			TenantID:               cfg.TenantID,
 			MaxStreams:             args.MaxStreams,
 			DropRateLimitedBatches: !cfg.RetryOnHTTP429,
+			Protocol:               cfg.Protocol,
+			Compression:            cfg.Compression,
+			MaxTenants:             cfg.MaxTenants,
 			QueueConfig: client.QueueConfig{
 				Capacity:        int(cfg.QueueConfig.Capacity),
 				MinShards:       cfg.QueueConfig.MinShards,
@@ -118,6 +212,12 @@ func (args Arguments) convertEndpointConfigs() []client.Config {
 				BlockOnOverflow: cfg.QueueConfig.BlockOnOverflow,
 			},
 		}
+		if len(cfg.Tenants) > 0 {
+			cc.Tenants = make(map[string]client.TenantConfig, len(cfg.Tenants))
+			for _, t := range cfg.Tenants {
+				cc.Tenants[t.ID] = t.convert(cfg.HTTPClientConfig)
+			}
+		}
 		res = append(res, cc)
 	}
 
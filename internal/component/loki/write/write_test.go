@@ -57,6 +57,127 @@ func TestBadAlloyConfig(t *testing.T) {
 	require.ErrorContains(t, err, "at most one of basic_auth, authorization, oauth2, bearer_token & bearer_token_file must be configured")
 }
 
+func TestTenantAlloyConfig(t *testing.T) {
+	var args Arguments
+	err := syntax.Unmarshal([]byte(`
+	endpoint {
+		url     = "http://0.0.0.0:11111/loki/api/v1/push"
+		headers = {"X-Team" = "default"}
+
+		tls_config {
+			insecure_skip_verify = true
+		}
+
+		basic_auth {
+			username = "default"
+			password = "default"
+		}
+
+		tenant {
+			id           = "team-a"
+			bearer_token = "token-a"
+			headers      = {"X-Team" = "a"}
+		}
+
+		tenant {
+			id  = "team-b"
+			url = "http://0.0.0.0:22222/loki/api/v1/push"
+		}
+	}
+`), &args)
+	require.NoError(t, err)
+
+	cfgs := args.convertEndpointConfigs()
+	require.Len(t, cfgs, 1)
+	require.Len(t, cfgs[0].Tenants, 2)
+
+	teamA := cfgs[0].Tenants["team-a"]
+	require.Nil(t, teamA.URL.URL)
+	require.Equal(t, map[string]string{"X-Team": "a"}, teamA.Headers)
+	require.NotNil(t, teamA.Client)
+	// The credentials of the tenant replace the ones of the endpoint, but the
+	// other HTTP client settings are kept.
+	require.Nil(t, teamA.Client.BasicAuth)
+	require.Equal(t, "Bearer", teamA.Client.Authorization.Type)
+	require.Equal(t, "token-a", string(teamA.Client.Authorization.Credentials))
+	require.True(t, teamA.Client.TLSConfig.InsecureSkipVerify)
+
+	teamB := cfgs[0].Tenants["team-b"]
+	require.Equal(t, "0.0.0.0:22222", teamB.URL.Host)
+	require.Nil(t, teamB.Client)
+}
+
+func TestBadTenantAlloyConfig(t *testing.T) {
+	tests := map[string]struct {
+		config      string
+		expectedErr string
+	}{
+		"duplicate tenant": {
+			config: `
+			tenant {
+				id = "team-a"
+			}
+			tenant {
+				id = "team-a"
+			}`,
+			expectedErr: `duplicate tenant block for tenant "team-a"`,
+		},
+		"empty tenant id": {
+			config: `
+			tenant {
+				id = ""
+			}`,
+			expectedErr: "tenant id must not be empty",
+		},
+		"multiple credentials": {
+			config: `
+			tenant {
+				id                = "team-a"
+				bearer_token      = "token"
+				bearer_token_file = "/path/to/file.token"
+			}`,
+			expectedErr: `at most one of basic_auth, authorization, bearer_token & bearer_token_file must be configured for tenant "team-a"`,
+		},
+		"negative max tenants": {
+			config:      `max_tenants = -1`,
+			expectedErr: "max_tenants must not be negative",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var args Arguments
+			err := syntax.Unmarshal([]byte(fmt.Sprintf(`
+			endpoint {
+				url = "http://0.0.0.0:11111/loki/api/v1/push"
+				%s
+			}`, tc.config)), &args)
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestEncodingAlloyConfig(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+	endpoint {
+		url         = "http://0.0.0.0:11111/otlp/v1/logs"
+		protocol    = "otlp_http"
+		compression = "zstd"
+	}
+`), &args))
+	cfgs := args.convertEndpointConfigs()
+	require.Equal(t, "otlp_http", cfgs[0].Protocol)
+	require.Equal(t, "zstd", cfgs[0].Compression)
+
+	err := syntax.Unmarshal([]byte(`
+	endpoint {
+		url         = "http://0.0.0.0:11111/loki/api/v1/push"
+		compression = "zstd"
+	}
+`), &args)
+	require.ErrorContains(t, err, `protocol "loki_protobuf" only supports "snappy" compression`)
+}
+
 func TestUnmarshallWalAttrributes(t *testing.T) {
 	type testcase struct {
 		raw           string
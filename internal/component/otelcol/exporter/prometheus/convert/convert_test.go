@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"testing"
 
-	"github.com/grafana/alloy/internal/component/otelcol/exporter/prometheus/internal/convert"
+	"github.com/grafana/alloy/internal/component/otelcol/exporter/prometheus/convert"
 	"github.com/grafana/alloy/internal/util"
 	"github.com/grafana/alloy/internal/util/testappender"
 	"github.com/prometheus/prometheus/storage"
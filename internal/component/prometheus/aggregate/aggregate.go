@@ -0,0 +1,379 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/prometheus"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/service/labelstore"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.aggregate",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// The outputs supported by aggregation rules.
+const (
+	outputSum             = "sum"
+	outputCount           = "count"
+	outputMin             = "min"
+	outputMax             = "max"
+	outputRate            = "rate"
+	outputTotal           = "total"
+	outputHistogramBucket = "histogram_bucket"
+	outputNativeHistogram = "native_histogram"
+)
+
+var supportedOutputs = []string{
+	outputSum,
+	outputCount,
+	outputMin,
+	outputMax,
+	outputRate,
+	outputTotal,
+	outputHistogramBucket,
+	outputNativeHistogram,
+}
+
+// Arguments holds values which are used to configure the prometheus.aggregate
+// component.
+type Arguments struct {
+	// Where the aggregated metrics should be forwarded to.
+	ForwardTo []storage.Appendable `alloy:"forward_to,attr"`
+
+	// The aggregation rules to apply to each metric.
+	Rules []Rule `alloy:"rule,block,optional"`
+
+	// Whether the samples which are aggregated are also forwarded.
+	KeepInput bool `alloy:"keep_input,attr,optional"`
+
+	// How long series are kept in the aggregations without receiving samples.
+	StalenessInterval time.Duration `alloy:"staleness_interval,attr,optional"`
+}
+
+// DefaultArguments provides the default arguments for the
+// prometheus.aggregate component.
+var DefaultArguments = Arguments{
+	StalenessInterval: 5 * time.Minute,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (args *Arguments) Validate() error {
+	if args.StalenessInterval <= 0 {
+		return errors.New("staleness_interval must be greater than 0")
+	}
+	for i, rule := range args.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("invalid rule at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Rule configures how matching series are aggregated.
+type Rule struct {
+	// A series selector of the series to aggregate. All series are aggregated
+	// if it's empty.
+	Match string `alloy:"match,attr,optional"`
+
+	// The labels to group series by.
+	By []string `alloy:"by,attr,optional"`
+
+	// The labels to remove when grouping series.
+	Without []string `alloy:"without,attr,optional"`
+
+	// How often the aggregated series are written.
+	Interval time.Duration `alloy:"interval,attr,optional"`
+
+	// The aggregations to compute.
+	Outputs []string `alloy:"outputs,attr"`
+
+	// Whether the aggregated series keep the name of the input series.
+	KeepMetricNames bool `alloy:"keep_metric_names,attr,optional"`
+}
+
+// DefaultRule provides the default arguments of an aggregation rule.
+var DefaultRule = Rule{
+	Interval: time.Minute,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (r *Rule) SetToDefault() {
+	*r = DefaultRule
+}
+
+func (r *Rule) validate() error {
+	if r.Match != "" {
+		if _, err := parser.ParseMetricSelector(r.Match); err != nil {
+			return fmt.Errorf("invalid match: %w", err)
+		}
+	}
+	if len(r.By) > 0 && len(r.Without) > 0 {
+		return errors.New("by and without can't be used together")
+	}
+	for _, name := range slices.Concat(r.By, r.Without) {
+		if !model.LegacyValidation.IsValidLabelName(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	if r.Interval <= 0 {
+		return errors.New("interval must be greater than 0")
+	}
+	if len(r.Outputs) == 0 {
+		return errors.New("at least one output must be set")
+	}
+	for i, output := range r.Outputs {
+		if !slices.Contains(supportedOutputs, output) {
+			return fmt.Errorf("unsupported output %q, must be one of %v", output, supportedOutputs)
+		}
+		if slices.Contains(r.Outputs[:i], output) {
+			return fmt.Errorf("duplicate output %q", output)
+		}
+	}
+	if r.KeepMetricNames && len(r.Outputs) > 1 {
+		return errors.New("keep_metric_names can only be used with a single output")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the prometheus.aggregate
+// component.
+type Exports struct {
+	Receiver storage.Appendable `alloy:"receiver,attr"`
+}
+
+var _ component.Component = (*Component)(nil)
+
+// Component implements the prometheus.aggregate component.
+type Component struct {
+	opts     component.Options
+	receiver *receiver
+	fanout   *prometheus.Fanout
+	exited   atomic.Bool
+
+	mut         sync.RWMutex
+	args        Arguments
+	aggregators []*aggregator
+
+	// updated is notified when the aggregators change.
+	updated chan struct{}
+
+	samplesAggregated prometheus_client.Counter
+	samplesFailed     prometheus_client.Counter
+	groups            prometheus_client.Gauge
+	flushFailures     prometheus_client.Counter
+}
+
+// New creates a new prometheus.aggregate component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	service, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    o,
+		fanout:  prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+		updated: make(chan struct{}, 1),
+	}
+
+	c.samplesAggregated = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "alloy_prometheus_aggregate_samples_aggregated_total",
+		Help: "Total number of samples which were aggregated",
+	})
+	c.samplesFailed = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "alloy_prometheus_aggregate_samples_failed_total",
+		Help: "Total number of samples which couldn't be aggregated",
+	})
+	c.groups = prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+		Name: "alloy_prometheus_aggregate_groups",
+		Help: "Number of groups of series which are aggregated",
+	})
+	c.flushFailures = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "alloy_prometheus_aggregate_flush_failures_total",
+		Help: "Total number of times the aggregated series couldn't be written",
+	})
+	for _, metric := range []prometheus_client.Collector{c.samplesAggregated, c.samplesFailed, c.groups, c.flushFailures} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.receiver = &receiver{c: c}
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.updated:
+		case now := <-timer.C:
+			c.flush(ctx, now)
+		}
+
+		// Wait until the next aggregator has to be flushed.
+		timer.Stop()
+		c.mut.RLock()
+		next := time.Time{}
+		for _, a := range c.aggregators {
+			if next.IsZero() || a.nextFlush().Before(next) {
+				next = a.nextFlush()
+			}
+		}
+		c.mut.RUnlock()
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	// Aggregators of unchanged rules are kept to not lose their state.
+	now := time.Now()
+	aggregators := make([]*aggregator, 0, len(newArgs.Rules))
+	removed := slices.Clone(c.aggregators)
+	for _, rule := range newArgs.Rules {
+		idx := slices.IndexFunc(removed, func(a *aggregator) bool { return reflect.DeepEqual(a.rule, rule) })
+		if idx >= 0 {
+			aggregators = append(aggregators, removed[idx])
+			removed = slices.Delete(removed, idx, idx+1)
+			continue
+		}
+		a, err := newAggregator(rule, now)
+		if err != nil {
+			return err
+		}
+		aggregators = append(aggregators, a)
+	}
+	for _, a := range aggregators {
+		a.setStalenessInterval(newArgs.StalenessInterval)
+	}
+
+	// The series of the removed aggregators are marked as stale.
+	if len(removed) > 0 {
+		app := c.fanout.Appender(context.Background())
+		for _, a := range removed {
+			a.markStale(now, app)
+		}
+		if err := app.Commit(); err != nil {
+			level.Warn(c.opts.Logger).Log("msg", "failed to write staleness markers", "err", err)
+		}
+	}
+
+	c.args = newArgs
+	c.aggregators = aggregators
+
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// aggregate passes the series l to the aggregators whose rule matches it by
+// calling fn.
+func (c *Component) aggregate(l labels.Labels, fn func(a *aggregator, now time.Time) error) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	now := time.Now()
+	for _, a := range c.aggregators {
+		if !a.matches(l) {
+			continue
+		}
+		if err := fn(a, now); err != nil {
+			c.samplesFailed.Inc()
+			level.Debug(c.opts.Logger).Log("msg", "failed to aggregate sample", "series", l.String(), "err", err)
+			continue
+		}
+		c.samplesAggregated.Inc()
+	}
+}
+
+// forwardInput returns whether data of the series l has to be forwarded.
+func (c *Component) forwardInput(l labels.Labels) bool {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	if c.args.KeepInput {
+		return true
+	}
+	for _, a := range c.aggregators {
+		if a.matches(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// flush writes the aggregated series of the aggregators which are due.
+func (c *Component) flush(ctx context.Context, now time.Time) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	app := c.fanout.Appender(ctx)
+	groups := 0
+	for _, a := range c.aggregators {
+		if !now.Before(a.nextFlush()) {
+			if err := a.flush(now, app); err != nil {
+				level.Warn(c.opts.Logger).Log("msg", "failed to write aggregated series", "err", err)
+			}
+		}
+		groups += a.numGroups()
+	}
+	c.groups.Set(float64(groups))
+
+	if err := app.Commit(); err != nil {
+		c.flushFailures.Inc()
+		level.Warn(c.opts.Logger).Log("msg", "failed to write aggregated series", "err", err)
+	}
+}
@@ -0,0 +1,387 @@
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/internal/util/testappender"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		rule {
+			match   = "{__name__=~\"http_.*\"}"
+			without = ["instance"]
+			outputs = ["total", "rate"]
+		}
+	`), &args))
+	require.Equal(t, DefaultArguments.StalenessInterval, args.StalenessInterval)
+	require.Len(t, args.Rules, 1)
+	require.Equal(t, DefaultRule.Interval, args.Rules[0].Interval)
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{
+			config: `staleness_interval = "0s"`,
+			err:    "staleness_interval must be greater than 0",
+		},
+		{
+			config: `rule {
+				match   = "{"
+				outputs = ["sum"]
+			}`,
+			err: `invalid rule at index 0: invalid match: 1:2: parse error: unexpected end of input inside braces`,
+		},
+		{
+			config: `rule {
+				by      = ["job"]
+				without = ["instance"]
+				outputs = ["sum"]
+			}`,
+			err: "invalid rule at index 0: by and without can't be used together",
+		},
+		{
+			config: `rule {
+				by      = ["not-valid"]
+				outputs = ["sum"]
+			}`,
+			err: `invalid rule at index 0: invalid label name "not-valid"`,
+		},
+		{
+			config: `rule {
+				interval = "0s"
+				outputs  = ["sum"]
+			}`,
+			err: "invalid rule at index 0: interval must be greater than 0",
+		},
+		{
+			config: `rule {
+				outputs = []
+			}`,
+			err: "invalid rule at index 0: at least one output must be set",
+		},
+		{
+			config: `rule {
+				outputs = ["avg"]
+			}`,
+			err: `invalid rule at index 0: unsupported output "avg", must be one of [sum count min max rate total histogram_bucket native_histogram]`,
+		},
+		{
+			config: `rule {
+				outputs = ["sum", "sum"]
+			}`,
+			err: `invalid rule at index 0: duplicate output "sum"`,
+		},
+		{
+			config: `rule {
+				outputs           = ["sum", "count"]
+				keep_metric_names = true
+			}`,
+			err: "invalid rule at index 0: keep_metric_names can only be used with a single output",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.err, func(t *testing.T) {
+			var args Arguments
+			err := syntax.Unmarshal([]byte("forward_to = []\n"+tc.config), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	rule := DefaultRule
+	rule.Without = []string{"instance"}
+	rule.Outputs = []string{outputSum, outputCount, outputMin, outputMax, outputRate, outputTotal}
+	start := time.Unix(0, 0)
+	a, err := newAggregator(rule, start)
+	require.NoError(t, err)
+	a.setStalenessInterval(5 * time.Minute)
+
+	series := func(instance string) labels.Labels {
+		return labels.FromStrings("__name__", "requests_total", "job", "api", "instance", instance)
+	}
+	output := func(name string) string {
+		return fmt.Sprintf(`{__name__="requests_total:1m_without_instance_%s", job="api"}`, name)
+	}
+
+	app := testappender.NewCollectingAppender()
+	require.NoError(t, a.append(series("a"), 10, start))
+	require.NoError(t, a.append(series("b"), 5, start))
+	require.NoError(t, a.flush(start.Add(time.Minute), app))
+
+	require.Equal(t, 15.0, app.LatestSampleFor(output(outputSum)).Value)
+	require.Equal(t, 2.0, app.LatestSampleFor(output(outputCount)).Value)
+	require.Equal(t, 5.0, app.LatestSampleFor(output(outputMin)).Value)
+	require.Equal(t, 10.0, app.LatestSampleFor(output(outputMax)).Value)
+	require.Equal(t, 0.0, app.LatestSampleFor(output(outputRate)).Value)
+	require.Equal(t, 15.0, app.LatestSampleFor(output(outputTotal)).Value)
+	require.Equal(t, start.Add(time.Minute).UnixMilli(), app.LatestSampleFor(output(outputSum)).Timestamp)
+
+	// The counter of b is reset, which must not decrease the total.
+	now := start.Add(90 * time.Second)
+	require.NoError(t, a.append(series("a"), 70, now))
+	require.NoError(t, a.append(series("b"), 2, now))
+	require.NoError(t, a.flush(start.Add(2*time.Minute), app))
+
+	require.Equal(t, 72.0, app.LatestSampleFor(output(outputSum)).Value)
+	require.Equal(t, 2.0, app.LatestSampleFor(output(outputMin)).Value)
+	require.Equal(t, 70.0, app.LatestSampleFor(output(outputMax)).Value)
+	require.Equal(t, 77.0, app.LatestSampleFor(output(outputTotal)).Value)
+	require.Equal(t, 62.0/60, app.LatestSampleFor(output(outputRate)).Value)
+
+	// b is stale, so it isn't part of the aggregation anymore.
+	now = start.Add(150 * time.Second)
+	require.NoError(t, a.append(series("b"), math.Float64frombits(value.StaleNaN), now))
+	require.NoError(t, a.append(series("a"), 73, now))
+	require.NoError(t, a.flush(start.Add(3*time.Minute), app))
+
+	require.Equal(t, 73.0, app.LatestSampleFor(output(outputSum)).Value)
+	require.Equal(t, 1.0, app.LatestSampleFor(output(outputCount)).Value)
+	require.Equal(t, 80.0, app.LatestSampleFor(output(outputTotal)).Value)
+	require.Equal(t, 3.0/60, app.LatestSampleFor(output(outputRate)).Value)
+
+	// a expires after the staleness interval, which removes the group and
+	// marks its series as stale.
+	require.NoError(t, a.flush(start.Add(10*time.Minute), app))
+	require.Equal(t, 0, a.numGroups())
+	for _, name := range rule.Outputs {
+		require.True(t, value.IsStaleNaN(app.LatestSampleFor(output(name)).Value), name)
+	}
+}
+
+func TestAggregator_StaleSeriesComeBack(t *testing.T) {
+	rule := DefaultRule
+	rule.Without = []string{"instance"}
+	rule.Outputs = []string{outputSum, outputCount, outputTotal, outputNativeHistogram}
+	start := time.Unix(0, 0)
+	a, err := newAggregator(rule, start)
+	require.NoError(t, err)
+	a.setStalenessInterval(5 * time.Minute)
+
+	counter := func(instance string) labels.Labels {
+		return labels.FromStrings("__name__", "requests_total", "instance", instance)
+	}
+	hist := func(instance string) labels.Labels {
+		return labels.FromStrings("__name__", "latency", "instance", instance)
+	}
+	h := &histogram.Histogram{
+		Count:           10,
+		Sum:             10,
+		PositiveSpans:   []histogram.Span{{Offset: 0, Length: 1}},
+		PositiveBuckets: []int64{10},
+	}
+	output := func(name, output string) string {
+		return fmt.Sprintf(`{__name__="%s:1m_without_instance_%s"}`, name, output)
+	}
+	appendAll := func(now time.Time, instances ...string) {
+		for _, instance := range instances {
+			require.NoError(t, a.append(counter(instance), 100, now))
+			require.NoError(t, a.appendHistogram(hist(instance), h, nil, now))
+		}
+	}
+
+	app := testappender.NewCollectingAppender()
+	appendAll(start, "a", "b")
+	require.NoError(t, a.flush(start.Add(time.Minute), app))
+	require.Equal(t, 200.0, app.LatestSampleFor(output("requests_total", outputTotal)).Value)
+	require.Equal(t, 20.0, app.CollectedHistograms()[output("latency", outputNativeHistogram)].FloatHistogram.Count)
+
+	// A failed scrape of a marks its series as stale, which removes them from
+	// the aggregations.
+	now := start.Add(90 * time.Second)
+	appendAll(now, "b")
+	require.NoError(t, a.append(counter("a"), math.Float64frombits(value.StaleNaN), now))
+	require.NoError(t, a.appendHistogram(hist("a"), nil, &histogram.FloatHistogram{Sum: math.Float64frombits(value.StaleNaN)}, now))
+	require.NoError(t, a.flush(start.Add(2*time.Minute), app))
+	require.Equal(t, 100.0, app.LatestSampleFor(output("requests_total", outputSum)).Value)
+	require.Equal(t, 1.0, app.LatestSampleFor(output("requests_total", outputCount)).Value)
+	require.Equal(t, 200.0, app.LatestSampleFor(output("requests_total", outputTotal)).Value)
+
+	// The series of a come back with the same values: the totals must not
+	// count their cumulative values again.
+	appendAll(start.Add(150*time.Second), "a", "b")
+	require.NoError(t, a.flush(start.Add(3*time.Minute), app))
+	require.Equal(t, 200.0, app.LatestSampleFor(output("requests_total", outputSum)).Value)
+	require.Equal(t, 2.0, app.LatestSampleFor(output("requests_total", outputCount)).Value)
+	require.Equal(t, 200.0, app.LatestSampleFor(output("requests_total", outputTotal)).Value)
+	require.Equal(t, 20.0, app.CollectedHistograms()[output("latency", outputNativeHistogram)].FloatHistogram.Count)
+}
+
+func TestAggregator_HistogramBucket(t *testing.T) {
+	rule := DefaultRule
+	rule.By = []string{"job"}
+	rule.Outputs = []string{outputHistogramBucket}
+	rule.KeepMetricNames = true
+	start := time.Unix(0, 0)
+	a, err := newAggregator(rule, start)
+	require.NoError(t, err)
+	a.setStalenessInterval(5 * time.Minute)
+
+	app := testappender.NewCollectingAppender()
+	for _, instance := range []string{"a", "b"} {
+		require.NoError(t, a.append(labels.FromStrings("__name__", "latency_bucket", "job", "api", "instance", instance, "le", "1"), 1, start))
+		require.NoError(t, a.append(labels.FromStrings("__name__", "latency_bucket", "job", "api", "instance", instance, "le", "+Inf"), 3, start))
+	}
+	require.NoError(t, a.flush(start.Add(time.Minute), app))
+
+	require.Equal(t, 2.0, app.LatestSampleFor(`{__name__="latency_bucket", job="api", le="1"}`).Value)
+	require.Equal(t, 6.0, app.LatestSampleFor(`{__name__="latency_bucket", job="api", le="+Inf"}`).Value)
+}
+
+func TestAggregator_NativeHistogram(t *testing.T) {
+	rule := DefaultRule
+	rule.Without = []string{"instance"}
+	rule.Outputs = []string{outputNativeHistogram}
+	start := time.Unix(0, 0)
+	a, err := newAggregator(rule, start)
+	require.NoError(t, err)
+	a.setStalenessInterval(5 * time.Minute)
+
+	h := func(count uint64) *histogram.Histogram {
+		return &histogram.Histogram{
+			Count:           count,
+			Sum:             float64(count),
+			Schema:          0,
+			PositiveSpans:   []histogram.Span{{Offset: 0, Length: 1}},
+			PositiveBuckets: []int64{int64(count)},
+		}
+	}
+	series := func(instance string) labels.Labels {
+		return labels.FromStrings("__name__", "latency", "instance", instance)
+	}
+	output := `{__name__="latency:1m_without_instance_native_histogram"}`
+
+	app := testappender.NewCollectingAppender()
+	require.NoError(t, a.appendHistogram(series("a"), h(4), nil, start))
+	require.NoError(t, a.appendHistogram(series("b"), h(2), nil, start))
+	require.NoError(t, a.flush(start.Add(time.Minute), app))
+	require.Equal(t, 6.0, app.CollectedHistograms()[output].FloatHistogram.Count)
+
+	// The histogram of b is reset.
+	require.NoError(t, a.appendHistogram(series("a"), h(5), nil, start.Add(time.Minute)))
+	require.NoError(t, a.appendHistogram(series("b"), h(1), nil, start.Add(time.Minute)))
+	require.NoError(t, a.flush(start.Add(2*time.Minute), app))
+	require.Equal(t, 8.0, app.CollectedHistograms()[output].FloatHistogram.Count)
+	require.Equal(t, 8.0, app.CollectedHistograms()[output].FloatHistogram.Sum)
+
+	// Both series are stale.
+	require.NoError(t, a.appendHistogram(series("a"), nil, &histogram.FloatHistogram{Sum: math.Float64frombits(value.StaleNaN)}, start.Add(2*time.Minute)))
+	require.NoError(t, a.appendHistogram(series("b"), nil, &histogram.FloatHistogram{Sum: math.Float64frombits(value.StaleNaN)}, start.Add(2*time.Minute)))
+	require.NoError(t, a.flush(start.Add(3*time.Minute), app))
+	require.True(t, value.IsStaleNaN(app.CollectedHistograms()[output].FloatHistogram.Sum))
+}
+
+func TestComponent(t *testing.T) {
+	receiver, app := runComponent(t, `
+		forward_to = []
+		rule {
+			match    = "{__name__=\"requests_total\"}"
+			by       = ["job"]
+			interval = "1s"
+			outputs  = ["total"]
+		}
+	`)
+
+	a := receiver.Appender(t.Context())
+	_, err := a.Append(0, labels.FromStrings("__name__", "requests_total", "job", "api", "instance", "a"), 0, 1)
+	require.NoError(t, err)
+	_, err = a.Append(0, labels.FromStrings("__name__", "requests_total", "job", "api", "instance", "b"), 0, 2)
+	require.NoError(t, err)
+	_, err = a.Append(0, labels.FromStrings("__name__", "up", "job", "api", "instance", "a"), 0, 1)
+	require.NoError(t, err)
+	require.NoError(t, a.Commit())
+
+	require.Eventually(t, func() bool {
+		s := app.LatestSampleFor(`{__name__="requests_total:1s_by_job_total", job="api"}`)
+		return s != nil && s.Value == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Only the series which aren't aggregated are forwarded.
+	require.NotNil(t, app.LatestSampleFor(`{__name__="up", instance="a", job="api"}`))
+	require.Nil(t, app.LatestSampleFor(`{__name__="requests_total", instance="a", job="api"}`))
+}
+
+func TestComponent_Rollback(t *testing.T) {
+	receiver, app := runComponent(t, `
+		forward_to = []
+		rule {
+			by       = ["job"]
+			interval = "1s"
+			outputs  = ["total", "count", "max"]
+		}
+	`)
+	output := func(name string) string {
+		return fmt.Sprintf(`{__name__="requests_total:1s_by_job_%s", job="api"}`, name)
+	}
+
+	// The samples of a rolled back appender aren't aggregated.
+	a := receiver.Appender(t.Context())
+	_, err := a.Append(0, labels.FromStrings("__name__", "requests_total", "job", "api", "instance", "a"), 0, 100)
+	require.NoError(t, err)
+	_, err = a.Append(0, labels.FromStrings("__name__", "requests_total", "job", "api", "instance", "b"), 0, 100)
+	require.NoError(t, err)
+	require.NoError(t, a.Rollback())
+
+	a = receiver.Appender(t.Context())
+	_, err = a.Append(0, labels.FromStrings("__name__", "requests_total", "job", "api", "instance", "a"), 0, 1)
+	require.NoError(t, err)
+	require.NoError(t, a.Commit())
+
+	require.Eventually(t, func() bool {
+		return app.LatestSampleFor(output(outputTotal)) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1.0, app.LatestSampleFor(output(outputTotal)).Value)
+	require.Equal(t, 1.0, app.LatestSampleFor(output(outputCount)).Value)
+	require.Equal(t, 1.0, app.LatestSampleFor(output(outputMax)).Value)
+}
+
+// runComponent runs a prometheus.aggregate component with the config, and
+// returns its receiver and the appender its outputs are written to.
+func runComponent(t *testing.T, config string) (storage.Appendable, testappender.CollectingAppender) {
+	app := testappender.NewCollectingAppender()
+
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(config), &args))
+	args.ForwardTo = []storage.Appendable{testappender.ConstantAppendable{Inner: app}}
+
+	var receiver storage.Appendable
+	c, err := New(component.Options{
+		ID:         "prometheus.aggregate.test",
+		Logger:     util.TestAlloyLogger(t),
+		Registerer: prom.NewRegistry(),
+		OnStateChange: func(e component.Exports) {
+			receiver = e.(Exports).Receiver
+		},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+	go c.Run(t.Context())
+	return receiver, app
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case labelstore.ServiceName:
+		return labelstore.New(nil, prom.DefaultRegisterer), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
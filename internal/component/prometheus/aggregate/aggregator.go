@@ -0,0 +1,423 @@
+package aggregate
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// aggregator aggregates the series matching a rule.
+type aggregator struct {
+	rule     Rule
+	matchers []*labels.Matcher
+
+	// grouping are the labels which are kept, or removed if without is true,
+	// when grouping series.
+	grouping []string
+	without  bool
+	// suffix is added to the names of the aggregated series.
+	suffix string
+
+	mut               sync.Mutex
+	stalenessInterval time.Duration
+	groups            map[string]*group
+	lastFlush         time.Time
+	next              time.Time
+}
+
+// group holds the state of the series aggregated together.
+type group struct {
+	labels labels.Labels
+	series map[string]*series
+
+	// The following fields are reset after every flush.
+	floatSamples int
+	min, max     float64
+	increase     float64
+
+	// total is the sum of the increases of the float series, and totalHistogram
+	// the sum of the increases of the counter histogram series.
+	total          float64
+	totalHistogram *histogram.FloatHistogram
+
+	// emitted holds the outputs which were written for the group.
+	emitted map[string]struct{}
+}
+
+// series holds the state of an input series.
+type series struct {
+	lastSeen time.Time
+	// stale is true if the last sample of the series was a staleness marker.
+	// Stale series aren't part of the aggregations anymore, but their last
+	// value is kept until the staleness interval elapses, so that the
+	// increases of the series are correct if it comes back.
+	stale bool
+
+	value    float64
+	hasValue bool
+
+	histogram *histogram.FloatHistogram
+	gauge     bool
+}
+
+func newAggregator(rule Rule, now time.Time) (*aggregator, error) {
+	var matchers []*labels.Matcher
+	if rule.Match != "" {
+		var err error
+		matchers, err = parser.ParseMetricSelector(rule.Match)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a := &aggregator{
+		rule:      rule,
+		matchers:  matchers,
+		without:   len(rule.By) == 0,
+		groups:    make(map[string]*group),
+		lastFlush: now,
+	}
+
+	var suffix strings.Builder
+	suffix.WriteString(":" + model.Duration(rule.Interval).String())
+	if a.without {
+		// The name of the series is always kept, and the buckets of classic
+		// histograms aren't merged together.
+		a.grouping = slices.DeleteFunc(slices.Clone(rule.Without), func(name string) bool {
+			return name == model.MetricNameLabel || (name == model.BucketLabel && slices.Contains(rule.Outputs, outputHistogramBucket))
+		})
+		if len(rule.Without) > 0 {
+			suffix.WriteString("_without_" + strings.Join(rule.Without, "_"))
+		}
+	} else {
+		a.grouping = append(slices.Clone(rule.By), model.MetricNameLabel)
+		if slices.Contains(rule.Outputs, outputHistogramBucket) {
+			a.grouping = append(a.grouping, model.BucketLabel)
+		}
+		suffix.WriteString("_by_" + strings.Join(rule.By, "_"))
+	}
+	a.suffix = suffix.String()
+	a.next = a.nextFlushAfter(now)
+	return a, nil
+}
+
+func (a *aggregator) setStalenessInterval(interval time.Duration) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.stalenessInterval = interval
+}
+
+// matches returns whether the series l is aggregated by a.
+func (a *aggregator) matches(l labels.Labels) bool {
+	for _, m := range a.matchers {
+		if !m.Matches(l.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// group returns the group of the series l. It must be called with a.mut held.
+func (a *aggregator) group(l labels.Labels) *group {
+	lb := labels.NewBuilder(l)
+	if a.without {
+		lb.Del(a.grouping...)
+	} else {
+		lb.Keep(a.grouping...)
+	}
+	gl := lb.Labels()
+
+	key := string(gl.Bytes(nil))
+	g, ok := a.groups[key]
+	if !ok {
+		g = &group{
+			labels:  gl,
+			series:  make(map[string]*series),
+			emitted: make(map[string]struct{}),
+		}
+		a.groups[key] = g
+	}
+	return g
+}
+
+// append aggregates the float sample v of the series l.
+func (a *aggregator) append(l labels.Labels, v float64, now time.Time) error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	g := a.group(l)
+	key := string(l.Bytes(nil))
+	if value.IsStaleNaN(v) {
+		if s, ok := g.series[key]; ok {
+			s.stale = true
+		}
+		return nil
+	}
+
+	s, ok := g.series[key]
+	if !ok {
+		s = &series{}
+		g.series[key] = s
+	}
+
+	// The increases of the series are computed as if it was a counter. The
+	// first sample of a series is fully added to the total, so that it matches
+	// the sum of its series, but doesn't count towards the rate as the time
+	// the series started is unknown.
+	if s.hasValue {
+		increase := v - s.value
+		if v < s.value {
+			// The counter was reset.
+			increase = v
+		}
+		g.increase += increase
+		g.total += increase
+	} else {
+		g.total += v
+	}
+	s.value, s.hasValue, s.lastSeen, s.stale = v, true, now, false
+
+	if g.floatSamples == 0 || v < g.min {
+		g.min = v
+	}
+	if g.floatSamples == 0 || v > g.max {
+		g.max = v
+	}
+	g.floatSamples++
+	return nil
+}
+
+// appendHistogram aggregates the native histogram sample of the series l.
+func (a *aggregator) appendHistogram(l labels.Labels, h *histogram.Histogram, fh *histogram.FloatHistogram, now time.Time) error {
+	if h != nil {
+		fh = h.ToFloat(nil)
+	} else if fh == nil {
+		return errors.New("missing histogram")
+	} else {
+		fh = fh.Copy()
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	g := a.group(l)
+	key := string(l.Bytes(nil))
+	if value.IsStaleNaN(fh.Sum) {
+		if s, ok := g.series[key]; ok {
+			s.stale = true
+		}
+		return nil
+	}
+
+	s, ok := g.series[key]
+	if !ok {
+		s = &series{}
+		g.series[key] = s
+	}
+
+	if fh.CounterResetHint == histogram.GaugeType {
+		// Gauge histograms are summed when the group is flushed.
+		s.histogram, s.gauge, s.lastSeen, s.stale = fh, true, now, false
+		return nil
+	}
+
+	increase := fh.Copy()
+	if s.histogram != nil && !s.gauge && !fh.DetectReset(s.histogram) {
+		var err error
+		if increase, _, _, err = increase.Sub(s.histogram); err != nil {
+			return err
+		}
+	}
+	increase.CounterResetHint = histogram.UnknownCounterReset
+	if g.totalHistogram == nil {
+		g.totalHistogram = increase
+	} else {
+		total, _, _, err := g.totalHistogram.Copy().Add(increase)
+		if err != nil {
+			return err
+		}
+		g.totalHistogram = total.Compact(0)
+	}
+	s.histogram, s.gauge, s.lastSeen, s.stale = fh, false, now, false
+	return nil
+}
+
+func (a *aggregator) nextFlush() time.Time {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return a.next
+}
+
+// nextFlushAfter returns the next flush after t, which is aligned to the
+// interval of the rule.
+func (a *aggregator) nextFlushAfter(t time.Time) time.Time {
+	return t.Truncate(a.rule.Interval).Add(a.rule.Interval)
+}
+
+func (a *aggregator) numGroups() int {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return len(a.groups)
+}
+
+// flush writes the aggregated series to app. The aggregated series of groups
+// without active series are marked as stale, and the groups are removed once
+// their series expired.
+func (a *aggregator) flush(now time.Time, app storage.Appender) error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	elapsed := now.Sub(a.lastFlush).Seconds()
+	a.lastFlush = now
+	a.next = a.nextFlushAfter(now)
+
+	t := now.UnixMilli()
+	var errs []error
+	for key, g := range a.groups {
+		for k, s := range g.series {
+			if now.Sub(s.lastSeen) > a.stalenessInterval {
+				delete(g.series, k)
+			}
+		}
+		if len(g.series) == 0 {
+			errs = append(errs, a.markGroupStale(g, t, app))
+			delete(a.groups, key)
+			continue
+		}
+
+		var (
+			activeSeries int
+			floatSeries  int
+			sum          float64
+			hist         *histogram.FloatHistogram
+		)
+		if g.totalHistogram != nil {
+			hist = g.totalHistogram.Copy()
+		}
+		for _, s := range g.series {
+			if s.stale {
+				continue
+			}
+			activeSeries++
+			switch {
+			case s.hasValue:
+				floatSeries++
+				sum += s.value
+			case s.gauge && hist == nil:
+				hist = s.histogram.Copy()
+			case s.gauge:
+				res, _, _, err := hist.Add(s.histogram)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				hist = res
+			}
+		}
+
+		if activeSeries == 0 {
+			errs = append(errs, a.markGroupStale(g, t, app))
+			clear(g.emitted)
+			g.floatSamples = 0
+			g.increase = 0
+			continue
+		}
+
+		for _, output := range a.rule.Outputs {
+			var err error
+			l := a.outputLabels(g, output)
+			switch output {
+			case outputSum:
+				if floatSeries > 0 {
+					_, err = app.Append(0, l, t, sum)
+					g.emitted[output] = struct{}{}
+				}
+			case outputCount:
+				_, err = app.Append(0, l, t, float64(activeSeries))
+				g.emitted[output] = struct{}{}
+			case outputMin:
+				if g.floatSamples > 0 {
+					_, err = app.Append(0, l, t, g.min)
+					g.emitted[output] = struct{}{}
+				}
+			case outputMax:
+				if g.floatSamples > 0 {
+					_, err = app.Append(0, l, t, g.max)
+					g.emitted[output] = struct{}{}
+				}
+			case outputRate:
+				if floatSeries > 0 && elapsed > 0 {
+					_, err = app.Append(0, l, t, g.increase/elapsed)
+					g.emitted[output] = struct{}{}
+				}
+			case outputTotal, outputHistogramBucket:
+				if floatSeries > 0 {
+					_, err = app.Append(0, l, t, g.total)
+					g.emitted[output] = struct{}{}
+				}
+			case outputNativeHistogram:
+				if hist != nil {
+					if g.totalHistogram == nil {
+						hist.CounterResetHint = histogram.GaugeType
+					}
+					_, err = app.AppendHistogram(0, l, t, nil, hist)
+					g.emitted[output] = struct{}{}
+				}
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		g.floatSamples = 0
+		g.increase = 0
+	}
+	return errors.Join(errs...)
+}
+
+// markStale marks all the aggregated series as stale.
+func (a *aggregator) markStale(now time.Time, app storage.Appender) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	for key, g := range a.groups {
+		_ = a.markGroupStale(g, now.UnixMilli(), app)
+		delete(a.groups, key)
+	}
+}
+
+func (a *aggregator) markGroupStale(g *group, t int64, app storage.Appender) error {
+	var errs []error
+	for output := range g.emitted {
+		var err error
+		l := a.outputLabels(g, output)
+		if output == outputNativeHistogram {
+			_, err = app.AppendHistogram(0, l, t, nil, &histogram.FloatHistogram{Sum: math.Float64frombits(value.StaleNaN)})
+		} else {
+			_, err = app.Append(0, l, t, math.Float64frombits(value.StaleNaN))
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// outputLabels returns the labels of the aggregated series of the group g for
+// the output.
+func (a *aggregator) outputLabels(g *group, output string) labels.Labels {
+	if a.rule.KeepMetricNames {
+		return g.labels
+	}
+	lb := labels.NewBuilder(g.labels)
+	lb.Set(model.MetricNameLabel, g.labels.Get(model.MetricNameLabel)+a.suffix+"_"+output)
+	return lb.Labels()
+}
@@ -0,0 +1,155 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// receiver is the storage.Appendable exported by the component.
+type receiver struct {
+	c *Component
+}
+
+var _ storage.Appendable = (*receiver)(nil)
+
+// Appender implements storage.Appendable.
+func (r *receiver) Appender(ctx context.Context) storage.Appender {
+	return &appender{
+		c:     r.c,
+		child: r.c.fanout.Appender(ctx),
+	}
+}
+
+func (r *receiver) String() string {
+	return r.c.opts.ID + ".receiver"
+}
+
+// appender forwards the series which aren't aggregated, or all series if
+// keep_input is set, to the fanout of the component. The samples are only
+// aggregated when the appender is committed, so that rolled back samples
+// don't change the aggregations.
+type appender struct {
+	c     *Component
+	child storage.Appender
+
+	// pending holds the samples appended since the appender was created.
+	pending []pendingSample
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// pendingSample is a float or native histogram sample waiting to be
+// aggregated.
+type pendingSample struct {
+	labels labels.Labels
+	value  float64
+	h      *histogram.Histogram
+	fh     *histogram.FloatHistogram
+}
+
+// aggregate applies the sample to the aggregators at time now.
+func (s pendingSample) aggregate(a *aggregator, now time.Time) error {
+	if s.h != nil || s.fh != nil {
+		return a.appendHistogram(s.labels, s.h, s.fh, now)
+	}
+	return a.append(s.labels, s.value, now)
+}
+
+// forward calls f with the child appender if data of the series l has to be
+// forwarded, and returns ref unchanged otherwise.
+func (a *appender) forward(ref storage.SeriesRef, l labels.Labels, f func(app storage.Appender) (storage.SeriesRef, error)) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+	if !a.c.forwardInput(l) {
+		return ref, nil
+	}
+	return f(a.child)
+}
+
+// SetOptions implements storage.Appender.
+func (a *appender) SetOptions(opts *storage.AppendOptions) {
+	a.child.SetOptions(opts)
+}
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	ref, err := a.forward(ref, l, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.Append(ref, l, t, v)
+	})
+	if err == nil {
+		a.pending = append(a.pending, pendingSample{labels: l, value: v})
+	}
+	return ref, err
+}
+
+// AppendHistogram implements storage.Appender.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	ref, err := a.forward(ref, l, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendHistogram(ref, l, t, h, fh)
+	})
+	if err == nil {
+		// The histograms are copied as the caller may reuse them once they're
+		// appended.
+		s := pendingSample{labels: l}
+		if h != nil {
+			s.h = h.Copy()
+		}
+		if fh != nil {
+			s.fh = fh.Copy()
+		}
+		a.pending = append(a.pending, s)
+	}
+	return ref, err
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return a.forward(ref, l, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendExemplar(ref, l, e)
+	})
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	return a.forward(ref, l, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.UpdateMetadata(ref, l, m)
+	})
+}
+
+// AppendCTZeroSample implements storage.Appender.
+func (a *appender) AppendCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	return a.forward(ref, l, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendCTZeroSample(ref, l, t, ct)
+	})
+}
+
+// AppendHistogramCTZeroSample implements storage.Appender.
+func (a *appender) AppendHistogramCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return a.forward(ref, l, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendHistogramCTZeroSample(ref, l, t, ct, h, fh)
+	})
+}
+
+// Commit implements storage.Appender. The pending samples are aggregated
+// before the forwarded data is committed.
+func (a *appender) Commit() error {
+	for _, s := range a.pending {
+		a.c.aggregate(s.labels, s.aggregate)
+	}
+	a.pending = nil
+	return a.child.Commit()
+}
+
+// Rollback implements storage.Appender. The pending samples are dropped.
+func (a *appender) Rollback() error {
+	a.pending = nil
+	return a.child.Rollback()
+}
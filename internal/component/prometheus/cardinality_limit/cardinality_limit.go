@@ -0,0 +1,324 @@
+package cardinality_limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/prometheus"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/service/labelstore"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.cardinality_limit",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// The actions applied to new series above the limit of their metric.
+const (
+	actionDrop     = "drop"
+	actionOverflow = "overflow"
+)
+
+// pruneInterval is how often series which left the window stop being
+// tracked.
+const pruneInterval = time.Minute
+
+// Arguments holds values which are used to configure the
+// prometheus.cardinality_limit component.
+type Arguments struct {
+	// Where the metrics should be forwarded to.
+	ForwardTo []storage.Appendable `alloy:"forward_to,attr"`
+
+	// How long series are active after their last sample.
+	Window time.Duration `alloy:"window,attr,optional"`
+
+	// The default maximum number of active series per metric.
+	MaxSeriesPerMetric int `alloy:"max_series_per_metric,attr,optional"`
+
+	// What to do with new series above the limit of their metric.
+	Action string `alloy:"action,attr,optional"`
+
+	// The labels whose values are tracked, and replaced by the overflow action.
+	Labels []string `alloy:"labels,attr,optional"`
+
+	// How many metrics and label values are reported in the debug information.
+	TopN int `alloy:"top_n,attr,optional"`
+
+	// Limits of specific metrics.
+	MetricLimits []MetricLimit `alloy:"metric_limit,block,optional"`
+}
+
+// MetricLimit overrides the maximum number of active series of a metric.
+type MetricLimit struct {
+	Name      string `alloy:"name,attr"`
+	MaxSeries int    `alloy:"max_series,attr"`
+}
+
+// DefaultArguments provides the default arguments for the
+// prometheus.cardinality_limit component.
+var DefaultArguments = Arguments{
+	Window: 20 * time.Minute,
+	Action: actionDrop,
+	TopN:   10,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (args *Arguments) Validate() error {
+	if args.Window <= 0 {
+		return errors.New("window must be greater than 0")
+	}
+	if args.MaxSeriesPerMetric < 0 {
+		return errors.New("max_series_per_metric can't be negative")
+	}
+	switch args.Action {
+	case actionDrop:
+	case actionOverflow:
+		if len(args.Labels) == 0 {
+			return fmt.Errorf("labels must be set when action is %q", actionOverflow)
+		}
+	default:
+		return fmt.Errorf("unsupported action %q, must be %q or %q", args.Action, actionDrop, actionOverflow)
+	}
+	for i, name := range args.Labels {
+		if !model.LegacyValidation.IsValidLabelName(name) || name == model.MetricNameLabel {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if slices.Contains(args.Labels[:i], name) {
+			return fmt.Errorf("duplicate label %q", name)
+		}
+	}
+	if args.TopN <= 0 {
+		return errors.New("top_n must be greater than 0")
+	}
+	for i, limit := range args.MetricLimits {
+		if limit.Name == "" {
+			return fmt.Errorf("metric_limit at index %d: name must be set", i)
+		}
+		if limit.MaxSeries < 0 {
+			return fmt.Errorf("metric_limit at index %d: max_series can't be negative", i)
+		}
+		if slices.ContainsFunc(args.MetricLimits[:i], func(l MetricLimit) bool { return l.Name == limit.Name }) {
+			return fmt.Errorf("duplicate metric_limit for %q", limit.Name)
+		}
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the
+// prometheus.cardinality_limit component.
+type Exports struct {
+	Receiver storage.Appendable `alloy:"receiver,attr"`
+}
+
+var (
+	_ component.Component      = (*Component)(nil)
+	_ component.DebugComponent = (*Component)(nil)
+)
+
+// Component implements the prometheus.cardinality_limit component.
+type Component struct {
+	opts     component.Options
+	receiver *prometheus.Interceptor
+	fanout   *prometheus.Fanout
+	tracker  *tracker
+	exited   atomic.Bool
+
+	mut  sync.RWMutex
+	args Arguments
+
+	activeSeries   prometheus_client.Gauge
+	limitedMetrics prometheus_client.Gauge
+	samplesLimited prometheus_client.Counter
+}
+
+// New creates a new prometheus.cardinality_limit component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	service, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    o,
+		fanout:  prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls),
+		tracker: newTracker(),
+	}
+
+	c.activeSeries = prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+		Name: "alloy_prometheus_cardinality_limit_active_series",
+		Help: "Number of active series",
+	})
+	c.limitedMetrics = prometheus_client.NewGauge(prometheus_client.GaugeOpts{
+		Name: "alloy_prometheus_cardinality_limit_limited_metrics",
+		Help: "Number of metrics which reached their limit of active series",
+	})
+	c.samplesLimited = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "alloy_prometheus_cardinality_limit_samples_limited_total",
+		Help: "Total number of samples of new series which were dropped or overflowed",
+	})
+	for _, metric := range []prometheus_client.Collector{c.activeSeries, c.limitedMetrics, c.samplesLimited} {
+		if err := o.Registerer.Register(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	c.receiver = prometheus.NewInterceptor(
+		c.fanout,
+		prometheus.WithComponentID(o.ID),
+		prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			newLabels, limited, ok := c.admit(l, value.IsStaleNaN(v))
+			if !ok {
+				return ref, nil
+			}
+			if limited {
+				// Since SeriesRefs are tied to the labels, we send zero to indicate the seriesRef should be recalculated downstream.
+				ref = 0
+			}
+			return next.Append(ref, newLabels, t, v)
+		}),
+		prometheus.WithHistogramHook(func(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			stale := (h != nil && value.IsStaleNaN(h.Sum)) || (fh != nil && value.IsStaleNaN(fh.Sum))
+			newLabels, limited, ok := c.admit(l, stale)
+			if !ok {
+				return ref, nil
+			}
+			if limited {
+				ref = 0
+			}
+			return next.AppendHistogram(ref, newLabels, t, h, fh)
+		}),
+		prometheus.WithExemplarHook(func(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			newLabels, limited, ok := c.tracker.lookup(l)
+			if !ok {
+				return ref, nil
+			}
+			if limited {
+				ref = 0
+			}
+			return next.AppendExemplar(ref, newLabels, e)
+		}),
+		prometheus.WithMetadataHook(func(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			newLabels, limited, ok := c.tracker.lookup(l)
+			if !ok {
+				return ref, nil
+			}
+			if limited {
+				ref = 0
+			}
+			return next.UpdateMetadata(ref, newLabels, m)
+		}),
+		prometheus.WithCTZeroSampleHook(func(ref storage.SeriesRef, l labels.Labels, t, ct int64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+			newLabels, limited, ok := c.tracker.lookup(l)
+			if !ok {
+				return ref, nil
+			}
+			if limited {
+				ref = 0
+			}
+			return next.AppendCTZeroSample(ref, newLabels, t, ct)
+		}),
+	)
+	o.OnStateChange(Exports{Receiver: c.receiver})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			c.tracker.prune(now)
+			c.updateMetrics()
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.args = newArgs
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+	c.tracker.update(newArgs)
+	c.updateMetrics()
+	return nil
+}
+
+// admit returns the labels the sample of the series l has to be forwarded
+// with and whether the series is limited, or false if the sample has to be
+// dropped. Staleness markers stop the
+// tracking of their series, and are dropped for series which aren't tracked
+// as they'd otherwise mark the overflow series as stale.
+func (c *Component) admit(l labels.Labels, stale bool) (newLabels labels.Labels, limited bool, ok bool) {
+	if stale {
+		return l, false, c.tracker.remove(l)
+	}
+	newLabels, limited, ok = c.tracker.admit(l, time.Now())
+	if limited {
+		c.samplesLimited.Inc()
+	}
+	return newLabels, limited, ok
+}
+
+func (c *Component) updateMetrics() {
+	activeSeries, limitedMetrics := c.tracker.stats()
+	c.activeSeries.Set(float64(activeSeries))
+	c.limitedMetrics.Set(float64(limitedMetrics))
+}
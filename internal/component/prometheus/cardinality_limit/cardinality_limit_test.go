@@ -0,0 +1,247 @@
+package cardinality_limit
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/internal/util/testappender"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to            = []
+		max_series_per_metric = 100
+		action                = "overflow"
+		labels                = ["pod"]
+		metric_limit {
+			name       = "http_requests_total"
+			max_series = 1000
+		}
+	`), &args))
+	require.Equal(t, DefaultArguments.Window, args.Window)
+	require.Equal(t, DefaultArguments.TopN, args.TopN)
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{
+			config: `window = "0s"`,
+			err:    "window must be greater than 0",
+		},
+		{
+			config: `max_series_per_metric = -1`,
+			err:    "max_series_per_metric can't be negative",
+		},
+		{
+			config: `action = "keep"`,
+			err:    `unsupported action "keep", must be "drop" or "overflow"`,
+		},
+		{
+			config: `action = "overflow"`,
+			err:    `labels must be set when action is "overflow"`,
+		},
+		{
+			config: `labels = ["__name__"]`,
+			err:    `invalid label name "__name__"`,
+		},
+		{
+			config: `labels = ["pod", "pod"]`,
+			err:    `duplicate label "pod"`,
+		},
+		{
+			config: `top_n = 0`,
+			err:    "top_n must be greater than 0",
+		},
+		{
+			config: `metric_limit {
+				name       = "a"
+				max_series = -1
+			}`,
+			err: "metric_limit at index 0: max_series can't be negative",
+		},
+		{
+			config: `
+				metric_limit {
+					name       = "a"
+					max_series = 1
+				}
+				metric_limit {
+					name       = "a"
+					max_series = 2
+				}`,
+			err: `duplicate metric_limit for "a"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.err, func(t *testing.T) {
+			var args Arguments
+			err := syntax.Unmarshal([]byte("forward_to = []\n"+tc.config), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	args := DefaultArguments
+	args.MaxSeriesPerMetric = 2
+	args.Labels = []string{"pod"}
+	args.MetricLimits = []MetricLimit{{Name: "unlimited", MaxSeries: 0}}
+	tr := newTracker()
+	tr.update(args)
+
+	series := func(name, pod string) labels.Labels {
+		return labels.FromStrings("__name__", name, "pod", pod)
+	}
+	now := time.Now()
+
+	for _, pod := range []string{"a", "b"} {
+		_, limited, ok := tr.admit(series("requests", pod), now)
+		require.True(t, ok)
+		require.False(t, limited)
+	}
+	// Known series are still admitted once the limit is reached.
+	_, limited, ok := tr.admit(series("requests", "a"), now)
+	require.True(t, ok)
+	require.False(t, limited)
+
+	_, limited, ok = tr.admit(series("requests", "c"), now)
+	require.False(t, ok)
+	require.True(t, limited)
+
+	// Metrics are limited independently.
+	for _, pod := range []string{"a", "b", "c"} {
+		_, _, ok := tr.admit(series("unlimited", pod), now)
+		require.True(t, ok)
+	}
+
+	// Stale series free up space for new series.
+	require.True(t, tr.remove(series("requests", "b")))
+	_, _, ok = tr.admit(series("requests", "c"), now)
+	require.True(t, ok)
+
+	require.Equal(t, DebugInfo{
+		ActiveSeries: 5,
+		Metrics: []DebugMetric{
+			{Name: "unlimited", ActiveSeries: 3, Limit: 0},
+			{Name: "requests", ActiveSeries: 2, Limit: 2, LimitedSamples: 1},
+		},
+		Labels: []DebugLabel{{
+			Name:   "pod",
+			Values: 3,
+			TopValues: []DebugLabelValue{
+				{Value: "a", ActiveSeries: 2},
+				{Value: "c", ActiveSeries: 2},
+			},
+		}},
+	}, tr.report(2))
+
+	// Series which weren't seen during the window are pruned.
+	_, _, ok = tr.admit(series("requests", "a"), now.Add(args.Window))
+	require.True(t, ok)
+	tr.prune(now.Add(args.Window + time.Second))
+	activeSeries, limitedMetrics := tr.stats()
+	require.Equal(t, 1, activeSeries)
+	require.Equal(t, 0, limitedMetrics)
+}
+
+func TestTrackerOverflow(t *testing.T) {
+	args := DefaultArguments
+	args.MaxSeriesPerMetric = 1
+	args.Action = actionOverflow
+	args.Labels = []string{"pod"}
+	tr := newTracker()
+	tr.update(args)
+
+	series := func(pod, requestID string) labels.Labels {
+		return labels.FromStrings("__name__", "requests", "job", "api", "pod", pod, "request_id", requestID)
+	}
+	now := time.Now()
+
+	_, _, ok := tr.admit(series("a", "1"), now)
+	require.True(t, ok)
+
+	// The first overflow series keeps the labels which aren't tracked.
+	l, limited, ok := tr.admit(series("b", "2"), now)
+	require.True(t, ok)
+	require.True(t, limited)
+	require.Equal(t, labels.FromStrings("__name__", "requests", "job", "api", "pod", overflowValue, "request_id", "2"), l)
+
+	l, _, _ = tr.admit(series("c", "2"), now)
+	require.Equal(t, labels.FromStrings("__name__", "requests", "job", "api", "pod", overflowValue, "request_id", "2"), l)
+
+	// The series which differ in labels which aren't tracked are merged into
+	// a single series, so that they can't blow up the number of series.
+	for _, requestID := range []string{"3", "4", "5"} {
+		l, limited, ok := tr.admit(series("d", requestID), now)
+		require.True(t, ok)
+		require.True(t, limited)
+		require.Equal(t, labels.FromStrings("__name__", "requests", "pod", overflowValue), l)
+	}
+}
+
+func TestComponent(t *testing.T) {
+	app := testappender.NewCollectingAppender()
+
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to            = []
+		max_series_per_metric = 1
+		action                = "overflow"
+		labels                = ["pod"]
+	`), &args))
+	args.ForwardTo = []storage.Appendable{testappender.ConstantAppendable{Inner: app}}
+
+	var receiver storage.Appendable
+	c, err := New(component.Options{
+		ID:         "prometheus.cardinality_limit.test",
+		Logger:     util.TestAlloyLogger(t),
+		Registerer: prom.NewRegistry(),
+		OnStateChange: func(e component.Exports) {
+			receiver = e.(Exports).Receiver
+		},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+
+	a := receiver.Appender(t.Context())
+	for i, pod := range []string{"a", "b", "c"} {
+		_, err := a.Append(0, labels.FromStrings("__name__", "requests", "job", "api", "pod", pod), 0, float64(i))
+		require.NoError(t, err)
+	}
+	// The staleness marker of an overflowed series doesn't mark the overflow
+	// series as stale.
+	_, err = a.Append(0, labels.FromStrings("__name__", "requests", "job", "api", "pod", "b"), 1, math.Float64frombits(value.StaleNaN))
+	require.NoError(t, err)
+	require.NoError(t, a.Commit())
+
+	require.Equal(t, 0.0, app.LatestSampleFor(`{__name__="requests", job="api", pod="a"}`).Value)
+	require.Equal(t, 2.0, app.LatestSampleFor(`{__name__="requests", job="api", pod="__overflow__"}`).Value)
+	require.Nil(t, app.LatestSampleFor(`{__name__="requests", job="api", pod="b"}`))
+
+	debugInfo := c.DebugInfo().(DebugInfo)
+	require.Equal(t, 1, debugInfo.ActiveSeries)
+	require.Equal(t, uint64(2), debugInfo.Metrics[0].LimitedSamples)
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case labelstore.ServiceName:
+		return labelstore.New(nil, prom.DefaultRegisterer), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
@@ -0,0 +1,37 @@
+package cardinality_limit
+
+// DebugInfo is the cardinality report of the component.
+type DebugInfo struct {
+	ActiveSeries int           `alloy:"active_series,attr"`
+	Metrics      []DebugMetric `alloy:"metric,block,optional"`
+	Labels       []DebugLabel  `alloy:"label,block,optional"`
+}
+
+// DebugMetric reports the active series of a metric.
+type DebugMetric struct {
+	Name           string `alloy:"name,attr"`
+	ActiveSeries   int    `alloy:"active_series,attr"`
+	Limit          int    `alloy:"limit,attr"`
+	LimitedSamples uint64 `alloy:"limited_samples,attr"`
+}
+
+// DebugLabel reports the values of a tracked label.
+type DebugLabel struct {
+	Name      string            `alloy:"name,attr"`
+	Values    int               `alloy:"values,attr"`
+	TopValues []DebugLabelValue `alloy:"value,block,optional"`
+}
+
+// DebugLabelValue reports the active series of a value of a tracked label.
+type DebugLabelValue struct {
+	Value        string `alloy:"value,attr"`
+	ActiveSeries int    `alloy:"active_series,attr"`
+}
+
+// DebugInfo implements component.DebugComponent.
+func (c *Component) DebugInfo() any {
+	c.mut.RLock()
+	n := c.args.TopN
+	c.mut.RUnlock()
+	return c.tracker.report(n)
+}
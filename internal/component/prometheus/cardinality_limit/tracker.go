@@ -0,0 +1,306 @@
+package cardinality_limit
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// overflowValue replaces the values of the tracked labels of series above
+// the limit of their metric when the action is overflow.
+const overflowValue = "__overflow__"
+
+// tracker tracks the active series per metric name and per value of the
+// tracked labels.
+type tracker struct {
+	mut sync.Mutex
+
+	window             time.Duration
+	action             string
+	labels             []string
+	maxSeriesPerMetric int
+	limits             map[string]int
+
+	series map[uint64]*trackedSeries
+	// metrics holds the number of active series per metric name.
+	metrics map[string]int
+	// labelValues holds the number of active series per value of each tracked
+	// label.
+	labelValues map[string]map[string]int
+	// limited holds the number of samples which were limited per metric name.
+	limited map[string]uint64
+	// overflow holds the hash of the overflow series of each limited metric
+	// when the action is overflow.
+	overflow map[string]uint64
+}
+
+type trackedSeries struct {
+	metric   string
+	lastSeen time.Time
+	// values are the values of the tracked labels, in the order of the
+	// labels of the tracker.
+	values []string
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		series:      make(map[uint64]*trackedSeries),
+		metrics:     make(map[string]int),
+		labelValues: make(map[string]map[string]int),
+		limited:     make(map[string]uint64),
+		overflow:    make(map[string]uint64),
+	}
+}
+
+// update applies the arguments to the tracker. The tracked series are reset
+// if the tracked labels changed.
+func (t *tracker) update(args Arguments) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.window = args.Window
+	t.action = args.Action
+	t.maxSeriesPerMetric = args.MaxSeriesPerMetric
+	t.limits = make(map[string]int, len(args.MetricLimits))
+	for _, limit := range args.MetricLimits {
+		t.limits[limit.Name] = limit.MaxSeries
+	}
+
+	if !slices.Equal(t.labels, args.Labels) {
+		t.labels = slices.Clone(args.Labels)
+		t.series = make(map[uint64]*trackedSeries)
+		t.metrics = make(map[string]int)
+		t.labelValues = make(map[string]map[string]int)
+		t.overflow = make(map[string]uint64)
+	}
+}
+
+// admit tracks the series l if it's below the limit of its metric, and
+// returns the labels the sample has to be forwarded with and whether the
+// series is limited. It returns false if the sample has to be dropped.
+func (t *tracker) admit(l labels.Labels, now time.Time) (newLabels labels.Labels, limited bool, ok bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	h := l.Hash()
+	if s, ok := t.series[h]; ok {
+		s.lastSeen = now
+		return l, false, true
+	}
+
+	metric := l.Get(model.MetricNameLabel)
+	if t.atLimit(metric) {
+		t.limited[metric]++
+		newLabels, ok = t.limit(l)
+		return newLabels, true, ok
+	}
+
+	s := &trackedSeries{
+		metric:   metric,
+		lastSeen: now,
+		values:   make([]string, len(t.labels)),
+	}
+	for i, name := range t.labels {
+		s.values[i] = l.Get(name)
+	}
+	t.add(h, s)
+	return l, false, true
+}
+
+// lookup works like admit, but doesn't track the series. It's used for data
+// which doesn't make a series active, like exemplars and metadata.
+func (t *tracker) lookup(l labels.Labels) (newLabels labels.Labels, limited bool, ok bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if _, tracked := t.series[l.Hash()]; tracked || !t.atLimit(l.Get(model.MetricNameLabel)) {
+		return l, false, true
+	}
+	newLabels, ok = t.limit(l)
+	return newLabels, true, ok
+}
+
+// remove stops tracking the series l, which is stale. It returns false if
+// the series wasn't tracked.
+func (t *tracker) remove(l labels.Labels) bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	h := l.Hash()
+	s, ok := t.series[h]
+	if !ok {
+		return false
+	}
+	t.delete(h, s)
+	return true
+}
+
+// prune stops tracking the series which weren't seen during the window.
+func (t *tracker) prune(now time.Time) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	for h, s := range t.series {
+		if now.Sub(s.lastSeen) > t.window {
+			t.delete(h, s)
+		}
+	}
+	// Only the metrics which are still limited are kept.
+	for metric := range t.limited {
+		if !t.atLimit(metric) {
+			delete(t.limited, metric)
+		}
+	}
+	for metric := range t.overflow {
+		if !t.atLimit(metric) {
+			delete(t.overflow, metric)
+		}
+	}
+}
+
+// atLimit returns whether the metric can't have new series. It must be
+// called with t.mut held.
+func (t *tracker) atLimit(metric string) bool {
+	limit, ok := t.limits[metric]
+	if !ok {
+		limit = t.maxSeriesPerMetric
+	}
+	return limit > 0 && t.metrics[metric] >= limit
+}
+
+// limit applies the action to the series l, which is above the limit of its
+// metric. It must be called with t.mut held.
+//
+// The overflow action replaces the values of the tracked labels. The first
+// overflow series of a metric keeps the other labels. The series which would
+// still result in a different overflow series, because they differ in labels
+// which aren't tracked, only keep the metric name and the tracked labels, so
+// that each metric has at most two overflow series.
+func (t *tracker) limit(l labels.Labels) (labels.Labels, bool) {
+	if t.action != actionOverflow {
+		return labels.EmptyLabels(), false
+	}
+	lb := labels.NewBuilder(l)
+	for _, name := range t.labels {
+		if l.Has(name) {
+			lb.Set(name, overflowValue)
+		}
+	}
+	newLabels := lb.Labels()
+
+	metric := l.Get(model.MetricNameLabel)
+	h, ok := t.overflow[metric]
+	if !ok {
+		t.overflow[metric] = newLabels.Hash()
+		return newLabels, true
+	}
+	if h == newLabels.Hash() {
+		return newLabels, true
+	}
+	lb.Keep(append([]string{model.MetricNameLabel}, t.labels...)...)
+	return lb.Labels(), true
+}
+
+func (t *tracker) add(h uint64, s *trackedSeries) {
+	t.series[h] = s
+	t.metrics[s.metric]++
+	for i, name := range t.labels {
+		if s.values[i] == "" {
+			continue
+		}
+		values, ok := t.labelValues[name]
+		if !ok {
+			values = make(map[string]int)
+			t.labelValues[name] = values
+		}
+		values[s.values[i]]++
+	}
+}
+
+func (t *tracker) delete(h uint64, s *trackedSeries) {
+	delete(t.series, h)
+	if t.metrics[s.metric]--; t.metrics[s.metric] <= 0 {
+		delete(t.metrics, s.metric)
+	}
+	for i, name := range t.labels {
+		if s.values[i] == "" {
+			continue
+		}
+		values := t.labelValues[name]
+		if values[s.values[i]]--; values[s.values[i]] <= 0 {
+			delete(values, s.values[i])
+		}
+	}
+}
+
+// stats returns the number of active series and of metrics at their limit.
+func (t *tracker) stats() (activeSeries int, limitedMetrics int) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	for metric := range t.metrics {
+		if t.atLimit(metric) {
+			limitedMetrics++
+		}
+	}
+	return len(t.series), limitedMetrics
+}
+
+// report returns the n metrics and values of each tracked label with the
+// most active series.
+func (t *tracker) report(n int) DebugInfo {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	info := DebugInfo{ActiveSeries: len(t.series)}
+	for _, e := range topN(t.metrics, n) {
+		limit, ok := t.limits[e.value]
+		if !ok {
+			limit = t.maxSeriesPerMetric
+		}
+		info.Metrics = append(info.Metrics, DebugMetric{
+			Name:           e.value,
+			ActiveSeries:   e.count,
+			Limit:          limit,
+			LimitedSamples: t.limited[e.value],
+		})
+	}
+	for _, name := range t.labels {
+		label := DebugLabel{
+			Name:   name,
+			Values: len(t.labelValues[name]),
+		}
+		for _, e := range topN(t.labelValues[name], n) {
+			label.TopValues = append(label.TopValues, DebugLabelValue{
+				Value:        e.value,
+				ActiveSeries: e.count,
+			})
+		}
+		info.Labels = append(info.Labels, label)
+	}
+	return info
+}
+
+type entry struct {
+	value string
+	count int
+}
+
+// topN returns the n entries of counts with the highest counts.
+func topN(counts map[string]int, n int) []entry {
+	entries := make([]entry, 0, len(counts))
+	for value, count := range counts {
+		entries = append(entries, entry{value: value, count: count})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.value, b.value)
+	})
+	return entries[:min(n, len(entries))]
+}
@@ -58,14 +58,30 @@ var (
 )
 
 type Component struct {
-	opts component.Options
-	mut  sync.RWMutex
-	args Arguments
+	opts    component.Options
+	mut     sync.RWMutex
+	args    Arguments
+	capture func([]Sample)
+}
+
+// Sample is a sample committed to the component.
+type Sample struct {
+	Labels    labels.Labels
+	Timestamp int64
+	Value     float64
 }
 
 func New(o component.Options, args Arguments) (*Component, error) {
+	return NewWithCapture(o, args, nil)
+}
+
+// NewWithCapture creates a new prometheus.echo component which also passes
+// the samples of each commit to capture, in the order they were appended, so
+// that callers such as alloy test can read them back.
+func NewWithCapture(o component.Options, args Arguments, capture func([]Sample)) (*Component, error) {
 	c := &Component{
-		opts: o,
+		opts:    o,
+		capture: capture,
 	}
 	if err := c.Update(args); err != nil {
 		return nil, err
@@ -98,6 +114,7 @@ func (c *Component) Appender(ctx context.Context) storage.Appender {
 		logger:      c.opts.Logger,
 		componentID: c.opts.ID,
 		format:      format,
+		capture:     c.capture,
 		samples:     make(map[string]sample),
 		exemplars:   make(map[string]seriesExemplar),
 		histograms:  make(map[string]seriesHistogram),
@@ -115,6 +132,9 @@ type echoAppender struct {
 	exemplars  map[string]seriesExemplar
 	histograms map[string]seriesHistogram
 	metadata   map[string]metadata.Metadata
+
+	capture func([]Sample)
+	pending []Sample
 }
 
 type sample struct {
@@ -145,6 +165,9 @@ func (a *echoAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v
 		Value:          v,
 		PrintTimestamp: t > 0,
 	}
+	if a.capture != nil {
+		a.pending = append(a.pending, Sample{Labels: l.Copy(), Timestamp: t, Value: v})
+	}
 
 	if ref == 0 {
 		ref = storage.SeriesRef(len(a.samples))
@@ -267,6 +290,9 @@ func (a *echoAppender) Commit() error {
 
 	level.Info(a.logger).Log("component", a.componentID, "metrics", buf.String())
 
+	if a.capture != nil && len(a.pending) > 0 {
+		a.capture(a.pending)
+	}
 	a.clearStorage()
 
 	return nil
@@ -297,6 +323,7 @@ func (a *echoAppender) clearStorage() {
 	for k := range a.metadata {
 		delete(a.metadata, k)
 	}
+	a.pending = nil
 }
 
 func (a *echoAppender) buildMetricFamilies() []*dto.MetricFamily {
@@ -101,6 +101,37 @@ func TestAppender_Rollback(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestAppender_Capture(t *testing.T) {
+	var captured []Sample
+	comp, err := NewWithCapture(component.Options{
+		ID:     "test",
+		Logger: log.NewNopLogger(),
+	}, Arguments{}, func(samples []Sample) {
+		captured = append(captured, samples...)
+	})
+	require.NoError(t, err)
+
+	lbls := labels.FromStrings("__name__", "test_metric", "job", "test")
+
+	appender := comp.Appender(context.Background())
+	_, err = appender.Append(0, lbls, 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, appender.Rollback())
+	require.Empty(t, captured)
+
+	appender = comp.Appender(context.Background())
+	_, err = appender.Append(0, lbls, 1000, 1)
+	require.NoError(t, err)
+	_, err = appender.Append(0, lbls, 2000, 2)
+	require.NoError(t, err)
+	require.NoError(t, appender.Commit())
+
+	require.Equal(t, []Sample{
+		{Labels: lbls, Timestamp: 1000, Value: 1},
+		{Labels: lbls, Timestamp: 2000, Value: 2},
+	}, captured)
+}
+
 func TestAppender_MultipleMetrics(t *testing.T) {
 	comp, err := New(component.Options{
 		ID:     "test",
@@ -0,0 +1,190 @@
+package receive_http
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/DataDog/agent-payload/v5/gogen"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+// DatadogArguments enables receiving metrics from the Datadog series API.
+type DatadogArguments struct{}
+
+// datadogSeriesV1 is the payload of the v1 series API.
+type datadogSeriesV1 struct {
+	Series []struct {
+		Metric string       `json:"metric"`
+		Points [][2]float64 `json:"points"`
+		Type   string       `json:"type"`
+		Host   string       `json:"host"`
+		Device string       `json:"device"`
+		Tags   []string     `json:"tags"`
+	} `json:"series"`
+}
+
+// handleDatadogSeriesV1 handles the JSON requests of the v1 series API.
+func (c *Component) handleDatadogSeriesV1(w http.ResponseWriter, r *http.Request) {
+	if c.currentArgs().Datadog == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := readBody(w, r, int64(c.currentArgs().MaxRequestBodySize))
+	if err != nil {
+		writeDatadogError(w, readBodyStatus(err), err)
+		return
+	}
+	var req datadogSeriesV1
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeDatadogError(w, http.StatusBadRequest, fmt.Errorf("failed to decode Datadog series: %w", err))
+		return
+	}
+
+	// Convert the series to the v2 format so that both APIs share the same
+	// conversion.
+	var payload gogen.MetricPayload
+	for _, s := range req.Series {
+		series := &gogen.MetricPayload_MetricSeries{
+			Metric: s.Metric,
+			Tags:   s.Tags,
+			Type:   gogen.MetricPayload_MetricType(gogen.MetricPayload_MetricType_value[strings.ToUpper(s.Type)]),
+		}
+		if s.Host != "" {
+			series.Resources = append(series.Resources, &gogen.MetricPayload_Resource{Type: "host", Name: s.Host})
+		}
+		if s.Device != "" {
+			series.Tags = append(series.Tags, "device:"+s.Device)
+		}
+		for _, p := range s.Points {
+			series.Points = append(series.Points, &gogen.MetricPayload_MetricPoint{Timestamp: int64(p[0]), Value: p[1]})
+		}
+		payload.Series = append(payload.Series, series)
+	}
+	c.appendDatadogPayload(w, r, &payload)
+}
+
+// handleDatadogSeriesV2 handles the protobuf and JSON requests of the v2
+// series API.
+func (c *Component) handleDatadogSeriesV2(w http.ResponseWriter, r *http.Request) {
+	if c.currentArgs().Datadog == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := readBody(w, r, int64(c.currentArgs().MaxRequestBodySize))
+	if err != nil {
+		writeDatadogError(w, readBodyStatus(err), err)
+		return
+	}
+	var payload gogen.MetricPayload
+	switch contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType {
+	case contentTypeProtobuf:
+		err = payload.Unmarshal(body)
+	case contentTypeJSON:
+		err = json.Unmarshal(body, &payload)
+	default:
+		writeDatadogError(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", r.Header.Get("Content-Type")))
+		return
+	}
+	if err != nil {
+		writeDatadogError(w, http.StatusBadRequest, fmt.Errorf("failed to decode Datadog series: %w", err))
+		return
+	}
+	c.appendDatadogPayload(w, r, &payload)
+}
+
+// handleDatadogValidate handles the API key validation requests of the
+// Datadog Agent. API keys aren't checked.
+func (c *Component) handleDatadogValidate(w http.ResponseWriter, r *http.Request) {
+	if c.currentArgs().Datadog == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	_, _ = w.Write([]byte(`{"valid":true}`))
+}
+
+func (c *Component) appendDatadogPayload(w http.ResponseWriter, r *http.Request, payload *gogen.MetricPayload) {
+	app := c.fanout.Appender(r.Context())
+	if err := appendDatadogSeries(app, payload, c.currentArgs().AppendMetadata); err != nil {
+		_ = app.Rollback()
+		writeDatadogError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to append metrics received from the Datadog series API", "err", err)
+		writeDatadogError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte(`{"errors":[]}`))
+}
+
+// appendDatadogSeries appends the points of the series of payload to app.
+// All the series are written as gauges: count series hold the count of their
+// interval, and rate series the per-second rate of their interval.
+func appendDatadogSeries(app storage.Appender, payload *gogen.MetricPayload, appendMetadata bool) error {
+	lb := labels.NewScratchBuilder(0)
+	for _, series := range payload.Series {
+		name := sanitizeMetricName(series.Metric)
+		if name == "" {
+			return fmt.Errorf("series without a metric name")
+		}
+
+		lb.Reset()
+		lb.Add(model.MetricNameLabel, name)
+		seen := map[string]struct{}{model.MetricNameLabel: {}}
+		add := func(name, value string) {
+			name = sanitizeLabelName(name)
+			if _, ok := seen[name]; ok || name == "" || value == "" {
+				return
+			}
+			seen[name] = struct{}{}
+			lb.Add(name, value)
+		}
+		for _, res := range series.Resources {
+			add(res.Type, res.Name)
+		}
+		for _, tag := range series.Tags {
+			// Tags without a value can't be converted to labels.
+			if name, value, ok := strings.Cut(tag, ":"); ok {
+				add(name, value)
+			}
+		}
+		lb.Sort()
+		l := lb.Labels()
+
+		for _, p := range series.Points {
+			if _, err := app.Append(0, l, p.Timestamp*1000, p.Value); err != nil {
+				return err
+			}
+		}
+
+		if appendMetadata {
+			md := metadata.Metadata{Type: model.MetricTypeGauge, Unit: series.Unit}
+			if _, err := app.UpdateMetadata(0, l, md); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeDatadogError writes err in the format of the Datadog API.
+func writeDatadogError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string][]string{
+		"errors": {err.Error()},
+	})
+}
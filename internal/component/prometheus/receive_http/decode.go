@@ -0,0 +1,109 @@
+package receive_http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/common/model"
+)
+
+// errBodyTooLarge is returned by readBody when the body of a request, once
+// decompressed, is larger than the maximum size.
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody reads the body of r, decompressing it according to its
+// Content-Encoding header. Both the compressed and the decompressed body
+// must be at most maxSize bytes.
+func readBody(w http.ResponseWriter, r *http.Request, maxSize int64) ([]byte, error) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxSize)
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		body = gr
+	case "deflate":
+		zr, err := zlib.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	case "zstd":
+		zr, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) || int64(len(buf)) > maxSize {
+		return nil, fmt.Errorf("%w: limit is %d bytes", errBodyTooLarge, maxSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	return buf, nil
+}
+
+// readBodyStatus returns the status code of the response to a request whose
+// body couldn't be read because of err.
+func readBodyStatus(err error) int {
+	if errors.Is(err, errBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// sanitizeMetricName replaces the characters of name which aren't allowed in
+// Prometheus metric names with underscores.
+func sanitizeMetricName(name string) string {
+	return sanitize(name, true)
+}
+
+// sanitizeLabelName replaces the characters of name which aren't allowed in
+// Prometheus label names with underscores.
+func sanitizeLabelName(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, allowColons bool) string {
+	if name == "" {
+		return ""
+	}
+	if allowColons && model.LegacyValidation.IsValidMetricName(name) {
+		return name
+	}
+	if !allowColons && model.LegacyValidation.IsValidLabelName(name) {
+		return name
+	}
+
+	var sb strings.Builder
+	if name[0] >= '0' && name[0] <= '9' {
+		sb.WriteByte('_')
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			sb.WriteRune(r)
+		case r == ':' && allowColons:
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
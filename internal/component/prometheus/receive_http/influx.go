@@ -0,0 +1,200 @@
+package receive_http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/line-protocol/v2/lineprotocol"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+// InfluxArguments enables receiving metrics in the InfluxDB line protocol.
+type InfluxArguments struct{}
+
+// handleInflux handles the write requests of the InfluxDB v1 and v2 APIs.
+// Each numeric or boolean field of each point is converted to a sample.
+func (c *Component) handleInflux(w http.ResponseWriter, r *http.Request) {
+	if c.currentArgs().Influx == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	precision, err := influxPrecision(r.URL.Query().Get("precision"))
+	if err != nil {
+		writeInfluxError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := readBody(w, r, int64(c.currentArgs().MaxRequestBodySize))
+	if err != nil {
+		writeInfluxError(w, readBodyStatus(err), err)
+		return
+	}
+
+	app := c.fanout.Appender(r.Context())
+	if err := appendInfluxPoints(app, body, precision, time.Now()); err != nil {
+		_ = app.Rollback()
+		writeInfluxError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to append metrics received in the InfluxDB line protocol", "err", err)
+		writeInfluxError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleInfluxPing handles the health checks of InfluxDB clients.
+func (c *Component) handleInfluxPing(w http.ResponseWriter, r *http.Request) {
+	if c.currentArgs().Influx == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// influxPrecision parses the precision query parameter of the InfluxDB v1
+// and v2 write APIs.
+func influxPrecision(precision string) (lineprotocol.Precision, error) {
+	switch precision {
+	case "", "n", "ns":
+		return lineprotocol.Nanosecond, nil
+	case "u", "us":
+		return lineprotocol.Microsecond, nil
+	case "ms":
+		return lineprotocol.Millisecond, nil
+	case "s":
+		return lineprotocol.Second, nil
+	default:
+		return 0, fmt.Errorf("unsupported precision %q", precision)
+	}
+}
+
+// appendInfluxPoints appends the points encoded in the line protocol in buf
+// to app. Points without a timestamp get the timestamp now.
+func appendInfluxPoints(app storage.Appender, buf []byte, precision lineprotocol.Precision, now time.Time) error {
+	var (
+		dec = lineprotocol.NewDecoderWithBytes(buf)
+		lb  = labels.NewScratchBuilder(0)
+
+		tags   []labels.Label
+		seen   = make(map[string]struct{})
+		fields []influxField
+	)
+	for dec.Next() {
+		measurement, err := dec.Measurement()
+		if err != nil {
+			return err
+		}
+
+		tags = tags[:0]
+		clear(seen)
+		seen[model.MetricNameLabel] = struct{}{}
+		for {
+			key, value, err := dec.NextTag()
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				break
+			}
+			// Different tags may have the same name once sanitized. Only the
+			// first one is kept.
+			name := sanitizeLabelName(string(key))
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			tags = append(tags, labels.Label{Name: name, Value: string(value)})
+		}
+
+		fields = fields[:0]
+		for {
+			key, value, err := dec.NextField()
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				break
+			}
+			v, ok := influxFieldValue(value)
+			if !ok {
+				continue
+			}
+			fields = append(fields, influxField{name: string(key), value: v})
+		}
+
+		t, err := dec.Time(precision, now)
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			lb.Reset()
+			lb.Add(model.MetricNameLabel, influxMetricName(string(measurement), field.name))
+			for _, tag := range tags {
+				lb.Add(tag.Name, tag.Value)
+			}
+			lb.Sort()
+			if _, err := app.Append(0, lb.Labels(), t.UnixMilli(), field.value); err != nil {
+				return err
+			}
+		}
+	}
+	return dec.Err()
+}
+
+type influxField struct {
+	name  string
+	value float64
+}
+
+// influxFieldValue converts the value of a field to a sample value. String
+// fields can't be converted.
+func influxFieldValue(v lineprotocol.Value) (float64, bool) {
+	switch v.Kind() {
+	case lineprotocol.Float:
+		return v.FloatV(), true
+	case lineprotocol.Int:
+		return float64(v.IntV()), true
+	case lineprotocol.Uint:
+		return float64(v.UintV()), true
+	case lineprotocol.Bool:
+		if v.BoolV() {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// influxMetricName returns the name of the metric of a field. Fields named
+// value are named after their measurement, as Telegraf does.
+func influxMetricName(measurement, field string) string {
+	if field == "value" {
+		return sanitizeMetricName(measurement)
+	}
+	return sanitizeMetricName(measurement + "_" + field)
+}
+
+// writeInfluxError writes err in the format of the InfluxDB v2 API.
+func writeInfluxError(w http.ResponseWriter, status int, err error) {
+	code := "invalid"
+	if status >= http.StatusInternalServerError {
+		code = "internal error"
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"code":    code,
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,123 @@
+package receive_http
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+
+	"github.com/grafana/alloy/internal/component/otelcol/exporter/prometheus/convert"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// OTLPArguments configures how metrics received over OTLP are converted to
+// Prometheus metrics. The options match the ones of
+// otelcol.exporter.prometheus.
+type OTLPArguments struct {
+	IncludeTargetInfo             bool          `alloy:"include_target_info,attr,optional"`
+	IncludeScopeInfo              bool          `alloy:"include_scope_info,attr,optional"`
+	IncludeScopeLabels            bool          `alloy:"include_scope_labels,attr,optional"`
+	GCFrequency                   time.Duration `alloy:"gc_frequency,attr,optional"`
+	AddMetricSuffixes             bool          `alloy:"add_metric_suffixes,attr,optional"`
+	ResourceToTelemetryConversion bool          `alloy:"resource_to_telemetry_conversion,attr,optional"`
+}
+
+// DefaultOTLPArguments holds the default values of the otlp block.
+var DefaultOTLPArguments = OTLPArguments{
+	IncludeTargetInfo:             true,
+	IncludeScopeInfo:              false,
+	IncludeScopeLabels:            true,
+	GCFrequency:                   5 * time.Minute,
+	AddMetricSuffixes:             true,
+	ResourceToTelemetryConversion: false,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (args *OTLPArguments) SetToDefault() {
+	*args = DefaultOTLPArguments
+}
+
+// Validate implements syntax.Validator.
+func (args *OTLPArguments) Validate() error {
+	if args.GCFrequency <= 0 {
+		return errors.New("gc_frequency must be greater than 0")
+	}
+	return nil
+}
+
+// convertOptions returns the options of the converter of OTLP metrics. The
+// defaults are used if the otlp block isn't set, which is harmless as the
+// converter doesn't receive any metrics then.
+func (args *OTLPArguments) convertOptions() convert.Options {
+	if args == nil {
+		args = &DefaultOTLPArguments
+	}
+	return convert.Options{
+		IncludeTargetInfo:             args.IncludeTargetInfo,
+		IncludeScopeInfo:              args.IncludeScopeInfo,
+		IncludeScopeLabels:            args.IncludeScopeLabels,
+		AddMetricSuffixes:             args.AddMetricSuffixes,
+		ResourceToTelemetryConversion: args.ResourceToTelemetryConversion,
+	}
+}
+
+// handleOTLP handles OTLP/HTTP export requests of metrics, encoded in
+// protobuf or JSON.
+func (c *Component) handleOTLP(w http.ResponseWriter, r *http.Request) {
+	if c.currentArgs().OTLP == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readBody(w, r, int64(c.currentArgs().MaxRequestBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), readBodyStatus(err))
+		return
+	}
+
+	req := pmetricotlp.NewExportRequest()
+	if contentType == contentTypeProtobuf {
+		err = req.UnmarshalProto(body)
+	} else {
+		err = req.UnmarshalJSON(body)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode OTLP request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.converter.ConsumeMetrics(r.Context(), req.Metrics()); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to append metrics received over OTLP", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := pmetricotlp.NewExportResponse()
+	var out []byte
+	if contentType == contentTypeProtobuf {
+		out, err = resp.MarshalProto()
+	} else {
+		out, err = resp.MarshalJSON()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
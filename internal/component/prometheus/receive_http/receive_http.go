@@ -2,12 +2,15 @@ package receive_http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"reflect"
 	"sync"
+	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/exp/api/remote"
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,6 +19,7 @@ import (
 
 	"github.com/grafana/alloy/internal/component"
 	fnet "github.com/grafana/alloy/internal/component/common/net"
+	"github.com/grafana/alloy/internal/component/otelcol/exporter/prometheus/convert"
 	alloyprom "github.com/grafana/alloy/internal/component/prometheus"
 	"github.com/grafana/alloy/internal/featuregate"
 	"github.com/grafana/alloy/internal/runtime/logging"
@@ -46,6 +50,15 @@ type Arguments struct {
 	EnableTypeAndUnitLabels bool `alloy:"enable_type_and_unit_labels,attr,optional"`
 	// Supported remote write protobuf message types. Valid values are "prometheus.WriteRequest" and "io.prometheus.write.v2.Request".
 	AcceptedRemoteWriteProtobufMessages []string `alloy:"accepted_remote_write_protobuf_messages,attr,optional"`
+
+	// Maximum size of the decompressed body of the requests of the OTLP,
+	// InfluxDB, and Datadog endpoints.
+	MaxRequestBodySize units.Base2Bytes `alloy:"max_request_body_size,attr,optional"`
+
+	// Additional push protocols to accept metrics from.
+	OTLP    *OTLPArguments    `alloy:"otlp,block,optional"`
+	Influx  *InfluxArguments  `alloy:"influx,block,optional"`
+	Datadog *DatadogArguments `alloy:"datadog,block,optional"`
 }
 
 // SetToDefault implements syntax.Defaulter.
@@ -53,13 +66,26 @@ func (args *Arguments) SetToDefault() {
 	*args = Arguments{
 		Server:                              fnet.DefaultServerConfig(),
 		AcceptedRemoteWriteProtobufMessages: []string{string(remote.WriteV1MessageType)},
+		MaxRequestBodySize:                  100 * units.MiB,
+	}
+}
+
+// Validate implements syntax.Validator.
+func (args *Arguments) Validate() error {
+	if args.MaxRequestBodySize <= 0 {
+		return errors.New("max_request_body_size must be greater than 0")
 	}
+	if args.OTLP != nil {
+		return args.OTLP.Validate()
+	}
+	return nil
 }
 
 type Component struct {
 	opts               component.Options
 	handler            http.Handler
 	fanout             *alloyprom.Fanout
+	converter          *convert.Converter
 	uncheckedCollector *util.UncheckedCollector
 
 	updateMut sync.RWMutex
@@ -81,6 +107,18 @@ func New(opts component.Options, args Arguments) (*Component, error) {
 	if args.EnableTypeAndUnitLabels && !opts.MinStability.Permits(featuregate.StabilityExperimental) {
 		return nil, fmt.Errorf("enable_type_and_unit_labels is an experimental feature, and must be enabled by setting the stability.level flag to experimental")
 	}
+	for _, block := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"otlp", args.OTLP != nil},
+		{"influx", args.Influx != nil},
+		{"datadog", args.Datadog != nil},
+	} {
+		if block.enabled && !opts.MinStability.Permits(featuregate.StabilityExperimental) {
+			return nil, fmt.Errorf("the %s block is an experimental feature, and must be enabled by setting the stability.level flag to experimental", block.name)
+		}
+	}
 
 	uncheckedCollector := util.NewUncheckedCollector(nil)
 	opts.Registerer.MustRegister(uncheckedCollector)
@@ -117,6 +155,7 @@ func New(opts component.Options, args Arguments) (*Component, error) {
 			args.AppendMetadata,
 		),
 		fanout:             fanout,
+		converter:          convert.New(opts.Logger, fanout, args.OTLP.convertOptions()),
 		uncheckedCollector: uncheckedCollector,
 	}
 
@@ -134,15 +173,36 @@ func (c *Component) Run(ctx context.Context) error {
 		c.shutdownServer()
 	}()
 
-	<-ctx.Done()
-	level.Info(c.opts.Logger).Log("msg", "terminating due to context done")
-	return nil
+	for {
+		select {
+		case <-ctx.Done():
+			level.Info(c.opts.Logger).Log("msg", "terminating due to context done")
+			return nil
+		case <-time.After(c.nextGC()):
+			c.converter.GC(5 * time.Minute)
+		}
+	}
+}
+
+// nextGC returns how long to wait before the next garbage collection of the
+// series converted from OTLP.
+func (c *Component) nextGC() time.Duration {
+	c.updateMut.RLock()
+	defer c.updateMut.RUnlock()
+	if c.args.OTLP == nil {
+		return DefaultOTLPArguments.GCFrequency
+	}
+	return c.args.OTLP.GCFrequency
 }
 
 // Update satisfies the Component interface.
 func (c *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
 	c.fanout.UpdateChildren(newArgs.ForwardTo)
+	c.converter.UpdateOptions(newArgs.OTLP.convertOptions())
+	// Flush the metadata cache so that new children get the metadata of the
+	// series converted from OTLP.
+	c.converter.FlushMetadata()
 
 	c.updateMut.Lock()
 	defer c.updateMut.Unlock()
@@ -162,6 +222,16 @@ func (c *Component) Update(args component.Arguments) error {
 
 	err = c.server.MountAndRun(func(router *mux.Router) {
 		router.Path("/api/v1/metrics/write").Methods("POST").Handler(c.handler)
+
+		router.Path("/v1/metrics").Methods("POST").HandlerFunc(c.handleOTLP)
+
+		router.Path("/api/v2/write").Methods("POST").HandlerFunc(c.handleInflux)
+		router.Path("/write").Methods("POST").HandlerFunc(c.handleInflux)
+		router.Path("/ping").Methods("GET", "HEAD").HandlerFunc(c.handleInfluxPing)
+
+		router.Path("/api/v1/series").Methods("POST").HandlerFunc(c.handleDatadogSeriesV1)
+		router.Path("/api/v2/series").Methods("POST").HandlerFunc(c.handleDatadogSeriesV2)
+		router.Path("/api/v1/validate").Methods("GET").HandlerFunc(c.handleDatadogValidate)
 	})
 	if err != nil {
 		return err
@@ -200,3 +270,10 @@ func (c *Component) shutdownServer() {
 		c.server = nil
 	}
 }
+
+// currentArgs returns the arguments the component is running with.
+func (c *Component) currentArgs() Arguments {
+	c.updateMut.RLock()
+	defer c.updateMut.RUnlock()
+	return c.args
+}
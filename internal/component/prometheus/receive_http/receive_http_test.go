@@ -1,6 +1,8 @@
 package receive_http
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -16,6 +18,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DataDog/agent-payload/v5/gogen"
+	"github.com/alecthomas/units"
 	"github.com/golang/snappy"
 	"github.com/phayes/freeport"
 	"github.com/prometheus/client_golang/exp/api/remote"
@@ -31,6 +35,9 @@ import (
 	promremote "github.com/prometheus/prometheus/storage/remote"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/protoadapt"
 
@@ -1012,3 +1019,222 @@ func TestAcceptedRemoteWriteProtobufMessages(t *testing.T) {
 		})
 	}
 }
+
+func TestPushProtocols(t *testing.T) {
+	ts := time.Now().Truncate(time.Second)
+
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("test.gauge")
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	dp.SetDoubleValue(12)
+	dp.Attributes().PutStr("foo", "bar")
+	otlpProto, err := pmetricotlp.NewExportRequestFromMetrics(md).MarshalProto()
+	require.NoError(t, err)
+	otlpJSON, err := pmetricotlp.NewExportRequestFromMetrics(md).MarshalJSON()
+	require.NoError(t, err)
+
+	var influx bytes.Buffer
+	gw := gzip.NewWriter(&influx)
+	_, err = fmt.Fprintf(gw, "cpu,host=a usage_idle=90,usage_user=10i,ok=true,msg=\"x\" %[1]d\nmem,host=a value=5 %[1]d\n", ts.Unix())
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	// A small compressed body which is too large once decompressed.
+	var bomb bytes.Buffer
+	gw = gzip.NewWriter(&bomb)
+	_, err = gw.Write(make([]byte, 1<<20))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	datadogV1 := fmt.Sprintf(`{"series": [{"metric": "system.load.1", "points": [[%d, 0.5]], "type": "gauge", "host": "h1", "tags": ["env:prod", "standalone"]}]}`, ts.Unix())
+	datadogV2, err := (&gogen.MetricPayload{Series: []*gogen.MetricPayload_MetricSeries{{
+		Metric:    "requests.count",
+		Type:      gogen.MetricPayload_COUNT,
+		Resources: []*gogen.MetricPayload_Resource{{Type: "host", Name: "h1"}},
+		Tags:      []string{"env:prod"},
+		Points:    []*gogen.MetricPayload_MetricPoint{{Timestamp: ts.Unix(), Value: 3}},
+	}}}).Marshal()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		path            string
+		contentType     string
+		contentEncoding string
+		body            []byte
+		status          int
+		expected        []testSample
+	}{
+		{
+			name:        "otlp protobuf",
+			path:        "/v1/metrics",
+			contentType: "application/x-protobuf",
+			body:        otlpProto,
+			status:      http.StatusOK,
+			expected: []testSample{
+				{ts: ts.UnixMilli(), val: 12, l: labels.FromStrings("__name__", "test_gauge", "foo", "bar")},
+			},
+		},
+		{
+			name:        "otlp json",
+			path:        "/v1/metrics",
+			contentType: "application/json",
+			body:        otlpJSON,
+			status:      http.StatusOK,
+			expected: []testSample{
+				{ts: ts.UnixMilli(), val: 12, l: labels.FromStrings("__name__", "test_gauge", "foo", "bar")},
+			},
+		},
+		{
+			name:        "otlp unsupported content type",
+			path:        "/v1/metrics",
+			contentType: "text/plain",
+			body:        otlpJSON,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:            "influx",
+			path:            "/api/v2/write?precision=s",
+			contentEncoding: "gzip",
+			body:            influx.Bytes(),
+			status:          http.StatusNoContent,
+			expected: []testSample{
+				{ts: ts.UnixMilli(), val: 90, l: labels.FromStrings("__name__", "cpu_usage_idle", "host", "a")},
+				{ts: ts.UnixMilli(), val: 10, l: labels.FromStrings("__name__", "cpu_usage_user", "host", "a")},
+				{ts: ts.UnixMilli(), val: 1, l: labels.FromStrings("__name__", "cpu_ok", "host", "a")},
+				{ts: ts.UnixMilli(), val: 5, l: labels.FromStrings("__name__", "mem", "host", "a")},
+			},
+		},
+		{
+			name:   "influx duplicate tags",
+			path:   "/write?precision=s",
+			body:   fmt.Appendf(nil, "cpu,__name__=x,a-b=1,a_b=2 value=1 %d\n", ts.Unix()),
+			status: http.StatusNoContent,
+			expected: []testSample{
+				{ts: ts.UnixMilli(), val: 1, l: labels.FromStrings("__name__", "cpu", "a_b", "1")},
+			},
+		},
+		{
+			name:            "influx body too large",
+			path:            "/write",
+			contentEncoding: "gzip",
+			body:            bomb.Bytes(),
+			status:          http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "influx invalid",
+			path:   "/write",
+			body:   []byte("cpu,host=a\n"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:        "datadog v1",
+			path:        "/api/v1/series",
+			contentType: "application/json",
+			body:        []byte(datadogV1),
+			status:      http.StatusAccepted,
+			expected: []testSample{
+				{ts: ts.UnixMilli(), val: 0.5, l: labels.FromStrings("__name__", "system_load_1", "env", "prod", "host", "h1")},
+			},
+		},
+		{
+			name:        "datadog v2",
+			path:        "/api/v2/series",
+			contentType: "application/x-protobuf",
+			body:        datadogV2,
+			status:      http.StatusAccepted,
+			expected: []testSample{
+				{ts: ts.UnixMilli(), val: 3, l: labels.FromStrings("__name__", "requests_count", "env", "prod", "host", "h1")},
+			},
+		},
+	}
+
+	actualSamples := make(chan testSample, 100)
+	otlp := DefaultOTLPArguments
+	otlp.IncludeTargetInfo = false
+	otlp.IncludeScopeLabels = false
+	args := Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{
+				ListenAddress: "localhost",
+				ListenPort:    getFreePort(t),
+			},
+			GRPC: testGRPCConfig(t),
+		},
+		AcceptedRemoteWriteProtobufMessages: []string{string(remote.WriteV1MessageType)},
+		ForwardTo:                           testAppendable(actualSamples),
+		MaxRequestBodySize:                  64 * units.KiB,
+		OTLP:                                &otlp,
+		Influx:                              &InfluxArguments{},
+		Datadog:                             &DatadogArguments{},
+	}
+
+	comp, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+	waitForServerToBeReady(t, args)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			url := fmt.Sprintf("http://%s:%d%s", args.Server.HTTP.ListenAddress, args.Server.HTTP.ListenPort, tc.path)
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, tc.status, resp.StatusCode)
+
+			for _, exp := range tc.expected {
+				select {
+				case actual := <-actualSamples:
+					require.Equal(t, exp, actual)
+				case <-ctx.Done():
+					t.Fatalf("test timed out")
+				}
+			}
+			select {
+			case unexpected := <-actualSamples:
+				t.Fatalf("unexpected extra sample received: %v", unexpected)
+			default:
+			}
+		})
+	}
+
+	// The endpoints of the protocols are disabled when their block is removed.
+	args.OTLP = nil
+	require.NoError(t, comp.Update(args))
+	url := fmt.Sprintf("http://%s:%d/v1/metrics", args.Server.HTTP.ListenAddress, args.Server.HTTP.ListenPort)
+	resp, err := http.Post(url, "application/x-protobuf", bytes.NewReader(otlpProto))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPushProtocolsStability(t *testing.T) {
+	opts := testOptions(t)
+	opts.MinStability = featuregate.StabilityGenerallyAvailable
+	_, err := New(opts, Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{
+				ListenAddress: "localhost",
+				ListenPort:    getFreePort(t),
+			},
+			GRPC: testGRPCConfig(t),
+		},
+		AcceptedRemoteWriteProtobufMessages: []string{string(remote.WriteV1MessageType)},
+		Influx:                              &InfluxArguments{},
+	})
+	require.EqualError(t, err, "the influx block is an experimental feature, and must be enabled by setting the stability.level flag to experimental")
+}
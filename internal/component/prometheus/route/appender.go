@@ -0,0 +1,172 @@
+package route
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/alloy/internal/component/prometheus"
+)
+
+// appender forwards each series to the fanouts of the routes it matches, or
+// to the fallback fanout if it doesn't match any route. The appenders of the
+// fanouts are only created once a series is forwarded to them.
+type appender struct {
+	ctx           context.Context
+	c             *Component
+	routes        []route
+	fallback      *prometheus.Fanout
+	dropUnmatched bool
+	opts          *storage.AppendOptions
+
+	// children holds the appender of each route, followed by the appender of
+	// the fallback fanout.
+	children []storage.Appender
+}
+
+var _ storage.Appender = (*appender)(nil)
+
+// child returns the appender of the route at index i, or of the fallback
+// fanout if i is the number of routes.
+func (a *appender) child(i int) storage.Appender {
+	if a.children[i] == nil {
+		fanout := a.fallback
+		if i < len(a.routes) {
+			fanout = a.routes[i].fanout
+		}
+		a.children[i] = fanout.Appender(a.ctx)
+		if a.opts != nil {
+			a.children[i].SetOptions(a.opts)
+		}
+	}
+	return a.children[i]
+}
+
+// forward calls f with the appenders the series l is forwarded to, and
+// returns the last series ref returned by f. ref is returned unchanged if the
+// series isn't forwarded anywhere.
+func (a *appender) forward(ref storage.SeriesRef, l labels.Labels, sample bool, f func(app storage.Appender) (storage.SeriesRef, error)) (storage.SeriesRef, error) {
+	if a.c.exited.Load() {
+		return 0, fmt.Errorf("%s has exited", a.c.opts.ID)
+	}
+
+	var (
+		multiErr error
+		matched  bool
+		newRef   = ref
+	)
+	call := func(i int) {
+		r, err := f(a.child(i))
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			return
+		}
+		newRef = r
+	}
+	for i := range a.routes {
+		if !a.routes[i].matches(l) {
+			continue
+		}
+		matched = true
+		call(i)
+		if !a.routes[i].cont {
+			break
+		}
+	}
+	if !matched {
+		if a.dropUnmatched {
+			if sample {
+				a.c.samplesDropped.Inc()
+			}
+			return ref, nil
+		}
+		call(len(a.routes))
+	}
+	return newRef, multiErr
+}
+
+// SetOptions implements storage.Appender.
+func (a *appender) SetOptions(opts *storage.AppendOptions) {
+	a.opts = opts
+	for _, x := range a.children {
+		if x != nil {
+			x.SetOptions(opts)
+		}
+	}
+}
+
+// Append implements storage.Appender.
+func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	return a.forward(ref, l, true, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.Append(ref, l, t, v)
+	})
+}
+
+// AppendExemplar implements storage.Appender.
+func (a *appender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	return a.forward(ref, l, false, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendExemplar(ref, l, e)
+	})
+}
+
+// UpdateMetadata implements storage.Appender.
+func (a *appender) UpdateMetadata(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata) (storage.SeriesRef, error) {
+	return a.forward(ref, l, false, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.UpdateMetadata(ref, l, m)
+	})
+}
+
+// AppendHistogram implements storage.Appender.
+func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return a.forward(ref, l, true, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendHistogram(ref, l, t, h, fh)
+	})
+}
+
+// AppendCTZeroSample implements storage.Appender.
+func (a *appender) AppendCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	return a.forward(ref, l, false, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendCTZeroSample(ref, l, t, ct)
+	})
+}
+
+// AppendHistogramCTZeroSample implements storage.Appender.
+func (a *appender) AppendHistogramCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return a.forward(ref, l, false, func(app storage.Appender) (storage.SeriesRef, error) {
+		return app.AppendHistogramCTZeroSample(ref, l, t, ct, h, fh)
+	})
+}
+
+// Commit implements storage.Appender.
+func (a *appender) Commit() error {
+	var multiErr error
+	for _, x := range a.children {
+		if x == nil {
+			continue
+		}
+		if err := x.Commit(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return multiErr
+}
+
+// Rollback implements storage.Appender.
+func (a *appender) Rollback() error {
+	var multiErr error
+	for _, x := range a.children {
+		if x == nil {
+			continue
+		}
+		if err := x.Rollback(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return multiErr
+}
@@ -0,0 +1,206 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	prometheus_client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/prometheus"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/service/labelstore"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.route",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the prometheus.route
+// component.
+type Arguments struct {
+	// Where the series which don't match any route should be forwarded to.
+	ForwardTo []storage.Appendable `alloy:"forward_to,attr,optional"`
+
+	// The routes series are matched against, in order.
+	Routes []Route `alloy:"route,block,optional"`
+}
+
+// Validate implements syntax.Validator.
+func (args *Arguments) Validate() error {
+	for i, r := range args.Routes {
+		if _, err := parser.ParseMetricSelector(r.Match); err != nil {
+			return fmt.Errorf("invalid match of route at index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Route forwards the series matching a series selector.
+type Route struct {
+	// A series selector of the series to forward.
+	Match string `alloy:"match,attr"`
+
+	// Where the matching series should be forwarded to.
+	ForwardTo []storage.Appendable `alloy:"forward_to,attr"`
+
+	// Whether the matching series are also matched against the next routes.
+	Continue bool `alloy:"continue,attr,optional"`
+}
+
+// Exports holds values which are exported by the prometheus.route component.
+type Exports struct {
+	Receiver storage.Appendable `alloy:"receiver,attr"`
+}
+
+var _ component.Component = (*Component)(nil)
+
+// Component implements the prometheus.route component.
+type Component struct {
+	opts   component.Options
+	ls     labelstore.LabelStore
+	exited atomic.Bool
+
+	mut    sync.RWMutex
+	routes []route
+	// fallback forwards the series which don't match any route.
+	fallback *prometheus.Fanout
+	// dropUnmatched is set when the series which don't match any route
+	// aren't forwarded anywhere.
+	dropUnmatched bool
+	// fanouts holds a fanout per route index. Fanouts are kept when routes
+	// are removed, as their metrics can't be registered again.
+	fanouts []*prometheus.Fanout
+
+	samplesDropped prometheus_client.Counter
+}
+
+// route is a Route whose selector has been parsed.
+type route struct {
+	matchers []*labels.Matcher
+	cont     bool
+	fanout   *prometheus.Fanout
+}
+
+// matches returns whether the series l matches the selector of the route.
+func (r *route) matches(l labels.Labels) bool {
+	for _, m := range r.matchers {
+		if !m.Matches(l.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// New creates a new prometheus.route component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	service, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	c := &Component{
+		opts: o,
+		ls:   ls,
+	}
+	c.fallback = c.newFanout("default")
+
+	c.samplesDropped = prometheus_client.NewCounter(prometheus_client.CounterOpts{
+		Name: "alloy_prometheus_route_samples_dropped_total",
+		Help: "Total number of samples dropped because they didn't match any route",
+	})
+	if err := o.Registerer.Register(c.samplesDropped); err != nil {
+		return nil, err
+	}
+
+	o.OnStateChange(Exports{Receiver: &receiver{c: c}})
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newFanout creates a fanout whose metrics are labeled with the route they
+// belong to.
+func (c *Component) newFanout(name string) *prometheus.Fanout {
+	reg := prometheus_client.WrapRegistererWith(prometheus_client.Labels{"route": name}, c.opts.Registerer)
+	return prometheus.NewFanout(nil, c.opts.ID, reg, c.ls)
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer c.exited.Store(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	routes := make([]route, 0, len(newArgs.Routes))
+	for _, r := range newArgs.Routes {
+		matchers, err := parser.ParseMetricSelector(r.Match)
+		if err != nil {
+			return err
+		}
+		routes = append(routes, route{matchers: matchers, cont: r.Continue})
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for i := range routes {
+		if i == len(c.fanouts) {
+			c.fanouts = append(c.fanouts, c.newFanout(strconv.Itoa(i)))
+		}
+		routes[i].fanout = c.fanouts[i]
+		routes[i].fanout.UpdateChildren(newArgs.Routes[i].ForwardTo)
+	}
+	for _, fanout := range c.fanouts[len(routes):] {
+		fanout.UpdateChildren(nil)
+	}
+	c.fallback.UpdateChildren(newArgs.ForwardTo)
+	c.dropUnmatched = len(newArgs.ForwardTo) == 0
+	c.routes = routes
+	return nil
+}
+
+// receiver is the storage.Appendable exported by the component.
+type receiver struct {
+	c *Component
+}
+
+var _ storage.Appendable = (*receiver)(nil)
+
+// Appender implements storage.Appendable.
+func (r *receiver) Appender(ctx context.Context) storage.Appender {
+	r.c.mut.RLock()
+	defer r.c.mut.RUnlock()
+
+	return &appender{
+		ctx:           ctx,
+		c:             r.c,
+		routes:        r.c.routes,
+		fallback:      r.c.fallback,
+		dropUnmatched: r.c.dropUnmatched,
+		children:      make([]storage.Appender, len(r.c.routes)+1),
+	}
+}
@@ -0,0 +1,120 @@
+package route
+
+import (
+	"fmt"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/internal/util/testappender"
+	"github.com/grafana/alloy/syntax"
+)
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to = []
+		route {
+			match      = "{__name__=~\"kube_.*\"}"
+			forward_to = []
+			continue   = true
+		}
+	`), &args))
+	require.Len(t, args.Routes, 1)
+	require.True(t, args.Routes[0].Continue)
+
+	err := syntax.Unmarshal([]byte(`
+		route {
+			match      = "{"
+			forward_to = []
+		}
+	`), &args)
+	require.ErrorContains(t, err, "invalid match of route at index 0")
+}
+
+func TestComponent(t *testing.T) {
+	var (
+		kube     = testappender.NewCollectingAppender()
+		node     = testappender.NewCollectingAppender()
+		fallback = testappender.NewCollectingAppender()
+	)
+
+	args := Arguments{
+		ForwardTo: []storage.Appendable{testappender.ConstantAppendable{Inner: fallback}},
+		Routes: []Route{
+			{
+				Match:     `{__name__=~"kube_.*"}`,
+				ForwardTo: []storage.Appendable{testappender.ConstantAppendable{Inner: kube}},
+				Continue:  true,
+			},
+			{
+				Match:     `{job="node"}`,
+				ForwardTo: []storage.Appendable{testappender.ConstantAppendable{Inner: node}},
+			},
+			{
+				// Never reached by series matching the previous route.
+				Match:     `{job="node"}`,
+				ForwardTo: []storage.Appendable{testappender.ConstantAppendable{Inner: fallback}},
+			},
+		},
+	}
+
+	var receiver storage.Appendable
+	c, err := New(component.Options{
+		ID:         "prometheus.route.test",
+		Logger:     util.TestAlloyLogger(t),
+		Registerer: prom.NewRegistry(),
+		OnStateChange: func(e component.Exports) {
+			receiver = e.(Exports).Receiver
+		},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+
+	appendSample := func(l labels.Labels, v float64) {
+		a := receiver.Appender(t.Context())
+		_, err := a.Append(0, l, 0, v)
+		require.NoError(t, err)
+		require.NoError(t, a.Commit())
+	}
+	appendSample(labels.FromStrings("__name__", "kube_pod_info", "job", "ksm"), 1)
+	appendSample(labels.FromStrings("__name__", "kube_node_info", "job", "node"), 2)
+	appendSample(labels.FromStrings("__name__", "node_load1", "job", "node"), 3)
+	appendSample(labels.FromStrings("__name__", "up", "job", "api"), 4)
+
+	require.Equal(t, 1.0, kube.LatestSampleFor(`{__name__="kube_pod_info", job="ksm"}`).Value)
+	require.Equal(t, 2.0, kube.LatestSampleFor(`{__name__="kube_node_info", job="node"}`).Value)
+	require.Nil(t, kube.LatestSampleFor(`{__name__="node_load1", job="node"}`))
+
+	require.Equal(t, 2.0, node.LatestSampleFor(`{__name__="kube_node_info", job="node"}`).Value)
+	require.Equal(t, 3.0, node.LatestSampleFor(`{__name__="node_load1", job="node"}`).Value)
+	require.Nil(t, node.LatestSampleFor(`{__name__="kube_pod_info", job="ksm"}`))
+
+	require.Equal(t, 4.0, fallback.LatestSampleFor(`{__name__="up", job="api"}`).Value)
+	require.Nil(t, fallback.LatestSampleFor(`{__name__="kube_pod_info", job="ksm"}`))
+	require.Nil(t, fallback.LatestSampleFor(`{__name__="node_load1", job="node"}`))
+
+	// Series which don't match any route are dropped without a fallback.
+	args.ForwardTo = nil
+	args.Routes = args.Routes[:1]
+	require.NoError(t, c.Update(args))
+	appendSample(labels.FromStrings("__name__", "up", "job", "api"), 5)
+	appendSample(labels.FromStrings("__name__", "node_load1", "job", "node"), 6)
+	require.Equal(t, 4.0, fallback.LatestSampleFor(`{__name__="up", job="api"}`).Value)
+	require.Equal(t, 3.0, node.LatestSampleFor(`{__name__="node_load1", job="node"}`).Value)
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case labelstore.ServiceName:
+		return labelstore.New(nil, prom.DefaultRegisterer), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
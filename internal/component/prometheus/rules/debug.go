@@ -0,0 +1,48 @@
+package rules
+
+import "time"
+
+type DebugInfo struct {
+	Groups []DebugRuleGroup `alloy:"group,block,optional"`
+}
+
+type DebugRuleGroup struct {
+	Source         string        `alloy:"source,attr"`
+	Name           string        `alloy:"name,attr"`
+	Interval       time.Duration `alloy:"interval,attr"`
+	LastEvaluation time.Time     `alloy:"last_evaluation,attr,optional"`
+	EvaluationTime time.Duration `alloy:"evaluation_time,attr,optional"`
+	Rules          []DebugRule   `alloy:"rule,block,optional"`
+}
+
+type DebugRule struct {
+	Name      string `alloy:"name,attr"`
+	Health    string `alloy:"health,attr"`
+	LastError string `alloy:"last_error,attr,optional"`
+}
+
+// DebugInfo implements component.DebugComponent.
+func (c *Component) DebugInfo() any {
+	var output DebugInfo
+	for _, g := range c.manager.RuleGroups() {
+		group := DebugRuleGroup{
+			Source:         g.File(),
+			Name:           g.Name(),
+			Interval:       g.Interval(),
+			LastEvaluation: g.GetLastEvaluation(),
+			EvaluationTime: g.GetEvaluationTime(),
+		}
+		for _, r := range g.Rules() {
+			rule := DebugRule{
+				Name:   r.Name(),
+				Health: string(r.Health()),
+			}
+			if err := r.LastError(); err != nil {
+				rule.LastError = err.Error()
+			}
+			group.Rules = append(group.Rules, rule)
+		}
+		output.Groups = append(output.Groups, group)
+	}
+	return output
+}
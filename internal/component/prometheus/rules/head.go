@@ -0,0 +1,87 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+)
+
+// head is an in-memory TSDB head holding the recent samples rules are
+// evaluated against. Its content isn't persisted.
+type head struct {
+	*tsdb.Head
+}
+
+// newHead creates a new head. The chunks which don't fit in memory anymore are
+// memory-mapped from dir, which is cleared first.
+func newHead(dir string, logger *slog.Logger) (*head, error) {
+	chunksDir := filepath.Join(dir, "head")
+	if err := os.RemoveAll(chunksDir); err != nil {
+		return nil, fmt.Errorf("failed to clear head directory: %w", err)
+	}
+
+	opts := tsdb.DefaultHeadOptions()
+	opts.ChunkDirRoot = chunksDir
+
+	h, err := tsdb.NewHead(nil, logger, nil, nil, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Init(math.MinInt64); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return &head{Head: h}, nil
+}
+
+// Querier implements storage.Queryable.
+func (h *head) Querier(mint, maxt int64) (storage.Querier, error) {
+	return tsdb.NewBlockQuerier(tsdb.NewRangeHead(h.Head, mint, maxt), mint, maxt)
+}
+
+// Appender implements storage.Appendable.
+func (h *head) Appender(ctx context.Context) storage.Appender {
+	return &headAppender{Appender: h.Head.Appender(ctx)}
+}
+
+// headAppender appends samples to the head. The series references received
+// from other components are global references from the label store which
+// mean nothing to the head, so they're always reset. Exemplars and metadata
+// aren't used by rules and are dropped.
+type headAppender struct {
+	storage.Appender
+}
+
+func (a *headAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	return a.Appender.Append(0, l, t, v)
+}
+
+func (a *headAppender) AppendHistogram(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return a.Appender.AppendHistogram(0, l, t, h, fh)
+}
+
+func (a *headAppender) AppendCTZeroSample(_ storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	return a.Appender.AppendCTZeroSample(0, l, t, ct)
+}
+
+func (a *headAppender) AppendHistogramCTZeroSample(_ storage.SeriesRef, l labels.Labels, t, ct int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return a.Appender.AppendHistogramCTZeroSample(0, l, t, ct, h, fh)
+}
+
+func (a *headAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *headAppender) UpdateMetadata(_ storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
+	return 0, nil
+}
@@ -0,0 +1,158 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	promExternalVersions "github.com/prometheus-operator/prometheus-operator/pkg/client/informers/externalversions"
+	promListers "github.com/prometheus-operator/prometheus-operator/pkg/client/listers/monitoring/v1"
+	promVersioned "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	coreListers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	controller "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml" // Used for CRD compatibility instead of gopkg.in/yaml.v2
+
+	commonK8s "github.com/grafana/alloy/internal/component/common/kubernetes"
+)
+
+// kubernetesSyncTimeout is how long to wait for the caches of the watched
+// resources to sync, for example when the API server is unreachable.
+var kubernetesSyncTimeout = time.Minute
+
+// kubernetesRules watches PrometheusRule resources.
+type kubernetesRules struct {
+	namespaceSelector labels.Selector
+	ruleSelector      labels.Selector
+	namespaceLister   coreListers.NamespaceLister
+	ruleLister        promListers.PrometheusRuleLister
+	stopChan          chan struct{}
+}
+
+// newKubernetesRules starts watching the PrometheusRule resources selected by
+// args, and waits for the caches of the resources to sync until ctx is done
+// or kubernetesSyncTimeout elapses. notify is called whenever a namespace or
+// PrometheusRule resource changes.
+func newKubernetesRules(ctx context.Context, args KubernetesArguments, notify func()) (*kubernetesRules, error) {
+	namespaceSelector, err := commonK8s.ConvertSelectorToListOptions(args.RuleNamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	ruleSelector, err := commonK8s.ConvertSelectorToListOptions(args.RuleSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := controller.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get k8s config: %w", err)
+	}
+	k8sClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	promClient, err := promVersioned.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus operator client: %w", err)
+	}
+
+	k := &kubernetesRules{
+		namespaceSelector: namespaceSelector,
+		ruleSelector:      ruleSelector,
+		stopChan:          make(chan struct{}),
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { notify() },
+		UpdateFunc: func(any, any) { notify() },
+		DeleteFunc: func(any) { notify() },
+	}
+
+	namespaceFactory := informers.NewSharedInformerFactoryWithOptions(
+		k8sClient,
+		24*time.Hour,
+		informers.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = namespaceSelector.String()
+		}),
+	)
+	namespaces := namespaceFactory.Core().V1().Namespaces()
+	k.namespaceLister = namespaces.Lister()
+	if _, err := namespaces.Informer().AddEventHandler(handler); err != nil {
+		return nil, err
+	}
+
+	ruleFactory := promExternalVersions.NewSharedInformerFactoryWithOptions(
+		promClient,
+		24*time.Hour,
+		promExternalVersions.WithTweakListOptions(func(lo *metav1.ListOptions) {
+			lo.LabelSelector = ruleSelector.String()
+		}),
+	)
+	promRules := ruleFactory.Monitoring().V1().PrometheusRules()
+	k.ruleLister = promRules.Lister()
+	if _, err := promRules.Informer().AddEventHandler(handler); err != nil {
+		return nil, err
+	}
+
+	namespaceFactory.Start(k.stopChan)
+	ruleFactory.Start(k.stopChan)
+
+	syncCtx, cancel := context.WithTimeout(ctx, kubernetesSyncTimeout)
+	defer cancel()
+	synced := namespaceFactory.WaitForCacheSync(syncCtx.Done())
+	for typ, ok := range ruleFactory.WaitForCacheSync(syncCtx.Done()) {
+		synced[typ] = ok
+	}
+	for typ, ok := range synced {
+		if !ok {
+			k.stop()
+			return nil, fmt.Errorf("failed to sync the cache of %s resources: %w", typ, syncCtx.Err())
+		}
+	}
+	return k, nil
+}
+
+// ruleGroups returns the recording rules of the watched PrometheusRule
+// resources, indexed by an identifier of their resource. PrometheusRule
+// resources which can't be parsed are returned in invalid.
+func (k *kubernetesRules) ruleGroups() (groups map[string]*rulefmt.RuleGroups, invalid map[string]error, err error) {
+	namespaces, err := k.namespaceLister.List(k.namespaceSelector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	groups = make(map[string]*rulefmt.RuleGroups)
+	invalid = make(map[string]error)
+	for _, namespace := range namespaces {
+		rules, err := k.ruleLister.PrometheusRules(namespace.Name).List(k.ruleSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list rules: %w", err)
+		}
+
+		for _, rule := range rules {
+			id := fmt.Sprintf("kubernetes/%s/%s", rule.Namespace, rule.Name)
+			buf, err := yaml.Marshal(rule.Spec)
+			if err != nil {
+				invalid[id] = err
+				continue
+			}
+			// PrometheusRule resources are usually shared with Prometheus or
+			// Mimir, so their alerting rules are skipped.
+			rg, err := parseRules(buf, true)
+			if err != nil {
+				invalid[id] = err
+				continue
+			}
+			groups[id] = rg
+		}
+	}
+	return groups, invalid, nil
+}
+
+func (k *kubernetesRules) stop() {
+	close(k.stopChan)
+}
@@ -0,0 +1,351 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	promrules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/prometheus"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime/logging"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/service/labelstore"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.rules",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   Exports{},
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Exports holds values which are exported by the prometheus.rules component.
+type Exports struct {
+	Receiver storage.Appendable `alloy:"receiver,attr"`
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+	_ component.DebugComponent  = (*Component)(nil)
+)
+
+// Component implements the prometheus.rules component.
+type Component struct {
+	opts component.Options
+	log  log.Logger
+
+	head    *head
+	fanout  *prometheus.Fanout
+	manager *promrules.Manager
+	loader  *groupLoader
+
+	mut        sync.Mutex
+	args       Arguments
+	kubernetes *kubernetesRules
+
+	// reload is notified when the rules have to be loaded again.
+	reload chan struct{}
+
+	// externalLabels is guarded by its own mutex as it's used while rules are
+	// evaluated, which the rule manager waits for while loading rules.
+	externalLabelsMut sync.RWMutex
+	externalLabels    map[string]string
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+// New creates a new prometheus.rules component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	service, err := o.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	logger := slog.New(logging.NewSlogGoKitHandler(o.Logger))
+	h, err := newHead(o.DataPath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create head: %w", err)
+	}
+
+	c := &Component{
+		opts:   o,
+		log:    o.Logger,
+		head:   h,
+		fanout: prometheus.NewFanout(nil, o.ID, o.Registerer, ls),
+		loader: &groupLoader{},
+		reload: make(chan struct{}, 1),
+	}
+
+	engine := promql.NewEngine(promql.EngineOpts{
+		Logger:               logger,
+		MaxSamples:           50_000_000,
+		Timeout:              2 * time.Minute,
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+	})
+	c.manager = promrules.NewManager(&promrules.ManagerOptions{
+		// The results of the rules are also appended to the head, so that they
+		// can be used by other rules.
+		Appendable: prometheus.NewInterceptor(
+			c.fanout,
+			prometheus.WithComponentID(o.ID),
+			prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, t int64, v float64, next storage.Appender) (storage.SeriesRef, error) {
+				return next.Append(ref, c.addExternalLabels(l), t, v)
+			}),
+			prometheus.WithHistogramHook(func(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error) {
+				return next.AppendHistogram(ref, c.addExternalLabels(l), t, h, fh)
+			}),
+		),
+		Queryable:   h,
+		QueryFunc:   promrules.EngineQueryFunc(engine, h),
+		NotifyFunc:  func(context.Context, string, ...*promrules.Alert) {},
+		Context:     context.Background(),
+		Logger:      logger,
+		Registerer:  o.Registerer,
+		GroupLoader: c.loader,
+	})
+
+	o.OnStateChange(Exports{Receiver: prometheus.NewInterceptor(h, prometheus.WithComponentID(o.ID))})
+
+	if err := c.Update(args); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	go c.manager.Run()
+	defer func() {
+		c.manager.Stop()
+
+		c.mut.Lock()
+		if c.kubernetes != nil {
+			c.kubernetes.stop()
+			c.kubernetes = nil
+		}
+		c.mut.Unlock()
+
+		if err := c.head.Close(); err != nil {
+			level.Warn(c.log).Log("msg", "failed to close head", "err", err)
+		}
+	}()
+
+	truncateTicker := time.NewTicker(time.Minute)
+	defer truncateTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.reload:
+			c.loadRules(ctx)
+		case now := <-truncateTicker.C:
+			c.mut.Lock()
+			mint := now.Add(-c.args.Retention).UnixMilli()
+			c.mut.Unlock()
+			if err := c.head.Truncate(mint); err != nil {
+				level.Warn(c.log).Log("msg", "failed to truncate head", "err", err)
+			}
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.kubernetes != nil && !reflect.DeepEqual(c.args.Kubernetes, newArgs.Kubernetes) {
+		c.kubernetes.stop()
+		c.kubernetes = nil
+	}
+	c.args = newArgs
+	c.fanout.UpdateChildren(append(slices.Clone(newArgs.ForwardTo), c.head))
+
+	c.externalLabelsMut.Lock()
+	c.externalLabels = newArgs.ExternalLabels
+	c.externalLabelsMut.Unlock()
+
+	c.requestReload()
+	return nil
+}
+
+func (c *Component) requestReload() {
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+}
+
+// loadRules loads the rules of the arguments and of the PrometheusRule
+// resources in the rule manager.
+func (c *Component) loadRules(ctx context.Context) {
+	groups, interval, err := c.ruleGroups(ctx)
+	if err != nil {
+		c.reportUnhealthy(err)
+		return
+	}
+
+	c.loader.set(groups)
+	if err := c.manager.Update(interval, slices.Sorted(maps.Keys(groups)), labels.EmptyLabels(), "", nil); err != nil {
+		c.reportUnhealthy(err)
+		return
+	}
+	c.reportHealthy()
+}
+
+// ruleGroups returns the rule groups to evaluate, indexed by their source,
+// and the default evaluation interval.
+func (c *Component) ruleGroups(ctx context.Context) (map[string]*rulefmt.RuleGroups, time.Duration, error) {
+	c.mut.Lock()
+	args := c.args
+	k := c.kubernetes
+	c.mut.Unlock()
+
+	groups := make(map[string]*rulefmt.RuleGroups, len(args.Rules))
+	for i, content := range args.Rules {
+		rg, err := parseRules([]byte(content), false)
+		if err != nil {
+			return nil, 0, err
+		}
+		groups[fmt.Sprintf("rules/%d", i)] = rg
+	}
+
+	if args.Kubernetes != nil {
+		if k == nil {
+			// Watching the resources waits for their caches to sync, which
+			// takes long if the API server is unreachable, so c.mut isn't held
+			// meanwhile.
+			var err error
+			k, err = newKubernetesRules(ctx, *args.Kubernetes, c.requestReload)
+			if err != nil {
+				level.Error(c.log).Log("msg", "failed to watch PrometheusRule resources", "err", err)
+				// Try again later.
+				time.AfterFunc(time.Minute, c.requestReload)
+				return nil, 0, err
+			}
+
+			c.mut.Lock()
+			if reflect.DeepEqual(c.args.Kubernetes, args.Kubernetes) {
+				c.kubernetes = k
+			} else {
+				// The arguments changed meanwhile, and Update already requested
+				// another reload.
+				defer k.stop()
+			}
+			c.mut.Unlock()
+		}
+
+		k8sGroups, invalid, err := k.ruleGroups()
+		if err != nil {
+			return nil, 0, err
+		}
+		for id, err := range invalid {
+			level.Warn(c.log).Log("msg", "skipping invalid PrometheusRule resource", "resource", id, "err", err)
+		}
+		maps.Copy(groups, k8sGroups)
+	}
+	return groups, args.EvaluationInterval, nil
+}
+
+// addExternalLabels adds the external labels which aren't set yet to l.
+func (c *Component) addExternalLabels(l labels.Labels) labels.Labels {
+	c.externalLabelsMut.RLock()
+	externalLabels := c.externalLabels
+	c.externalLabelsMut.RUnlock()
+	if len(externalLabels) == 0 {
+		return l
+	}
+
+	lb := labels.NewBuilder(l)
+	for name, value := range externalLabels {
+		if !l.Has(name) {
+			lb.Set(name, value)
+		}
+	}
+	return lb.Labels()
+}
+
+func (c *Component) reportUnhealthy(err error) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = component.Health{
+		Health:     component.HealthTypeUnhealthy,
+		Message:    err.Error(),
+		UpdateTime: time.Now(),
+	}
+}
+
+func (c *Component) reportHealthy() {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = component.Health{
+		Health:     component.HealthTypeHealthy,
+		UpdateTime: time.Now(),
+	}
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+// groupLoader loads rule groups from memory instead of files.
+type groupLoader struct {
+	mut    sync.RWMutex
+	groups map[string]*rulefmt.RuleGroups
+}
+
+var _ promrules.GroupLoader = (*groupLoader)(nil)
+
+func (l *groupLoader) set(groups map[string]*rulefmt.RuleGroups) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.groups = groups
+}
+
+// Load implements rules.GroupLoader.
+func (l *groupLoader) Load(identifier string, _ bool, _ model.ValidationScheme) (*rulefmt.RuleGroups, []error) {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	groups, ok := l.groups[identifier]
+	if !ok {
+		return nil, []error{fmt.Errorf("unknown rules %q", identifier)}
+	}
+	return groups, nil
+}
+
+// Parse implements rules.GroupLoader.
+func (l *groupLoader) Parse(query string) (parser.Expr, error) {
+	return parser.ParseExpr(query)
+}
@@ -0,0 +1,212 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/util"
+	"github.com/grafana/alloy/internal/util/testappender"
+	"github.com/grafana/alloy/syntax"
+)
+
+const testRules = `
+groups:
+  - name: example
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+      - record: job:up:sum_doubled
+        expr: job:up:sum * 2
+`
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	require.NoError(t, syntax.Unmarshal([]byte(`
+		forward_to      = []
+		rules           = [`+"`"+testRules+"`"+`]
+		external_labels = { cluster = "a" }
+		kubernetes {
+			rule_selector {
+				match_labels = { team = "a" }
+			}
+		}
+	`), &args))
+	require.Equal(t, DefaultArguments.EvaluationInterval, args.EvaluationInterval)
+	require.Equal(t, map[string]string{"team": "a"}, args.Kubernetes.RuleSelector.MatchLabels)
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{
+			config: `evaluation_interval = "0s"`,
+			err:    "evaluation_interval must be greater than 0",
+		},
+		{
+			config: `retention = "0s"`,
+			err:    "retention must be greater than 0",
+		},
+		{
+			config: `external_labels = { "not-valid" = "a" }`,
+			err:    `invalid external label name "not-valid"`,
+		},
+		{
+			config: "rules = [`groups: [{name: a, rules: [{alert: A, expr: up == 0}]}]`]",
+			err:    `invalid rules at index 0: group "a": alerting rule "A" isn't supported`,
+		},
+		{
+			config: "rules = [`groups: [{name: a, rules: [{record: a, expr: sum(}]}]`]",
+			err:    `invalid rules at index 0: 1:46: group "a", rule 1, "a": could not parse expression: 1:5: parse error: unclosed left parenthesis`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.err, func(t *testing.T) {
+			var args Arguments
+			err := syntax.Unmarshal([]byte("forward_to = []\n"+tc.config), &args)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	groups, err := parseRules([]byte(`
+groups:
+  - name: alerts
+    rules:
+      - alert: Down
+        expr: up == 0
+  - name: mixed
+    rules:
+      - alert: Down
+        expr: up == 0
+      - record: job:up:sum
+        expr: sum by (job) (up)
+`), true)
+	require.NoError(t, err)
+	require.Len(t, groups.Groups, 1)
+	require.Equal(t, "mixed", groups.Groups[0].Name)
+	require.Len(t, groups.Groups[0].Rules, 1)
+	require.Equal(t, "job:up:sum", groups.Groups[0].Rules[0].Record)
+}
+
+func TestRules(t *testing.T) {
+	app := testappender.NewCollectingAppender()
+
+	args := DefaultArguments
+	args.ForwardTo = []storage.Appendable{testappender.ConstantAppendable{Inner: app}}
+	args.Rules = []string{testRules}
+	args.EvaluationInterval = 100 * time.Millisecond
+	args.ExternalLabels = map[string]string{"cluster": "a", "job": "ignored"}
+
+	var receiver storage.Appendable
+	c, err := New(component.Options{
+		ID:         "prometheus.rules.test",
+		Logger:     util.TestAlloyLogger(t),
+		Registerer: prom.NewRegistry(),
+		DataPath:   t.TempDir(),
+		OnStateChange: func(e component.Exports) {
+			receiver = e.(Exports).Receiver
+		},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+	go c.Run(t.Context())
+
+	// Appends samples until the results of both rules are received.
+	require.Eventually(t, func() bool {
+		a := receiver.Appender(t.Context())
+		now := time.Now().UnixMilli()
+		for _, instance := range []string{"a", "b"} {
+			// The reference is a global reference which has to be ignored.
+			_, err := a.Append(1, labels.FromStrings("__name__", "up", "job", "api", "instance", instance), now, 1)
+			require.NoError(t, err)
+		}
+		require.NoError(t, a.Commit())
+
+		sum := app.LatestSampleFor(`{__name__="job:up:sum", cluster="a", job="api"}`)
+		doubled := app.LatestSampleFor(`{__name__="job:up:sum_doubled", cluster="a", job="api"}`)
+		return sum != nil && sum.Value == 2 && doubled != nil && doubled.Value == 4
+	}, 10*time.Second, 50*time.Millisecond)
+
+	debugInfo := c.DebugInfo().(DebugInfo)
+	require.Len(t, debugInfo.Groups, 1)
+	require.Equal(t, "rules/0", debugInfo.Groups[0].Source)
+	require.Len(t, debugInfo.Groups[0].Rules, 2)
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+}
+
+func TestRules_KubernetesUnreachable(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(`
+apiVersion: v1
+kind: Config
+clusters:
+  - name: unreachable
+    cluster:
+      server: http://127.0.0.1:1
+contexts:
+  - name: unreachable
+    context:
+      cluster: unreachable
+current-context: unreachable
+`), 0o600))
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	defaultTimeout := kubernetesSyncTimeout
+	kubernetesSyncTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { kubernetesSyncTimeout = defaultTimeout })
+
+	args := DefaultArguments
+	args.Kubernetes = &KubernetesArguments{}
+
+	c, err := New(component.Options{
+		ID:             "prometheus.rules.test",
+		Logger:         util.TestAlloyLogger(t),
+		Registerer:     prom.NewRegistry(),
+		DataPath:       t.TempDir(),
+		OnStateChange:  func(component.Exports) {},
+		GetServiceData: getServiceData,
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	require.Eventually(t, func() bool {
+		return c.CurrentHealth().Health == component.HealthTypeUnhealthy
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Neither updates nor shutting down are blocked by the unreachable API
+	// server.
+	require.NoError(t, c.Update(args))
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("component didn't exit")
+	}
+}
+
+func getServiceData(name string) (any, error) {
+	switch name {
+	case labelstore.ServiceName:
+		return labelstore.New(nil, prom.DefaultRegisterer), nil
+	default:
+		return nil, fmt.Errorf("service not found %s", name)
+	}
+}
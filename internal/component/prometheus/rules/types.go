@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/alloy/internal/component/common/kubernetes"
+)
+
+// Arguments holds values which are used to configure the prometheus.rules
+// component.
+type Arguments struct {
+	// Where the results of the recording rules should be appended to.
+	ForwardTo []storage.Appendable `alloy:"forward_to,attr"`
+
+	// The contents of Prometheus rule files.
+	Rules []string `alloy:"rules,attr,optional"`
+
+	// How often rule groups without an interval are evaluated.
+	EvaluationInterval time.Duration `alloy:"evaluation_interval,attr,optional"`
+
+	// How long samples are kept in memory to evaluate rules.
+	Retention time.Duration `alloy:"retention,attr,optional"`
+
+	// Labels to add to the results of the recording rules.
+	ExternalLabels map[string]string `alloy:"external_labels,attr,optional"`
+
+	// Where to read PrometheusRule resources from.
+	Kubernetes *KubernetesArguments `alloy:"kubernetes,block,optional"`
+}
+
+// KubernetesArguments configures how PrometheusRule resources are read from
+// Kubernetes.
+type KubernetesArguments struct {
+	RuleSelector          kubernetes.LabelSelector `alloy:"rule_selector,block,optional"`
+	RuleNamespaceSelector kubernetes.LabelSelector `alloy:"rule_namespace_selector,block,optional"`
+}
+
+// DefaultArguments provides the default arguments for the prometheus.rules
+// component.
+var DefaultArguments = Arguments{
+	EvaluationInterval: time.Minute,
+	Retention:          15 * time.Minute,
+}
+
+// SetToDefault implements syntax.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements syntax.Validator.
+func (args *Arguments) Validate() error {
+	if args.EvaluationInterval <= 0 {
+		return errors.New("evaluation_interval must be greater than 0")
+	}
+	if args.Retention <= 0 {
+		return errors.New("retention must be greater than 0")
+	}
+	for name := range args.ExternalLabels {
+		if !model.LegacyValidation.IsValidLabelName(name) {
+			return fmt.Errorf("invalid external label name %q", name)
+		}
+	}
+	for i, content := range args.Rules {
+		if _, err := parseRules([]byte(content), false); err != nil {
+			return fmt.Errorf("invalid rules at index %d: %w", i, err)
+		}
+	}
+	if args.Kubernetes != nil {
+		if _, err := kubernetes.ConvertSelectorToListOptions(args.Kubernetes.RuleSelector); err != nil {
+			return fmt.Errorf("invalid rule_selector: %w", err)
+		}
+		if _, err := kubernetes.ConvertSelectorToListOptions(args.Kubernetes.RuleNamespaceSelector); err != nil {
+			return fmt.Errorf("invalid rule_namespace_selector: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseRules parses the content of a Prometheus rule file and returns its
+// recording rules. Alerting rules are skipped if skipAlerts is true, and
+// rejected otherwise.
+func parseRules(content []byte, skipAlerts bool) (*rulefmt.RuleGroups, error) {
+	groups, errs := rulefmt.Parse(content, false, model.UTF8Validation)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	res := &rulefmt.RuleGroups{Groups: make([]rulefmt.RuleGroup, 0, len(groups.Groups))}
+	for _, g := range groups.Groups {
+		rules := make([]rulefmt.Rule, 0, len(g.Rules))
+		for _, r := range g.Rules {
+			if r.Alert != "" {
+				if !skipAlerts {
+					return nil, fmt.Errorf("group %q: alerting rule %q isn't supported", g.Name, r.Alert)
+				}
+				continue
+			}
+			rules = append(rules, r)
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		g.Rules = rules
+		res.Groups = append(res.Groups, g)
+	}
+	return res, nil
+}
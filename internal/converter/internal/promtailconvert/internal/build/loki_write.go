@@ -54,6 +54,7 @@ func toLokiWriteArguments(config *client.Config, diags *diag.Diagnostics) *lokiw
 				TenantID:          config.TenantID,
 				RetryOnHTTP429:    !config.DropRateLimitedBatches,
 				QueueConfig:       lokiwrite.GetDefaultEndpointOptions().QueueConfig,
+				Protocol:          lokiwrite.GetDefaultEndpointOptions().Protocol,
 			},
 		},
 		ExternalLabels: convertFlagLabels(config.ExternalLabels),
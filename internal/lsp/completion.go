@@ -0,0 +1,237 @@
+package lsp
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/grafana/alloy/internal/nodeconf/argument"
+	"github.com/grafana/alloy/internal/nodeconf/export"
+	"github.com/grafana/alloy/internal/nodeconf/foreach"
+	"github.com/grafana/alloy/internal/nodeconf/importsource"
+)
+
+// complete returns the completion items at the byte offset off of doc.
+func (s *Server) complete(doc *document, off int) []completionItem {
+	// The reference being typed is usually incomplete, so it is left out of
+	// the parsed document to let the parser handle the rest of it.
+	prefix := doc.prefixAt(off)
+	start := off - len(prefix)
+	o := parseOutline(doc.filename(), doc.text[:start]+strings.Repeat(" ", len(prefix))+doc.text[off:])
+
+	c := &completer{prefix: prefix, seen: make(map[string]struct{})}
+	switch {
+	case o.inValue(start):
+		s.completeReferences(c, o.blocks, o.scopeAt(start))
+	default:
+		s.completeBody(c, o, start)
+	}
+	return c.items
+}
+
+// completer collects completion items for the next segment of prefix.
+type completer struct {
+	prefix string
+	items  []completionItem
+	seen   map[string]struct{}
+}
+
+// add adds an item for name if it starts with the prefix being completed.
+// Names are completed one period-delimited segment at a time.
+func (c *completer) add(name string, kind int, detail string) {
+	segment, ok := nextSegment(name, c.prefix)
+	if !ok {
+		return
+	}
+	if c.prefix[:strings.LastIndexByte(c.prefix, '.')+1]+segment != strings.TrimSuffix(name, ".") {
+		// Only part of the name is completed.
+		kind, detail = completionKindModule, ""
+	}
+	if _, ok := c.seen[segment]; ok {
+		return
+	}
+	c.seen[segment] = struct{}{}
+	c.items = append(c.items, completionItem{Label: segment, Kind: kind, Detail: detail})
+}
+
+// completeBody completes the names of blocks and attributes that can be
+// set in the block enclosing the cursor.
+func (s *Server) completeBody(c *completer, o outline, off int) {
+	var (
+		blocks    = o.blocks
+		scope     = o.scopeAt(off)
+		container = true
+		typ       reflect.Type
+		parent    string
+		instance  = -1
+	)
+	for _, idx := range o.enclosing(off) {
+		b := blocks[idx]
+		switch {
+		case container && b.name == declareBlockName:
+			typ, instance = nil, -1
+		case !container && parent == foreach.BlockName && b.name == foreach.TypeTemplate:
+			container, typ, instance = true, nil, -1
+		case container:
+			container, typ, instance = false, nil, -1
+			if t, ok := s.configBlocks[b.name]; ok {
+				typ = t
+			} else if reg, err := s.getComponent(b.name); err == nil && reg.Args != nil {
+				typ = reflect.TypeOf(reg.Args)
+			} else {
+				instance = findDeclare(blocks, b.name, scopeOf(blocks, idx))
+			}
+		case typ != nil:
+			typ, _ = childBlockType(typ, b.name)
+			instance = -1
+		default:
+			instance = -1
+		}
+		parent = b.name
+	}
+
+	if container {
+		s.completeBlockNames(c, blocks, scope)
+		return
+	}
+
+	if typ != nil {
+		for _, f := range schemaFields(typ) {
+			if f.block {
+				c.add(f.name, completionKindStruct, "block")
+			} else {
+				c.add(f.name, completionKindProperty, f.typ.String())
+			}
+		}
+	}
+	if parent == foreach.BlockName {
+		c.add(foreach.TypeTemplate, completionKindStruct, "block")
+	}
+	if instance >= 0 {
+		// The arguments of a custom component are defined by the argument
+		// blocks of its declare block.
+		for _, b := range blocks {
+			if b.parent == instance && b.name == argument.BlockName && b.label != "" {
+				c.add(b.label, completionKindProperty, "argument")
+			}
+		}
+	}
+}
+
+// completeBlockNames completes the names of components and config blocks.
+func (s *Server) completeBlockNames(c *completer, blocks []blockInfo, scope int) {
+	for _, name := range s.components {
+		c.add(name, completionKindStruct, "component")
+	}
+	for _, name := range sortedKeys(s.configBlocks) {
+		c.add(name, completionKindStruct, "block")
+	}
+	for i, b := range blocks {
+		if !visibleFrom(blocks, i, scope) || b.label == "" {
+			continue
+		}
+		switch {
+		case b.name == declareBlockName:
+			c.add(b.label, completionKindStruct, "custom component")
+		case isImport(b.name):
+			c.add(b.label+".", completionKindModule, "")
+		}
+	}
+}
+
+// completeReferences completes references to components, arguments and
+// exports.
+func (s *Server) completeReferences(c *completer, blocks []blockInfo, scope int) {
+	parent := ""
+	if i := strings.LastIndexByte(c.prefix, '.'); i >= 0 {
+		parent = c.prefix[:i]
+	}
+
+	for i, b := range blocks {
+		if scopeOf(blocks, i) != scope || b.label == "" {
+			continue
+		}
+
+		switch {
+		case b.name == declareBlockName || b.name == export.BlockName || b.name == foreach.BlockName:
+			continue
+		case isImport(b.name):
+			c.add(b.label+".", completionKindModule, "")
+			continue
+		}
+
+		c.add(b.id(), completionKindVariable, b.name)
+		if b.id() != parent {
+			continue
+		}
+
+		// Complete the exports of the referenced block.
+		switch decl := findDeclare(blocks, b.name, scope); {
+		case b.name == argument.BlockName:
+			c.add(b.id()+".value", completionKindField, "any")
+		case decl >= 0:
+			for _, e := range blocks {
+				if e.parent == decl && e.name == export.BlockName && e.label != "" {
+					c.add(b.id()+"."+e.label, completionKindField, "export")
+				}
+			}
+		default:
+			reg, err := s.getComponent(b.name)
+			if err != nil || reg.Exports == nil {
+				continue
+			}
+			for _, f := range schemaFields(reflect.TypeOf(reg.Exports)) {
+				c.add(b.id()+"."+f.name, completionKindField, f.typ.String())
+			}
+		}
+	}
+}
+
+// scopeOf returns the index of the declare block enclosing the block at
+// index i, or -1 if it is defined at the top level.
+func scopeOf(blocks []blockInfo, i int) int {
+	for p := blocks[i].parent; p >= 0; p = blocks[p].parent {
+		if blocks[p].name == declareBlockName {
+			return p
+		}
+	}
+	return -1
+}
+
+// visibleFrom reports whether the block at index i is visible from the
+// declare block at index scope. Declare and import blocks are visible from
+// nested declare blocks.
+func visibleFrom(blocks []blockInfo, i int, scope int) bool {
+	target := scopeOf(blocks, i)
+	for {
+		if target == scope {
+			return true
+		}
+		if scope < 0 {
+			return false
+		}
+		scope = scopeOf(blocks, scope)
+	}
+}
+
+// findDeclare returns the index of the declare block called label visible
+// from scope, or -1.
+func findDeclare(blocks []blockInfo, label string, scope int) int {
+	found := -1
+	for i, b := range blocks {
+		if b.name == declareBlockName && b.label == label && visibleFrom(blocks, i, scope) {
+			// Prefer the innermost declaration.
+			if found < 0 || scopeOf(blocks, i) == scope {
+				found = i
+			}
+		}
+	}
+	return found
+}
+
+func isImport(name string) bool {
+	switch name {
+	case importsource.BlockNameFile, importsource.BlockNameString, importsource.BlockNameHTTP, importsource.BlockNameGit:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/alloy/internal/nodeconf/function"
+	"github.com/grafana/alloy/internal/nodeconf/importsource"
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/vm"
+)
+
+// definition returns the location of the block defining the reference at the
+// byte offset off of doc. References can point to components, arguments,
+// declare blocks used as custom components and import namespaces, and to the
+// declare and function blocks of modules imported with import.file.
+func (s *Server) definition(doc *document, off int) (location, bool) {
+	word, start := doc.wordAt(off)
+	if word == "" {
+		return location{}, false
+	}
+
+	var (
+		o      = parseOutline(doc.filename(), doc.text)
+		blocks = o.blocks
+		scope  = o.scopeAt(start)
+	)
+
+	segments := strings.Split(word, ".")
+	for n := len(segments); n > 0; n-- {
+		id := strings.Join(segments[:n], ".")
+		for i, b := range blocks {
+			if b.label == "" || b.id() != id || scopeOf(blocks, i) != scope {
+				continue
+			}
+			return blockLocation(doc, b), true
+		}
+	}
+
+	// The name of a custom component refers to its declare block, or to the
+	// import block of its namespace.
+	for n := len(segments); n > 0; n-- {
+		name := strings.Join(segments[:n], ".")
+		if i := findDeclare(blocks, name, scope); i >= 0 {
+			return blockLocation(doc, blocks[i]), true
+		}
+	}
+	for i, b := range blocks {
+		if isImport(b.name) && b.label == segments[0] && visibleFrom(blocks, i, scope) {
+			if loc, ok := s.importedDefinition(doc, b, segments[1:]); ok {
+				return loc, true
+			}
+			return blockLocation(doc, b), true
+		}
+	}
+	return location{}, false
+}
+
+// importedDefinition returns the location of the block named by segments in
+// the module imported by the import block b of doc. Only modules imported
+// with import.file can be read.
+func (s *Server) importedDefinition(doc *document, b blockInfo, segments []string) (location, bool) {
+	if b.name != importsource.BlockNameFile || len(segments) == 0 {
+		return location{}, false
+	}
+
+	for _, module := range s.importedDocuments(doc, b.stmt) {
+		for _, mb := range parseOutline(module.filename(), module.text).blocks {
+			if mb.parent >= 0 || mb.label != segments[0] {
+				continue
+			}
+
+			switch {
+			case mb.name == declareBlockName || mb.name == function.BlockName:
+				return blockLocation(module, mb), true
+			case isImport(mb.name):
+				if loc, ok := s.importedDefinition(module, mb, segments[1:]); ok {
+					return loc, true
+				}
+				return blockLocation(module, mb), true
+			}
+		}
+	}
+	return location{}, false
+}
+
+// importedDocuments returns the documents of the module imported by the
+// import.file block stmt of doc. The filename is evaluated with module_path
+// set to the directory of doc, from which relative paths are resolved.
+func (s *Server) importedDocuments(doc *document, stmt *ast.BlockStmt) []*document {
+	var (
+		dir      = filepath.Dir(doc.filename())
+		filename string
+	)
+	for _, st := range stmt.Body {
+		attr, ok := st.(*ast.AttributeStmt)
+		if !ok || attr.Name.Name != "filename" {
+			continue
+		}
+		scope := vm.NewScope(map[string]any{importsource.ModulePath: dir})
+		if err := vm.New(attr.Value).Evaluate(scope, &filename); err != nil {
+			return nil
+		}
+	}
+	if filename == "" {
+		return nil
+	}
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+
+	paths := []string{filename}
+	if fi, err := os.Stat(filename); err == nil && fi.IsDir() {
+		paths, _ = filepath.Glob(filepath.Join(filename, "*.alloy"))
+	}
+
+	var docs []*document
+	for _, path := range paths {
+		uri := pathToURI(path)
+		if open, ok := s.docs[uri]; ok {
+			docs = append(docs, open)
+		} else if bb, err := os.ReadFile(path); err == nil {
+			docs = append(docs, newDocument(uri, string(bb)))
+		}
+	}
+	return docs
+}
+
+func blockLocation(doc *document, b blockInfo) location {
+	return location{
+		URI: doc.uri,
+		Range: textRange{
+			Start: doc.position(b.offset),
+			End:   doc.position(b.offset + len(b.name)),
+		},
+	}
+}
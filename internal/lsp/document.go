@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/parser"
+	"github.com/grafana/alloy/syntax/token"
+)
+
+// document is a text document opened in the editor.
+type document struct {
+	uri  string
+	text string
+
+	// lines holds the byte offset of the start of each line.
+	lines []int
+}
+
+func newDocument(uri, text string) *document {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &document{uri: uri, text: text, lines: lines}
+}
+
+// filename returns the path of the document on disk.
+func (d *document) filename() string {
+	return uriToPath(d.uri)
+}
+
+// parse parses the document. The returned file may be incomplete if the
+// document contains syntax errors.
+func (d *document) parse() (*ast.File, error) {
+	return parser.ParseFile(d.filename(), []byte(d.text))
+}
+
+// offset returns the byte offset of pos. Characters in LSP positions are
+// counted in UTF-16 code units.
+func (d *document) offset(pos position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	off := d.lines[pos.Line]
+	for units := 0; units < pos.Character && off < len(d.text) && d.text[off] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[off:])
+		units += utf16.RuneLen(r)
+		off += size
+	}
+	return off
+}
+
+// position returns the LSP position of a byte offset.
+func (d *document) position(off int) position {
+	off = min(max(off, 0), len(d.text))
+
+	line := 0
+	for line+1 < len(d.lines) && d.lines[line+1] <= off {
+		line++
+	}
+
+	character := 0
+	for _, r := range d.text[d.lines[line]:off] {
+		character += utf16.RuneLen(r)
+	}
+	return position{Line: line, Character: character}
+}
+
+// tokenPosition returns the LSP position of a position reported by the
+// syntax packages.
+func (d *document) tokenPosition(p token.Position) position {
+	if p.Line <= 0 || p.Line > len(d.lines) {
+		return position{}
+	}
+	return d.position(d.lines[p.Line-1] + max(p.Column-1, 0))
+}
+
+// tokenRange returns the LSP range for the positions of a diagnostic. The end
+// of a diagnostic is inclusive, so the range is extended by one character.
+func (d *document) tokenRange(start, end token.Position) textRange {
+	r := textRange{Start: d.tokenPosition(start), End: d.tokenPosition(end)}
+	if !end.Valid() || r.End.Line < r.Start.Line || (r.End.Line == r.Start.Line && r.End.Character < r.Start.Character) {
+		r.End = r.Start
+	}
+	r.End.Character++
+	return r
+}
+
+// wordAt returns the reference, such as prometheus.remote_write.default.receiver,
+// surrounding the byte offset off, and the offset where it starts.
+func (d *document) wordAt(off int) (string, int) {
+	start, end := off, off
+	for start > 0 && isWordChar(d.text[start-1]) {
+		start--
+	}
+	for end < len(d.text) && isWordChar(d.text[end]) {
+		end++
+	}
+	return d.text[start:end], start
+}
+
+// prefixAt returns the part of the reference before the byte offset off.
+func (d *document) prefixAt(off int) string {
+	start := off
+	for start > 0 && isWordChar(d.text[start-1]) {
+		start--
+	}
+	return d.text[start:off]
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// uriToPath converts a file URI to a path.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI converts a path to a file URI.
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths such as C:/config.alloy.
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
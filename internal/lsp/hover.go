@@ -0,0 +1,140 @@
+package lsp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/alloy/internal/nodeconf/foreach"
+	"github.com/grafana/alloy/syntax/ast"
+)
+
+// docsURL is the base URL of the reference documentation.
+const docsURL = "https://grafana.com/docs/alloy/latest/reference/"
+
+// hover returns the documentation of the block or attribute name at the byte
+// offset off of doc.
+func (s *Server) hover(doc *document, off int) (hoverResult, bool) {
+	var (
+		o      = parseOutline(doc.filename(), doc.text)
+		blocks = o.blocks
+	)
+
+	for i, b := range blocks {
+		if b.offset <= off && off < b.offset+len(b.name) {
+			text, ok := s.blockDocs(o, i)
+			if !ok {
+				return hoverResult{}, false
+			}
+			return newHoverResult(doc, text, b.offset, len(b.name)), true
+		}
+	}
+
+	for i, b := range blocks {
+		for _, stmt := range b.stmt.Body {
+			attr, ok := stmt.(*ast.AttributeStmt)
+			if !ok {
+				continue
+			}
+			start := attr.Name.NamePos.Offset()
+			if off < start || off >= start+len(attr.Name.Name) {
+				continue
+			}
+
+			typ, _ := s.resolveBlockType(blocks, i)
+			if typ == nil {
+				return hoverResult{}, false
+			}
+			for _, f := range schemaFields(typ) {
+				if !f.block && f.name == attr.Name.Name {
+					return newHoverResult(doc, fieldDocs(f), start, len(attr.Name.Name)), true
+				}
+			}
+			return hoverResult{}, false
+		}
+	}
+	return hoverResult{}, false
+}
+
+// blockDocs returns the documentation of the block at index i of o.
+func (s *Server) blockDocs(o outline, i int) (string, bool) {
+	var (
+		blocks = o.blocks
+		b      = blocks[i]
+	)
+
+	if parent, nested := s.resolveBlockType(blocks, b.parent); nested && parent != nil {
+		for _, f := range schemaFields(parent) {
+			if f.block && f.name == b.name {
+				return fieldDocs(f), true
+			}
+		}
+		return "", false
+	} else if nested {
+		return "", false
+	}
+
+	if reg, err := s.getComponent(b.name); err == nil {
+		namespace, _, _ := strings.Cut(b.name, ".")
+		return fmt.Sprintf("**`%s`** component\n\nStability: %s\n\n[Documentation](%scomponents/%s/%s/)",
+			b.name, strings.Trim(reg.Stability.String(), `"`), docsURL, namespace, b.name), true
+	}
+	if _, ok := s.configBlocks[b.name]; ok {
+		return fmt.Sprintf("**`%s`** block\n\n[Documentation](%sconfig-blocks/%s/)", b.name, docsURL, b.name), true
+	}
+	if findDeclare(blocks, b.name, scopeOf(blocks, i)) >= 0 {
+		return fmt.Sprintf("**`%s`** custom component", b.name), true
+	}
+	return "", false
+}
+
+// resolveBlockType returns the type the body of the block at index i is
+// decoded into. nested is false if the block is a container of components,
+// such as the top level of the document or a declare block, in which case
+// the returned type is nil. The type is nil as well if it is unknown.
+func (s *Server) resolveBlockType(blocks []blockInfo, i int) (typ reflect.Type, nested bool) {
+	if i < 0 {
+		return nil, false
+	}
+
+	b := blocks[i]
+	parent, parentNested := s.resolveBlockType(blocks, b.parent)
+	switch {
+	case !parentNested && b.name == declareBlockName:
+		return nil, false
+	case parentNested && b.parent >= 0 && blocks[b.parent].name == foreach.BlockName && b.name == foreach.TypeTemplate:
+		return nil, false
+	case !parentNested:
+		if t, ok := s.configBlocks[b.name]; ok {
+			return t, true
+		}
+		if reg, err := s.getComponent(b.name); err == nil && reg.Args != nil {
+			return reflect.TypeOf(reg.Args), true
+		}
+		return nil, true
+	case parent != nil:
+		t, _ := childBlockType(parent, b.name)
+		return t, true
+	default:
+		return nil, true
+	}
+}
+
+// fieldDocs returns the documentation of an attribute or block.
+func fieldDocs(f schemaField) string {
+	required := "required"
+	if f.optional {
+		required = "optional"
+	}
+	if f.block {
+		return fmt.Sprintf("**`%s`** block, %s", f.name, required)
+	}
+	return fmt.Sprintf("**`%s`** `%s` attribute, %s", f.name, f.typ, required)
+}
+
+func newHoverResult(doc *document, text string, off, length int) hoverResult {
+	return hoverResult{
+		Contents: markupContent{Kind: markupKindMarkdown, Value: text},
+		Range:    textRange{Start: doc.position(off), End: doc.position(off + length)},
+	}
+}
@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/parser"
+)
+
+// blockInfo is a block of a document.
+type blockInfo struct {
+	name  string
+	label string
+	stmt  *ast.BlockStmt
+	// offset is the byte offset of the block name.
+	offset int
+	// body and end are the byte offsets of the braces enclosing the body of
+	// the block. Blocks which aren't closed end at the end of the document.
+	body, end int
+	// parent is the index of the enclosing block, or -1 for top-level blocks.
+	parent int
+}
+
+// id returns the identifier used to reference the block.
+func (b blockInfo) id() string {
+	if b.label == "" {
+		return b.name
+	}
+	return b.name + "." + b.label
+}
+
+// outline holds the blocks of a document and the byte offsets of the values
+// of its attributes.
+type outline struct {
+	blocks []blockInfo
+	values []valueRange
+}
+
+type valueRange struct {
+	start, end int
+}
+
+// parseOutline parses text. Statements with syntax errors, such as the one
+// being typed, are left out of the outline.
+func parseOutline(filename string, text string) outline {
+	var o outline
+	f, _ := parser.ParseFilePartial(filename, []byte(text))
+	if f != nil {
+		o.addBody(f.Body, -1, len(text))
+	}
+	return o
+}
+
+func (o *outline) addBody(body ast.Body, parent int, end int) {
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			start := ast.StartPos(stmt.Value)
+			if !start.Valid() {
+				continue
+			}
+			// The value of an attribute ends after its last character, where
+			// it is still being typed.
+			o.values = append(o.values, valueRange{
+				start: start.Offset(),
+				end:   max(ast.EndPos(stmt.Value).Offset()+1, start.Offset()),
+			})
+
+		case *ast.BlockStmt:
+			b := blockInfo{
+				name:   stmt.GetBlockName(),
+				label:  stmt.Label,
+				stmt:   stmt,
+				offset: stmt.NamePos.Offset(),
+				body:   stmt.LCurlyPos.Offset(),
+				end:    end,
+				parent: parent,
+			}
+			if stmt.RCurlyPos.Valid() {
+				b.end = min(stmt.RCurlyPos.Offset(), end)
+			}
+			o.blocks = append(o.blocks, b)
+			o.addBody(stmt.Body, len(o.blocks)-1, b.end)
+		}
+	}
+}
+
+// enclosing returns the indices of the blocks whose body encloses the byte
+// offset off, outermost first.
+func (o outline) enclosing(off int) []int {
+	var res []int
+	for i, b := range o.blocks {
+		// Blocks are listed before the blocks they enclose.
+		if b.body < off && off <= b.end {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// inValue reports whether the byte offset off is in the value of an
+// attribute.
+func (o outline) inValue(off int) bool {
+	for _, v := range o.values {
+		if v.start <= off && off <= v.end {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeAt returns the index of the innermost declare block enclosing the byte
+// offset off, or -1 if off is at the top level.
+func (o outline) scopeAt(off int) int {
+	scope := -1
+	for _, i := range o.enclosing(off) {
+		if o.blocks[i].name == declareBlockName {
+			scope = i
+		}
+	}
+	return scope
+}
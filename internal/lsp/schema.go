@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// schemaField is an attribute or block accepted in the body of a block.
+type schemaField struct {
+	name     string
+	block    bool
+	optional bool
+	typ      reflect.Type
+}
+
+// schemaFields returns the attributes and blocks that can be set in a block
+// decoded into a value of type t.
+func schemaFields(t reflect.Type) []schemaField {
+	t = indirect(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, kind, optional, ok := parseTag(f)
+		if !ok {
+			continue
+		}
+
+		switch kind {
+		case "squash":
+			fields = append(fields, schemaFields(f.Type)...)
+		case "attr":
+			fields = append(fields, schemaField{name: name, optional: optional, typ: f.Type})
+		case "block":
+			fields = append(fields, schemaField{name: name, block: true, optional: optional, typ: blockType(f.Type)})
+		case "enum":
+			// Each field of an enum element is a block which may be used in any
+			// order, like the stages of loki.process.
+			for _, ef := range schemaFields(blockType(f.Type)) {
+				fields = append(fields, schemaField{name: name + "." + ef.name, block: true, optional: true, typ: ef.typ})
+			}
+		}
+	}
+	return fields
+}
+
+// childBlockType returns the type of the block called name in a block decoded
+// into a value of type t.
+func childBlockType(t reflect.Type, name string) (reflect.Type, bool) {
+	for _, f := range schemaFields(t) {
+		if f.block && f.name == name {
+			return f.typ, true
+		}
+	}
+	return nil, false
+}
+
+// parseTag returns the name and kind of an Alloy struct tag, and whether the
+// field is optional.
+func parseTag(f reflect.StructField) (name string, kind string, optional bool, ok bool) {
+	tag, ok := f.Tag.Lookup("alloy")
+	if !ok || tag == "-" {
+		return "", "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	if len(parts) < 2 {
+		return "", "", false, false
+	}
+	if parts[0] == "" && parts[1] == "squash" {
+		return "", "squash", false, true
+	}
+	return parts[0], parts[1], slices.Contains(parts[2:], "optional"), true
+}
+
+// blockType returns the type of a single block for a field which may hold a
+// list of blocks.
+func blockType(t reflect.Type) reflect.Type {
+	t = indirect(t)
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return indirect(t.Elem())
+	}
+	return t
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
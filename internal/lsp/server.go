@@ -0,0 +1,436 @@
+// Package lsp implements a language server for Alloy configuration files.
+//
+// The server speaks the Language Server Protocol over a pair of streams,
+// usually stdin and stdout, and provides diagnostics, completion,
+// go-to-definition, hover documentation and formatting.
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/nodeconf/argument"
+	"github.com/grafana/alloy/internal/nodeconf/export"
+	"github.com/grafana/alloy/internal/nodeconf/foreach"
+	"github.com/grafana/alloy/internal/nodeconf/function"
+	"github.com/grafana/alloy/internal/nodeconf/importsource"
+	"github.com/grafana/alloy/internal/runtime/logging"
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+	"github.com/grafana/alloy/internal/runtime/tracing"
+	"github.com/grafana/alloy/internal/service"
+	"github.com/grafana/alloy/internal/validator"
+	"github.com/grafana/alloy/syntax/diag"
+	"github.com/grafana/alloy/syntax/printer"
+)
+
+// Options configures a Server.
+type Options struct {
+	// ComponentRegistry is used to look up components for completion and
+	// validation.
+	ComponentRegistry component.Registry
+	// ComponentNames are the names of components offered for completion.
+	// Components which can't be retrieved from ComponentRegistry are ignored.
+	ComponentNames []string
+	// ServiceDefinitions is used to validate and complete service config.
+	ServiceDefinitions []service.Definition
+	// MinStability is the minimum stability level of features that can be
+	// used in the configuration.
+	MinStability featuregate.Stability
+	// Logger receives logs of the server. Logs must not be written to the
+	// stream used to talk to the client.
+	Logger log.Logger
+}
+
+// Server is a language server for Alloy configuration files.
+type Server struct {
+	opts Options
+	log  log.Logger
+	conn *conn
+
+	// configBlocks holds the types of the config blocks which aren't
+	// components.
+	configBlocks map[string]reflect.Type
+	// components holds the names of the components offered for completion.
+	components []string
+
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer creates a new Server.
+func NewServer(opts Options) *Server {
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	configBlocks := map[string]reflect.Type{
+		"logging":                    reflect.TypeFor[logging.Options](),
+		"tracing":                    reflect.TypeFor[tracing.Options](),
+		argument.BlockName:           reflect.TypeFor[argument.Arguments](),
+		export.BlockName:             reflect.TypeFor[export.Arguments](),
+		foreach.BlockName:            reflect.TypeFor[foreach.Arguments](),
+		importsource.BlockNameFile:   reflect.TypeFor[importsource.FileArguments](),
+		importsource.BlockNameString: reflect.TypeFor[importsource.StringArguments](),
+		importsource.BlockNameHTTP:   reflect.TypeFor[importsource.HTTPArguments](),
+		importsource.BlockNameGit:    reflect.TypeFor[importsource.GitArguments](),
+		declareBlockName:             nil,
+		function.BlockName:           nil,
+	}
+	for _, def := range opts.ServiceDefinitions {
+		if def.ConfigType != nil {
+			configBlocks[def.Name] = reflect.TypeOf(def.ConfigType)
+		}
+	}
+
+	s := &Server{
+		opts:         opts,
+		log:          logger,
+		configBlocks: configBlocks,
+		docs:         make(map[string]*document),
+	}
+	for _, name := range opts.ComponentNames {
+		if _, err := s.getComponent(name); err == nil {
+			s.components = append(s.components, name)
+		}
+	}
+	return s
+}
+
+const declareBlockName = "declare"
+
+func (s *Server) getComponent(name string) (component.Registration, error) {
+	if s.opts.ComponentRegistry == nil {
+		return component.Registration{}, fmt.Errorf("cannot find the definition of component name %q", name)
+	}
+	return s.opts.ComponentRegistry.Get(name)
+}
+
+// errExitWithoutShutdown is returned by Serve when the client asks the server
+// to exit without asking it to shut down first.
+var errExitWithoutShutdown = errors.New("exit notification received before a shutdown request")
+
+// Serve reads requests from r and writes responses to w until the client
+// asks the server to exit, r is closed or ctx is canceled. An error is
+// returned if the client asks the server to exit before shutting it down, so
+// that the process exits with code 1 as required by the protocol.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		body, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.conn.write(response{JSONRPC: "2.0", Error: &responseError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(req)
+		if req.ID == nil {
+			// Notifications don't have a response.
+			if rerr != nil {
+				level.Warn(s.log).Log("msg", "failed to handle notification", "method", req.Method, "err", rerr.Message)
+			}
+			continue
+		}
+
+		if err := s.conn.write(response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req request) (any, *responseError) {
+	if !s.initialized && req.Method != "initialize" {
+		return nil, &responseError{Code: codeServerNotStarted, Message: "server not initialized"}
+	}
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		s.initialized = true
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync: textDocumentSyncOptions{
+					OpenClose: true,
+					Change:    textDocumentSyncFull,
+					Save:      saveOptions{IncludeText: true},
+				},
+				CompletionProvider:         completionOptions{TriggerCharacters: []string{"."}},
+				DefinitionProvider:         true,
+				HoverProvider:              true,
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: serverInfo{Name: "alloy"},
+		}, nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := newDocument(params.TextDocument.URI, params.TextDocument.Text)
+		s.docs[doc.uri] = doc
+		return nil, s.publishDiagnostics(doc)
+
+	case "textDocument/didChange":
+		var params didChangeTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		// The server requests full document sync, the last change holds the
+		// whole document.
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[params.TextDocument.URI] = newDocument(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didSave":
+		var params didSaveTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if params.Text != nil {
+			doc, ok = newDocument(params.TextDocument.URI, *params.Text), true
+			s.docs[doc.uri] = doc
+		}
+		if !ok {
+			return nil, nil
+		}
+		return nil, s.publishDiagnostics(doc)
+
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return completionList{Items: s.complete(doc, doc.offset(params.Position))}, nil
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		if loc, ok := s.definition(doc, doc.offset(params.Position)); ok {
+			return loc, nil
+		}
+		return nil, nil
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		if res, ok := s.hover(doc, doc.offset(params.Position)); ok {
+			return res, nil
+		}
+		return nil, nil
+
+	case "textDocument/formatting":
+		var params documentFormattingParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return format(doc)
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not supported", req.Method)}
+}
+
+func (s *Server) notify(method string, params any) *responseError {
+	if err := s.conn.write(notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		return &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// publishDiagnostics validates doc and sends the resulting diagnostics to the
+// client.
+func (s *Server) publishDiagnostics(doc *document) *responseError {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: s.diagnose(doc),
+	})
+}
+
+// diagnose validates doc the same way as alloy validate. Other Alloy files
+// in the same directory are validated with it, since they are loaded together
+// when Alloy runs a directory.
+func (s *Server) diagnose(doc *document) []diagnostic {
+	filename := doc.filename()
+	sources := map[string][]byte{filename: []byte(doc.text)}
+
+	if filepath.IsAbs(filename) {
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(filename), "*.alloy"))
+		for _, path := range matches {
+			if _, ok := sources[path]; ok {
+				continue
+			}
+			if other, ok := s.docs[pathToURI(path)]; ok {
+				sources[path] = []byte(other.text)
+			} else if bb, err := os.ReadFile(path); err == nil {
+				sources[path] = bb
+			}
+		}
+	}
+
+	err := validator.Validate(validator.Options{
+		Sources:            sources,
+		ServiceDefinitions: s.opts.ServiceDefinitions,
+		ComponentRegistry:  s.opts.ComponentRegistry,
+		MinStability:       s.opts.MinStability,
+	})
+	if err == nil {
+		return []diagnostic{}
+	}
+
+	var diags diag.Diagnostics
+	if !errors.As(err, &diags) {
+		return []diagnostic{{Severity: severityError, Source: "alloy", Message: err.Error()}}
+	}
+
+	res := make([]diagnostic, 0, len(diags))
+	for _, d := range diags {
+		if d.StartPos.Filename != "" && d.StartPos.Filename != filename {
+			continue
+		}
+
+		severity := severityError
+		if d.Severity == diag.SeverityLevelWarn {
+			severity = severityWarning
+		}
+		res = append(res, diagnostic{
+			Range:    doc.tokenRange(d.StartPos, d.EndPos),
+			Severity: severity,
+			Source:   "alloy",
+			Message:  d.Message,
+		})
+	}
+	return res
+}
+
+// format formats doc like alloy fmt.
+func format(doc *document) ([]textEdit, *responseError) {
+	f, err := doc.parse()
+	if err != nil {
+		// Documents with syntax errors can't be formatted; their errors are
+		// reported as diagnostics.
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, f); err != nil {
+		return nil, &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	_, _ = buf.Write([]byte{'\n'})
+
+	if buf.String() == doc.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   textRange{Start: doc.position(0), End: doc.position(len(doc.text))},
+		NewText: buf.String(),
+	}}, nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nextSegment returns the segment of name following prefix, if name starts
+// with prefix. Both are period-delimited, and the last segment of prefix may
+// be partial.
+func nextSegment(name, prefix string) (string, bool) {
+	parent := ""
+	if i := strings.LastIndexByte(prefix, '.'); i >= 0 {
+		parent = prefix[:i+1]
+	}
+	if !strings.HasPrefix(name, parent) || !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+
+	rest := name[len(parent):]
+	if i := strings.IndexByte(rest, '.'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest, rest != ""
+}
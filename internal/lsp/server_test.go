@@ -0,0 +1,525 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+)
+
+type testScrapeArgs struct {
+	Targets   []map[string]string `alloy:"targets,attr"`
+	ForwardTo []any               `alloy:"forward_to,attr"`
+	Timeout   string              `alloy:"timeout,attr,optional"`
+	BasicAuth *testBasicAuth      `alloy:"basic_auth,block,optional"`
+}
+
+type testBasicAuth struct {
+	Username string `alloy:"username,attr"`
+	Password string `alloy:"password,attr"`
+}
+
+type testWriteExports struct {
+	Receiver any `alloy:"receiver,attr"`
+}
+
+const testConfig = `test.write "default" {
+}
+
+test.scrape "default" {
+	targets    = []
+	forward_to = [test.write.default.receiver]
+}
+
+declare "pipeline" {
+	argument "input" { }
+
+	test.scrape "inner" {
+		targets    = []
+		forward_to = [argument.input.value]
+	}
+}
+
+pipeline "default" {
+	input = test.write.default.receiver
+}
+`
+
+func TestServer_Diagnostics(t *testing.T) {
+	c := newTestClient(t)
+	uri := c.open(testConfig)
+	require.Empty(t, c.diagnostics[uri])
+
+	c.save(uri, testConfig+"\ntest.unknown \"default\" {\n}\n")
+	diags := c.diagnostics[uri]
+	require.Len(t, diags, 1)
+	require.Equal(t, `cannot find the definition of component name "test.unknown"`, diags[0].Message)
+	require.Equal(t, position{Line: 21, Character: 0}, diags[0].Range.Start)
+	require.Equal(t, position{Line: 21, Character: 12}, diags[0].Range.End)
+
+	c.save(uri, "test.write \"default\" {\n")
+	require.Len(t, c.diagnostics[uri], 1)
+}
+
+func TestServer_Completion(t *testing.T) {
+	tt := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			name:     "component namespaces",
+			text:     "te|",
+			expected: []string{"test"},
+		},
+		{
+			name:     "component names",
+			text:     "test.|",
+			expected: []string{"scrape", "write"},
+		},
+		{
+			name:     "block names in declare",
+			text:     "declare \"a\" {\n\tfore|\n}",
+			expected: []string{"foreach"},
+		},
+		{
+			name:     "custom components",
+			text:     "declare \"pipeline\" { }\n\npipe|",
+			expected: []string{"pipeline"},
+		},
+		{
+			name:     "attributes and blocks",
+			text:     "test.scrape \"default\" {\n\t|\n}",
+			expected: []string{"targets", "forward_to", "timeout", "basic_auth"},
+		},
+		{
+			name:     "nested block",
+			text:     "test.scrape \"default\" {\n\tbasic_auth {\n\t\tuser|\n\t}\n}",
+			expected: []string{"username"},
+		},
+		{
+			name:     "foreach template",
+			text:     "foreach \"default\" {\n\ttemp|\n}",
+			expected: []string{"template"},
+		},
+		{
+			name:     "custom component arguments",
+			text:     "declare \"pipeline\" {\n\targument \"input\" { }\n}\n\npipeline \"default\" {\n\t|\n}",
+			expected: []string{"input"},
+		},
+		{
+			name:     "references",
+			text:     "test.write \"default\" { }\n\ntest.scrape \"default\" {\n\tforward_to = [test.|]\n}",
+			expected: []string{"write", "scrape"},
+		},
+		{
+			name:     "exports",
+			text:     "test.write \"default\" { }\n\ntest.scrape \"default\" {\n\tforward_to = [test.write.default.|]\n}",
+			expected: []string{"receiver"},
+		},
+		{
+			name:     "unclosed block",
+			text:     "test.scrape \"default\" {\n\ttime|",
+			expected: []string{"timeout"},
+		},
+		{
+			name:     "invalid statement before the cursor",
+			text:     "test.write \"default\" {\n\tbroken\n}\n\ntest.scrape \"default\" {\n\tforward_to = [test.write.default.|]\n}",
+			expected: []string{"receiver"},
+		},
+		{
+			name:     "references are scoped to declare blocks",
+			text:     "test.write \"default\" { }\n\ndeclare \"a\" {\n\targument \"input\" { }\n\n\ttest.scrape \"default\" {\n\t\tforward_to = [|]\n\t}\n}",
+			expected: []string{"argument", "test"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			off := strings.Index(tc.text, "|")
+			text := tc.text[:off] + tc.text[off+1:]
+
+			c := newTestClient(t)
+			uri := c.open(text)
+
+			var list completionList
+			c.call("textDocument/completion", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: uri},
+				Position:     newDocument(uri, text).position(off),
+			}, &list)
+
+			var labels []string
+			for _, item := range list.Items {
+				labels = append(labels, item.Label)
+			}
+			require.ElementsMatch(t, tc.expected, labels)
+		})
+	}
+}
+
+func TestServer_Definition(t *testing.T) {
+	tt := []struct {
+		name   string
+		cursor string
+		target string
+	}{
+		{
+			name:   "component",
+			cursor: "write.default.receiver]",
+			target: `test.write "default"`,
+		},
+		{
+			name:   "argument",
+			cursor: "input.value",
+			target: `argument "input"`,
+		},
+		{
+			name:   "custom component",
+			cursor: `line "default"`,
+			target: `declare "pipeline"`,
+		},
+	}
+
+	c := newTestClient(t)
+	uri := c.open(testConfig)
+	doc := newDocument(uri, testConfig)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var loc location
+			c.call("textDocument/definition", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: uri},
+				Position:     doc.position(strings.Index(testConfig, tc.cursor)),
+			}, &loc)
+
+			require.Equal(t, uri, loc.URI)
+			require.Equal(t, doc.position(strings.Index(testConfig, tc.target)), loc.Range.Start)
+		})
+	}
+}
+
+func TestServer_DefinitionInImport(t *testing.T) {
+	const (
+		config = `import.file "lib" {
+	filename = file.path_join(module_path, "lib")
+}
+
+lib.pipeline "default" { }
+
+lib.missing "default" { }
+
+test.write "default" {
+	value = lib.double(2)
+}
+`
+		module = `function "double" {
+	arguments = ["x"]
+	result    = x * 2
+}
+
+declare "pipeline" { }
+`
+	)
+
+	c := newTestClient(t)
+	require.NoError(t, os.Mkdir(filepath.Join(c.dir, "lib"), 0o755))
+	modulePath := filepath.Join(c.dir, "lib", "module.alloy")
+	require.NoError(t, os.WriteFile(modulePath, []byte(module), 0o644))
+
+	uri := c.open(config)
+	doc := newDocument(uri, config)
+	moduleDoc := newDocument(pathToURI(modulePath), module)
+
+	tt := []struct {
+		name   string
+		cursor string
+		uri    string
+		target position
+	}{
+		{
+			name:   "custom component",
+			cursor: `pipeline "default"`,
+			uri:    moduleDoc.uri,
+			target: moduleDoc.position(strings.Index(module, `declare "pipeline"`)),
+		},
+		{
+			name:   "function",
+			cursor: "double(2)",
+			uri:    moduleDoc.uri,
+			target: moduleDoc.position(strings.Index(module, `function "double"`)),
+		},
+		{
+			name:   "namespace",
+			cursor: "lib.missing",
+			uri:    uri,
+			target: position{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var loc location
+			c.call("textDocument/definition", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: uri},
+				Position:     doc.position(strings.Index(config, tc.cursor)),
+			}, &loc)
+
+			require.Equal(t, tc.uri, loc.URI)
+			require.Equal(t, tc.target, loc.Range.Start)
+		})
+	}
+}
+
+func TestServer_Formatting(t *testing.T) {
+	c := newTestClient(t)
+	uri := c.open("test.write \"default\" {\n}\ntest.scrape   \"default\"  {\n  targets = []\n  forward_to = []\n}")
+
+	var edits []textEdit
+	c.call("textDocument/formatting", documentFormattingParams{TextDocument: textDocumentIdentifier{URI: uri}}, &edits)
+	require.Len(t, edits, 1)
+	require.Equal(t, position{}, edits[0].Range.Start)
+	require.Equal(t, position{Line: 5, Character: 1}, edits[0].Range.End)
+	require.Equal(t, "test.write \"default\" { }\n\ntest.scrape \"default\" {\n\ttargets    = []\n\tforward_to = []\n}\n", edits[0].NewText)
+}
+
+func TestServer_Hover(t *testing.T) {
+	const text = `test.scrape "default" {
+	targets    = []
+	forward_to = []
+
+	basic_auth {
+		username = "user"
+	}
+}
+
+declare "pipeline" { }
+
+pipeline "default" { }
+`
+
+	tt := []struct {
+		name     string
+		cursor   string
+		expected string
+	}{
+		{
+			name:     "component",
+			cursor:   "scrape \"default\"",
+			expected: "**`test.scrape`** component\n\nStability: generally-available\n\n[Documentation](https://grafana.com/docs/alloy/latest/reference/components/test/test.scrape/)",
+		},
+		{
+			name:     "config block",
+			cursor:   "declare",
+			expected: "**`declare`** block\n\n[Documentation](https://grafana.com/docs/alloy/latest/reference/config-blocks/declare/)",
+		},
+		{
+			name:     "custom component",
+			cursor:   "pipeline \"default\"",
+			expected: "**`pipeline`** custom component",
+		},
+		{
+			name:     "attribute",
+			cursor:   "forward_to",
+			expected: "**`forward_to`** `[]interface {}` attribute, required",
+		},
+		{
+			name:     "block",
+			cursor:   "basic_auth",
+			expected: "**`basic_auth`** block, optional",
+		},
+		{
+			name:     "attribute in nested block",
+			cursor:   "username",
+			expected: "**`username`** `string` attribute, required",
+		},
+		{
+			name:   "value",
+			cursor: "user\"",
+		},
+	}
+
+	c := newTestClient(t)
+	uri := c.open(text)
+	doc := newDocument(uri, text)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var res *hoverResult
+			c.call("textDocument/hover", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: uri},
+				Position:     doc.position(strings.Index(text, tc.cursor)),
+			}, &res)
+
+			if tc.expected == "" {
+				require.Nil(t, res)
+				return
+			}
+			require.NotNil(t, res)
+			require.Equal(t, markupKindMarkdown, res.Contents.Kind)
+			require.Equal(t, tc.expected, res.Contents.Value)
+		})
+	}
+}
+
+func TestServer_Exit(t *testing.T) {
+	tt := []struct {
+		name        string
+		methods     []string
+		expectedErr error
+	}{
+		{
+			name:    "after shutdown",
+			methods: []string{"initialize", "shutdown", "exit"},
+		},
+		{
+			name:        "without shutdown",
+			methods:     []string{"initialize", "exit"},
+			expectedErr: errExitWithoutShutdown,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var input bytes.Buffer
+			in := newConn(nil, &input)
+			for i, method := range tc.methods {
+				if method == "exit" {
+					require.NoError(t, in.write(notification{JSONRPC: "2.0", Method: method}))
+					continue
+				}
+				id := json.RawMessage(strconv.Itoa(i + 1))
+				require.NoError(t, in.write(request{JSONRPC: "2.0", ID: &id, Method: method, Params: json.RawMessage(`{}`)}))
+			}
+
+			err := NewServer(Options{}).Serve(context.Background(), &input, io.Discard)
+			require.Equal(t, tc.expectedErr, err)
+		})
+	}
+}
+
+func TestServer_NotInitialized(t *testing.T) {
+	c := newTestClient(t)
+	require.ErrorContains(t, c.rawCall("textDocument/completion", struct{}{}, nil), "server not initialized")
+}
+
+// testClient is a language client talking to a Server through pipes.
+type testClient struct {
+	t           *testing.T
+	conn        *conn
+	dir         string
+	nextID      int
+	diagnostics map[string][]diagnostic
+}
+
+func newTestClient(t *testing.T) *testClient {
+	srv := NewServer(Options{
+		ComponentRegistry: component.NewRegistryMap(featuregate.StabilityGenerallyAvailable, false, map[string]component.Registration{
+			"test.scrape": {Name: "test.scrape", Stability: featuregate.StabilityGenerallyAvailable, Args: testScrapeArgs{}},
+			"test.write":  {Name: "test.write", Stability: featuregate.StabilityGenerallyAvailable, Args: struct{}{}, Exports: testWriteExports{}},
+		}),
+		ComponentNames: []string{"test.scrape", "test.write"},
+		MinStability:   featuregate.StabilityGenerallyAvailable,
+	})
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(context.Background(), serverR, serverW) }()
+	t.Cleanup(func() {
+		require.NoError(t, clientW.Close())
+		require.NoError(t, <-done)
+	})
+
+	c := &testClient{
+		t:           t,
+		conn:        newConn(clientR, clientW),
+		dir:         t.TempDir(),
+		diagnostics: make(map[string][]diagnostic),
+	}
+	return c
+}
+
+func (c *testClient) initialize() {
+	var res initializeResult
+	c.call("initialize", struct{}{}, &res)
+	require.True(c.t, res.Capabilities.DefinitionProvider)
+}
+
+// open initializes the server and opens a document with text.
+func (c *testClient) open(text string) string {
+	c.initialize()
+
+	uri := pathToURI(filepath.Join(c.dir, "config.alloy"))
+	c.notify("textDocument/didOpen", didOpenTextDocumentParams{
+		TextDocument: textDocumentItem{URI: uri, Text: text},
+	})
+	c.readDiagnostics()
+	return uri
+}
+
+func (c *testClient) save(uri string, text string) {
+	c.notify("textDocument/didSave", didSaveTextDocumentParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Text:         &text,
+	})
+	c.readDiagnostics()
+}
+
+func (c *testClient) notify(method string, params any) {
+	require.NoError(c.t, c.conn.write(notification{JSONRPC: "2.0", Method: method, Params: params}))
+}
+
+func (c *testClient) readDiagnostics() {
+	body, err := c.conn.read()
+	require.NoError(c.t, err)
+
+	var msg struct {
+		Method string                   `json:"method"`
+		Params publishDiagnosticsParams `json:"params"`
+	}
+	require.NoError(c.t, json.Unmarshal(body, &msg))
+	require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+	c.diagnostics[msg.Params.URI] = msg.Params.Diagnostics
+}
+
+func (c *testClient) call(method string, params any, result any) {
+	require.NoError(c.t, c.rawCall(method, params, result))
+}
+
+func (c *testClient) rawCall(method string, params any, result any) error {
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	raw, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(request{JSONRPC: "2.0", ID: &id, Method: method, Params: raw}))
+
+	body, err := c.conn.read()
+	require.NoError(c.t, err)
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *responseError  `json:"error"`
+	}
+	require.NoError(c.t, json.Unmarshal(body, &resp))
+	if resp.Error != nil {
+		return &rpcError{resp.Error}
+	}
+	if result != nil {
+		require.NoError(c.t, json.Unmarshal(resp.Result, result))
+	}
+	return nil
+}
+
+type rpcError struct{ *responseError }
+
+func (e *rpcError) Error() string { return e.Message }
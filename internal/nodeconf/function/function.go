@@ -0,0 +1,224 @@
+package function
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/scanner"
+	"github.com/grafana/alloy/syntax/vm"
+)
+
+const (
+	// BlockName is the block name for function blocks.
+	BlockName = "function"
+	// StabilityLevel for function blocks.
+	StabilityLevel = featuregate.StabilityExperimental
+
+	attrArguments = "arguments"
+	attrResult    = "result"
+)
+
+// Build creates a function value from a function block. The returned value
+// can be exposed through a vm.Scope and called from Alloy expressions.
+//
+// The result expression of the block is evaluated every time the function is
+// called. It may only refer to the function arguments and to the standard
+// library, which keeps functions pure.
+func Build(block *ast.BlockStmt) (any, error) {
+	fns, err := BuildModule([]*ast.BlockStmt{block})
+	if err != nil {
+		return nil, err
+	}
+	return fns[block.Label], nil
+}
+
+// BuildModule creates the function values of the function blocks of a module,
+// keyed by function name. On top of the function arguments and the standard
+// library, the result expression of each function can call the other
+// functions of the module by name. A function can't call itself, directly or
+// through other functions.
+func BuildModule(blocks []*ast.BlockStmt) (map[string]any, error) {
+	exprs := make(map[string]*ast.FuncExpr, len(blocks))
+	for _, block := range blocks {
+		expr, err := funcExpr(block)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := exprs[block.Label]; ok {
+			return nil, fmt.Errorf("function %q redefined", block.Label)
+		}
+		exprs[block.Label] = expr
+	}
+	if err := checkCycles(exprs); err != nil {
+		return nil, err
+	}
+
+	// The functions are only called once they're all added to the scope.
+	var (
+		fns   = make(map[string]any, len(exprs))
+		scope = vm.NewScope(fns)
+	)
+	for name, expr := range exprs {
+		var fn any
+		if err := vm.New(expr).Evaluate(scope, &fn); err != nil {
+			return nil, fmt.Errorf("function %q: %w", name, err)
+		}
+		fns[name] = fn
+	}
+	return fns, nil
+}
+
+// funcExpr validates a function block and returns the function expression
+// it defines.
+func funcExpr(block *ast.BlockStmt) (*ast.FuncExpr, error) {
+	if block.Label == "" {
+		return nil, fmt.Errorf("function block must have a label")
+	}
+	if !scanner.IsValidIdentifier(block.Label) {
+		return nil, fmt.Errorf("function name %q is not a valid identifier", block.Label)
+	}
+
+	var argumentsAttr, resultAttr *ast.AttributeStmt
+	for _, stmt := range block.Body {
+		attr, ok := stmt.(*ast.AttributeStmt)
+		if !ok {
+			return nil, fmt.Errorf("function %q: only attributes are allowed in a function block", block.Label)
+		}
+
+		switch attr.Name.Name {
+		case attrArguments:
+			if argumentsAttr != nil {
+				return nil, fmt.Errorf("function %q: attribute %q redefined", block.Label, attrArguments)
+			}
+			argumentsAttr = attr
+		case attrResult:
+			if resultAttr != nil {
+				return nil, fmt.Errorf("function %q: attribute %q redefined", block.Label, attrResult)
+			}
+			resultAttr = attr
+		default:
+			return nil, fmt.Errorf("function %q: unrecognized attribute name %q", block.Label, attr.Name.Name)
+		}
+	}
+	if resultAttr == nil {
+		return nil, fmt.Errorf("function %q: missing required attribute %q", block.Label, attrResult)
+	}
+
+	var params []*ast.Ident
+	if argumentsAttr != nil {
+		var names []string
+		if err := vm.New(argumentsAttr.Value).Evaluate(nil, &names); err != nil {
+			return nil, fmt.Errorf("function %q: invalid arguments: %w", block.Label, err)
+		}
+
+		seen := make(map[string]struct{}, len(names))
+		for _, name := range names {
+			if !scanner.IsValidIdentifier(name) {
+				return nil, fmt.Errorf("function %q: argument name %q is not a valid identifier", block.Label, name)
+			}
+			if _, ok := seen[name]; ok {
+				return nil, fmt.Errorf("function %q: duplicate argument %q", block.Label, name)
+			}
+			seen[name] = struct{}{}
+			params = append(params, &ast.Ident{Name: name, NamePos: ast.StartPos(argumentsAttr.Value)})
+		}
+	}
+
+	return &ast.FuncExpr{
+		Params:   params,
+		ArrowPos: resultAttr.Name.NamePos,
+		Body:     resultAttr.Value,
+	}, nil
+}
+
+// checkCycles returns an error if a function of exprs calls itself, directly
+// or through other functions.
+func checkCycles(exprs map[string]*ast.FuncExpr) error {
+	var (
+		done = make(map[string]bool, len(exprs))
+		path []string
+	)
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if i := slices.Index(path, name); i >= 0 {
+			return fmt.Errorf("function %q: cycle in function calls: %s", name, strings.Join(append(path[i:], name), " -> "))
+		}
+		if done[name] {
+			return nil
+		}
+
+		path = append(path, name)
+		for _, callee := range calls(exprs[name], exprs) {
+			if err := visit(callee); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[name] = true
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(exprs)) {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calls returns the sorted names of the functions of exprs referenced by the
+// body of expr. Arguments, and the parameters of the anonymous functions
+// enclosing a reference, shadow the functions of the same name.
+func calls(expr *ast.FuncExpr, exprs map[string]*ast.FuncExpr) []string {
+	var names []string
+	ast.Walk(&callsVisitor{
+		exprs:  exprs,
+		scopes: [][]*ast.Ident{expr.Params},
+		names:  &names,
+	}, expr.Body)
+	slices.Sort(names)
+	return names
+}
+
+// callsVisitor is an ast.Visitor collecting the functions of exprs referenced
+// by the nodes it visits. scopes holds the parameters bound around the nodes.
+type callsVisitor struct {
+	exprs  map[string]*ast.FuncExpr
+	scopes [][]*ast.Ident
+	names  *[]string
+}
+
+func (v *callsVisitor) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.FuncExpr:
+		// The body of an anonymous function is evaluated in a child scope
+		// binding its parameters.
+		return &callsVisitor{
+			exprs:  v.exprs,
+			scopes: append(slices.Clip(v.scopes), node.Params),
+			names:  v.names,
+		}
+	case *ast.IdentifierExpr:
+		name := node.Ident.Name
+		if _, ok := v.exprs[name]; !ok || slices.Contains(*v.names, name) || v.bound(name) {
+			return v
+		}
+		*v.names = append(*v.names, name)
+	}
+	return v
+}
+
+// bound reports whether name is a parameter of an enclosing function.
+func (v *callsVisitor) bound(name string) bool {
+	for _, params := range v.scopes {
+		if slices.ContainsFunc(params, func(p *ast.Ident) bool { return p.Name == name }) {
+			return true
+		}
+	}
+	return false
+}
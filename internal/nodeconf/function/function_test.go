@@ -0,0 +1,235 @@
+package function_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/nodeconf/function"
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/parser"
+	"github.com/grafana/alloy/syntax/vm"
+)
+
+func TestBuild(t *testing.T) {
+	fn, err := function.Build(parseBlock(t, `
+		function "join_labels" {
+			arguments = ["a", "b"]
+			result    = string.join([a, b], "_")
+		}
+	`))
+	require.NoError(t, err)
+
+	var actual string
+	require.NoError(t, evaluate(fn, `f("job", "name")`, &actual))
+	require.Equal(t, "job_name", actual)
+
+	err = evaluate(fn, `f("job")`, &actual)
+	require.ErrorContains(t, err, "expected 2 args, got 1")
+}
+
+func TestBuild_NoArguments(t *testing.T) {
+	fn, err := function.Build(parseBlock(t, `
+		function "answer" {
+			result = 42
+		}
+	`))
+	require.NoError(t, err)
+
+	var actual int
+	require.NoError(t, evaluate(fn, `f()`, &actual))
+	require.Equal(t, 42, actual)
+}
+
+func TestBuild_Errors(t *testing.T) {
+	tt := []struct {
+		name        string
+		block       string
+		expectedErr string
+	}{
+		{
+			name:        "missing label",
+			block:       `function { result = 1 }`,
+			expectedErr: "function block must have a label",
+		},
+		{
+			name:        "missing result",
+			block:       `function "f" { arguments = ["x"] }`,
+			expectedErr: `function "f": missing required attribute "result"`,
+		},
+		{
+			name:        "unknown attribute",
+			block:       `function "f" { value = 1 }`,
+			expectedErr: `function "f": unrecognized attribute name "value"`,
+		},
+		{
+			name:        "nested block",
+			block:       `function "f" { inner {} }`,
+			expectedErr: `function "f": only attributes are allowed in a function block`,
+		},
+		{
+			name: "invalid argument name",
+			block: `
+				function "f" {
+					arguments = ["not-valid"]
+					result    = 1
+				}
+			`,
+			expectedErr: `function "f": argument name "not-valid" is not a valid identifier`,
+		},
+		{
+			name: "duplicate argument",
+			block: `
+				function "f" {
+					arguments = ["x", "x"]
+					result    = x
+				}
+			`,
+			expectedErr: `function "f": duplicate argument "x"`,
+		},
+		{
+			name: "arguments is not an array",
+			block: `
+				function "f" {
+					arguments = "x"
+					result    = 1
+				}
+			`,
+			expectedErr: `function "f": invalid arguments`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := function.Build(parseBlock(t, tc.block))
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestBuildModule(t *testing.T) {
+	fns, err := function.BuildModule(parseBlocks(t, `
+		function "add" {
+			arguments = ["x", "y"]
+			result    = x + y
+		}
+
+		function "add_twice" {
+			arguments = ["x", "y"]
+			result    = add(add(x, y), y)
+		}
+
+		function "shadowed" {
+			arguments = ["add"]
+			result    = add * 2
+		}
+	`))
+	require.NoError(t, err)
+
+	var actual int
+	require.NoError(t, evaluate(fns["add_twice"], `f(1, 2)`, &actual))
+	require.Equal(t, 5, actual)
+
+	require.NoError(t, evaluate(fns["shadowed"], `f(3)`, &actual))
+	require.Equal(t, 6, actual)
+}
+
+func TestBuildModule_LambdaShadowing(t *testing.T) {
+	// The lambda parameter b of a shadows the function b, so a doesn't call b
+	// and there's no cycle.
+	fns, err := function.BuildModule(parseBlocks(t, `
+		function "a" {
+			arguments = ["xs"]
+			result    = array.map(xs, (b) => b + 1)
+		}
+
+		function "b" {
+			arguments = ["xs"]
+			result    = a(xs)
+		}
+	`))
+	require.NoError(t, err)
+
+	var actual []int
+	require.NoError(t, evaluate(fns["b"], `f([1, 2])`, &actual))
+	require.Equal(t, []int{2, 3}, actual)
+}
+
+func TestBuildModule_Errors(t *testing.T) {
+	tt := []struct {
+		name        string
+		blocks      string
+		expectedErr string
+	}{
+		{
+			name:        "recursion",
+			blocks:      `function "f" { result = f() }`,
+			expectedErr: `function "f": cycle in function calls: f -> f`,
+		},
+		{
+			name: "cycle",
+			blocks: `
+				function "a" { result = b() }
+				function "b" { result = c() + 1 }
+				function "c" { result = a() }
+			`,
+			expectedErr: `function "a": cycle in function calls: a -> b -> c -> a`,
+		},
+		{
+			name: "cycle through a lambda",
+			blocks: `
+				function "a" { result = array.map([1], (x) => b(x)) }
+				function "b" {
+					arguments = ["x"]
+					result    = a()
+				}
+			`,
+			expectedErr: `function "a": cycle in function calls: a -> b -> a`,
+		},
+		{
+			name: "redefined",
+			blocks: `
+				function "f" { result = 1 }
+				function "f" { result = 2 }
+			`,
+			expectedErr: `function "f" redefined`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := function.BuildModule(parseBlocks(t, tc.blocks))
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func parseBlocks(t *testing.T, src string) []*ast.BlockStmt {
+	t.Helper()
+
+	file, err := parser.ParseFile("test", []byte(src))
+	require.NoError(t, err)
+
+	var blocks []*ast.BlockStmt
+	for _, stmt := range file.Body {
+		blocks = append(blocks, stmt.(*ast.BlockStmt))
+	}
+	return blocks
+}
+
+func parseBlock(t *testing.T, src string) *ast.BlockStmt {
+	t.Helper()
+
+	file, err := parser.ParseFile("test", []byte(src))
+	require.NoError(t, err)
+	require.Len(t, file.Body, 1)
+	return file.Body[0].(*ast.BlockStmt)
+}
+
+func evaluate(fn any, src string, v any) error {
+	expr, err := parser.ParseExpression(src)
+	if err != nil {
+		return err
+	}
+	return vm.New(expr).Evaluate(vm.NewScope(map[string]any{"f": fn}), v)
+}
@@ -0,0 +1,184 @@
+package pipelinetest
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/component/discovery"
+)
+
+// compareOutputs compares the data captured by the stubs of outputs with the
+// expected data, returning the outputs which do not match.
+func compareOutputs(outputs []output) []Diff {
+	var diffs []Diff
+	for _, out := range outputs {
+		var expected, actual []record
+
+		out.stub.mut.Lock()
+		switch {
+		case out.logs != nil:
+			for _, e := range out.logs.Entries {
+				expected = append(expected, logRecord(e.Labels, e.StructuredMetadata, e.Line, e.Timestamp))
+			}
+			for _, e := range out.stub.capturedLogs {
+				actual = append(actual, capturedLogRecord(e))
+			}
+		case out.metrics != nil:
+			for _, s := range out.metrics.Samples {
+				expected = append(expected, sampleRecord(sampleLabels(s), s.Value, s.Timestamp))
+			}
+			for _, s := range out.stub.capturedSamples {
+				actual = append(actual, sampleRecord(s.Labels, s.Value, time.UnixMilli(s.Timestamp)))
+			}
+		case out.targets != nil:
+			for _, t := range out.targets.Targets {
+				expected = append(expected, record{text: discovery.NewTargetFromMap(t).String()})
+			}
+			for _, t := range out.stub.capturedTargets {
+				actual = append(actual, record{text: t.String()})
+			}
+		case out.traces != nil:
+			for _, s := range out.traces.Spans {
+				expected = append(expected, spanRecord(s))
+			}
+			for _, s := range out.stub.capturedSpans {
+				actual = append(actual, spanRecord(s))
+			}
+		}
+		out.stub.mut.Unlock()
+
+		missing, unexpected := compareRecords(expected, actual)
+		if len(missing) > 0 || len(unexpected) > 0 {
+			diffs = append(diffs, Diff{
+				Block:      out.block,
+				Component:  out.stub.id,
+				Missing:    missing,
+				Unexpected: unexpected,
+			})
+		}
+	}
+	return diffs
+}
+
+// record is the text representation of a log entry, sample, target or span.
+// Timestamps are kept apart so that they are only compared when expected.
+type record struct {
+	text      string
+	timestamp string
+}
+
+func (r record) String() string {
+	if r.timestamp == "" {
+		return r.text
+	}
+	return r.timestamp + " " + r.text
+}
+
+// compareRecords matches the expected records against the actual records,
+// regardless of their order. It returns the expected records which were not
+// found and the actual records which were not expected.
+func compareRecords(expected, actual []record) (missing, unexpected []string) {
+	var (
+		matched    = make([]bool, len(actual))
+		timestamps bool
+	)
+	for _, e := range expected {
+		if e.timestamp != "" {
+			timestamps = true
+		}
+
+		found := false
+		for i, a := range actual {
+			if matched[i] || a.text != e.text || (e.timestamp != "" && a.timestamp != e.timestamp) {
+				continue
+			}
+			matched[i], found = true, true
+			break
+		}
+		if !found {
+			missing = append(missing, e.String())
+		}
+	}
+
+	for i, a := range actual {
+		if matched[i] {
+			continue
+		}
+		if !timestamps {
+			a.timestamp = ""
+		}
+		unexpected = append(unexpected, a.String())
+	}
+	return missing, unexpected
+}
+
+func logRecord(lbls, structuredMetadata map[string]string, line string, ts time.Time) record {
+	var sb strings.Builder
+	sb.WriteString(mapString(lbls))
+	sb.WriteString(" ")
+	sb.WriteString(strconv.Quote(line))
+	if len(structuredMetadata) > 0 {
+		sb.WriteString(" structured_metadata=")
+		sb.WriteString(mapString(structuredMetadata))
+	}
+	return record{text: sb.String(), timestamp: formatTimestamp(ts)}
+}
+
+func capturedLogRecord(e loki.Entry) record {
+	lbls := make(map[string]string, len(e.Labels))
+	for name, value := range e.Labels {
+		lbls[string(name)] = string(value)
+	}
+	structuredMetadata := make(map[string]string, len(e.StructuredMetadata))
+	for _, l := range e.StructuredMetadata {
+		structuredMetadata[l.Name] = l.Value
+	}
+	return logRecord(lbls, structuredMetadata, e.Line, e.Timestamp)
+}
+
+func sampleRecord(lbls labels.Labels, value float64, ts time.Time) record {
+	var (
+		name = lbls.Get(model.MetricNameLabel)
+		rest = labels.NewBuilder(lbls).Del(model.MetricNameLabel).Labels()
+	)
+	return record{
+		text:      fmt.Sprintf("%s%s %s", name, rest.String(), strconv.FormatFloat(value, 'g', -1, 64)),
+		timestamp: formatTimestamp(ts),
+	}
+}
+
+func spanRecord(s Span) record {
+	return record{text: fmt.Sprintf("%q %s", s.Name, mapString(s.Attributes))}
+}
+
+// mapString formats m like a label set.
+func mapString(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, m[k]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,97 @@
+// Package pipelinetest runs unit tests against Alloy pipelines. A test
+// replaces components of a configuration with fixtures: input components
+// inject log entries, metric samples, targets or spans into the components
+// they forward to, and output components capture what reaches them so that it
+// can be compared against the expected data.
+package pipelinetest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/alloy/syntax"
+)
+
+// File is a test file holding a list of tests.
+type File struct {
+	Tests []Test `alloy:"test,block,optional"`
+}
+
+// Test is a single test run against a configuration.
+type Test struct {
+	Name string `alloy:"name,attr"`
+
+	LogsInputs     []LogsFixture    `alloy:"logs_input,block,optional"`
+	LogsOutputs    []LogsFixture    `alloy:"logs_output,block,optional"`
+	MetricsInputs  []MetricsFixture `alloy:"metrics_input,block,optional"`
+	MetricsOutputs []MetricsFixture `alloy:"metrics_output,block,optional"`
+	TargetsInputs  []TargetsFixture `alloy:"targets_input,block,optional"`
+	TargetsOutputs []TargetsFixture `alloy:"targets_output,block,optional"`
+	TracesInputs   []TracesFixture  `alloy:"traces_input,block,optional"`
+	TracesOutputs  []TracesFixture  `alloy:"traces_output,block,optional"`
+}
+
+// LogsFixture holds the log entries sent by or expected at a component.
+type LogsFixture struct {
+	Component string     `alloy:"component,attr"`
+	Entries   []LogEntry `alloy:"entry,block,optional"`
+}
+
+// LogEntry is a single log entry. Timestamps are only compared for expected
+// entries which set one.
+type LogEntry struct {
+	Line               string            `alloy:"line,attr"`
+	Labels             map[string]string `alloy:"labels,attr,optional"`
+	StructuredMetadata map[string]string `alloy:"structured_metadata,attr,optional"`
+	Timestamp          time.Time         `alloy:"timestamp,attr,optional"`
+}
+
+// MetricsFixture holds the samples sent by or expected at a component.
+type MetricsFixture struct {
+	Component string         `alloy:"component,attr"`
+	Samples   []MetricSample `alloy:"sample,block,optional"`
+}
+
+// MetricSample is a single sample of a series. Timestamps are only compared
+// for expected samples which set one.
+type MetricSample struct {
+	Name      string            `alloy:"name,attr"`
+	Labels    map[string]string `alloy:"labels,attr,optional"`
+	Value     float64           `alloy:"value,attr"`
+	Timestamp time.Time         `alloy:"timestamp,attr,optional"`
+}
+
+// TargetsFixture holds the targets exported by or expected at a component.
+type TargetsFixture struct {
+	Component string              `alloy:"component,attr"`
+	Targets   []map[string]string `alloy:"targets,attr,optional"`
+}
+
+// TracesFixture holds the spans sent by or expected at a component.
+type TracesFixture struct {
+	Component string `alloy:"component,attr"`
+	Spans     []Span `alloy:"span,block,optional"`
+}
+
+// Span is a single span.
+type Span struct {
+	Name       string            `alloy:"name,attr"`
+	Attributes map[string]string `alloy:"attributes,attr,optional"`
+}
+
+// ParseFile parses a test file.
+func ParseFile(bb []byte) (*File, error) {
+	var f File
+	if err := syntax.Unmarshal(bb, &f); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(f.Tests))
+	for _, t := range f.Tests {
+		if _, ok := seen[t.Name]; ok {
+			return nil, fmt.Errorf("test %q is defined more than once", t.Name)
+		}
+		seen[t.Name] = struct{}{}
+	}
+	return &f, nil
+}
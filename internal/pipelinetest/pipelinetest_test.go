@@ -0,0 +1,357 @@
+package pipelinetest_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/pipelinetest"
+
+	_ "github.com/grafana/alloy/internal/component/discovery/file"
+	_ "github.com/grafana/alloy/internal/component/discovery/relabel"
+	_ "github.com/grafana/alloy/internal/component/loki/process"
+	_ "github.com/grafana/alloy/internal/component/loki/source/file"
+	_ "github.com/grafana/alloy/internal/component/loki/write"
+	_ "github.com/grafana/alloy/internal/component/otelcol/exporter/otlp"
+	_ "github.com/grafana/alloy/internal/component/otelcol/processor/attributes"
+	_ "github.com/grafana/alloy/internal/component/otelcol/receiver/otlp"
+	_ "github.com/grafana/alloy/internal/component/prometheus/relabel"
+	_ "github.com/grafana/alloy/internal/component/prometheus/remotewrite"
+	_ "github.com/grafana/alloy/internal/component/prometheus/scrape"
+)
+
+const testConfig = `
+loki.source.file "app" {
+	targets    = []
+	forward_to = [loki.process.app.receiver]
+}
+
+loki.process "app" {
+	stage.logfmt {
+		mapping = { level = "" }
+	}
+
+	stage.drop {
+		source = "level"
+		value  = "debug"
+	}
+
+	stage.labels {
+		values = { level = "" }
+	}
+
+	forward_to = [loki.write.default.receiver]
+}
+
+loki.write "default" {
+	endpoint {
+		url = "http://localhost:3100/loki/api/v1/push"
+	}
+}
+
+prometheus.scrape "app" {
+	targets    = []
+	forward_to = [prometheus.relabel.app.receiver]
+}
+
+prometheus.relabel "app" {
+	rule {
+		source_labels = ["__name__"]
+		regex         = "go_.*"
+		action        = "drop"
+	}
+
+	rule {
+		target_label = "env"
+		replacement  = "test"
+	}
+
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+
+prometheus.remote_write "default" {
+	endpoint {
+		url = "http://localhost:9009/api/v1/push"
+	}
+}
+
+discovery.file "app" {
+	files = ["/does/not/exist.json"]
+}
+
+discovery.relabel "app" {
+	targets = discovery.file.app.targets
+
+	rule {
+		source_labels = ["__meta_port"]
+		target_label  = "__address__"
+		replacement   = "localhost:$1"
+	}
+}
+
+prometheus.scrape "targets" {
+	targets    = discovery.relabel.app.output
+	forward_to = []
+}
+
+otelcol.receiver.otlp "default" {
+	grpc { }
+
+	output {
+		traces = [otelcol.processor.attributes.default.input]
+	}
+}
+
+otelcol.processor.attributes "default" {
+	action {
+		key    = "env"
+		value  = "test"
+		action = "insert"
+	}
+
+	output {
+		traces = [otelcol.exporter.otlp.default.input]
+	}
+}
+
+otelcol.exporter.otlp "default" {
+	client {
+		endpoint = "localhost:4317"
+	}
+}
+`
+
+func TestRunner(t *testing.T) {
+	file, err := pipelinetest.ParseFile([]byte(`
+test {
+	name = "logs"
+
+	logs_input {
+		component = "loki.source.file.app"
+
+		entry {
+			line   = "level=info msg=hello"
+			labels = { job = "app" }
+		}
+		entry {
+			line   = "level=debug msg=noise"
+			labels = { job = "app" }
+		}
+	}
+
+	logs_output {
+		component = "loki.write.default"
+
+		entry {
+			line   = "level=info msg=hello"
+			labels = { job = "app", level = "info" }
+		}
+	}
+}
+
+test {
+	name = "metrics"
+
+	metrics_input {
+		component = "prometheus.scrape.app"
+
+		sample {
+			name   = "up"
+			labels = { job = "app" }
+			value  = 1
+		}
+		sample {
+			name  = "go_goroutines"
+			value = 10
+		}
+	}
+
+	metrics_output {
+		component = "prometheus.remote_write.default"
+
+		sample {
+			name   = "up"
+			labels = { job = "app", env = "test" }
+			value  = 1
+		}
+	}
+}
+
+test {
+	name = "targets"
+
+	targets_input {
+		component = "discovery.file.app"
+
+		targets = [{ __meta_port = "8080" }]
+	}
+
+	targets_output {
+		component = "prometheus.scrape.targets"
+
+		targets = [{ __address__ = "localhost:8080", __meta_port = "8080" }]
+	}
+}
+
+test {
+	name = "traces"
+
+	traces_input {
+		component = "otelcol.receiver.otlp.default"
+
+		span {
+			name       = "GET /"
+			attributes = { "http.method" = "GET" }
+		}
+	}
+
+	traces_output {
+		component = "otelcol.exporter.otlp.default"
+
+		span {
+			name       = "GET /"
+			attributes = { "http.method" = "GET", env = "test" }
+		}
+	}
+}
+`))
+	require.NoError(t, err)
+
+	runner := pipelinetest.NewRunner(pipelinetest.Options{MinStability: featuregate.StabilityGenerallyAvailable})
+	for _, test := range file.Tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res := runner.Run(context.Background(), map[string][]byte{"config.alloy": []byte(testConfig)}, "config.alloy", test)
+			require.NoError(t, res.Err)
+			require.Empty(t, res.Diffs)
+		})
+	}
+}
+
+func TestRunner_Failure(t *testing.T) {
+	file, err := pipelinetest.ParseFile([]byte(`
+test {
+	name = "dropped logs"
+
+	logs_input {
+		component = "loki.source.file.app"
+
+		entry {
+			line   = "level=debug msg=noise"
+			labels = { job = "app" }
+		}
+		entry {
+			line      = "level=warn msg=slow"
+			labels    = { job = "app" }
+			timestamp = "2024-01-01T00:00:00Z"
+		}
+	}
+
+	logs_output {
+		component = "loki.write.default"
+
+		entry {
+			line   = "level=debug msg=noise"
+			labels = { job = "app", level = "debug" }
+		}
+	}
+}
+`))
+	require.NoError(t, err)
+
+	runner := pipelinetest.NewRunner(pipelinetest.Options{
+		MinStability: featuregate.StabilityGenerallyAvailable,
+		Timeout:      time.Second,
+	})
+	res := runner.Run(context.Background(), map[string][]byte{"config.alloy": []byte(testConfig)}, "config.alloy", file.Tests[0])
+	require.NoError(t, res.Err)
+	require.Equal(t, []pipelinetest.Diff{{
+		Block:      "logs_output",
+		Component:  "loki.write.default",
+		Missing:    []string{`{job="app", level="debug"} "level=debug msg=noise"`},
+		Unexpected: []string{`{job="app", level="warn"} "level=warn msg=slow"`},
+	}}, res.Diffs)
+
+	var out bytes.Buffer
+	pipelinetest.Report(&out, []pipelinetest.Result{res})
+	require.Contains(t, out.String(), "--- FAIL: dropped logs")
+	require.Contains(t, out.String(), `        - {job="app", level="debug"} "level=debug msg=noise"`)
+	require.Contains(t, out.String(), `        + {job="app", level="warn"} "level=warn msg=slow"`)
+}
+
+func TestRunner_Errors(t *testing.T) {
+	tt := []struct {
+		name     string
+		test     string
+		expected string
+	}{
+		{
+			name: "unknown component",
+			test: `test {
+	name = "a"
+
+	logs_output {
+		component = "loki.write.missing"
+	}
+}`,
+			expected: `component "loki.write.missing" is not defined in the configuration`,
+		},
+		{
+			name: "duplicate output",
+			test: `test {
+	name = "a"
+
+	logs_output {
+		component = "loki.write.default"
+	}
+
+	logs_output {
+		component = "loki.write.default"
+	}
+}`,
+			expected: `logs_output block for component "loki.write.default" is defined more than once`,
+		},
+		{
+			name: "nothing to forward to",
+			test: `test {
+	name = "a"
+
+	logs_input {
+		component = "loki.write.default"
+
+		entry {
+			line = "hello"
+		}
+	}
+}`,
+			expected: `component "loki.write.default" does not forward logs to any receivers`,
+		},
+	}
+
+	runner := pipelinetest.NewRunner(pipelinetest.Options{MinStability: featuregate.StabilityGenerallyAvailable})
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := pipelinetest.ParseFile([]byte(tc.test))
+			require.NoError(t, err)
+
+			res := runner.Run(context.Background(), map[string][]byte{"config.alloy": []byte(testConfig)}, "config.alloy", file.Tests[0])
+			require.EqualError(t, res.Err, tc.expected)
+		})
+	}
+}
+
+func TestParseFile_DuplicateTest(t *testing.T) {
+	_, err := pipelinetest.ParseFile([]byte(`
+test {
+	name = "a"
+}
+
+test {
+	name = "a"
+}
+`))
+	require.EqualError(t, err, `test "a" is defined more than once`)
+}
@@ -0,0 +1,324 @@
+package pipelinetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/discovery"
+	"github.com/grafana/alloy/internal/featuregate"
+	alloy_runtime "github.com/grafana/alloy/internal/runtime"
+	"github.com/grafana/alloy/internal/runtime/logging"
+	"github.com/grafana/alloy/internal/service"
+	"github.com/grafana/alloy/internal/service/labelstore"
+	"github.com/grafana/alloy/internal/service/livedebugging"
+)
+
+// Options configures a Runner.
+type Options struct {
+	// ComponentRegistry is used to look up the components of the
+	// configuration. Components under test are replaced with stubs.
+	ComponentRegistry component.Registry
+
+	// MinStability is the minimum stability level of features that can be
+	// used by the configuration.
+	MinStability featuregate.Stability
+
+	// Logger to use for the components of the configuration. A no-op logger
+	// is used if Logger is nil.
+	Logger *logging.Logger
+
+	// Timeout is the maximum duration to wait for the expected outputs of a
+	// test.
+	Timeout time.Duration
+
+	// Settle is how long the outputs of a test must keep matching the
+	// expected outputs for the test to pass. It catches data which arrives
+	// after the expected data, and tests which expect no data at all.
+	Settle time.Duration
+}
+
+// DefaultOptions holds the default timings of a Runner.
+var DefaultOptions = Options{
+	Timeout: 5 * time.Second,
+	Settle:  250 * time.Millisecond,
+}
+
+// Runner runs tests against a configuration.
+type Runner struct {
+	opts Options
+}
+
+// NewRunner creates a new Runner. Timings which are not set in o are taken
+// from DefaultOptions.
+func NewRunner(o Options) *Runner {
+	if o.Logger == nil {
+		o.Logger = logging.NewNop()
+	}
+	if o.Timeout == 0 {
+		o.Timeout = DefaultOptions.Timeout
+	}
+	if o.Settle == 0 {
+		o.Settle = DefaultOptions.Settle
+	}
+	return &Runner{opts: o}
+}
+
+// Result is the result of a test.
+type Result struct {
+	Name     string
+	Duration time.Duration
+
+	// Err is set if the test could not be run.
+	Err error
+
+	// Diffs holds the outputs which did not match the expected data.
+	Diffs []Diff
+}
+
+// Failed reports whether the test failed.
+func (r Result) Failed() bool {
+	return r.Err != nil || len(r.Diffs) > 0
+}
+
+// Diff describes the differences between the data expected at an output and
+// the data which reached it.
+type Diff struct {
+	Block     string // Name of the output block.
+	Component string // Component the output block refers to.
+
+	Missing    []string // Expected data which did not reach the output.
+	Unexpected []string // Data which reached the output but was not expected.
+}
+
+// Run runs test against the configuration in sources, a map of file names
+// to file contents.
+func (r *Runner) Run(ctx context.Context, sources map[string][]byte, configPath string, test Test) Result {
+	start := time.Now()
+	diffs, err := r.run(ctx, sources, configPath, test)
+	return Result{
+		Name:     test.Name,
+		Duration: time.Since(start),
+		Err:      err,
+		Diffs:    diffs,
+	}
+}
+
+func (r *Runner) run(ctx context.Context, sources map[string][]byte, configPath string, test Test) ([]Diff, error) {
+	stubs, outputs, err := newStubs(test)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := alloy_runtime.ParseSources(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	dataPath, err := os.MkdirTemp("", "alloy-test-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dataPath)
+
+	var (
+		reg    = prometheus.NewRegistry()
+		parent = r.opts.ComponentRegistry
+	)
+	if parent == nil {
+		parent = component.NewDefaultRegistry(r.opts.MinStability, false)
+	}
+	f := alloy_runtime.New(alloy_runtime.Options{
+		Logger:       r.opts.Logger,
+		DataPath:     dataPath,
+		Reg:          reg,
+		MinStability: r.opts.MinStability,
+		Services: append([]service.Service{
+			labelstore.New(r.opts.Logger, reg),
+			livedebugging.New(),
+		}, stubServices()...),
+		ComponentRegistry: &registry{parent: parent, stubs: stubs},
+	})
+	if err := f.LoadSource(source, nil, configPath); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for _, id := range sortedKeys(stubs) {
+		if !stubs[id].isBuilt() {
+			return nil, fmt.Errorf("component %q is not defined in the configuration", id)
+		}
+	}
+	for _, id := range sortedKeys(stubs) {
+		if err := stubs[id].send(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wait for the outputs to match the expected data, and keep matching it
+	// for the settle period.
+	var (
+		ticker       = time.NewTicker(25 * time.Millisecond)
+		matchedSince time.Time
+	)
+	defer ticker.Stop()
+	for {
+		diffs := compareOutputs(outputs)
+		switch {
+		case len(diffs) > 0:
+			matchedSince = time.Time{}
+		case matchedSince.IsZero():
+			matchedSince = time.Now()
+		case time.Since(matchedSince) >= r.opts.Settle:
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if len(diffs) == 0 {
+				return nil, nil
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return diffs, nil
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// output is an output block of a test.
+type output struct {
+	block string
+	stub  *stub
+
+	logs    *LogsFixture
+	metrics *MetricsFixture
+	targets *TargetsFixture
+	traces  *TracesFixture
+}
+
+// newStubs returns the stubs for the components referred to by the input
+// and output blocks of test.
+func newStubs(test Test) (map[string]*stub, []output, error) {
+	var (
+		stubs   = make(map[string]*stub)
+		outputs []output
+		seen    = make(map[string]struct{})
+	)
+
+	get := func(block, id string) (*stub, error) {
+		key := block + " " + id
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s block for component %q is defined more than once", block, id)
+		}
+		seen[key] = struct{}{}
+
+		if _, ok := stubs[id]; !ok {
+			stubs[id] = newStub(id)
+		}
+		return stubs[id], nil
+	}
+
+	for _, in := range test.LogsInputs {
+		s, err := get("logs_input", in.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.logs = append(s.logs, in.Entries...)
+	}
+	for _, in := range test.MetricsInputs {
+		s, err := get("metrics_input", in.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.samples = append(s.samples, in.Samples...)
+	}
+	for _, in := range test.TargetsInputs {
+		s, err := get("targets_input", in.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, t := range in.Targets {
+			s.targets = append(s.targets, discovery.NewTargetFromMap(t))
+		}
+	}
+	for _, in := range test.TracesInputs {
+		s, err := get("traces_input", in.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.spans = append(s.spans, in.Spans...)
+	}
+
+	for i, out := range test.LogsOutputs {
+		s, err := get("logs_output", out.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, output{block: "logs_output", stub: s, logs: &test.LogsOutputs[i]})
+	}
+	for i, out := range test.MetricsOutputs {
+		s, err := get("metrics_output", out.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, output{block: "metrics_output", stub: s, metrics: &test.MetricsOutputs[i]})
+	}
+	for i, out := range test.TargetsOutputs {
+		s, err := get("targets_output", out.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, output{block: "targets_output", stub: s, targets: &test.TargetsOutputs[i]})
+	}
+	for i, out := range test.TracesOutputs {
+		s, err := get("traces_output", out.Component)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, output{block: "traces_output", stub: s, traces: &test.TracesOutputs[i]})
+	}
+
+	return stubs, outputs, nil
+}
+
+// Report writes a summary of results to w in the style of go test.
+func Report(w io.Writer, results []Result) {
+	for _, res := range results {
+		if !res.Failed() {
+			fmt.Fprintf(w, "--- PASS: %s (%.2fs)\n", res.Name, res.Duration.Seconds())
+			continue
+		}
+
+		fmt.Fprintf(w, "--- FAIL: %s (%.2fs)\n", res.Name, res.Duration.Seconds())
+		if res.Err != nil {
+			fmt.Fprintf(w, "    %s\n", res.Err)
+		}
+		for _, d := range res.Diffs {
+			fmt.Fprintf(w, "    %s %q:\n", d.Block, d.Component)
+			for _, m := range d.Missing {
+				fmt.Fprintf(w, "        - %s\n", m)
+			}
+			for _, u := range d.Unexpected {
+				fmt.Fprintf(w, "        + %s\n", u)
+			}
+		}
+	}
+}
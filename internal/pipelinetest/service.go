@@ -0,0 +1,55 @@
+package pipelinetest
+
+import (
+	"context"
+	"net"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/service"
+	"github.com/grafana/alloy/internal/service/cluster"
+	http_service "github.com/grafana/alloy/internal/service/http"
+)
+
+// stubService provides the data of a service without running it. Tests do
+// not serve HTTP or join a cluster, but components such as prometheus.scrape
+// require the data of these services to be built.
+type stubService struct {
+	name string
+	data any
+}
+
+var _ service.Service = (*stubService)(nil)
+
+func stubServices() []service.Service {
+	return []service.Service{
+		&stubService{
+			name: http_service.ServiceName,
+			data: http_service.Data{
+				HTTPListenAddr:   "127.0.0.1:12345",
+				MemoryListenAddr: "alloy.internal:12345",
+				BaseHTTPPath:     "/",
+				DialFunc:         (&net.Dialer{}).DialContext,
+			},
+		},
+		&stubService{
+			name: cluster.ServiceName,
+			data: cluster.Mock(),
+		},
+	}
+}
+
+func (s *stubService) Definition() service.Definition {
+	return service.Definition{
+		Name:      s.name,
+		Stability: featuregate.StabilityGenerallyAvailable,
+	}
+}
+
+func (s *stubService) Run(ctx context.Context, _ service.Host) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s *stubService) Update(any) error { return nil }
+
+func (s *stubService) Data() any { return s.data }
@@ -0,0 +1,391 @@
+package pipelinetest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/component/common/loki"
+	"github.com/grafana/alloy/internal/component/discovery"
+	lokiecho "github.com/grafana/alloy/internal/component/loki/echo"
+	"github.com/grafana/alloy/internal/component/otelcol"
+	"github.com/grafana/alloy/internal/component/prometheus"
+	promecho "github.com/grafana/alloy/internal/component/prometheus/echo"
+	"github.com/grafana/alloy/internal/service/labelstore"
+)
+
+// registry wraps a component.Registry, replacing the components under test
+// with stubs.
+type registry struct {
+	parent component.Registry
+	stubs  map[string]*stub
+}
+
+var _ component.Registry = (*registry)(nil)
+
+// Get implements component.Registry. The returned registration keeps the
+// arguments and exports of the real component so that the configuration is
+// decoded as usual, but builds a stub for the components under test.
+func (r *registry) Get(name string) (component.Registration, error) {
+	reg, err := r.parent.Get(name)
+	if err != nil {
+		return reg, err
+	}
+
+	build := reg.Build
+	reg.Build = func(opts component.Options, args component.Arguments) (component.Component, error) {
+		s, ok := r.stubs[opts.ID]
+		if !ok {
+			return build(opts, args)
+		}
+		return s.build(opts, args, reg.Exports)
+	}
+	return reg, nil
+}
+
+// stub replaces a component with fixtures. It sends input fixtures to the
+// receivers in the arguments of the component, and exports the receivers of
+// a loki.echo and a prometheus.echo component, which capture the logs and
+// samples sent to the component.
+//
+// Stubs aren't run with componenttest: its controller builds a component on
+// its own, outside of a config, while a stub is built by the runtime in
+// place of a component of the config under test so that the references of
+// the other blocks resolve to it.
+type stub struct {
+	id string
+
+	logs    []LogEntry
+	samples []MetricSample
+	targets []discovery.Target
+	spans   []Span
+
+	fanout       *prometheus.Fanout
+	logsEcho     *lokiecho.Component
+	logsReceiver loki.LogsReceiver
+	metricsEcho  *promecho.Component
+
+	mut             sync.Mutex
+	built           bool
+	args            component.Arguments
+	capturedLogs    []loki.Entry
+	capturedSamples []promecho.Sample
+	capturedTargets []discovery.Target
+	capturedSpans   []Span
+}
+
+func newStub(id string) *stub {
+	return &stub{id: id}
+}
+
+func (s *stub) build(opts component.Options, args component.Arguments, exports component.Exports) (component.Component, error) {
+	data, err := opts.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	echoOpts := opts
+	echoOpts.OnStateChange = func(e component.Exports) {
+		if e, ok := e.(lokiecho.Exports); ok {
+			s.logsReceiver = e.Receiver
+		}
+	}
+	logsEcho, err := lokiecho.NewWithCapture(echoOpts, lokiecho.DefaultArguments, s.captureLog)
+	if err != nil {
+		return nil, err
+	}
+	echoOpts.OnStateChange = nil
+	metricsEcho, err := promecho.NewWithCapture(echoOpts, promecho.DefaultArguments, s.captureSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mut.Lock()
+	s.built = true
+	s.fanout = prometheus.NewFanout(nil, opts.ID, opts.Registerer, data.(labelstore.LabelStore))
+	s.logsEcho = logsEcho
+	s.metricsEcho = metricsEcho
+	s.mut.Unlock()
+
+	c := &stubComponent{stub: s}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+
+	if exports != nil {
+		e := reflect.New(reflect.TypeOf(exports)).Elem()
+		s.setExports(e)
+		opts.OnStateChange(e.Interface())
+	}
+	return c, nil
+}
+
+var (
+	logsReceiverType = reflect.TypeOf((*loki.LogsReceiver)(nil)).Elem()
+	appendableType   = reflect.TypeOf((*storage.Appendable)(nil)).Elem()
+	consumerType     = reflect.TypeOf((*otelcol.Consumer)(nil)).Elem()
+	targetsType      = reflect.TypeOf([]discovery.Target(nil))
+)
+
+// setExports sets the receivers and targets in the exports value v.
+func (s *stub) setExports(v reflect.Value) {
+	switch {
+	case v.Type() == logsReceiverType:
+		v.Set(reflect.ValueOf(s.logsReceiver))
+	case v.Type() == appendableType:
+		v.Set(reflect.ValueOf(storage.Appendable(s.metricsEcho)))
+	case v.Type() == consumerType:
+		v.Set(reflect.ValueOf(otelcol.Consumer(&stubConsumer{stub: s})))
+	case v.Type() == targetsType:
+		v.Set(reflect.ValueOf(s.targets))
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				s.setExports(v.Field(i))
+			}
+		}
+	}
+}
+
+func (s *stub) captureLog(entry loki.Entry) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.capturedLogs = append(s.capturedLogs, entry)
+}
+
+func (s *stub) captureSamples(samples []promecho.Sample) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.capturedSamples = append(s.capturedSamples, samples...)
+}
+
+func (s *stub) isBuilt() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.built
+}
+
+// send sends the input fixtures of the stub to the receivers in its
+// arguments.
+func (s *stub) send(ctx context.Context) error {
+	s.mut.Lock()
+	args := s.args
+	s.mut.Unlock()
+
+	if len(s.logs) > 0 {
+		receivers := collect[loki.LogsReceiver](args)
+		if len(receivers) == 0 {
+			return fmt.Errorf("component %q does not forward logs to any receivers", s.id)
+		}
+		for _, e := range s.logs {
+			entry := newLokiEntry(e)
+			for _, r := range receivers {
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("timed out sending logs from component %q", s.id)
+				case r.Chan() <- entry.Clone():
+				}
+			}
+		}
+	}
+
+	if len(s.samples) > 0 {
+		if len(collect[storage.Appendable](args)) == 0 {
+			return fmt.Errorf("component %q does not forward metrics to any receivers", s.id)
+		}
+		app := s.fanout.Appender(ctx)
+		for _, sample := range s.samples {
+			if _, err := app.Append(0, sampleLabels(sample), timestamp(sample.Timestamp).UnixMilli(), sample.Value); err != nil {
+				_ = app.Rollback()
+				return fmt.Errorf("appending samples from component %q: %w", s.id, err)
+			}
+		}
+		if err := app.Commit(); err != nil {
+			return fmt.Errorf("appending samples from component %q: %w", s.id, err)
+		}
+	}
+
+	if len(s.spans) > 0 {
+		var consumers []otelcol.Consumer
+		for _, out := range collect[otelcol.ConsumerArguments](args) {
+			consumers = append(consumers, out.Traces...)
+		}
+		if len(consumers) == 0 {
+			return fmt.Errorf("component %q does not forward traces to any receivers", s.id)
+		}
+		for _, c := range consumers {
+			if err := c.ConsumeTraces(ctx, newTraces(s.spans)); err != nil {
+				return fmt.Errorf("sending traces from component %q: %w", s.id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// stubComponent is the component built for a stub.
+type stubComponent struct {
+	stub *stub
+}
+
+var _ component.Component = (*stubComponent)(nil)
+
+// Run implements component.Component. It runs the loki.echo component
+// capturing the log entries sent to the stub.
+func (c *stubComponent) Run(ctx context.Context) error {
+	return c.stub.logsEcho.Run(ctx)
+}
+
+// Update implements component.Component. It captures the targets set in the
+// arguments of the stub and updates the receivers of its samples.
+func (c *stubComponent) Update(args component.Arguments) error {
+	c.stub.mut.Lock()
+	defer c.stub.mut.Unlock()
+	c.stub.args = args
+	c.stub.capturedTargets = collect[discovery.Target](args)
+	c.stub.fanout.UpdateChildren(collect[storage.Appendable](args))
+	return nil
+}
+
+// stubConsumer captures the spans sent to a stub. Metrics and logs are
+// dropped.
+type stubConsumer struct {
+	stub *stub
+}
+
+var _ otelcol.Consumer = (*stubConsumer)(nil)
+
+func (c *stubConsumer) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{MutatesData: false}
+}
+
+func (c *stubConsumer) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+	var spans []Span
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		ss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			for k := 0; k < ss.At(j).Spans().Len(); k++ {
+				span := ss.At(j).Spans().At(k)
+				attrs := make(map[string]string, span.Attributes().Len())
+				span.Attributes().Range(func(name string, value pcommon.Value) bool {
+					attrs[name] = value.AsString()
+					return true
+				})
+				spans = append(spans, Span{Name: span.Name(), Attributes: attrs})
+			}
+		}
+	}
+
+	c.stub.mut.Lock()
+	defer c.stub.mut.Unlock()
+	c.stub.capturedSpans = append(c.stub.capturedSpans, spans...)
+	return nil
+}
+
+func (c *stubConsumer) ConsumeMetrics(context.Context, pmetric.Metrics) error { return nil }
+
+func (c *stubConsumer) ConsumeLogs(context.Context, plog.Logs) error { return nil }
+
+// collect returns the values of type T found in v, searching through
+// structs, pointers, slices and arrays.
+func collect[T any](v any) []T {
+	var (
+		res []T
+		typ = reflect.TypeOf((*T)(nil)).Elem()
+	)
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		if !v.IsValid() {
+			return
+		}
+		if v.Type() == typ {
+			if v.Kind() != reflect.Interface || !v.IsNil() {
+				res = append(res, v.Interface().(T))
+			}
+			return
+		}
+
+		switch v.Kind() {
+		case reflect.Pointer:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					walk(v.Field(i))
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(v))
+	return res
+}
+
+func newLokiEntry(e LogEntry) loki.Entry {
+	entry := loki.Entry{
+		Labels: make(model.LabelSet, len(e.Labels)),
+		Entry: push.Entry{
+			Timestamp: timestamp(e.Timestamp),
+			Line:      e.Line,
+		},
+	}
+	for name, value := range e.Labels {
+		entry.Labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+	for _, name := range sortedKeys(e.StructuredMetadata) {
+		entry.StructuredMetadata = append(entry.StructuredMetadata, push.LabelAdapter{Name: name, Value: e.StructuredMetadata[name]})
+	}
+	return entry
+}
+
+func sampleLabels(s MetricSample) labels.Labels {
+	b := labels.NewBuilder(labels.FromMap(s.Labels))
+	b.Set(model.MetricNameLabel, s.Name)
+	return b.Labels()
+}
+
+func newTraces(spans []Span) ptrace.Traces {
+	var (
+		td  = ptrace.NewTraces()
+		ss  = td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+		now = time.Now()
+	)
+	for i, s := range spans {
+		span := ss.AppendEmpty()
+		span.SetName(s.Name)
+		span.SetTraceID([16]byte{15: byte(i + 1)})
+		span.SetSpanID([8]byte{7: byte(i + 1)})
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(now))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(now))
+		for _, name := range sortedKeys(s.Attributes) {
+			span.Attributes().PutStr(name, s.Attributes[name])
+		}
+	}
+	return td
+}
+
+// timestamp returns t, or the current time if t is not set.
+func timestamp(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
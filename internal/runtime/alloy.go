@@ -117,6 +117,11 @@ type Options struct {
 
 	// TaskShutdownDeadline is the maximum duration to wait for a component to shut down before giving up and logging an error.
 	TaskShutdownDeadline time.Duration
+
+	// ComponentRegistry is used to look up components. The default registry
+	// of components registered to github.com/grafana/alloy/internal/component
+	// is used if ComponentRegistry is nil.
+	ComponentRegistry component.Registry
 }
 
 // Runtime is the Alloy system.
@@ -141,6 +146,7 @@ type Runtime struct {
 func New(o Options) *Runtime {
 	return newController(controllerOptions{
 		Options:              o,
+		ComponentRegistry:    o.ComponentRegistry,
 		ModuleRegistry:       newModuleRegistry(),
 		IsModule:             false, // We are creating a new root controller.
 		WorkerPool:           worker.NewDefaultWorkerPool(),
@@ -0,0 +1,464 @@
+package runtime
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/grafana/alloy/internal/dag"
+	"github.com/grafana/alloy/internal/nodeconf/foreach"
+	"github.com/grafana/alloy/internal/nodeconf/importsource"
+	"github.com/grafana/alloy/internal/runtime/internal/controller"
+	"github.com/grafana/alloy/internal/runtime/logging"
+	"github.com/grafana/alloy/internal/runtime/tracing"
+	"github.com/grafana/alloy/internal/util"
+	astutil "github.com/grafana/alloy/internal/util/ast"
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/diag"
+	"github.com/grafana/alloy/syntax/vm"
+)
+
+// GraphNodeKind is the kind of block a GraphNode was created from.
+type GraphNodeKind string
+
+// Kinds of nodes in a Graph.
+const (
+	GraphNodeComponent       GraphNodeKind = "component"
+	GraphNodeCustomComponent GraphNodeKind = "custom_component"
+	GraphNodeDeclare         GraphNodeKind = "declare"
+	GraphNodeForeach         GraphNodeKind = "foreach"
+	GraphNodeImport          GraphNodeKind = "import"
+	GraphNodeArgument        GraphNodeKind = "argument"
+	GraphNodeExport          GraphNodeKind = "export"
+	GraphNodeService         GraphNodeKind = "service"
+	GraphNodeConfig          GraphNodeKind = "config"
+)
+
+// Graph is the dependency graph of a configuration, as returned by
+// LoadGraph.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+
+	// Edges go from a node to a node it depends on.
+	Edges []GraphEdge `json:"edges"`
+
+	// Cycles holds the IDs of the nodes of every dependency cycle.
+	Cycles [][]string `json:"cycles,omitempty"`
+
+	// Orphans holds the IDs of the components which neither depend on nor are
+	// depended on by any other block.
+	Orphans []string `json:"orphans,omitempty"`
+}
+
+// GraphNode is a node of a Graph.
+type GraphNode struct {
+	// ID uniquely identifies the node in the graph. The IDs of nodes within
+	// the body of a declare or foreach block are prefixed with the ID of the
+	// block, such as "declare.pipeline/loki.process.default".
+	ID string `json:"id"`
+
+	// LocalID is the ID of the node within its module.
+	LocalID string        `json:"local_id"`
+	Kind    GraphNodeKind `json:"kind"`
+
+	// Module is the ID of the declare or foreach node whose body holds the
+	// node. It is empty for the nodes of the root configuration.
+	Module string `json:"module,omitempty"`
+
+	// Position is the location of the block of the node in the sources.
+	Position string `json:"position,omitempty"`
+}
+
+// GraphEdge is a directed edge of a Graph.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// LoadGraph loads the dependency graph of source without building, evaluating
+// or running any component, so it doesn't need access to the systems the
+// configuration refers to.
+//
+// The bodies of declare blocks and the templates of foreach blocks are loaded
+// once each, as modules nested in the root graph. The instances of a custom
+// component depend on the declare or import block which defines it. Imported
+// modules aren't loaded, as that would require evaluating the import blocks.
+//
+// Services and the logging and tracing blocks are only part of the graph if
+// they are configured. The graph is returned along with any errors found
+// while loading it, including dependency cycles.
+func LoadGraph(o Options, source *Source, configPath string) (*Graph, diag.Diagnostics) {
+	o = graphOptions(o)
+
+	gl := &graphLoader{opts: o, out: &Graph{}}
+	gl.load(nil, "", rootApplyOptions(source, configPath), nil)
+	gl.finish()
+	return gl.out, gl.diags
+}
+
+// rootApplyOptions returns the options to load source as the root
+// configuration.
+func rootApplyOptions(source *Source, configPath string) controller.ApplyOptions {
+	modulePath, err := util.ExtractDirPath(configPath)
+	if err != nil {
+		modulePath = ""
+	}
+	return controller.ApplyOptions{
+		ComponentBlocks: source.Components(),
+		ConfigBlocks:    source.Configs(),
+		DeclareBlocks:   source.Declares(),
+		ArgScope: vm.NewScope(map[string]any{
+			importsource.ModulePath: modulePath,
+		}),
+	}
+}
+
+// graphOptions fills in the defaults of o needed to load graphs.
+func graphOptions(o Options) Options {
+	if o.Logger == nil {
+		o.Logger = logging.NewNop()
+	}
+	if o.Tracer == nil {
+		var err error
+		o.Tracer, err = tracing.New(tracing.DefaultOptions)
+		if err != nil {
+			// This shouldn't happen unless there's a bug
+			panic(err)
+		}
+	}
+	return o
+}
+
+// graphLoader loads the graphs of a configuration and of the modules nested
+// in it into a single Graph.
+type graphLoader struct {
+	opts  Options
+	out   *Graph
+	diags diag.Diagnostics
+}
+
+// graphModule is the loaded graph of the root configuration or of a module
+// nested in it.
+type graphModule struct {
+	id     string // Empty for the root configuration.
+	graph  *dag.Graph
+	reg    *controller.CustomComponentRegistry
+	scope  *vm.Scope
+	parent *graphModule // Module holding the declare or foreach block of this module.
+
+	// outer is set for foreach templates, which can reference the nodes of
+	// the module holding the foreach block.
+	outer *graphModule
+}
+
+func (gl *graphLoader) load(parent *graphModule, id string, opts controller.ApplyOptions, outer *graphModule) {
+	loader := newGraphLoader(gl.opts, id)
+	g, reg, diags := loader.LoadGraph(opts)
+	gl.diags = append(gl.diags, diags...)
+
+	m := &graphModule{id: id, graph: g, reg: reg, scope: opts.ArgScope, parent: parent, outer: outer}
+	gl.addNodes(m)
+
+	for _, n := range sortedNodes(g) {
+		switch n := n.(type) {
+		case *controller.DeclareNode:
+			gl.loadDeclare(m, n)
+		case *controller.ForeachConfigNode:
+			gl.loadTemplate(m, n)
+		}
+	}
+}
+
+// newGraphLoader returns a Loader which only loads graphs. Its nodes are
+// never evaluated.
+func newGraphLoader(o Options, id string) *controller.Loader {
+	return controller.NewLoader(controller.LoaderOptions{
+		ComponentGlobals: controller.ComponentGlobals{
+			Logger:               o.Logger,
+			TraceProvider:        o.Tracer,
+			DataPath:             o.DataPath,
+			MinStability:         o.MinStability,
+			EnableCommunityComps: o.EnableCommunityComps,
+			OnBlockNodeUpdate:    func(controller.BlockNode) {},
+			ControllerID:         id,
+			NewModuleController: func(opts controller.ModuleControllerOpts) controller.ModuleController {
+				// Components are never built, so their modules are never
+				// created.
+				return newModuleController(&moduleControllerOptions{
+					Logger:       o.Logger,
+					Tracer:       o.Tracer,
+					MinStability: o.MinStability,
+					ID:           opts.Id,
+				})
+			},
+			GetServiceData: func(name string) (any, error) {
+				return nil, fmt.Errorf("service %q is not available while loading a graph", name)
+			},
+		},
+		Services:          o.Services,
+		ComponentRegistry: o.ComponentRegistry,
+	})
+}
+
+// loadDeclare loads the body of a declare block as a module.
+func (gl *graphLoader) loadDeclare(m *graphModule, n *controller.DeclareNode) {
+	source, err := sourceFromBody(n.Block().Body)
+	if err != nil {
+		gl.diags = append(gl.diags, toDiags(err)...)
+		return
+	}
+	gl.load(m, m.nodeID(n.NodeID()), controller.ApplyOptions{
+		ComponentBlocks:         source.Components(),
+		ConfigBlocks:            source.Configs(),
+		DeclareBlocks:           source.Declares(),
+		CustomComponentRegistry: m.reg,
+		ArgScope:                m.scope,
+	}, nil)
+}
+
+// loadTemplate loads the template of a foreach block as a module. The
+// variable of the foreach block and the nodes the template can reference
+// outside of it are replaced with placeholders, as the collection is not
+// evaluated.
+func (gl *graphLoader) loadTemplate(m *graphModule, n *controller.ForeachConfigNode) {
+	var template *ast.BlockStmt
+	for _, stmt := range n.Block().Body {
+		if block, ok := stmt.(*ast.BlockStmt); ok && block.GetBlockName() == foreach.TypeTemplate {
+			template = block
+		}
+	}
+	if template == nil {
+		// Reported when evaluating the foreach block.
+		return
+	}
+
+	source, err := sourceFromBody(template.Body)
+	if err != nil {
+		gl.diags = append(gl.diags, toDiags(err)...)
+		return
+	}
+
+	vars := make(map[string]any)
+	if m.scope != nil {
+		maps.Copy(vars, m.scope.Variables)
+	}
+	for outer := m; outer != nil; outer = outer.outer {
+		for _, node := range outer.graph.Nodes() {
+			name, _, _ := strings.Cut(node.NodeID(), ".")
+			if _, ok := vars[name]; !ok {
+				vars[name] = map[string]any{}
+			}
+		}
+	}
+	if name := foreachVar(n.Block()); name != "" {
+		vars[name] = nil
+	}
+
+	gl.load(m, m.nodeID(n.NodeID()), controller.ApplyOptions{
+		ComponentBlocks:         source.Components(),
+		ConfigBlocks:            source.Configs(),
+		DeclareBlocks:           source.Declares(),
+		CustomComponentRegistry: m.reg,
+		ArgScope:                vm.NewScope(vars),
+	}, m)
+}
+
+// foreachVar returns the name of the variable of a foreach block.
+func foreachVar(block *ast.BlockStmt) string {
+	for _, stmt := range block.Body {
+		attr, ok := stmt.(*ast.AttributeStmt)
+		if !ok || attr.Name.Name != "var" {
+			continue
+		}
+		var name string
+		if err := vm.New(attr.Value).Evaluate(nil, &name); err != nil {
+			return ""
+		}
+		return name
+	}
+	return ""
+}
+
+func (gl *graphLoader) addNodes(m *graphModule) {
+	added := make(map[dag.Node]struct{})
+	for _, n := range sortedNodes(m.graph) {
+		bn, ok := n.(controller.BlockNode)
+		if !ok || bn.Block() == nil {
+			continue
+		}
+		added[n] = struct{}{}
+		gl.out.Nodes = append(gl.out.Nodes, GraphNode{
+			ID:       m.nodeID(n.NodeID()),
+			LocalID:  n.NodeID(),
+			Kind:     graphNodeKind(n),
+			Module:   m.id,
+			Position: bn.Block().NamePos.Position().String(),
+		})
+	}
+
+	for _, e := range m.graph.Edges() {
+		_, fromOK := added[e.From]
+		_, toOK := added[e.To]
+		if fromOK && toOK {
+			gl.addEdge(m.nodeID(e.From.NodeID()), m.nodeID(e.To.NodeID()))
+		}
+	}
+
+	for n := range added {
+		switch n := n.(type) {
+		case *controller.CustomComponentNode:
+			if target := m.findCustomComponent(n.ComponentName()); target != "" {
+				gl.addEdge(m.nodeID(n.NodeID()), target)
+			}
+		}
+		if m.outer != nil {
+			for _, target := range m.outerReferences(n.(controller.BlockNode)) {
+				gl.addEdge(m.nodeID(n.NodeID()), target)
+			}
+		}
+	}
+
+	for _, scc := range dag.StronglyConnectedComponents(m.graph) {
+		if len(scc) == 1 && !hasSelfEdge(m.graph, scc[0]) {
+			continue
+		}
+		cycle := make([]string, 0, len(scc))
+		for _, n := range scc {
+			cycle = append(cycle, m.nodeID(n.NodeID()))
+		}
+		slices.Sort(cycle)
+		gl.out.Cycles = append(gl.out.Cycles, cycle)
+	}
+}
+
+func (gl *graphLoader) addEdge(from, to string) {
+	gl.out.Edges = append(gl.out.Edges, GraphEdge{From: from, To: to})
+}
+
+// finish sorts the graph and finds its orphaned components.
+func (gl *graphLoader) finish() {
+	slices.SortFunc(gl.out.Nodes, func(a, b GraphNode) int { return cmp.Compare(a.ID, b.ID) })
+	slices.SortFunc(gl.out.Edges, func(a, b GraphEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	gl.out.Edges = slices.Compact(gl.out.Edges)
+	slices.SortFunc(gl.out.Cycles, func(a, b []string) int { return cmp.Compare(a[0], b[0]) })
+
+	var (
+		kinds     = make(map[string]GraphNodeKind, len(gl.out.Nodes))
+		connected = make(map[string]struct{}, len(gl.out.Nodes))
+	)
+	for _, n := range gl.out.Nodes {
+		kinds[n.ID] = n.Kind
+	}
+	for _, e := range gl.out.Edges {
+		if kinds[e.From] == GraphNodeService || kinds[e.To] == GraphNodeService {
+			continue
+		}
+		connected[e.From] = struct{}{}
+		connected[e.To] = struct{}{}
+	}
+	for _, n := range gl.out.Nodes {
+		if n.Kind != GraphNodeComponent && n.Kind != GraphNodeCustomComponent {
+			continue
+		}
+		if _, ok := connected[n.ID]; !ok {
+			gl.out.Orphans = append(gl.out.Orphans, n.ID)
+		}
+	}
+}
+
+// nodeID returns the ID of a node of m in the Graph.
+func (m *graphModule) nodeID(localID string) string {
+	if m.id == "" {
+		return localID
+	}
+	return m.id + "/" + localID
+}
+
+// findCustomComponent returns the ID of the declare or import node defining
+// the custom component name, looking it up from m up to the root
+// configuration.
+func (m *graphModule) findCustomComponent(name string) string {
+	namespace, _, imported := strings.Cut(name, ".")
+	for mod := m; mod != nil; mod = mod.parent {
+		for _, n := range mod.graph.Nodes() {
+			switch n := n.(type) {
+			case *controller.ImportConfigNode:
+				if imported && n.Label() == namespace {
+					return mod.nodeID(n.NodeID())
+				}
+			case *controller.DeclareNode:
+				if !imported && n.Label() == name {
+					return mod.nodeID(n.NodeID())
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// outerReferences returns the IDs of the nodes outside of a foreach template
+// that n references.
+func (m *graphModule) outerReferences(n controller.BlockNode) []string {
+	var ids []string
+	for _, t := range astutil.TraversalsFromBody(n.Block().Body) {
+		if _, diags := astutil.ResolveTraversal(t, m.graph); !diags.HasErrors() {
+			continue
+		}
+		for outer := m.outer; outer != nil; outer = outer.outer {
+			if ref, diags := astutil.ResolveTraversal(t, outer.graph); !diags.HasErrors() {
+				ids = append(ids, outer.nodeID(ref.Target.NodeID()))
+				break
+			}
+		}
+	}
+	return ids
+}
+
+func graphNodeKind(n dag.Node) GraphNodeKind {
+	switch n.(type) {
+	case *controller.BuiltinComponentNode:
+		return GraphNodeComponent
+	case *controller.CustomComponentNode:
+		return GraphNodeCustomComponent
+	case *controller.DeclareNode:
+		return GraphNodeDeclare
+	case *controller.ForeachConfigNode:
+		return GraphNodeForeach
+	case *controller.ImportConfigNode:
+		return GraphNodeImport
+	case *controller.ArgumentConfigNode:
+		return GraphNodeArgument
+	case *controller.ExportConfigNode:
+		return GraphNodeExport
+	case *controller.ServiceNode:
+		return GraphNodeService
+	default:
+		return GraphNodeConfig
+	}
+}
+
+func sortedNodes(g *dag.Graph) []dag.Node {
+	nodes := g.Nodes()
+	slices.SortFunc(nodes, func(a, b dag.Node) int { return cmp.Compare(a.NodeID(), b.NodeID()) })
+	return nodes
+}
+
+func hasSelfEdge(g *dag.Graph, n dag.Node) bool {
+	return slices.Contains(g.Dependencies(n), n)
+}
+
+// toDiags converts an error returned by sourceFromBody to diagnostics.
+func toDiags(err error) diag.Diagnostics {
+	switch err := err.(type) {
+	case diag.Diagnostic:
+		return diag.Diagnostics{err}
+	case diag.Diagnostics:
+		return err
+	default:
+		return diag.Diagnostics{{Severity: diag.SeverityLevelError, Message: err.Error()}}
+	}
+}
@@ -0,0 +1,103 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
+	_ "github.com/grafana/alloy/internal/runtime/internal/testcomponents"
+)
+
+func TestLoadGraph(t *testing.T) {
+	source, err := runtime.ParseSource(t.Name(), []byte(`
+		declare "pipeline" {
+			argument "input" { }
+
+			testcomponents.passthrough "pt" {
+				input = argument.input.value
+				lag   = "1ms"
+			}
+
+			export "output" {
+				value = testcomponents.passthrough.pt.output
+			}
+		}
+
+		testcomponents.count "inc" {
+			frequency = "10ms"
+			max       = 10
+		}
+
+		pipeline "default" {
+			input = testcomponents.count.inc.count
+		}
+
+		foreach "each" {
+			collection = [1, 2]
+			var        = "num"
+
+			template {
+				testcomponents.pulse "pt" {
+					max        = num
+					frequency  = "10ms"
+					forward_to = [testcomponents.summation_receiver.sum.receiver]
+				}
+			}
+		}
+
+		testcomponents.summation_receiver "sum" { }
+
+		testcomponents.tick "unused" {
+			frequency = "1s"
+		}
+	`))
+	require.NoError(t, err)
+
+	g, diags := runtime.LoadGraph(runtime.Options{MinStability: featuregate.StabilityExperimental}, source, "")
+	require.Empty(t, diags)
+
+	ids := make(map[string]runtime.GraphNode)
+	for _, n := range g.Nodes {
+		ids[n.ID] = n
+	}
+	require.Equal(t, runtime.GraphNode{
+		ID:       "declare.pipeline/testcomponents.passthrough.pt",
+		LocalID:  "testcomponents.passthrough.pt",
+		Kind:     runtime.GraphNodeComponent,
+		Module:   "declare.pipeline",
+		Position: "TestLoadGraph:5:4",
+	}, ids["declare.pipeline/testcomponents.passthrough.pt"])
+	require.Equal(t, runtime.GraphNodeCustomComponent, ids["pipeline.default"].Kind)
+	require.Equal(t, runtime.GraphNodeForeach, ids["foreach.each"].Kind)
+	require.Equal(t, "foreach.each", ids["foreach.each/testcomponents.pulse.pt"].Module)
+
+	require.Subset(t, g.Edges, []runtime.GraphEdge{
+		{From: "declare.pipeline/export.output", To: "declare.pipeline/testcomponents.passthrough.pt"},
+		{From: "declare.pipeline/testcomponents.passthrough.pt", To: "declare.pipeline/argument.input"},
+		{From: "pipeline.default", To: "declare.pipeline"},
+		{From: "pipeline.default", To: "testcomponents.count.inc"},
+		{From: "foreach.each/testcomponents.pulse.pt", To: "testcomponents.summation_receiver.sum"},
+	})
+	require.Equal(t, []string{"testcomponents.tick.unused"}, g.Orphans)
+	require.Empty(t, g.Cycles)
+}
+
+func TestLoadGraph_Cycle(t *testing.T) {
+	source, err := runtime.ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "a" {
+			input = testcomponents.passthrough.b.output
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+	`))
+	require.NoError(t, err)
+
+	g, diags := runtime.LoadGraph(runtime.Options{MinStability: featuregate.StabilityExperimental}, source, "")
+	require.True(t, diags.HasErrors())
+	require.Equal(t, [][]string{{"testcomponents.passthrough.a", "testcomponents.passthrough.b"}}, g.Cycles)
+	require.Len(t, g.Nodes, 2)
+}
@@ -123,6 +123,28 @@ func TestImportFile(t *testing.T) {
 	}
 }
 
+func TestImportFunction(t *testing.T) {
+	directory := "./testdata/import_function"
+	for _, file := range getTestFiles(directory, t) {
+		tc := buildTestImportFile(t, filepath.Join(directory, file.Name()))
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.module != "" {
+				defer os.Remove("module.alloy")
+				require.NoError(t, os.WriteFile("module.alloy", []byte(tc.module), 0664))
+			}
+
+			var update func()
+			if tc.update != nil {
+				update = func() {
+					require.NoError(t, os.WriteFile(tc.update.name, []byte(tc.update.updateConfig), 0664))
+				}
+			}
+			// Function blocks are experimental.
+			testConfigWithStability(t, tc.main, tc.reloadConfig, update, featuregate.StabilityExperimental)
+		})
+	}
+}
+
 func TestImportString(t *testing.T) {
 	directory := "./testdata/import_string"
 	for _, file := range getTestFiles(directory, t) {
@@ -300,8 +322,12 @@ func TestImportError(t *testing.T) {
 }
 
 func testConfig(t *testing.T, config string, reloadConfig string, update func()) {
+	testConfigWithStability(t, config, reloadConfig, update, featuregate.StabilityPublicPreview)
+}
+
+func testConfigWithStability(t *testing.T, config string, reloadConfig string, update func(), stability featuregate.Stability) {
 	defer verifyNoGoroutineLeaks(t)
-	ctrl, f := setup(t, config, nil, featuregate.StabilityPublicPreview)
+	ctrl, f := setup(t, config, nil, stability)
 
 	err := ctrl.LoadSource(f, nil, "")
 	require.NoError(t, err)
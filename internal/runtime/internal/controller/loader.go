@@ -290,6 +290,30 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 	return diags
 }
 
+// LoadGraph builds the graph of the provided blocks without evaluating or
+// running any of its nodes. The graph of the Loader isn't replaced, but
+// LoadGraph updates the scope variables of its value cache and replaces the
+// custom component registry its component nodes are built with. It must
+// only be called on a Loader which is dedicated to loading graphs.
+//
+// LoadGraph also returns the CustomComponentRegistry holding the declare and
+// import blocks of the config, so that the bodies of declare and foreach
+// blocks can be loaded in turn. The graph is returned even if it contains
+// errors, such as cycles.
+func (l *Loader) LoadGraph(options ApplyOptions) (*dag.Graph, *CustomComponentRegistry, diag.Diagnostics) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if options.ArgScope != nil {
+		l.cache.UpdateScopeVariables(options.ArgScope.Variables)
+	}
+
+	reg := NewCustomComponentRegistry(options.CustomComponentRegistry, options.ArgScope)
+	l.componentNodeManager.setCustomComponentRegistry(reg)
+	g, diags := l.loadNewGraph(options.Args, options.ComponentBlocks, options.ConfigBlocks, options.DeclareBlocks)
+	return &g, reg, diags
+}
+
 // Cleanup unregisters any existing metrics and optionally stops the worker pool.
 func (l *Loader) Cleanup(stopWorkerPool bool) {
 	if stopWorkerPool {
@@ -538,6 +562,9 @@ func (l *Loader) populateConfigBlockNodes(args map[string]any, g *dag.Graph, con
 		}
 
 		if importNode, ok := node.(*ImportConfigNode); ok {
+			if vm.NewScope(nil).IsStdlibIdentifiers(importNode.label) {
+				level.Warn(l.log).Log("msg", "an import namespace is shadowing an existing stdlib name", "import", importNode.NodeID(), "stdlib name", importNode.label)
+			}
 			l.componentNodeManager.customComponentReg.registerImport(importNode.label)
 		}
 
@@ -560,6 +587,7 @@ func (l *Loader) populateConfigBlockNodes(args map[string]any, g *dag.Graph, con
 	}
 
 	l.importConfigNodes = nodeMap.importMap
+	l.cache.SyncImportNamespaces(nodeMap.importMap)
 	l.forEachNodes = nodeMap.foreachMap
 
 	return diags
@@ -649,6 +677,10 @@ func (l *Loader) wireGraphEdges(g *dag.Graph) diag.Diagnostics {
 			l.wireForEachNode(g, n)
 		}
 
+		if bn, ok := n.(BlockNode); ok {
+			l.wireImportedFunctionReferences(g, bn)
+		}
+
 		// Finally, wire component references.
 		l.cache.mut.RLock()
 		refs, nodeDiags := ComponentReferences(n, g, l.log, l.cache.GetContext(), l.globals.MinStability)
@@ -687,6 +719,19 @@ func (l *Loader) wireForEachNode(g *dag.Graph, fn *ForeachConfigNode) {
 	}
 }
 
+// wireImportedFunctionReferences adds edges between a node and the import nodes whose namespace it references,
+// so that the node is evaluated again when the functions of the import change.
+func (l *Loader) wireImportedFunctionReferences(g *dag.Graph, bn BlockNode) {
+	if bn.Block() == nil {
+		return
+	}
+	for _, t := range astutil.TraversalsFromBody(bn.Block().Body) {
+		if importNode, ok := l.importConfigNodes[t[0].Name]; ok && importNode != bn {
+			g.AddEdge(dag.Edge{From: bn, To: importNode})
+		}
+	}
+}
+
 // Variables returns the Variables the Loader exposes for other components to
 // reference.
 func (l *Loader) Variables() map[string]any {
@@ -762,6 +807,7 @@ func (l *Loader) EvaluateDependants(ctx context.Context, updatedNodes []*QueuedN
 		case *ImportConfigNode:
 			// Update the scope with the imported content.
 			l.componentNodeManager.customComponentReg.updateImportContent(parentNode)
+			l.cache.CacheImportedFunctions(parentNode.label, parentNode.ImportedFunctions())
 		}
 		// We collect all nodes directly incoming to parent.
 		_ = dag.WalkIncomingNodes(l.graph, parent.Node, func(n dag.Node) error {
@@ -923,6 +969,7 @@ func (l *Loader) postEvaluate(logger log.Logger, bn BlockNode, err error) error
 		}
 	case *ImportConfigNode:
 		l.componentNodeManager.customComponentReg.updateImportContent(c)
+		l.cache.CacheImportedFunctions(c.label, c.ImportedFunctions())
 	}
 
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"maps"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +18,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/alloy/internal/component"
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/nodeconf/function"
 	"github.com/grafana/alloy/internal/nodeconf/importsource"
 	"github.com/grafana/alloy/internal/runner"
 	"github.com/grafana/alloy/internal/runtime/logging/level"
@@ -26,8 +29,8 @@ import (
 	"github.com/grafana/alloy/syntax/vm"
 )
 
-// ImportConfigNode imports declare and import blocks via a managed import source.
-// The imported declare are stored in importedDeclares.
+// ImportConfigNode imports declare, function and import blocks via a managed import source.
+// The imported declare are stored in importedDeclares and the imported functions in importedFunctions.
 // For every imported import block, the ImportConfigNode will create ImportConfigNode children.
 // The children are evaluated and ran by the parent.
 // When an ImportConfigNode receives new content from its source, it updates its importedDeclares and recreates its children.
@@ -53,6 +56,8 @@ type ImportConfigNode struct {
 	importConfigNodesChildren map[string]*ImportConfigNode
 	importChildrenRunning     bool
 	importedDeclares          map[string]ast.Body
+	importedFunctions         map[string]any
+	importedFunctionBlocks    map[string]*ast.BlockStmt
 
 	// NOTE: To avoid deadlocks, whenever we need both locks we must always first lock the mut, then healthMut.
 	healthMut     sync.RWMutex
@@ -212,6 +217,8 @@ func (cn *ImportConfigNode) onContentUpdate(importedContent map[string]string) {
 		cn.importedContent[k] = v
 	}
 	cn.importedDeclares = make(map[string]ast.Body)
+	cn.importedFunctions = make(map[string]any)
+	cn.importedFunctionBlocks = make(map[string]*ast.BlockStmt)
 	cn.importConfigNodesChildren = make(map[string]*ImportConfigNode)
 
 	for f, ic := range importedContent {
@@ -222,7 +229,7 @@ func (cn *ImportConfigNode) onContentUpdate(importedContent map[string]string) {
 			return
 		}
 
-		// populate importedDeclares and importConfigNodesChildren
+		// populate importedDeclares, importedFunctions and importConfigNodesChildren
 		err = cn.processImportedContent(parsedImportedContent)
 		if err != nil {
 			level.Error(cn.logger).Log("msg", "failed to process imported content", "file", f, "err", err)
@@ -231,8 +238,17 @@ func (cn *ImportConfigNode) onContentUpdate(importedContent map[string]string) {
 		}
 	}
 
+	// Functions are built once the blocks of all files are known, so that they
+	// can call each other.
+	err := cn.buildFunctions()
+	if err != nil {
+		level.Error(cn.logger).Log("msg", "failed to build imported functions", "err", err)
+		cn.setContentHealth(component.HealthTypeUnhealthy, fmt.Sprintf("imported functions are invalid: %s", err))
+		return
+	}
+
 	// evaluate the importConfigNodesChildren that have been created
-	err := cn.evaluateChildren()
+	err = cn.evaluateChildren()
 	if err != nil {
 		level.Error(cn.logger).Log("msg", "failed to evaluate nested import", "err", err)
 		cn.setContentHealth(component.HealthTypeUnhealthy, fmt.Sprintf("nested import block failed to evaluate: %s", err))
@@ -251,25 +267,37 @@ func (cn *ImportConfigNode) onContentUpdate(importedContent map[string]string) {
 	cn.OnBlockNodeUpdate(cn)
 }
 
-// processImportedContent processes declare and import blocks of the provided ast content.
+// processImportedContent processes declare, function and import blocks of the provided ast content.
 func (cn *ImportConfigNode) processImportedContent(content *ast.File) error {
 	for _, stmt := range content.Body {
 		blockStmt, ok := stmt.(*ast.BlockStmt)
 		if !ok {
-			return fmt.Errorf("only declare and import blocks are allowed in a module")
+			return fmt.Errorf("only declare, function and import blocks are allowed in a module")
 		}
 
 		componentName := strings.Join(blockStmt.Name, ".")
 		switch componentName {
 		case declareType:
 			cn.processDeclareBlock(blockStmt)
+		case function.BlockName:
+			err := cn.processFunctionBlock(blockStmt)
+			if err != nil {
+				return err
+			}
 		case importsource.BlockNameFile, importsource.BlockNameString, importsource.BlockNameHTTP, importsource.BlockNameGit:
 			err := cn.processImportBlock(blockStmt, componentName)
 			if err != nil {
 				return err
 			}
 		default:
-			return fmt.Errorf("only declare and import blocks are allowed in a module, got %s", componentName)
+			return fmt.Errorf("only declare, function and import blocks are allowed in a module, got %s", componentName)
+		}
+	}
+
+	// Functions and custom components share the import namespace.
+	for name := range cn.importedFunctionBlocks {
+		if _, ok := cn.importedDeclares[name]; ok {
+			return fmt.Errorf("function %q conflicts with a declare block of the same name", name)
 		}
 	}
 	return nil
@@ -284,7 +312,33 @@ func (cn *ImportConfigNode) processDeclareBlock(stmt *ast.BlockStmt) {
 	cn.importedDeclares[stmt.Label] = stmt.Body
 }
 
-// processDeclareBlock creates an ImportConfigNode child from the provided import block.
+// processFunctionBlock stores the function block in the importedFunctionBlocks.
+func (cn *ImportConfigNode) processFunctionBlock(stmt *ast.BlockStmt) error {
+	if err := featuregate.CheckAllowed(function.StabilityLevel, cn.globals.MinStability, fmt.Sprintf("function block %q", stmt.Label)); err != nil {
+		return err
+	}
+	if _, ok := cn.importedFunctionBlocks[stmt.Label]; ok {
+		return fmt.Errorf("function block redefined %s", stmt.Label)
+	}
+	cn.importedFunctionBlocks[stmt.Label] = stmt
+	return nil
+}
+
+// buildFunctions builds the functions of the importedFunctionBlocks and stores them in the importedFunctions.
+func (cn *ImportConfigNode) buildFunctions() error {
+	blocks := make([]*ast.BlockStmt, 0, len(cn.importedFunctionBlocks))
+	for _, name := range slices.Sorted(maps.Keys(cn.importedFunctionBlocks)) {
+		blocks = append(blocks, cn.importedFunctionBlocks[name])
+	}
+	fns, err := function.BuildModule(blocks)
+	if err != nil {
+		return err
+	}
+	cn.importedFunctions = fns
+	return nil
+}
+
+// processImportBlock creates an ImportConfigNode child from the provided import block.
 func (cn *ImportConfigNode) processImportBlock(stmt *ast.BlockStmt, fullName string) error {
 	sourceType := importsource.GetSourceType(fullName)
 	if _, ok := cn.importConfigNodesChildren[stmt.Label]; ok {
@@ -434,11 +488,23 @@ func (cn *ImportConfigNode) ImportedDeclares() map[string]ast.Body {
 	return cn.importedDeclares
 }
 
+// ImportedFunctions returns all functions that it imported, keyed by name.
+func (cn *ImportConfigNode) ImportedFunctions() map[string]any {
+	cn.mut.RLock()
+	defer cn.mut.RUnlock()
+	return cn.importedFunctions
+}
+
 // Scope returns the scope associated with the import source.
+// The imported functions are part of the scope so that the imported declare blocks can call them.
 func (cn *ImportConfigNode) Scope() *vm.Scope {
-	return vm.NewScope(map[string]any{
-		importsource.ModulePath: cn.source.ModulePath(),
-	})
+	functions := cn.ImportedFunctions()
+	vars := make(map[string]any, len(functions)+1)
+	for name, fn := range functions {
+		vars[name] = fn
+	}
+	vars[importsource.ModulePath] = cn.source.ModulePath()
+	return vm.NewScope(vars)
 }
 
 // ImportConfigNodesChildren returns the ImportConfigNodesChildren of this ImportConfigNode.
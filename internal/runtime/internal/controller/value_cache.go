@@ -17,20 +17,22 @@ const argumentLabel = "argument"
 // The exports are stored directly in the scope which is used to evaluate Alloy expressions.
 type valueCache struct {
 	mut                sync.RWMutex
-	componentIds       map[string]ComponentID // NodeID -> ComponentID
-	moduleExports      map[string]any         // Export label -> Export value
-	moduleArguments    map[string]any         // Argument label -> Map with the key "value" that points to the Argument value
-	moduleChangedIndex int                    // Everytime a change occurs this is incremented
-	scope              *vm.Scope              // scope provides additional context for the nodes in the module
+	componentIds       map[string]ComponentID    // NodeID -> ComponentID
+	moduleExports      map[string]any            // Export label -> Export value
+	moduleArguments    map[string]any            // Argument label -> Map with the key "value" that points to the Argument value
+	importedFunctions  map[string]map[string]any // Import namespace -> Function name -> Function value
+	moduleChangedIndex int                       // Everytime a change occurs this is incremented
+	scope              *vm.Scope                 // scope provides additional context for the nodes in the module
 }
 
 // newValueCache creates a new ValueCache.
 func newValueCache() *valueCache {
 	return &valueCache{
-		componentIds:    make(map[string]ComponentID, 0),
-		moduleExports:   make(map[string]any),
-		moduleArguments: make(map[string]any),
-		scope:           vm.NewScope(make(map[string]any)),
+		componentIds:      make(map[string]ComponentID, 0),
+		moduleExports:     make(map[string]any),
+		moduleArguments:   make(map[string]any),
+		importedFunctions: make(map[string]map[string]any),
+		scope:             vm.NewScope(make(map[string]any)),
 	}
 }
 
@@ -204,6 +206,37 @@ func (vc *valueCache) SyncModuleArgs(args map[string]any) {
 	}
 }
 
+// CacheImportedFunctions will cache the functions imported under the given import namespace.
+func (vc *valueCache) CacheImportedFunctions(namespace string, functions map[string]any) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+
+	namespaceFunctions := make(map[string]any, len(functions))
+	for name, fn := range functions {
+		namespaceFunctions[name] = fn
+	}
+	vc.importedFunctions[namespace] = namespaceFunctions
+}
+
+// SyncImportNamespaces will remove any cached functions for any import namespace no longer in the map.
+// New namespaces are registered without functions so that references to them can be resolved
+// before the corresponding import node is evaluated.
+func (vc *valueCache) SyncImportNamespaces(namespaces map[string]*ImportConfigNode) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+
+	for namespace := range vc.importedFunctions {
+		if _, ok := namespaces[namespace]; !ok {
+			delete(vc.importedFunctions, namespace)
+		}
+	}
+	for namespace := range namespaces {
+		if _, ok := vc.importedFunctions[namespace]; !ok {
+			vc.importedFunctions[namespace] = make(map[string]any)
+		}
+	}
+}
+
 // GetContext returns a scope that can be used for evaluation.
 func (vc *valueCache) GetContext() *vm.Scope {
 	vc.mut.RLock()
@@ -215,6 +248,19 @@ func (vc *valueCache) GetContext() *vm.Scope {
 		vars[argumentLabel] = deepCopyMap(vc.moduleArguments)
 	}
 
+	// Imported functions live in the import namespace, next to the exports of
+	// the custom components instantiated from the same import.
+	for namespace, functions := range vc.importedFunctions {
+		namespaceVars, ok := vars[namespace].(map[string]any)
+		if !ok {
+			namespaceVars = make(map[string]any, len(functions))
+			vars[namespace] = namespaceVars
+		}
+		for name, fn := range functions {
+			namespaceVars[name] = fn
+		}
+	}
+
 	return vm.NewScope(vars)
 }
 
@@ -0,0 +1,303 @@
+package runtime
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/grafana/alloy/internal/dag"
+	"github.com/grafana/alloy/internal/runtime/internal/controller"
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/diag"
+	"github.com/grafana/alloy/syntax/token"
+)
+
+// PlanAction is the action loading a configuration takes on a node.
+type PlanAction string
+
+// Actions of a PlanChange.
+const (
+	// PlanCreate is used for nodes which are only in the new configuration.
+	PlanCreate PlanAction = "create"
+
+	// PlanUpdate is used for nodes whose block or definition changed. Nodes
+	// are updated in place: components keep running and receive their new
+	// arguments.
+	PlanUpdate PlanAction = "update"
+
+	// PlanDelete is used for nodes which are only in the current
+	// configuration. Deleted components are stopped.
+	PlanDelete PlanAction = "delete"
+)
+
+// Plan holds the changes loading a new configuration would make to the
+// nodes of the current configuration. Nodes which don't change are not part
+// of the plan.
+type Plan struct {
+	Changes []PlanChange `json:"changes"`
+}
+
+// PlanChange is the change loading a new configuration would make to a node.
+type PlanChange struct {
+	ID     string        `json:"id"`
+	Kind   GraphNodeKind `json:"kind"`
+	Action PlanAction    `json:"action"`
+
+	// Arguments holds the names of the attributes and blocks of an updated
+	// node which are added, removed or changed.
+	Arguments []string `json:"arguments,omitempty"`
+
+	// DefinitionChanged is set for custom components whose declare or import
+	// block changed. Their module is reloaded with the new definition.
+	DefinitionChanged bool `json:"definition_changed,omitempty"`
+
+	// Dependants holds the IDs of the nodes which are evaluated again if the
+	// exports of a created or updated node change, either because they
+	// reference the node or because they reference such a node in turn.
+	Dependants []string `json:"dependants,omitempty"`
+}
+
+// Plan computes the changes loading next would make to the configuration
+// which is currently loaded. next is not loaded. Plan returns the errors
+// found in next, in which case loading next would fail and change nothing.
+func (f *Runtime) Plan(next *Source, configPath string) (*Plan, diag.Diagnostics) {
+	f.loadMut.RLock()
+	current := f.loader.Graph()
+	f.loadMut.RUnlock()
+
+	o := f.opts.Options
+	o.ComponentRegistry = f.opts.ComponentRegistry
+	return planGraph(o, current, next, configPath)
+}
+
+// PlanSources computes the changes loading next would make to a
+// configuration loaded from current. Neither configuration is evaluated or
+// run. PlanSources returns the errors found in either configuration.
+func PlanSources(o Options, current, next *Source, configPath string) (*Plan, diag.Diagnostics) {
+	o = graphOptions(o)
+	g, _, diags := newGraphLoader(o, "").LoadGraph(rootApplyOptions(current, configPath))
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return planGraph(o, g, next, configPath)
+}
+
+func planGraph(o Options, current *dag.Graph, next *Source, configPath string) (*Plan, diag.Diagnostics) {
+	o = graphOptions(o)
+	g, _, diags := newGraphLoader(o, "").LoadGraph(rootApplyOptions(next, configPath))
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var (
+		before = blockNodes(current)
+		after  = blockNodes(g)
+		plan   = &Plan{Changes: []PlanChange{}}
+	)
+	for _, id := range nodeIDs(before, after) {
+		var (
+			prev, prevOK = before[id]
+			node, nodeOK = after[id]
+		)
+		switch {
+		case !nodeOK:
+			if prev.Block() != nil {
+				plan.Changes = append(plan.Changes, PlanChange{ID: id, Kind: graphNodeKind(prev), Action: PlanDelete})
+			}
+
+		case !prevOK:
+			if node.Block() != nil {
+				plan.Changes = append(plan.Changes, PlanChange{
+					ID:         id,
+					Kind:       graphNodeKind(node),
+					Action:     PlanCreate,
+					Dependants: dependants(g, node),
+				})
+			}
+
+		default:
+			change := PlanChange{
+				ID:                id,
+				Kind:              graphNodeKind(node),
+				Action:            PlanUpdate,
+				Arguments:         changedArguments(prev.Block(), node.Block()),
+				DefinitionChanged: definitionChanged(before, after, node),
+			}
+			if len(change.Arguments) > 0 || change.DefinitionChanged {
+				change.Dependants = dependants(g, node)
+				plan.Changes = append(plan.Changes, change)
+			}
+		}
+	}
+	return plan, nil
+}
+
+func blockNodes(g *dag.Graph) map[string]controller.BlockNode {
+	nodes := make(map[string]controller.BlockNode)
+	for _, n := range g.Nodes() {
+		if bn, ok := n.(controller.BlockNode); ok {
+			nodes[n.NodeID()] = bn
+		}
+	}
+	return nodes
+}
+
+// nodeIDs returns the sorted IDs of the nodes of a and b.
+func nodeIDs(a, b map[string]controller.BlockNode) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// definitionChanged reports whether the declare or import block defining a
+// custom component changed.
+func definitionChanged(before, after map[string]controller.BlockNode, n controller.BlockNode) bool {
+	cc, ok := n.(*controller.CustomComponentNode)
+	if !ok {
+		return false
+	}
+
+	id := "declare." + cc.ComponentName()
+	if namespace, _, imported := strings.Cut(cc.ComponentName(), "."); imported {
+		id = ""
+		for nodeID, n := range after {
+			if in, ok := n.(*controller.ImportConfigNode); ok && in.Label() == namespace {
+				id = nodeID
+			}
+		}
+	}
+
+	prev, prevOK := before[id]
+	node, nodeOK := after[id]
+	if !prevOK || !nodeOK {
+		return prevOK != nodeOK
+	}
+	return len(changedArguments(prev.Block(), node.Block())) > 0
+}
+
+// changedArguments returns the names of the attributes and blocks which
+// differ between a and b. Either block may be nil.
+func changedArguments(a, b *ast.BlockStmt) []string {
+	var (
+		before = bodyStatements(a)
+		after  = bodyStatements(b)
+		names  []string
+	)
+	for name, stmts := range before {
+		if !astEqual(stmts, after[name]) {
+			names = append(names, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// bodyStatements groups the statements of the body of block by name.
+func bodyStatements(block *ast.BlockStmt) map[string][]ast.Stmt {
+	stmts := make(map[string][]ast.Stmt)
+	if block == nil {
+		return stmts
+	}
+	for _, stmt := range block.Body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			stmts[stmt.Name.Name] = append(stmts[stmt.Name.Name], stmt)
+		case *ast.BlockStmt:
+			name := stmt.GetBlockName()
+			stmts[name] = append(stmts[name], stmt)
+		}
+	}
+	return stmts
+}
+
+// dependants returns the IDs of the nodes which depend on n, directly or
+// indirectly.
+func dependants(g *dag.Graph, n dag.Node) []string {
+	var (
+		ids     []string
+		visited = map[dag.Node]struct{}{n: {}}
+		queue   = g.Dependants(n)
+	)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[next]; ok {
+			continue
+		}
+		visited[next] = struct{}{}
+		ids = append(ids, next.NodeID())
+		queue = append(queue, g.Dependants(next)...)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+var posType = reflect.TypeOf(token.Pos{})
+
+// astEqual reports whether two AST values are equal, ignoring their
+// positions.
+func astEqual(a, b any) bool {
+	return astValuesEqual(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func astValuesEqual(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	if a.Type() == posType {
+		return true
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return astValuesEqual(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := range a.Len() {
+			if !astValuesEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if !astValuesEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,159 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
+	_ "github.com/grafana/alloy/internal/runtime/internal/testcomponents"
+)
+
+const planCurrent = `
+	declare "pipeline" {
+		argument "input" { }
+
+		export "output" {
+			value = argument.input.value
+		}
+	}
+
+	testcomponents.count "inc" {
+		frequency = "10ms"
+		max       = 10
+	}
+
+	testcomponents.passthrough "pt" {
+		input = testcomponents.count.inc.count
+		lag   = "1ms"
+	}
+
+	pipeline "default" {
+		input = testcomponents.passthrough.pt.output
+	}
+
+	testcomponents.summation "sum" {
+		input = pipeline.default.output
+	}
+
+	testcomponents.tick "removed" {
+		frequency = "1s"
+	}
+`
+
+const planNext = `
+	declare "pipeline" {
+		argument "input" { }
+
+		export "output" {
+			value = argument.input.value + 1
+		}
+	}
+
+	testcomponents.count "inc" {
+		frequency = "10ms"
+		max       = 10
+	}
+
+	// Comments and formatting changes don't change a node.
+	testcomponents.passthrough "pt" {
+		input = testcomponents.count.inc.count
+		lag = "5ms"
+	}
+
+	pipeline "default" {
+		input = testcomponents.passthrough.pt.output
+	}
+
+	testcomponents.summation "sum" {
+		input = pipeline.default.output
+	}
+
+	testcomponents.tick "added" {
+		frequency = "1s"
+	}
+`
+
+func TestPlanSources(t *testing.T) {
+	current, err := runtime.ParseSource(t.Name(), []byte(planCurrent))
+	require.NoError(t, err)
+	next, err := runtime.ParseSource(t.Name(), []byte(planNext))
+	require.NoError(t, err)
+
+	plan, diags := runtime.PlanSources(runtime.Options{MinStability: featuregate.StabilityExperimental}, current, next, "")
+	require.Empty(t, diags)
+	require.Equal(t, []runtime.PlanChange{
+		{
+			ID:        "declare.pipeline",
+			Kind:      runtime.GraphNodeDeclare,
+			Action:    runtime.PlanUpdate,
+			Arguments: []string{"export"},
+		},
+		{
+			ID:                "pipeline.default",
+			Kind:              runtime.GraphNodeCustomComponent,
+			Action:            runtime.PlanUpdate,
+			DefinitionChanged: true,
+			Dependants:        []string{"testcomponents.summation.sum"},
+		},
+		{
+			ID:         "testcomponents.passthrough.pt",
+			Kind:       runtime.GraphNodeComponent,
+			Action:     runtime.PlanUpdate,
+			Arguments:  []string{"lag"},
+			Dependants: []string{"pipeline.default", "testcomponents.summation.sum"},
+		},
+		{
+			ID:     "testcomponents.tick.added",
+			Kind:   runtime.GraphNodeComponent,
+			Action: runtime.PlanCreate,
+		},
+		{
+			ID:     "testcomponents.tick.removed",
+			Kind:   runtime.GraphNodeComponent,
+			Action: runtime.PlanDelete,
+		},
+	}, plan.Changes)
+}
+
+func TestPlanSources_Errors(t *testing.T) {
+	current, err := runtime.ParseSource(t.Name(), []byte(planCurrent))
+	require.NoError(t, err)
+	next, err := runtime.ParseSource(t.Name(), []byte(`testcomponents.passthrough "pt" { input = testcomponents.missing.x.output }`))
+	require.NoError(t, err)
+
+	_, diags := runtime.PlanSources(runtime.Options{MinStability: featuregate.StabilityExperimental}, current, next, "")
+	require.True(t, diags.HasErrors())
+}
+
+func TestRuntimePlan(t *testing.T) {
+	current, err := runtime.ParseSource(t.Name(), []byte(planCurrent))
+	require.NoError(t, err)
+	next, err := runtime.ParseSource(t.Name(), []byte(planNext))
+	require.NoError(t, err)
+
+	ctrl := runtime.New(testOptions(t))
+	require.NoError(t, ctrl.LoadSource(current, nil, ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	plan, diags := ctrl.Plan(next, "")
+	require.Empty(t, diags)
+	require.Len(t, plan.Changes, 5)
+
+	// Planning doesn't load the new configuration.
+	plan, diags = ctrl.Plan(current, "")
+	require.Empty(t, diags)
+	require.Empty(t, plan.Changes)
+}
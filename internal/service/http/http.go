@@ -22,6 +22,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/grafana/alloy/internal/component"
 	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
 	"github.com/grafana/alloy/internal/runtime/logging"
 	"github.com/grafana/alloy/internal/runtime/logging/level"
 	"github.com/grafana/alloy/internal/service"
@@ -53,6 +54,11 @@ type Options struct {
 	ReadyFunc  func() bool
 	ReloadFunc func() error
 
+	// PlanFunc computes the changes loading sources would make to the
+	// running configuration. If sources is nil, the configuration is read
+	// from the same location as ReloadFunc.
+	PlanFunc func(sources map[string][]byte) (*runtime.Plan, error)
+
 	HTTPListenAddr   string                // Address to listen for HTTP traffic on.
 	MemoryListenAddr string                // Address to accept in-memory traffic on.
 	EnablePProf      bool                  // Whether pprof endpoints should be exposed.
@@ -278,6 +284,10 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 		}).Methods(http.MethodGet, http.MethodPost)
 	}
 
+	if s.opts.PlanFunc != nil {
+		r.HandleFunc("/api/v0/plan", s.planHandler).Methods(http.MethodGet, http.MethodPost)
+	}
+
 	// Wire in support bundle generator
 	r.HandleFunc("/-/support", s.generateSupportBundleHandler(host)).Methods("GET")
 
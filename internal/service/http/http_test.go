@@ -2,13 +2,16 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/grafana/alloy/internal/component"
 	"github.com/grafana/alloy/internal/featuregate"
+	"github.com/grafana/alloy/internal/runtime"
 	"github.com/grafana/alloy/internal/runtime/componenttest"
 	"github.com/grafana/alloy/internal/service"
 	"github.com/grafana/alloy/internal/service/remotecfg"
@@ -69,6 +72,43 @@ func TestHTTP(t *testing.T) {
 	})
 }
 
+func TestPlan(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+
+	env, err := newTestEnvironment(t)
+	require.NoError(t, err)
+	require.NoError(t, env.ApplyConfig(`/* empty */`))
+
+	go func() {
+		require.NoError(t, env.Run(ctx))
+	}()
+
+	tt := []struct {
+		method, body string
+		code         int
+		expected     string
+	}{
+		{http.MethodGet, "", http.StatusOK, `{"changes":[{"id":"reloaded","kind":"","action":"update"}]}` + "\n"},
+		{http.MethodPost, "loki.write.default", http.StatusOK, `{"changes":[{"id":"loki.write.default","kind":"","action":"create"}]}` + "\n"},
+		{http.MethodPost, "invalid", http.StatusBadRequest, "invalid config\n"},
+	}
+	for _, tc := range tt {
+		util.Eventually(t, func(t require.TestingT) {
+			req, err := http.NewRequest(tc.method, fmt.Sprintf("http://%s/api/v0/plan", env.ListenAddr()), strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			buf, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.code, resp.StatusCode)
+			require.Equal(t, tc.expected, string(buf))
+		})
+	}
+}
+
 func TestTLS(t *testing.T) {
 	ctx := componenttest.TestContext(t)
 
@@ -349,6 +389,15 @@ func newTestEnvironment(t *testing.T) (*testEnvironment, error) {
 
 		ReadyFunc:  func() bool { return true },
 		ReloadFunc: func() error { return nil },
+		PlanFunc: func(sources map[string][]byte) (*runtime.Plan, error) {
+			if sources == nil {
+				return &runtime.Plan{Changes: []runtime.PlanChange{{ID: "reloaded", Action: runtime.PlanUpdate}}}, nil
+			}
+			if string(sources["plan.alloy"]) == "invalid" {
+				return nil, errors.New("invalid config")
+			}
+			return &runtime.Plan{Changes: []runtime.PlanChange{{ID: string(sources["plan.alloy"]), Action: runtime.PlanCreate}}}, nil
+		},
 
 		HTTPListenAddr:   fmt.Sprintf("127.0.0.1:%d", port),
 		MemoryListenAddr: "alloy.internal:12345",
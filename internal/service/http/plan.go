@@ -0,0 +1,41 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/grafana/alloy/internal/runtime/logging/level"
+)
+
+// maxPlanSourceSize is the maximum size of a configuration sent to the plan
+// endpoint.
+const maxPlanSourceSize = 10 << 20
+
+// planHandler returns the changes loading a configuration would make to the
+// running configuration. GET requests plan the configuration which
+// /-/reload would load, and POST requests plan the configuration in the
+// request body.
+func (s *Service) planHandler(w http.ResponseWriter, r *http.Request) {
+	var sources map[string][]byte
+	if r.Method == http.MethodPost {
+		bb, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPlanSourceSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sources = map[string][]byte{"plan.alloy": bb}
+	}
+
+	plan, err := s.opts.PlanFunc(sources)
+	if err != nil {
+		level.Info(s.log).Log("msg", "failed to plan config", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		level.Error(s.log).Log("msg", "failed to write plan", "err", err)
+	}
+}
@@ -85,6 +85,11 @@ type traversalWalker struct {
 
 	buildTraversal   bool      // Whether
 	currentTraversal Traversal // currentTraversal being built.
+
+	// params holds the parameter names of the function expressions enclosing
+	// the node being visited. Identifiers which refer to a parameter are local
+	// to the function and never start a traversal.
+	params map[string]int
 }
 
 func (tw *traversalWalker) Visit(node ast.Node) ast.Visitor {
@@ -92,9 +97,29 @@ func (tw *traversalWalker) Visit(node ast.Node) ast.Visitor {
 	case *ast.IdentifierExpr:
 		// Identifiers always start new traversals. Pop the last one.
 		tw.flush()
+		if tw.params[n.Ident.Name] > 0 {
+			return nil
+		}
 		tw.buildTraversal = true
 		tw.currentTraversal = append(tw.currentTraversal, n.Ident)
 
+	case *ast.FuncExpr:
+		// Function expressions interrupt traversals. Their parameters shadow
+		// anything else with the same name while walking the body.
+		tw.flush()
+		if tw.params == nil {
+			tw.params = make(map[string]int)
+		}
+		for _, p := range n.Params {
+			tw.params[p.Name]++
+		}
+		ast.Walk(tw, n.Body)
+		tw.flush()
+		for _, p := range n.Params {
+			tw.params[p.Name]--
+		}
+		return nil
+
 	case *ast.AccessExpr:
 		ast.Walk(tw, n.Value)
 
@@ -0,0 +1,55 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/grafana/alloy/syntax/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraversalsFromBody(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		expect []string
+	}{
+		{
+			name:   "component export",
+			input:  `targets = discovery.kubernetes.pods.targets`,
+			expect: []string{"discovery.kubernetes.pods.targets"},
+		},
+		{
+			name:   "call interrupts traversal",
+			input:  `value = sys.env("HOME").length`,
+			expect: []string{"sys.env"},
+		},
+		{
+			name:   "function parameters are not traversals",
+			input:  `targets = array.map(discovery.kubernetes.pods.targets, t => t.labels)`,
+			expect: []string{"array.map", "discovery.kubernetes.pods.targets"},
+		},
+		{
+			name:   "function body references outer values",
+			input:  `targets = array.filter(local.file.a.content, (x) => x == local.file.b.content)`,
+			expect: []string{"array.filter", "local.file.a.content", "local.file.b.content"},
+		},
+		{
+			name:   "parameter scope ends with function",
+			input:  `value = [x => x, x.y]`,
+			expect: []string{"x.y"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := parser.ParseFile(t.Name(), []byte(tc.input))
+			require.NoError(t, err)
+
+			var actual []string
+			for _, tr := range TraversalsFromBody(f.Body) {
+				actual = append(actual, tr.String())
+			}
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
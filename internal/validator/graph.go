@@ -3,6 +3,7 @@ package validator
 import (
 	"fmt"
 	"iter"
+	"reflect"
 	"slices"
 	"strings"
 
@@ -65,14 +66,17 @@ func (g *graph) Iter() iter.Seq[dag.Node] {
 }
 
 func validateGraph(s *state, minStability featuregate.Stability) diag.Diagnostics {
-	var diags diag.Diagnostics
+	var (
+		diags   diag.Diagnostics
+		resolve = resolveExportsType(s)
+	)
 	for n := range s.graph.Iter() {
 		switch node := n.(type) {
 		case *node:
 			// Add any diagnostic for node that should be before type check.
 			diags.Merge(node.diags)
 			if node.args != nil {
-				diags.Merge(typecheck.Block(node.block, node.args))
+				diags.Merge(typecheck.BlockWithTypes(node.block, node.args, resolve))
 			}
 		case *componentNode:
 			name := node.block.GetBlockName()
@@ -93,17 +97,17 @@ func validateGraph(s *state, minStability featuregate.Stability) diag.Diagnostic
 			if reg.Args == nil {
 				continue
 			}
-			diags.Merge(typecheck.Block(node.block, reg.CloneArguments()))
+			diags.Merge(typecheck.BlockWithTypes(node.block, reg.CloneArguments(), resolve))
 		case *moduleNode:
 			diags.Merge(node.n.diags)
 			if node.n.args != nil {
-				diags.Merge(typecheck.Block(node.n.block, node.n.args))
+				diags.Merge(typecheck.BlockWithTypes(node.n.block, node.n.args, resolve))
 			}
 			diags.Merge(validateGraph(node.state, minStability))
 		case *foreachNode:
 			diags.Merge(node.n.diags)
 			if node.n.args != nil {
-				diags.Merge(typecheck.Block(node.n.block, node.n.args))
+				diags.Merge(typecheck.BlockWithTypes(node.n.block, node.n.args, resolve))
 			}
 			diags.Merge(validateGraph(node.state, minStability))
 		}
@@ -164,6 +168,23 @@ func validateGraph(s *state, minStability featuregate.Stability) diag.Diagnostic
 	return diags
 }
 
+// resolveExportsType returns a typecheck.TypeResolver that resolves references
+// to components in the graph of s to the type of their exports.
+func resolveExportsType(s *state) typecheck.TypeResolver {
+	return func(path []string) (reflect.Type, bool) {
+		node, ok := s.graph.GetByID(strings.Join(path, ".")).(*componentNode)
+		if !ok {
+			return nil, false
+		}
+
+		reg, err := s.cr.Get(node.block.GetBlockName())
+		if err != nil || reg.Exports == nil {
+			return nil, false
+		}
+		return reflect.TypeOf(reg.Exports), true
+	}
+}
+
 type blockNode interface {
 	dag.Node
 	Block() *ast.BlockStmt
@@ -167,7 +167,11 @@ func (v *validator) validateDeclares(s *state) {
 				args[arg.Label] = struct{}{}
 			}
 			moduleState.scope.Variables["argument"] = args
-			s.cr.registerCustomComponent(node.block, generateArgumentsStruct(moduleState.arguments))
+			s.cr.registerCustomComponent(
+				node.block,
+				generateArgumentsStruct(moduleState.arguments),
+				generateExportsStruct(moduleState),
+			)
 		}
 	}
 }
@@ -274,7 +278,10 @@ func (v *validator) validateImport(node *node, register bool, s *state) {
 	}
 
 	if register {
-		s.cr.registerCustomComponent(node.block, nil)
+		s.cr.registerCustomComponent(node.block, nil, nil)
+		// Functions defined in the imported module are called through the import
+		// namespace. Their definitions are only known at runtime.
+		s.scope.Variables[node.block.Label] = struct{}{}
 	}
 }
 
@@ -557,3 +564,43 @@ func generateArgumentsStruct(args []*ast.BlockStmt) any {
 
 	return reflect.New(reflect.StructOf(fields)).Interface()
 }
+
+// generateExportsStruct creates the exports of a module from its export blocks.
+// The type of each export is inferred from its value, and is any when it can't
+// be known without evaluating the module.
+func generateExportsStruct(s *state) any {
+	var (
+		resolve = resolveExportsType(s)
+		seen    = make(map[string]struct{})
+		fields  = make([]reflect.StructField, 0)
+	)
+
+	for _, c := range s.configs {
+		if c.GetBlockName() != export.BlockName || c.Label == "" {
+			continue
+		}
+
+		if _, ok := seen[c.Label]; ok {
+			continue
+		}
+		seen[c.Label] = struct{}{}
+
+		typ := reflect.TypeFor[any]()
+		for _, stmt := range c.Body {
+			if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == "value" {
+				if t, ok := typecheck.ExprType(attr.Value, resolve); ok {
+					typ = t
+				}
+			}
+		}
+
+		// Labels are not always valid Go identifiers, the tag holds the export name.
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Export%d", len(fields)),
+			Type: typ,
+			Tag:  reflect.StructTag(fmt.Sprintf(`alloy:"%s,attr"`, c.Label)),
+		})
+	}
+
+	return reflect.New(reflect.StructOf(fields)).Interface()
+}
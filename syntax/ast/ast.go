@@ -176,6 +176,29 @@ type ParenExpr struct {
 	Secret bool
 }
 
+// ConditionalExpr evaluates to one of two values depending on the result of
+// a boolean condition. Only the selected value is evaluated.
+type ConditionalExpr struct {
+	Condition, True, False Expr
+	QuestionPos, ColonPos  token.Pos
+
+	Secret bool
+}
+
+// FuncExpr declares an anonymous function. The Body is evaluated each time
+// the function is called, with Params bound to the call arguments.
+//
+// LParenPos and RParenPos are only set when the parameter list was wrapped in
+// parentheses; a single parameter may be written without them.
+type FuncExpr struct {
+	Params               []*Ident
+	LParenPos, RParenPos token.Pos
+	ArrowPos             token.Pos
+	Body                 Expr
+
+	Secret bool
+}
+
 // Type assertions
 
 var (
@@ -194,6 +217,8 @@ var (
 	_ Node = (*UnaryExpr)(nil)
 	_ Node = (*BinaryExpr)(nil)
 	_ Node = (*ParenExpr)(nil)
+	_ Node = (*ConditionalExpr)(nil)
+	_ Node = (*FuncExpr)(nil)
 
 	_ Stmt = (*AttributeStmt)(nil)
 	_ Stmt = (*BlockStmt)(nil)
@@ -208,61 +233,71 @@ var (
 	_ Expr = (*UnaryExpr)(nil)
 	_ Expr = (*BinaryExpr)(nil)
 	_ Expr = (*ParenExpr)(nil)
+	_ Expr = (*ConditionalExpr)(nil)
+	_ Expr = (*FuncExpr)(nil)
 )
 
-func (n *File) astNode()           {}
-func (n Body) astNode()            {}
-func (n CommentGroup) astNode()    {}
-func (n *Comment) astNode()        {}
-func (n *AttributeStmt) astNode()  {}
-func (n *BlockStmt) astNode()      {}
-func (n *Ident) astNode()          {}
-func (n *IdentifierExpr) astNode() {}
-func (n *LiteralExpr) astNode()    {}
-func (n *ArrayExpr) astNode()      {}
-func (n *ObjectExpr) astNode()     {}
-func (n *AccessExpr) astNode()     {}
-func (n *IndexExpr) astNode()      {}
-func (n *CallExpr) astNode()       {}
-func (n *UnaryExpr) astNode()      {}
-func (n *BinaryExpr) astNode()     {}
-func (n *ParenExpr) astNode()      {}
+func (n *File) astNode()            {}
+func (n Body) astNode()             {}
+func (n CommentGroup) astNode()     {}
+func (n *Comment) astNode()         {}
+func (n *AttributeStmt) astNode()   {}
+func (n *BlockStmt) astNode()       {}
+func (n *Ident) astNode()           {}
+func (n *IdentifierExpr) astNode()  {}
+func (n *LiteralExpr) astNode()     {}
+func (n *ArrayExpr) astNode()       {}
+func (n *ObjectExpr) astNode()      {}
+func (n *AccessExpr) astNode()      {}
+func (n *IndexExpr) astNode()       {}
+func (n *CallExpr) astNode()        {}
+func (n *UnaryExpr) astNode()       {}
+func (n *BinaryExpr) astNode()      {}
+func (n *ParenExpr) astNode()       {}
+func (n *ConditionalExpr) astNode() {}
+func (n *FuncExpr) astNode()        {}
 
 func (n *AttributeStmt) astStmt() {}
 func (n *BlockStmt) astStmt()     {}
 
-func (n *IdentifierExpr) astExpr() {}
-func (n *LiteralExpr) astExpr()    {}
-func (n *ArrayExpr) astExpr()      {}
-func (n *ObjectExpr) astExpr()     {}
-func (n *AccessExpr) astExpr()     {}
-func (n *IndexExpr) astExpr()      {}
-func (n *CallExpr) astExpr()       {}
-func (n *UnaryExpr) astExpr()      {}
-func (n *BinaryExpr) astExpr()     {}
-func (n *ParenExpr) astExpr()      {}
-
-func (n *IdentifierExpr) IsSecret() bool { return n.Secret }
-func (n *LiteralExpr) IsSecret() bool    { return n.Secret }
-func (n *ArrayExpr) IsSecret() bool      { return n.Secret }
-func (n *ObjectExpr) IsSecret() bool     { return n.Secret }
-func (n *AccessExpr) IsSecret() bool     { return n.Secret }
-func (n *IndexExpr) IsSecret() bool      { return n.Secret }
-func (n *CallExpr) IsSecret() bool       { return n.Secret }
-func (n *UnaryExpr) IsSecret() bool      { return n.Secret }
-func (n *BinaryExpr) IsSecret() bool     { return n.Secret }
-func (n *ParenExpr) IsSecret() bool      { return n.Secret }
-
-func (n *IdentifierExpr) SetSecret(s bool) { n.Secret = s }
-func (n *LiteralExpr) SetSecret(s bool)    { n.Secret = s }
-func (n *ArrayExpr) SetSecret(s bool)      { n.Secret = s }
-func (n *ObjectExpr) SetSecret(s bool)     { n.Secret = s }
-func (n *AccessExpr) SetSecret(s bool)     { n.Secret = s }
-func (n *IndexExpr) SetSecret(s bool)      { n.Secret = s }
-func (n *CallExpr) SetSecret(s bool)       { n.Secret = s }
-func (n *UnaryExpr) SetSecret(s bool)      { n.Secret = s }
-func (n *BinaryExpr) SetSecret(s bool)     { n.Secret = s }
-func (n *ParenExpr) SetSecret(s bool)      { n.Secret = s }
+func (n *IdentifierExpr) astExpr()  {}
+func (n *LiteralExpr) astExpr()     {}
+func (n *ArrayExpr) astExpr()       {}
+func (n *ObjectExpr) astExpr()      {}
+func (n *AccessExpr) astExpr()      {}
+func (n *IndexExpr) astExpr()       {}
+func (n *CallExpr) astExpr()        {}
+func (n *UnaryExpr) astExpr()       {}
+func (n *BinaryExpr) astExpr()      {}
+func (n *ParenExpr) astExpr()       {}
+func (n *ConditionalExpr) astExpr() {}
+func (n *FuncExpr) astExpr()        {}
+
+func (n *IdentifierExpr) IsSecret() bool  { return n.Secret }
+func (n *LiteralExpr) IsSecret() bool     { return n.Secret }
+func (n *ArrayExpr) IsSecret() bool       { return n.Secret }
+func (n *ObjectExpr) IsSecret() bool      { return n.Secret }
+func (n *AccessExpr) IsSecret() bool      { return n.Secret }
+func (n *IndexExpr) IsSecret() bool       { return n.Secret }
+func (n *CallExpr) IsSecret() bool        { return n.Secret }
+func (n *UnaryExpr) IsSecret() bool       { return n.Secret }
+func (n *BinaryExpr) IsSecret() bool      { return n.Secret }
+func (n *ParenExpr) IsSecret() bool       { return n.Secret }
+func (n *ConditionalExpr) IsSecret() bool { return n.Secret }
+func (n *FuncExpr) IsSecret() bool        { return n.Secret }
+
+func (n *IdentifierExpr) SetSecret(s bool)  { n.Secret = s }
+func (n *LiteralExpr) SetSecret(s bool)     { n.Secret = s }
+func (n *ArrayExpr) SetSecret(s bool)       { n.Secret = s }
+func (n *ObjectExpr) SetSecret(s bool)      { n.Secret = s }
+func (n *AccessExpr) SetSecret(s bool)      { n.Secret = s }
+func (n *IndexExpr) SetSecret(s bool)       { n.Secret = s }
+func (n *CallExpr) SetSecret(s bool)        { n.Secret = s }
+func (n *UnaryExpr) SetSecret(s bool)       { n.Secret = s }
+func (n *BinaryExpr) SetSecret(s bool)      { n.Secret = s }
+func (n *ParenExpr) SetSecret(s bool)       { n.Secret = s }
+func (n *ConditionalExpr) SetSecret(s bool) { n.Secret = s }
+func (n *FuncExpr) SetSecret(s bool)        { n.Secret = s }
 
 // StartPos returns the position of the first character belonging to a Node.
 func StartPos(n Node) token.Pos {
@@ -310,6 +345,13 @@ func StartPos(n Node) token.Pos {
 		return StartPos(n.Left)
 	case *ParenExpr:
 		return n.LParenPos
+	case *ConditionalExpr:
+		return StartPos(n.Condition)
+	case *FuncExpr:
+		if n.LParenPos.Valid() || len(n.Params) == 0 {
+			return n.LParenPos
+		}
+		return StartPos(n.Params[0])
 	default:
 		panic(fmt.Sprintf("Unhandled Node type %T", n))
 	}
@@ -361,6 +403,10 @@ func EndPos(n Node) token.Pos {
 		return EndPos(n.Right)
 	case *ParenExpr:
 		return n.RParenPos
+	case *ConditionalExpr:
+		return EndPos(n.False)
+	case *FuncExpr:
+		return EndPos(n.Body)
 	default:
 		panic(fmt.Sprintf("Unhandled Node type %T", n))
 	}
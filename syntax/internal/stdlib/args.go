@@ -0,0 +1,46 @@
+package stdlib
+
+import (
+	"fmt"
+
+	"github.com/grafana/alloy/syntax/internal/value"
+)
+
+// checkArgCount returns an error if args doesn't have exactly n elements.
+func checkArgCount(name string, args []value.Value, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s: expected %d arguments, got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// checkArgType returns an ArgError if args[index] isn't of the expected
+// type.
+func checkArgType(funcValue value.Value, args []value.Value, index int, expected value.Type) error {
+	if args[index].Type() != expected {
+		return value.ArgError{
+			Function: funcValue,
+			Argument: args[index],
+			Index:    index,
+			Inner: value.TypeError{
+				Value:    args[index],
+				Expected: expected,
+			},
+		}
+	}
+	return nil
+}
+
+// checkStringArgs validates that there are exactly n arguments and that all
+// of them are strings.
+func checkStringArgs(name string, funcValue value.Value, args []value.Value, n int) error {
+	if err := checkArgCount(name, args, n); err != nil {
+		return err
+	}
+	for i := range args {
+		if err := checkArgType(funcValue, args, i, value.TypeString); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package stdlib
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/alloy/syntax/internal/value"
+)
+
+// The functions in this file accept function values (such as function
+// expressions written in the config) and call them once per array element.
+// Any error returned by a called function is propagated unchanged so that it
+// keeps pointing at the expression which caused it.
+
+// arrayMap returns a new array with the result of calling fn on every element
+// of the input array.
+//
+// Inputs:
+// args[0]: array:             input array
+// args[1]: function(elem):    transform function
+var arrayMap = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("map", args, 2); err != nil {
+		return value.Null, err
+	}
+	if err := checkArrayAndFunc(funcValue, args); err != nil {
+		return value.Null, err
+	}
+
+	var (
+		in, fn = args[0], args[1]
+		res    = make([]value.Value, in.Len())
+	)
+	for i := 0; i < in.Len(); i++ {
+		out, err := fn.Call(in.Index(i))
+		if err != nil {
+			return value.Null, err
+		}
+		res[i] = out
+	}
+	return value.Array(res...), nil
+})
+
+// arrayFilter returns a new array with the elements of the input array for
+// which fn returns true.
+//
+// Inputs:
+// args[0]: array:                 input array
+// args[1]: function(elem) bool:   predicate function
+var arrayFilter = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("filter", args, 2); err != nil {
+		return value.Null, err
+	}
+	if err := checkArrayAndFunc(funcValue, args); err != nil {
+		return value.Null, err
+	}
+
+	var (
+		in, fn = args[0], args[1]
+		res    = make([]value.Value, 0, in.Len())
+	)
+	for i := 0; i < in.Len(); i++ {
+		elem := in.Index(i)
+		keep, err := fn.Call(elem)
+		if err != nil {
+			return value.Null, err
+		}
+		if keep.Type() != value.TypeBool {
+			return value.Null, value.ArgError{
+				Function: funcValue,
+				Argument: keep,
+				Index:    1,
+				Inner:    fmt.Errorf("filter: function must return bool, got %s", keep.Type()),
+			}
+		}
+		if keep.Bool() {
+			res = append(res, elem)
+		}
+	}
+	return value.Array(res...), nil
+})
+
+// arrayReduce folds the input array into a single value by calling fn with
+// the accumulated value and each element in turn.
+//
+// Inputs:
+// args[0]: array:                input array
+// args[1]: function(acc, elem):  reducer function
+// args[2]: any:                  initial accumulated value
+var arrayReduce = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("reduce", args, 3); err != nil {
+		return value.Null, err
+	}
+	if err := checkArrayAndFunc(funcValue, args); err != nil {
+		return value.Null, err
+	}
+
+	var (
+		in, fn = args[0], args[1]
+		acc    = args[2]
+	)
+	for i := 0; i < in.Len(); i++ {
+		out, err := fn.Call(acc, in.Index(i))
+		if err != nil {
+			return value.Null, err
+		}
+		acc = out
+	}
+	return acc, nil
+})
+
+// arraySortBy returns a copy of the input array sorted by the key returned by
+// fn for each element. Keys must either all be numbers or all be strings.
+// Elements with equal keys keep their original order.
+//
+// Inputs:
+// args[0]: array:                              input array
+// args[1]: function(elem) number | string:     key function
+var arraySortBy = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("sort_by", args, 2); err != nil {
+		return value.Null, err
+	}
+	if err := checkArrayAndFunc(funcValue, args); err != nil {
+		return value.Null, err
+	}
+
+	var (
+		in, fn = args[0], args[1]
+		elems  = make([]value.Value, in.Len())
+		keys   = make([]value.Value, in.Len())
+	)
+	for i := 0; i < in.Len(); i++ {
+		elems[i] = in.Index(i)
+
+		key, err := fn.Call(elems[i])
+		if err != nil {
+			return value.Null, err
+		}
+		if key.Type() != value.TypeNumber && key.Type() != value.TypeString {
+			return value.Null, value.ArgError{
+				Function: funcValue,
+				Argument: key,
+				Index:    1,
+				Inner:    fmt.Errorf("sort_by: function must return number or string, got %s", key.Type()),
+			}
+		}
+		if i > 0 && key.Type() != keys[0].Type() {
+			return value.Null, value.ArgError{
+				Function: funcValue,
+				Argument: key,
+				Index:    1,
+				Inner:    fmt.Errorf("sort_by: function must return keys of a single type, got %s and %s", keys[0].Type(), key.Type()),
+			}
+		}
+		keys[i] = key
+	}
+
+	sort.Stable(keyedValues{elems: elems, keys: keys})
+	return value.Array(elems...), nil
+})
+
+// arrayUniqueBy returns a copy of the input array which only keeps the first
+// element for each distinct key returned by fn. Keys must be strings, numbers
+// or bools.
+//
+// Inputs:
+// args[0]: array:                                   input array
+// args[1]: function(elem) string | number | bool:   key function
+var arrayUniqueBy = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("unique_by", args, 2); err != nil {
+		return value.Null, err
+	}
+	if err := checkArrayAndFunc(funcValue, args); err != nil {
+		return value.Null, err
+	}
+
+	var (
+		in, fn = args[0], args[1]
+		res    = make([]value.Value, 0, in.Len())
+		seen   = make(map[string]struct{}, in.Len())
+	)
+	for i := 0; i < in.Len(); i++ {
+		elem := in.Index(i)
+		key, err := fn.Call(elem)
+		if err != nil {
+			return value.Null, err
+		}
+
+		// Prefix keys with their type so that values of different types which
+		// share a textual form (such as "1" and 1) remain distinct.
+		var id string
+		switch key.Type() {
+		case value.TypeString:
+			id = "s:" + key.Text()
+		case value.TypeNumber:
+			id = "n:" + key.Number().ToString()
+		case value.TypeBool:
+			id = "b:" + strconv.FormatBool(key.Bool())
+		default:
+			return value.Null, value.ArgError{
+				Function: funcValue,
+				Argument: key,
+				Index:    1,
+				Inner:    fmt.Errorf("unique_by: function must return string, number or bool, got %s", key.Type()),
+			}
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		res = append(res, elem)
+	}
+	return value.Array(res...), nil
+})
+
+// checkArrayAndFunc validates that args[0] is an array and args[1] is a
+// function.
+func checkArrayAndFunc(funcValue value.Value, args []value.Value) error {
+	if err := checkArgType(funcValue, args, 0, value.TypeArray); err != nil {
+		return err
+	}
+	return checkArgType(funcValue, args, 1, value.TypeFunction)
+}
+
+// keyedValues implements sort.Interface for a list of elements sorted by a
+// parallel list of keys. All keys must have the same type.
+type keyedValues struct {
+	elems []value.Value
+	keys  []value.Value
+}
+
+func (kv keyedValues) Len() int { return len(kv.elems) }
+
+func (kv keyedValues) Swap(i, j int) {
+	kv.elems[i], kv.elems[j] = kv.elems[j], kv.elems[i]
+	kv.keys[i], kv.keys[j] = kv.keys[j], kv.keys[i]
+}
+
+func (kv keyedValues) Less(i, j int) bool {
+	return compareKeys(kv.keys[i], kv.keys[j]) < 0
+}
+
+// compareKeys compares two keys of the same type, which must be either
+// numbers or strings.
+func compareKeys(a, b value.Value) int {
+	if a.Type() == value.TypeString {
+		return strings.Compare(a.Text(), b.Text())
+	}
+
+	aNum, bNum := a.Number(), b.Number()
+	switch {
+	case aNum.Kind() == value.NumberKindFloat || bNum.Kind() == value.NumberKindFloat:
+		return cmp.Compare(aNum.Float(), bNum.Float())
+	case aNum.Kind() == value.NumberKindUint && bNum.Kind() == value.NumberKindUint:
+		return cmp.Compare(aNum.Uint(), bNum.Uint())
+	default:
+		return cmp.Compare(aNum.Int(), bNum.Int())
+	}
+}
@@ -0,0 +1,27 @@
+package stdlib
+
+import (
+	"crypto/md5" //nolint:gosec // MD5 is offered for compatibility, not security.
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/grafana/alloy/syntax/internal/value"
+)
+
+// sha256Hex returns the hex-encoded SHA-256 checksum of the input string.
+var sha256Hex = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkStringArgs("sha256", funcValue, args, 1); err != nil {
+		return value.Null, err
+	}
+	sum := sha256.Sum256([]byte(args[0].Text()))
+	return value.String(hex.EncodeToString(sum[:])), nil
+})
+
+// md5Hex returns the hex-encoded MD5 checksum of the input string.
+var md5Hex = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkStringArgs("md5", funcValue, args, 1); err != nil {
+		return value.Null, err
+	}
+	sum := md5.Sum([]byte(args[0].Text())) //nolint:gosec
+	return value.String(hex.EncodeToString(sum[:])), nil
+})
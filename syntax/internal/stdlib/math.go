@@ -0,0 +1,61 @@
+package stdlib
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/grafana/alloy/syntax/internal/value"
+)
+
+// mathMin returns the smallest of one or more numbers.
+var mathMin = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	return pickNumber("min", funcValue, args, func(cmp int) bool { return cmp < 0 })
+})
+
+// mathMax returns the largest of one or more numbers.
+var mathMax = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	return pickNumber("max", funcValue, args, func(cmp int) bool { return cmp > 0 })
+})
+
+// pickNumber returns the argument which wins against every other argument
+// according to better. The original value is returned so its number kind is
+// preserved.
+func pickNumber(name string, funcValue value.Value, args []value.Value, better func(cmp int) bool) (value.Value, error) {
+	if len(args) == 0 {
+		return value.Null, fmt.Errorf("%s: expected at least 1 argument, got 0", name)
+	}
+
+	var res value.Value
+	for i := range args {
+		if err := checkArgType(funcValue, args, i, value.TypeNumber); err != nil {
+			return value.Null, err
+		}
+		if i == 0 || better(compareKeys(args[i], res)) {
+			res = args[i]
+		}
+	}
+	return res, nil
+}
+
+// mathFloor returns the greatest integer less than or equal to the input
+// number.
+var mathFloor = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("floor", args, 1); err != nil {
+		return value.Null, err
+	}
+	if err := checkArgType(funcValue, args, 0, value.TypeNumber); err != nil {
+		return value.Null, err
+	}
+
+	num := args[0].Number()
+	if num.Kind() != value.NumberKindFloat {
+		// Integers are already floored.
+		return args[0], nil
+	}
+
+	floored := math.Floor(num.Float())
+	if floored < math.MinInt64 || floored >= math.MaxInt64 || math.IsNaN(floored) {
+		return value.Float(floored), nil
+	}
+	return value.Int(int64(floored)), nil
+})
@@ -0,0 +1,81 @@
+package stdlib
+
+import (
+	"regexp"
+
+	"github.com/grafana/alloy/syntax/internal/value"
+)
+
+// compileRegexArg compiles args[index] as a regular expression. An ArgError
+// is returned if the pattern is invalid.
+func compileRegexArg(funcValue value.Value, args []value.Value, index int) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(args[index].Text())
+	if err != nil {
+		return nil, value.ArgError{
+			Function: funcValue,
+			Argument: args[index],
+			Index:    index,
+			Inner:    err,
+		}
+	}
+	return re, nil
+}
+
+// regexMatch reports whether the input string contains any match of the
+// regular expression.
+//
+// Inputs:
+// args[0]: string: input string
+// args[1]: string: regular expression
+var regexMatch = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkStringArgs("regex_match", funcValue, args, 2); err != nil {
+		return value.Null, err
+	}
+	re, err := compileRegexArg(funcValue, args, 1)
+	if err != nil {
+		return value.Null, err
+	}
+	return value.Bool(re.MatchString(args[0].Text())), nil
+})
+
+// regexReplace replaces all matches of the regular expression in the input
+// string with the replacement. The replacement may refer to capture groups
+// using $1 or ${name}.
+//
+// Inputs:
+// args[0]: string: input string
+// args[1]: string: regular expression
+// args[2]: string: replacement
+var regexReplace = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkStringArgs("regex_replace", funcValue, args, 3); err != nil {
+		return value.Null, err
+	}
+	re, err := compileRegexArg(funcValue, args, 1)
+	if err != nil {
+		return value.Null, err
+	}
+	return value.String(re.ReplaceAllString(args[0].Text(), args[2].Text())), nil
+})
+
+// regexFindAll returns all successive matches of the regular expression in
+// the input string.
+//
+// Inputs:
+// args[0]: string: input string
+// args[1]: string: regular expression
+var regexFindAll = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkStringArgs("regex_find_all", funcValue, args, 2); err != nil {
+		return value.Null, err
+	}
+	re, err := compileRegexArg(funcValue, args, 1)
+	if err != nil {
+		return value.Null, err
+	}
+
+	matches := re.FindAllString(args[0].Text(), -1)
+	res := make([]value.Value, len(matches))
+	for i, m := range matches {
+		res[i] = value.String(m)
+	}
+	return value.Array(res...), nil
+})
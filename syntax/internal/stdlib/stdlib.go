@@ -24,6 +24,25 @@ import (
 var ExperimentalIdentifiers = map[string]bool{
 	"array.combine_maps": true,
 	"array.group_by":     true,
+	"array.map":          true,
+	"array.filter":       true,
+	"array.reduce":       true,
+	"array.sort_by":      true,
+	"array.unique_by":    true,
+
+	"encoding.base64_decode": true,
+	"encoding.base64_encode": true,
+	"encoding.md5":           true,
+	"encoding.sha256":        true,
+	"math.floor":             true,
+	"math.max":               true,
+	"math.min":               true,
+	"string.regex_find_all":  true,
+	"string.regex_match":     true,
+	"string.regex_replace":   true,
+	"time.format":            true,
+	"time.now":               true,
+	"time.parse_duration":    true,
 }
 
 // DeprecatedIdentifiers are deprecated in favour of the namespaced ones.
@@ -64,6 +83,8 @@ var Identifiers = map[string]any{
 	"encoding": encoding,
 	"string":   str,
 	"file":     file,
+	"time":     timeNamespace,
+	"math":     mathNamespace,
 }
 
 func init() {
@@ -85,6 +106,10 @@ var encoding = map[string]any{
 	"to_URLbase64":   base64URLEncode,
 	"url_encode":     urlEncode,
 	"url_decode":     urlDecode,
+	"base64_encode":  base64Encode,
+	"base64_decode":  base64Decode,
+	"sha256":         sha256Hex,
+	"md5":            md5Hex,
 }
 
 var str = map[string]any{
@@ -98,6 +123,22 @@ var str = map[string]any{
 	"trim_prefix": strings.TrimPrefix,
 	"trim_suffix": strings.TrimSuffix,
 	"trim_space":  strings.TrimSpace,
+
+	"regex_match":    regexMatch,
+	"regex_replace":  regexReplace,
+	"regex_find_all": regexFindAll,
+}
+
+var timeNamespace = map[string]any{
+	"now":            timeNow,
+	"format":         timeFormat,
+	"parse_duration": timeParseDuration,
+}
+
+var mathNamespace = map[string]any{
+	"min":   mathMin,
+	"max":   mathMax,
+	"floor": mathFloor,
 }
 
 // groupBy takes an array of objects, a key to group by, and a boolean to determine
@@ -209,6 +250,11 @@ var array = map[string]any{
 	"concat":       concat,
 	"combine_maps": combineMaps,
 	"group_by":     groupBy,
+	"map":          arrayMap,
+	"filter":       arrayFilter,
+	"reduce":       arrayReduce,
+	"sort_by":      arraySortBy,
+	"unique_by":    arrayUniqueBy,
 }
 
 var convert = map[string]any{
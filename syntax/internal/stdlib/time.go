@@ -0,0 +1,77 @@
+package stdlib
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/grafana/alloy/syntax/internal/value"
+)
+
+// timeNow returns the current time in UTC as an RFC 3339 string.
+var timeNow = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("now", args, 0); err != nil {
+		return value.Null, err
+	}
+	return value.String(time.Now().UTC().Format(time.RFC3339Nano)), nil
+})
+
+// timeFormat formats a timestamp using a Go time layout. The timestamp is
+// either an RFC 3339 string or a number of seconds since the Unix epoch.
+//
+// Inputs:
+// args[0]: string | number: timestamp
+// args[1]: string:          layout
+var timeFormat = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkArgCount("format", args, 2); err != nil {
+		return value.Null, err
+	}
+	if err := checkArgType(funcValue, args, 1, value.TypeString); err != nil {
+		return value.Null, err
+	}
+
+	var ts time.Time
+	switch args[0].Type() {
+	case value.TypeString:
+		parsed, err := time.Parse(time.RFC3339Nano, args[0].Text())
+		if err != nil {
+			return value.Null, value.ArgError{
+				Function: funcValue,
+				Argument: args[0],
+				Index:    0,
+				Inner:    fmt.Errorf("format: timestamp must be in RFC 3339 format: %w", err),
+			}
+		}
+		ts = parsed
+	case value.TypeNumber:
+		sec, frac := math.Modf(args[0].Float())
+		ts = time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC()
+	default:
+		return value.Null, value.ArgError{
+			Function: funcValue,
+			Argument: args[0],
+			Index:    0,
+			Inner:    fmt.Errorf("format: timestamp must be a string or number, got %s", args[0].Type()),
+		}
+	}
+
+	return value.String(ts.Format(args[1].Text())), nil
+})
+
+// timeParseDuration parses a duration string such as "1h30m" and returns the
+// duration as a number of seconds.
+var timeParseDuration = value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+	if err := checkStringArgs("parse_duration", funcValue, args, 1); err != nil {
+		return value.Null, err
+	}
+	d, err := time.ParseDuration(args[0].Text())
+	if err != nil {
+		return value.Null, value.ArgError{
+			Function: funcValue,
+			Argument: args[0],
+			Index:    0,
+			Inner:    err,
+		}
+	}
+	return value.Float(d.Seconds()), nil
+})
@@ -35,6 +35,11 @@ type parser struct {
 	// Position of the last error written. Two parse errors on the same line are
 	// ignored.
 	lastError token.Position
+
+	// keepBlocks makes the parser skip invalid statements up to their end
+	// rather than up to the next identifier, so that the closing braces of
+	// the enclosing blocks are kept. It's used to build partial ASTs.
+	keepBlocks bool
 }
 
 // newParser creates a new parser which will parse the provided src.
@@ -301,6 +306,14 @@ func (p *parser) parseStatement() ast.Stmt {
 			p.addErrorf("expected block body, got %s", p.tok)
 		}
 
+		if p.keepBlocks {
+			// Give up on this statement and skip to its end.
+			if p.tok != token.RCURLY {
+				p.consumeStatement()
+			}
+			return nil
+		}
+
 		// Give up on this statement and skip to the next identifier.
 		p.advance(token.IDENT)
 		return nil
@@ -356,6 +369,9 @@ func (p *parser) parseBlockName() *blockName {
 			bn.LabelPos = p.pos
 		} else {
 			p.addErrorf("expected block label, got %s", p.tok)
+			if p.keepBlocks && (p.tok == token.TERMINATOR || p.tok == token.RCURLY) {
+				return &bn
+			}
 		}
 		p.next()
 	}
@@ -379,9 +395,36 @@ func (n blockName) ValidAttribute() bool {
 
 // ParseExpression parses a single expression.
 //
-//	Expression = BinOpExpr
+//	Expression = CondExpr
 func (p *parser) ParseExpression() ast.Expr {
-	return p.parseBinOp(1)
+	return p.parseCondExpr()
+}
+
+// parseCondExpr parses a conditional expression. If there is no "?" following
+// the condition, the condition is returned instead.
+//
+//	CondExpr = BinOpExpr [ "?" Expression ":" Expression ]
+//
+// The conditional operator has the lowest precedence and is
+// right-associative, so a ? b : c ? d : e is parsed as a ? b : (c ? d : e).
+func (p *parser) parseCondExpr() ast.Expr {
+	cond := p.parseBinOp(1)
+	if p.tok != token.QUESTION {
+		return cond
+	}
+
+	questionPos, _, _ := p.expect(token.QUESTION)
+	trueExpr := p.ParseExpression()
+	colonPos, _, _ := p.expect(token.COLON)
+	falseExpr := p.ParseExpression()
+
+	return &ast.ConditionalExpr{
+		Condition:   cond,
+		QuestionPos: questionPos,
+		True:        trueExpr,
+		ColonPos:    colonPos,
+		False:       falseExpr,
+	}
 }
 
 // parseBinOp is the entrypoint for binary expressions. If there is no binary
@@ -569,13 +612,14 @@ func isUnaryOp(tok token.Token) bool {
 
 // parsePrimaryExpr parses a primary expression.
 //
-//	PrimaryExpr = LiteralValue | ArrayExpr | ObjectExpr
+//	PrimaryExpr = LiteralValue | ArrayExpr | ObjectExpr | FuncExpr
 //
 //	LiteralValue = identifier | string | number | float | bool | null |
 //	               "(" Expression ")"
 //
 //	ArrayExpr  = "[" [ ExpressionList ] "]"
 //	ObjectExpr = "{" [ FieldList ] "}"
+//	FuncExpr   = ( identifier | "(" [ IdentList ] ")" ) "=>" Expression
 func (p *parser) parsePrimaryExpr() ast.Expr {
 	switch p.tok {
 	case token.IDENT:
@@ -586,6 +630,10 @@ func (p *parser) parsePrimaryExpr() ast.Expr {
 			},
 		}
 		p.next()
+
+		if p.tok == token.ARROW {
+			return p.parseFuncBody(&ast.FuncExpr{Params: []*ast.Ident{res.Ident}})
+		}
 		return res
 
 	case token.STRING, token.NUMBER, token.FLOAT, token.BOOL, token.NULL:
@@ -598,15 +646,7 @@ func (p *parser) parsePrimaryExpr() ast.Expr {
 		return res
 
 	case token.LPAREN:
-		lParen, _, _ := p.expect(token.LPAREN)
-		expr := p.ParseExpression()
-		rParen, _, _ := p.expect(token.RPAREN)
-
-		return &ast.ParenExpr{
-			LParenPos: lParen,
-			Inner:     expr,
-			RParenPos: rParen,
-		}
+		return p.parseParenOrFuncExpr()
 
 	case token.LBRACK:
 		var res ast.ArrayExpr
@@ -635,6 +675,91 @@ func (p *parser) parsePrimaryExpr() ast.Expr {
 	return res
 }
 
+// parseParenOrFuncExpr parses either a parenthesized expression or a function
+// expression with a parenthesized parameter list. Both start with "(", so the
+// contents are parsed as an expression list and then checked against the
+// token which follows the closing ")".
+//
+//	ParenOrFuncExpr = "(" Expression ")" |
+//	                  "(" [ IdentList ] ")" "=>" Expression
+func (p *parser) parseParenOrFuncExpr() ast.Expr {
+	lParen, _, _ := p.expect(token.LPAREN)
+
+	var exprs []ast.Expr
+	if p.tok != token.RPAREN {
+		exprs = p.parseExpressionList(token.RPAREN)
+	}
+	rParen, _, _ := p.expect(token.RPAREN)
+
+	if p.tok == token.ARROW {
+		fn := &ast.FuncExpr{
+			LParenPos: lParen,
+			RParenPos: rParen,
+		}
+		for _, expr := range exprs {
+			ident, ok := expr.(*ast.IdentifierExpr)
+			if !ok {
+				p.diags.Add(diag.Diagnostic{
+					Severity: diag.SeverityLevelError,
+					StartPos: ast.StartPos(expr).Position(),
+					EndPos:   ast.EndPos(expr).Position(),
+					Message:  "expected identifier for function parameter",
+				})
+				continue
+			}
+			fn.Params = append(fn.Params, ident.Ident)
+		}
+		return p.parseFuncBody(fn)
+	}
+
+	switch {
+	case len(exprs) == 0:
+		p.diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			StartPos: rParen.Position(),
+			Message:  fmt.Sprintf("expected expression, got %s", token.RPAREN),
+		})
+		return &ast.LiteralExpr{Kind: token.NULL, Value: "null", ValuePos: lParen}
+	case len(exprs) > 1:
+		p.diags.Add(diag.Diagnostic{
+			Severity: diag.SeverityLevelError,
+			StartPos: lParen.Position(),
+			EndPos:   rParen.Position(),
+			Message:  `expected a single expression in parentheses or a function parameter list followed by "=>"`,
+		})
+	}
+
+	return &ast.ParenExpr{
+		LParenPos: lParen,
+		Inner:     exprs[0],
+		RParenPos: rParen,
+	}
+}
+
+// parseFuncBody parses the remainder of a function expression after its
+// parameter list, filling in fn.
+//
+//	FuncBody = "=>" Expression
+func (p *parser) parseFuncBody(fn *ast.FuncExpr) ast.Expr {
+	fn.ArrowPos, _, _ = p.expect(token.ARROW)
+
+	seen := make(map[string]struct{}, len(fn.Params))
+	for _, param := range fn.Params {
+		if _, dup := seen[param.Name]; dup {
+			p.diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				StartPos: ast.StartPos(param).Position(),
+				EndPos:   ast.EndPos(param).Position(),
+				Message:  fmt.Sprintf("duplicate function parameter %q", param.Name),
+			})
+		}
+		seen[param.Name] = struct{}{}
+	}
+
+	fn.Body = p.ParseExpression()
+	return fn
+}
+
 var statementEnd = map[token.Token]struct{}{
 	token.TERMINATOR: {},
 	token.RPAREN:     {},
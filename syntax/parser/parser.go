@@ -22,6 +22,27 @@ func ParseFile(filename string, data []byte) (*ast.File, error) {
 	return f, nil
 }
 
+// ParseFilePartial parses an entire Alloy configuration file like ParseFile,
+// but always returns the AST of the statements which could be parsed. It is
+// meant for tools, such as language servers, which work on files that are
+// being edited.
+//
+// If an error was encountered during parsing, err will be an diag.Diagnostics
+// with all the errors encountered during parsing. Statements which couldn't
+// be parsed are missing from the returned AST, without affecting the blocks
+// which enclose or follow them. Blocks which aren't closed end at the end of
+// the file.
+func ParseFilePartial(filename string, data []byte) (*ast.File, error) {
+	p := newParser(filename, data)
+	p.keepBlocks = true
+
+	f := p.ParseFile()
+	if len(p.diags) > 0 {
+		return f, p.diags
+	}
+	return f, nil
+}
+
 // ParseExpression parses a single Alloy expression from expr.
 //
 // If an error was encountered during parsing, the returned expression will be
@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alloy/syntax/ast"
 )
 
 func FuzzParser(f *testing.F) {
@@ -108,6 +110,19 @@ func TestParseExpressions(t *testing.T) {
 
 		"parens": `(1 + 5) * 100`,
 
+		"conditional":        `a == 1 ? "one" : "other"`,
+		"nested conditional": `a ? b : c ? d : e`,
+		"conditional multiline": `a ?
+			b :
+			c`,
+
+		"function single param":    `x => x + 1`,
+		"function parens":          `(x) => x + 1`,
+		"function no params":       `() => 5`,
+		"function multiple params": `(acc, x) => acc + x`,
+		"function as argument":     `array.map(xs, x => x * 2)`,
+		"function returning func":  `x => y => x + y`,
+
 		"mixed expression": `(a.b.c)(1, 3 * some_list[magic_index * 2]).resulting_field`,
 	}
 
@@ -121,3 +136,28 @@ func TestParseExpressions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFilePartial(t *testing.T) {
+	input := `a "one" {
+	b = 1
+	invalid
+}
+
+c "two" {
+	d = [e.]
+`
+
+	res, err := ParseFilePartial("partial", []byte(input))
+	require.Error(t, err)
+	require.Len(t, res.Body, 2)
+
+	one := res.Body[0].(*ast.BlockStmt)
+	require.Equal(t, "one", one.Label)
+	require.Len(t, one.Body, 1)
+	require.True(t, one.RCurlyPos.Valid())
+
+	two := res.Body[1].(*ast.BlockStmt)
+	require.Equal(t, "two", two.Label)
+	require.Len(t, two.Body, 1)
+	require.Equal(t, len(input), two.RCurlyPos.Offset())
+}
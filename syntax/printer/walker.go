@@ -179,6 +179,12 @@ func (w *walker) walkExpr(e ast.Expr) {
 		w.p.Write(token.LPAREN)
 		w.walkExpr(e.Inner)
 		w.p.Write(token.RPAREN)
+
+	case *ast.ConditionalExpr:
+		w.walkConditionalExpr(e)
+
+	case *ast.FuncExpr:
+		w.walkFuncExpr(e)
 	}
 }
 
@@ -332,6 +338,50 @@ func (w *walker) walkCallExpr(e *ast.CallExpr) {
 	w.p.Write(token.RPAREN)
 }
 
+func (w *walker) walkConditionalExpr(e *ast.ConditionalExpr) {
+	w.walkExpr(e.Condition)
+	w.p.Write(wsBlank, e.QuestionPos, token.QUESTION)
+	w.walkConditionalBranch(e.QuestionPos, e.True)
+	w.p.Write(wsBlank, e.ColonPos, token.COLON)
+	w.walkConditionalBranch(e.ColonPos, e.False)
+}
+
+// walkConditionalBranch writes a branch of a conditional expression which
+// follows the operator at opPos. Branches which start on a new line in the
+// source are kept on a new line and indented.
+func (w *walker) walkConditionalBranch(opPos token.Pos, e ast.Expr) {
+	if !differentLines(opPos, ast.StartPos(e)) {
+		w.p.Write(wsBlank)
+		w.walkExpr(e)
+		return
+	}
+
+	w.p.Write(wsIndent, wsFormfeed)
+	w.walkExpr(e)
+	w.p.Write(wsUnindent)
+}
+
+func (w *walker) walkFuncExpr(e *ast.FuncExpr) {
+	// A single parameter may be written without parentheses; keep the form
+	// used in the source.
+	parens := e.LParenPos.Valid() || len(e.Params) != 1
+	if parens {
+		w.p.Write(e.LParenPos, token.LPAREN)
+	}
+	for i, param := range e.Params {
+		w.p.Write(param.NamePos, param)
+		if i+1 < len(e.Params) {
+			w.p.Write(token.COMMA, wsBlank)
+		}
+	}
+	if parens {
+		w.p.Write(e.RParenPos, token.RPAREN)
+	}
+
+	w.p.Write(wsBlank, e.ArrowPos, token.ARROW, wsBlank)
+	w.walkExpr(e.Body)
+}
+
 // differentLines returns true if a and b are on different lines.
 func differentLines(a, b token.Pos) bool {
 	return a.Position().Line != b.Position().Line
@@ -69,6 +69,9 @@ var tokens = []tokenExample{
 	{token.LCURLY, "{"},
 	{token.COMMA, ","},
 	{token.DOT, "."},
+	{token.QUESTION, "?"},
+	{token.COLON, ":"},
+	{token.ARROW, "=>"},
 
 	{token.RPAREN, ")"},
 	{token.RBRACK, "]"},
@@ -57,6 +57,10 @@ const (
 	RBRACK // ]
 	COMMA  // ,
 	DOT    // .
+
+	QUESTION // ?
+	COLON    // :
+	ARROW    // =>
 	operatorEnd
 
 	TERMINATOR // \n
@@ -103,6 +107,10 @@ var tokenNames = [...]string{
 	COMMA:  ",",
 	DOT:    ".",
 
+	QUESTION: "?",
+	COLON:    ":",
+	ARROW:    "=>",
+
 	TERMINATOR: "TERMINATOR",
 }
 
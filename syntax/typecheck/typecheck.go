@@ -19,10 +19,19 @@ type structState struct {
 
 func Block(b *ast.BlockStmt, args any) diag.Diagnostics {
 	rv := reflectutil.DeferencePointer(reflect.ValueOf(args))
-	return block(b, rv)
+	return block(b, rv, nil)
 }
 
-func block(b *ast.BlockStmt, rv reflect.Value) diag.Diagnostics {
+// BlockWithTypes is like Block, but it also checks that the value assigned to
+// each attribute has a type compatible with the attribute. The types of
+// references to other values are provided by resolve. Expressions whose type
+// can't be inferred statically are not checked.
+func BlockWithTypes(b *ast.BlockStmt, args any, resolve TypeResolver) diag.Diagnostics {
+	rv := reflectutil.DeferencePointer(reflect.ValueOf(args))
+	return block(b, rv, resolve)
+}
+
+func block(b *ast.BlockStmt, rv reflect.Value, resolve TypeResolver) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	switch rv.Kind() {
@@ -56,9 +65,9 @@ func block(b *ast.BlockStmt, rv reflect.Value) diag.Diagnostics {
 		for _, stmt := range b.Body {
 			switch n := stmt.(type) {
 			case *ast.BlockStmt:
-				diags.Merge(checkStructBlock(&s, n, rv))
+				diags.Merge(checkStructBlock(&s, n, rv, resolve))
 			case *ast.AttributeStmt:
-				diags.Merge(checkStructAttr(&s, n, rv))
+				diags.Merge(checkStructAttr(&s, n, rv, resolve))
 			default:
 				panic(fmt.Sprintf("syntax/vm: unrecognized node type %T", stmt))
 			}
@@ -115,10 +124,10 @@ func checkMapBlock(b *ast.BlockStmt, _ reflect.Value) diag.Diagnostics {
 	return diags
 }
 
-func checkStructBlock(s *structState, b *ast.BlockStmt, rv reflect.Value) diag.Diagnostics {
+func checkStructBlock(s *structState, b *ast.BlockStmt, rv reflect.Value, resolve TypeResolver) diag.Diagnostics {
 	name := b.GetBlockName()
 	if _, ok := s.tags.EnumLookup[name]; ok {
-		return checkStructEnum(s, b, rv)
+		return checkStructEnum(s, b, rv, resolve)
 	}
 
 	tag, ok := s.tags.TagLookup[name]
@@ -144,7 +153,7 @@ func checkStructBlock(s *structState, b *ast.BlockStmt, rv reflect.Value) diag.D
 	case reflect.Slice:
 		// NOTE: we do not need to store any values so we can always set len and cap to 1 and reuse the same slot
 		field.Set(reflect.MakeSlice(field.Type(), 1, 1))
-		return block(b, reflectutil.DeferencePointer(field.Index(0)))
+		return block(b, reflectutil.DeferencePointer(field.Index(0)), resolve)
 	case reflect.Array:
 		if field.Len() != s.blockCount[name] {
 			return diag.Diagnostics{{
@@ -160,7 +169,7 @@ func checkStructBlock(s *structState, b *ast.BlockStmt, rv reflect.Value) diag.D
 			}}
 		}
 
-		return block(b, reflectutil.DeferencePointer(field.Index(0)))
+		return block(b, reflectutil.DeferencePointer(field.Index(0)), resolve)
 	default:
 		if s.blockCount[name] > 1 {
 			return diag.Diagnostics{{
@@ -170,11 +179,11 @@ func checkStructBlock(s *structState, b *ast.BlockStmt, rv reflect.Value) diag.D
 				Message:  fmt.Sprintf("block %q may only be specified once", name),
 			}}
 		}
-		return block(b, reflectutil.DeferencePointer(field))
+		return block(b, reflectutil.DeferencePointer(field), resolve)
 	}
 }
 
-func checkStructEnum(s *structState, b *ast.BlockStmt, rv reflect.Value) diag.Diagnostics {
+func checkStructEnum(s *structState, b *ast.BlockStmt, rv reflect.Value, resolve TypeResolver) diag.Diagnostics {
 	tf, ok := s.tags.EnumLookup[b.GetBlockName()]
 	if !ok {
 		panic("checkEnum called with a non-enum block")
@@ -189,10 +198,10 @@ func checkStructEnum(s *structState, b *ast.BlockStmt, rv reflect.Value) diag.Di
 
 	elem := reflectutil.DeferencePointer(field.Index(0))
 
-	return block(b, reflectutil.DeferencePointer(reflectutil.GetOrAlloc(elem, tf.BlockField)))
+	return block(b, reflectutil.DeferencePointer(reflectutil.GetOrAlloc(elem, tf.BlockField)), resolve)
 }
 
-func checkStructAttr(s *structState, a *ast.AttributeStmt, _ reflect.Value) diag.Diagnostics {
+func checkStructAttr(s *structState, a *ast.AttributeStmt, rv reflect.Value, resolve TypeResolver) diag.Diagnostics {
 	tf, ok := s.tags.TagLookup[a.Name.Name]
 	if !ok {
 		return diag.Diagnostics{{
@@ -220,5 +229,9 @@ func checkStructAttr(s *structState, a *ast.AttributeStmt, _ reflect.Value) diag
 	}
 
 	s.seenAttrs[a.Name.Name] = struct{}{}
-	return nil
+
+	if resolve == nil {
+		return nil
+	}
+	return checkExpr(a.Value, reflectutil.GetOrAlloc(rv, tf).Type(), resolve)
 }
@@ -0,0 +1,371 @@
+package typecheck
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/diag"
+	"github.com/grafana/alloy/syntax/internal/tagcache"
+	"github.com/grafana/alloy/syntax/internal/value"
+	"github.com/grafana/alloy/syntax/token"
+)
+
+var (
+	goAny                    = reflect.TypeFor[any]()
+	goString                 = reflect.TypeFor[string]()
+	goByteSlice              = reflect.TypeFor[[]byte]()
+	goDuration               = reflect.TypeFor[time.Duration]()
+	goTextUnmarshaler        = reflect.TypeFor[encoding.TextUnmarshaler]()
+	goAlloyDecoder           = reflect.TypeFor[value.Unmarshaler]()
+	goConvertibleIntoCapsule = reflect.TypeFor[value.ConvertibleIntoCapsule]()
+	goConvertibleFromCapsule = reflect.TypeFor[value.ConvertibleFromCapsule]()
+)
+
+// TypeResolver returns the Go type of the value named by path, for example
+// ["prometheus", "remote_write", "default"] for the exports of a component.
+// It returns false if path doesn't name a value with a known type.
+//
+// Fields accessed after the resolved value, such as "receiver" in
+// prometheus.remote_write.default.receiver, are looked up by the type checker.
+type TypeResolver func(path []string) (reflect.Type, bool)
+
+// ExprType returns the static Go type of expr. References to other values are
+// resolved with resolve. ExprType returns false if the type of expr can't be
+// inferred without evaluating it.
+func ExprType(expr ast.Expr, resolve TypeResolver) (reflect.Type, bool) {
+	switch expr := expr.(type) {
+	case *ast.LiteralExpr:
+		switch expr.Kind {
+		case token.STRING:
+			return goString, true
+		case token.NUMBER:
+			return reflect.TypeFor[int](), true
+		case token.FLOAT:
+			return reflect.TypeFor[float64](), true
+		case token.BOOL:
+			return reflect.TypeFor[bool](), true
+		}
+		// Null values can be assigned to anything.
+		return nil, false
+
+	case *ast.ArrayExpr:
+		return reflect.SliceOf(commonType(expr.Elements, resolve)), true
+
+	case *ast.ObjectExpr:
+		values := make([]ast.Expr, 0, len(expr.Fields))
+		for _, f := range expr.Fields {
+			values = append(values, f.Value)
+		}
+		return reflect.MapOf(goString, commonType(values, resolve)), true
+
+	case *ast.ParenExpr:
+		return ExprType(expr.Inner, resolve)
+
+	case *ast.IdentifierExpr, *ast.AccessExpr:
+		path, ok := accessPath(expr)
+		if !ok || resolve == nil {
+			return nil, false
+		}
+		for i := 1; i <= len(path); i++ {
+			if t, ok := resolve(path[:i]); ok {
+				return fieldType(t, path[i:])
+			}
+		}
+		return nil, false
+
+	case *ast.IndexExpr:
+		t, ok := ExprType(expr.Value, resolve)
+		if !ok {
+			return nil, false
+		}
+		t = deref(t)
+		switch {
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+			return t.Elem(), true
+		case t.Kind() == reflect.Map && t.Key() == goString:
+			return t.Elem(), true
+		}
+		return nil, false
+
+	case *ast.UnaryExpr:
+		if expr.Kind == token.NOT {
+			return reflect.TypeFor[bool](), true
+		}
+		return nil, false
+
+	case *ast.BinaryExpr:
+		switch expr.Kind {
+		case token.OR, token.AND, token.EQ, token.NEQ, token.LT, token.LTE, token.GT, token.GTE:
+			return reflect.TypeFor[bool](), true
+		}
+		return nil, false
+	}
+
+	return nil, false
+}
+
+// commonType returns the type shared by all exprs, or any if their types
+// differ or can't be inferred.
+func commonType(exprs []ast.Expr, resolve TypeResolver) reflect.Type {
+	var common reflect.Type
+	for _, e := range exprs {
+		t, ok := ExprType(e, resolve)
+		if !ok || (common != nil && t != common) {
+			return goAny
+		}
+		common = t
+	}
+	if common == nil {
+		return goAny
+	}
+	return common
+}
+
+// accessPath returns the names of an uninterrupted chain of identifiers and
+// field accesses, such as prometheus.remote_write.default.receiver.
+func accessPath(expr ast.Expr) ([]string, bool) {
+	switch expr := expr.(type) {
+	case *ast.IdentifierExpr:
+		return []string{expr.Ident.Name}, true
+	case *ast.AccessExpr:
+		path, ok := accessPath(expr.Value)
+		if !ok {
+			return nil, false
+		}
+		return append(path, expr.Name.Name), true
+	}
+	return nil, false
+}
+
+// fieldType returns the type of the value reached by accessing names from a
+// value of type t.
+func fieldType(t reflect.Type, names []string) (reflect.Type, bool) {
+	for _, name := range names {
+		t = deref(t)
+		switch {
+		case t.Kind() == reflect.Struct && value.AlloyType(t) == value.TypeObject:
+			tf, ok := tagcache.Get(t).TagLookup[name]
+			if !ok {
+				return nil, false
+			}
+			t = t.FieldByIndex(tf.Index).Type
+		case t.Kind() == reflect.Map && t.Key() == goString:
+			t = t.Elem()
+		default:
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// checkExpr checks that expr can be assigned to a value of type into.
+// Array and object expressions are checked element by element so that
+// diagnostics point to the offending element.
+func checkExpr(expr ast.Expr, into reflect.Type, resolve TypeResolver) diag.Diagnostics {
+	target := deref(into)
+
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		return checkExpr(expr.Inner, into, resolve)
+
+	case *ast.ArrayExpr:
+		if (target.Kind() == reflect.Slice || target.Kind() == reflect.Array) && value.AlloyType(into) == value.TypeArray && !hasCustomDecoding(into) {
+			var diags diag.Diagnostics
+			for _, e := range expr.Elements {
+				diags.Merge(checkExpr(e, target.Elem(), resolve))
+			}
+			return diags
+		}
+
+	case *ast.ObjectExpr:
+		if hasCustomDecoding(into) {
+			break
+		}
+		switch {
+		case target.Kind() == reflect.Map && target.Key() == goString:
+			var diags diag.Diagnostics
+			for _, f := range expr.Fields {
+				diags.Merge(checkExpr(f.Value, target.Elem(), resolve))
+			}
+			return diags
+		case target.Kind() == reflect.Struct && value.AlloyType(target) == value.TypeObject:
+			// Unknown keys are reported when the object is decoded; only the
+			// types of known keys are checked here.
+			tags := tagcache.Get(target)
+			var diags diag.Diagnostics
+			for _, f := range expr.Fields {
+				if tf, ok := tags.TagLookup[f.Name.Name]; ok && tf.IsAttr() {
+					diags.Merge(checkExpr(f.Value, target.FieldByIndex(tf.Index).Type, resolve))
+				}
+			}
+			return diags
+		}
+	}
+
+	from, ok := ExprType(expr, resolve)
+	if !ok || assignable(from, into) {
+		return nil
+	}
+	return diag.Diagnostics{{
+		Severity: diag.SeverityLevelError,
+		StartPos: ast.StartPos(expr).Position(),
+		EndPos:   ast.EndPos(expr).Position(),
+		Message:  fmt.Sprintf("expected %s, got %s", describe(into), describe(from)),
+	}}
+}
+
+// assignable reports whether a value of type from may be decoded into a
+// value of type into. It errs on the side of reporting true when the outcome
+// depends on the value itself.
+func assignable(from, into reflect.Type) bool {
+	fromType, intoType := deref(from), deref(into)
+
+	switch {
+	case fromType == intoType:
+		return true
+	case intoType == goAny || fromType == goAny:
+		return true
+	case fromType.Kind() == reflect.Interface:
+		// The dynamic type of an interface value is only known at runtime, but
+		// it must implement the interface. Capsules can only hold it if the
+		// target type is compatible with that interface.
+		if value.AlloyType(into) != value.TypeCapsule {
+			return true
+		}
+		if intoType.Kind() == reflect.Interface {
+			return fromType.Implements(intoType)
+		}
+		return implements(intoType, fromType)
+	case hasCustomDecoding(into):
+		if !implements(into, goTextUnmarshaler) && intoType != goDuration {
+			return true
+		}
+		// Text unmarshalers and durations are decoded from strings.
+		into, intoType = goString, goString
+	}
+
+	if converted, known := capsuleConversion(from, into); known {
+		return converted
+	}
+
+	fromAlloy, intoAlloy := value.AlloyType(from), value.AlloyType(into)
+	if fromAlloy != intoAlloy {
+		switch {
+		case fromAlloy == value.TypeNumber && intoAlloy == value.TypeString,
+			fromAlloy == value.TypeString && intoAlloy == value.TypeNumber:
+			return true
+		case fromType == goByteSlice && intoType == goString,
+			fromType == goString && intoType == goByteSlice:
+			return true
+		}
+		return false
+	}
+
+	switch intoAlloy {
+	case value.TypeArray:
+		if isList(fromType) && isList(intoType) {
+			return assignable(fromType.Elem(), intoType.Elem())
+		}
+	case value.TypeObject:
+		if fromType.Kind() == reflect.Map && intoType.Kind() == reflect.Map {
+			return assignable(fromType.Elem(), intoType.Elem())
+		}
+	case value.TypeCapsule:
+		if intoType.Kind() == reflect.Interface {
+			return from.Implements(intoType) || reflect.PointerTo(fromType).Implements(intoType)
+		}
+		return false
+	}
+	return true
+}
+
+// capsuleConversion reports whether a value of type from can be converted
+// into a value of type into through the capsule conversion interfaces. The
+// conversion is attempted with zero values; known is false if neither type
+// offers a conversion for the pair.
+//
+// Conversions from a value may only fail because of the target type, so their
+// errors are reported as incompatible types. Errors converting into a value
+// usually depend on the value being converted and are ignored.
+func capsuleConversion(from, into reflect.Type) (converted bool, known bool) {
+	defer func() {
+		// Conversions of zero values may not be supported by every
+		// implementation.
+		if recover() != nil {
+			converted, known = true, true
+		}
+	}()
+
+	if cc, ok := zeroValue(from).(value.ConvertibleIntoCapsule); ok {
+		err := cc.ConvertInto(reflect.New(into).Interface())
+		if !errors.Is(err, value.ErrNoConversion) {
+			return err == nil, true
+		}
+	}
+	if cc, ok := reflect.New(into).Interface().(value.ConvertibleFromCapsule); ok {
+		if err := cc.ConvertFrom(zeroValue(from)); !errors.Is(err, value.ErrNoConversion) {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// zeroValue returns an addressable zero value of t, or a pointer to it if
+// only the pointer implements the capsule conversion interfaces.
+func zeroValue(t reflect.Type) any {
+	ptr := reflect.New(t)
+	if !t.Implements(goConvertibleIntoCapsule) && ptr.Type().Implements(goConvertibleIntoCapsule) {
+		return ptr.Interface()
+	}
+	return ptr.Elem().Interface()
+}
+
+// hasCustomDecoding reports whether values of type t are decoded through an
+// interface rather than by their Alloy type.
+func hasCustomDecoding(t reflect.Type) bool {
+	return deref(t) == goDuration || implements(t, goAlloyDecoder) || implements(t, goTextUnmarshaler) ||
+		implements(t, goConvertibleFromCapsule)
+}
+
+// implements reports whether t or a pointer to t implements iface.
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface))
+}
+
+func isList(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// describe returns a description of the Alloy type for values of type t.
+func describe(t reflect.Type) string {
+	inner := deref(t)
+	switch ty := value.AlloyType(t); ty {
+	case value.TypeArray:
+		if isList(inner) {
+			return fmt.Sprintf("list(%s)", describe(inner.Elem()))
+		}
+	case value.TypeObject:
+		if inner.Kind() == reflect.Map {
+			return fmt.Sprintf("map(%s)", describe(inner.Elem()))
+		}
+	case value.TypeCapsule:
+		if inner == goAny {
+			return "any"
+		}
+		return fmt.Sprintf("capsule(%q)", inner.String())
+	default:
+		return ty.String()
+	}
+	return value.AlloyType(t).String()
+}
@@ -0,0 +1,221 @@
+package typecheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/parser"
+	"github.com/stretchr/testify/require"
+)
+
+type appendable interface {
+	Append(string)
+}
+
+type logsReceiver interface {
+	Receive(string)
+}
+
+type target map[string]string
+
+func (target) AlloyCapsule() {}
+
+type receiverExports struct {
+	Receiver appendable `alloy:"receiver,attr"`
+}
+
+type logsExports struct {
+	Receiver logsReceiver `alloy:"receiver,attr"`
+}
+
+type discoveryExports struct {
+	Targets []target `alloy:"targets,attr"`
+}
+
+type pipelineArgs struct {
+	Targets   []target          `alloy:"targets,attr,optional"`
+	ForwardTo []appendable      `alloy:"forward_to,attr,optional"`
+	Labels    map[string]string `alloy:"labels,attr,optional"`
+	Enabled   bool              `alloy:"enabled,attr,optional"`
+	Timeout   time.Duration     `alloy:"timeout,attr,optional"`
+	Anything  any               `alloy:"anything,attr,optional"`
+	Nested    *pipelineNested   `alloy:"nested,block,optional"`
+}
+
+type pipelineNested struct {
+	ForwardTo []appendable `alloy:"forward_to,attr,optional"`
+}
+
+func testResolver(path []string) (reflect.Type, bool) {
+	switch strings.Join(path, ".") {
+	case "remote.default":
+		return reflect.TypeFor[receiverExports](), true
+	case "discovery.default":
+		return reflect.TypeFor[discoveryExports](), true
+	case "logs.default":
+		return reflect.TypeFor[logsExports](), true
+	}
+	return nil, false
+}
+
+func TestBlockWithTypes(t *testing.T) {
+	tests := []struct {
+		desc        string
+		src         string
+		expectedErr string
+	}{
+		{
+			desc: "compatible references",
+			src: `
+				test "name" {
+					targets    = discovery.default.targets
+					forward_to = [remote.default.receiver]
+					labels     = {"a" = "b", "c" = 1}
+					enabled    = 1 == 2
+					timeout    = "10s"
+					anything   = discovery.default.targets
+				}
+			`,
+		},
+		{
+			desc: "unknown references are not checked",
+			src: `
+				test "name" {
+					targets    = unknown.default.targets
+					forward_to = remote.other.receivers
+					enabled    = sys.env("ENABLED")
+				}
+			`,
+		},
+		{
+			desc: "capsule in list of interfaces",
+			src: `
+				test "name" {
+					forward_to = [discovery.default.targets[0]]
+				}
+			`,
+			expectedErr: `3:20: expected capsule("typecheck.appendable"), got capsule("typecheck.target")`,
+		},
+		{
+			desc: "interface in list of other interfaces",
+			src: `
+				test "name" {
+					forward_to = [logs.default.receiver]
+				}
+			`,
+			expectedErr: `3:20: expected capsule("typecheck.appendable"), got capsule("typecheck.logsReceiver")`,
+		},
+		{
+			desc: "interface in list of capsules",
+			src: `
+				test "name" {
+					targets = [remote.default.receiver]
+				}
+			`,
+			expectedErr: `3:17: expected capsule("typecheck.target"), got capsule("typecheck.appendable")`,
+		},
+		{
+			desc: "list assigned to list of other capsules",
+			src: `
+				test "name" {
+					forward_to = discovery.default.targets
+				}
+			`,
+			expectedErr: `3:19: expected list(capsule("typecheck.appendable")), got list(capsule("typecheck.target"))`,
+		},
+		{
+			desc: "literal of the wrong type",
+			src: `
+				test "name" {
+					enabled = "yes"
+					labels  = {"a" = true}
+				}
+			`,
+			expectedErr: `3:16: expected bool, got string`,
+		},
+		{
+			desc: "nested block",
+			src: `
+				test "name" {
+					nested {
+						forward_to = [remote.default.receiver, "remote"]
+					}
+				}
+			`,
+			expectedErr: `4:46: expected capsule("typecheck.appendable"), got string`,
+		},
+		{
+			// Numbers convert to strings; whether the string is a valid
+			// duration depends on the value and isn't checked.
+			desc: "duration from a number",
+			src: `
+				test "name" {
+					timeout = 10
+				}
+			`,
+		},
+		{
+			desc: "duration from a bool",
+			src: `
+				test "name" {
+					timeout = true
+				}
+			`,
+			expectedErr: `3:16: expected string, got bool`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			file, err := parser.ParseFile("", []byte(tt.src))
+			require.NoError(t, err)
+			diags := BlockWithTypes(file.Body[0].(*ast.BlockStmt), &pipelineArgs{}, testResolver)
+			if tt.expectedErr == "" {
+				require.Len(t, diags, 0)
+			} else {
+				require.NotEmpty(t, diags)
+				require.EqualError(t, diags[0], tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestExprType(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected reflect.Type
+	}{
+		{`"str"`, reflect.TypeFor[string]()},
+		{`15`, reflect.TypeFor[int]()},
+		{`1.5`, reflect.TypeFor[float64]()},
+		{`!true`, reflect.TypeFor[bool]()},
+		{`[1, 2]`, reflect.TypeFor[[]int]()},
+		{`[1, "a"]`, reflect.TypeFor[[]any]()},
+		{`{"a" = "b"}`, reflect.TypeFor[map[string]string]()},
+		{`remote.default.receiver`, reflect.TypeFor[appendable]()},
+		{`discovery.default.targets[1]`, reflect.TypeFor[target]()},
+		{`(discovery.default)`, reflect.TypeFor[discoveryExports]()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpression(tt.expr)
+			require.NoError(t, err)
+			actual, ok := ExprType(expr, testResolver)
+			require.True(t, ok)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+
+	for _, expr := range []string{`null`, `discovery.default.unknown`, `other.value`, `1 + 2`, `f(1)`} {
+		t.Run(expr, func(t *testing.T) {
+			parsed, err := parser.ParseExpression(expr)
+			require.NoError(t, err)
+			_, ok := ExprType(parsed, testResolver)
+			require.False(t, ok)
+		})
+	}
+}
@@ -462,6 +462,22 @@ func (vm *Evaluator) evaluateExpr(scope *Scope, assoc map[value.Value]ast.Node,
 	case *ast.ParenExpr:
 		return vm.evaluateExpr(scope, assoc, expr.Inner)
 
+	case *ast.ConditionalExpr:
+		cond, err := vm.evaluateExpr(scope, assoc, expr.Condition)
+		if err != nil {
+			return value.Null, err
+		}
+		if cond.Type() != value.TypeBool {
+			return value.Null, value.TypeError{Value: cond, Expected: value.TypeBool}
+		}
+
+		// Only the selected branch is evaluated so that the other branch may
+		// reference values which don't exist or would otherwise fail.
+		if cond.Bool() {
+			return vm.evaluateExpr(scope, assoc, expr.True)
+		}
+		return vm.evaluateExpr(scope, assoc, expr.False)
+
 	case *ast.UnaryExpr:
 		val, err := vm.evaluateExpr(scope, assoc, expr.Value)
 		if err != nil {
@@ -469,6 +485,9 @@ func (vm *Evaluator) evaluateExpr(scope *Scope, assoc map[value.Value]ast.Node,
 		}
 		return evalUnaryOp(expr.Kind, val)
 
+	case *ast.FuncExpr:
+		return vm.evaluateFuncExpr(scope, assoc, expr), nil
+
 	case *ast.CallExpr:
 		funcVal, err := vm.evaluateExpr(scope, assoc, expr.Value)
 		if err != nil {
@@ -492,6 +511,26 @@ func (vm *Evaluator) evaluateExpr(scope *Scope, assoc map[value.Value]ast.Node,
 	}
 }
 
+// evaluateFuncExpr creates a function value from a function expression. The
+// function captures scope so that its body can refer to any variable which
+// was available where the function was declared.
+func (vm *Evaluator) evaluateFuncExpr(scope *Scope, assoc map[value.Value]ast.Node, expr *ast.FuncExpr) value.Value {
+	return value.Func(value.RawFunction(func(funcValue value.Value, args ...value.Value) (value.Value, error) {
+		if len(args) != len(expr.Params) {
+			return value.Null, value.Error{
+				Value: funcValue,
+				Inner: fmt.Errorf("expected %d args, got %d", len(expr.Params), len(args)),
+			}
+		}
+
+		vars := make(map[string]any, len(expr.Params))
+		for i, param := range expr.Params {
+			vars[param.Name] = args[i]
+		}
+		return vm.evaluateExpr(scope.child(vars), assoc, expr.Body)
+	}))
+}
+
 // A Scope exposes a set of variables available to use during evaluation.
 type Scope struct {
 	// Variables holds the list of available variable names that can be used when
@@ -501,6 +540,10 @@ type Scope struct {
 	// Evaluate; maps and slices will be copied by reference for performance
 	// optimizations.
 	Variables map[string]any
+
+	// parent is the enclosing scope, set for scopes created when calling
+	// function expressions. Variables in a scope shadow those of its parent.
+	parent *Scope
 }
 
 func NewScope(variables map[string]any) *Scope {
@@ -509,11 +552,19 @@ func NewScope(variables map[string]any) *Scope {
 	}
 }
 
+// child returns a new Scope which defines variables on top of s.
+func (s *Scope) child(variables map[string]any) *Scope {
+	return &Scope{
+		Variables: variables,
+		parent:    s,
+	}
+}
+
 // Lookup looks up a named identifier from the scope and the stdlib.
 func (s *Scope) Lookup(name string) (any, bool) {
-	// Check the scope first.
-	if s != nil {
-		if val, ok := s.Variables[name]; ok {
+	// Check the scope and its parents first.
+	for sc := s; sc != nil; sc = sc.parent {
+		if val, ok := sc.Variables[name]; ok {
 			return val, true
 		}
 	}
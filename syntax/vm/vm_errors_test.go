@@ -63,6 +63,38 @@ func TestVM_ExprErrors(t *testing.T) {
 			}{},
 			expect: `test:1:7: [0, 1, 2] should be string, got array`,
 		},
+		{
+			name:  "conditional with non-bool condition",
+			input: `key = 5 ? true : false`,
+			into: &struct {
+				Key bool `alloy:"key,attr"`
+			}{},
+			expect: `test:1:7: 5 should be bool, got number`,
+		},
+		{
+			name:  "conditional selected branch error",
+			input: `key = true ? missing : 0`,
+			into: &struct {
+				Key int `alloy:"key,attr"`
+			}{},
+			expect: `test:1:14: identifier "missing" does not exist`,
+		},
+		{
+			name:  "function called with wrong number of args",
+			input: `key = (x => x)(1, 2)`,
+			into: &struct {
+				Key int `alloy:"key,attr"`
+			}{},
+			expect: `test:1:7: (x => x) expected 1 args, got 2`,
+		},
+		{
+			name:  "error inside function body",
+			input: `key = (x => x.missing)({})`,
+			into: &struct {
+				Key int `alloy:"key,attr"`
+			}{},
+			expect: `test:1:15: field "missing" does not exist`,
+		},
 	}
 
 	for _, tc := range tt {
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/grafana/alloy/syntax/alloytypes"
 	"github.com/grafana/alloy/syntax/internal/value"
@@ -50,6 +51,34 @@ func TestVM_Stdlib(t *testing.T) {
 			`encoding.to_json({"modules"={"http_2xx"={"prober"="http","timeout"="5s","http"={"headers"={"Authorization"=sys.env("TEST_VAR")}}}}})`,
 			string(`{"modules":{"http_2xx":{"http":{"headers":{"Authorization":"Hello!"}},"prober":"http","timeout":"5s"}}}`),
 		},
+		// Higher-order array functions
+		{"array.map", `array.map([1, 2, 3], x => x * 2)`, []int{2, 4, 6}},
+		{"array.map empty", `array.map([], x => x * 2)`, []int{}},
+		{"array.map objects", `array.map([{"a" = 1}, {"a" = 2}], x => x.a)`, []int{1, 2}},
+		{"array.filter", `array.filter([1, 2, 3, 4], x => x % 2 == 0)`, []int{2, 4}},
+		{"array.filter none", `array.filter([1, 2], x => false)`, []int{}},
+		{"array.reduce", `array.reduce([1, 2, 3], (acc, x) => acc + x, 10)`, int(16)},
+		{"array.reduce empty", `array.reduce([], (acc, x) => acc + x, 10)`, int(10)},
+		{"array.sort_by numbers", `array.sort_by([3, 1, 2], x => x)`, []int{1, 2, 3}},
+		{"array.sort_by descending", `array.sort_by([3, 1, 2], x => -x)`, []int{3, 2, 1}},
+		{
+			"array.sort_by stable",
+			`array.sort_by([{"k" = "b", "v" = 1}, {"k" = "a", "v" = 2}, {"k" = "b", "v" = 3}], x => x.k)`,
+			[]map[string]any{{"k": "a", "v": 2}, {"k": "b", "v": 1}, {"k": "b", "v": 3}},
+		},
+		{"array.unique_by strings", `array.unique_by(["a", "b", "a", "c"], x => x)`, []string{"a", "b", "c"}},
+		{"array.unique_by numbers", `array.unique_by([1, 2, 3, 4], x => x % 2)`, []int{1, 2}},
+		{
+			"array.unique_by objects",
+			`array.unique_by([{"a" = 1, "b" = 1}, {"a" = 1, "b" = 2}, {"a" = 2, "b" = 3}], x => x.a)`,
+			[]map[string]any{{"a": 1, "b": 1}, {"a": 2, "b": 3}},
+		},
+		{"array.unique_by mixed types", `array.unique_by(["1", 1, true, "true"], x => x)`, []any{"1", 1, true, "true"}},
+		{
+			"array.map and array.filter chained",
+			`array.map(array.filter([{"job" = "a", "port" = 80}, {"job" = "b", "port" = 81}], t => t.job == "b"), t => t.port)`,
+			[]int{81},
+		},
 		// Map tests
 		{
 			// Basic case. No conflicting key/val pairs.
@@ -176,6 +205,42 @@ func TestVM_Stdlib_Errors(t *testing.T) {
 		input       string
 		expectedErr string
 	}{
+		// Higher-order array functions
+		{
+			"array.map",
+			`array.map(1, x => x)`,
+			`1 should be array, got number`,
+		},
+		{
+			"array.map",
+			`array.map([1], 1)`,
+			`1 should be function, got number`,
+		},
+		{
+			"array.filter",
+			`array.filter([1, 2], x => x)`,
+			`filter: function must return bool, got number`,
+		},
+		{
+			"array.sort_by",
+			`array.sort_by([1, "a"], x => x)`,
+			`sort_by: function must return keys of a single type, got number and string`,
+		},
+		{
+			"array.sort_by",
+			`array.sort_by([[1]], x => x)`,
+			`sort_by: function must return number or string, got array`,
+		},
+		{
+			"array.unique_by",
+			`array.unique_by([{}], x => x)`,
+			`unique_by: function must return string, number or bool, got object`,
+		},
+		{
+			"array.reduce",
+			`array.reduce([1], (a, b) => a + b)`,
+			`reduce: expected 3 arguments, got 2`,
+		},
 		// Map tests
 		{
 			// Error: invalid RHS type - string.
@@ -380,6 +445,94 @@ func TestStdlib_StringFunc(t *testing.T) {
 	}
 }
 
+func TestStdlib_RegexHashTimeMath(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		expect any
+	}{
+		{"string.regex_match", `string.regex_match("api-server-1", "^api-.*-\\d+$")`, true},
+		{"string.regex_match no match", `string.regex_match("web-1", "^api-")`, false},
+		{"string.regex_replace", `string.regex_replace("pod-abc-123", "-(\\d+)$", ":$1")`, "pod-abc:123"},
+		{"string.regex_replace named group", `string.regex_replace("10.0.0.1:9090", "(?P<host>[^:]+):\\d+", "${host}")`, "10.0.0.1"},
+		{"string.regex_find_all", `string.regex_find_all("a1 b22 c333", "\\d+")`, []string{"1", "22", "333"}},
+		{"string.regex_find_all none", `string.regex_find_all("abc", "\\d+")`, []string{}},
+
+		{"encoding.sha256", `encoding.sha256("hello")`, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{"encoding.md5", `encoding.md5("hello")`, "5d41402abc4b2a76b9719d911017c592"},
+		{"encoding.base64_encode", `encoding.base64_encode("hello")`, "aGVsbG8="},
+		{"encoding.base64_decode", `encoding.base64_decode("aGVsbG8=")`, "hello"},
+
+		{"time.format string", `time.format("2024-03-05T10:20:30Z", "2006-01-02")`, "2024-03-05"},
+		{"time.format unix", `time.format(0, "2006-01-02T15:04:05Z07:00")`, "1970-01-01T00:00:00Z"},
+		{"time.parse_duration", `time.parse_duration("1m30s")`, float64(90)},
+		{"time.parse_duration fraction", `time.parse_duration("250ms")`, float64(0.25)},
+
+		{"math.min", `math.min(3, 1, 2)`, 1},
+		{"math.min floats", `math.min(1.5, -2.5)`, float64(-2.5)},
+		{"math.max", `math.max(3, 1, 2)`, 3},
+		{"math.max single", `math.max(7)`, 7},
+		{"math.floor", `math.floor(2.7)`, 2},
+		{"math.floor negative", `math.floor(-2.5)`, -3},
+		{"math.floor int", `math.floor(4)`, 4},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, err := parser.ParseExpression(tc.input)
+			require.NoError(t, err)
+
+			eval := vm.New(expr)
+
+			rv := reflect.New(reflect.TypeOf(tc.expect))
+			require.NoError(t, eval.Evaluate(nil, rv.Interface()))
+			require.Equal(t, tc.expect, rv.Elem().Interface())
+		})
+	}
+}
+
+func TestStdlib_TimeNow(t *testing.T) {
+	expr, err := parser.ParseExpression(`time.now()`)
+	require.NoError(t, err)
+
+	var actual string
+	require.NoError(t, vm.New(expr).Evaluate(nil, &actual))
+
+	ts, err := time.Parse(time.RFC3339Nano, actual)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), ts, time.Minute)
+}
+
+func TestStdlib_RegexHashTimeMath_Errors(t *testing.T) {
+	tt := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{"string.regex_match invalid regex", `string.regex_match("a", "(")`, `"(" error parsing regexp: missing closing ): ` + "`(`"},
+		{"string.regex_match wrong type", `string.regex_match(1, "a")`, `1 should be string, got number`},
+		{"string.regex_replace arg count", `string.regex_replace("a", "b")`, `regex_replace: expected 3 arguments, got 2`},
+		{"encoding.sha256 wrong type", `encoding.sha256([])`, `[] should be string, got array`},
+		{"time.format invalid timestamp", `time.format("yesterday", "2006")`, `format: timestamp must be in RFC 3339 format`},
+		{"time.format wrong type", `time.format(true, "2006")`, `format: timestamp must be a string or number, got bool`},
+		{"time.parse_duration invalid", `time.parse_duration("5 minutes")`, `time: unknown unit " minutes" in duration "5 minutes"`},
+		{"math.min no args", `math.min()`, `min: expected at least 1 argument, got 0`},
+		{"math.max wrong type", `math.max(1, "2")`, `"2" should be number, got string`},
+		{"math.floor wrong type", `math.floor("2.5")`, `"2.5" should be number, got string`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, err := parser.ParseExpression(tc.input)
+			require.NoError(t, err)
+
+			var v any
+			err = vm.New(expr).Evaluate(nil, &v)
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
 func TestStdlibFileFunc(t *testing.T) {
 	tt := []struct {
 		name   string
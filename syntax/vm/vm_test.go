@@ -181,6 +181,23 @@ func TestVM_Evaluate(t *testing.T) {
 		{`!true`, bool(false)},
 		{`!false`, bool(true)},
 		{`-15`, int(-15)},
+
+		// Conditional
+		{`true ? 1 : 2`, int(1)},
+		{`false ? 1 : 2`, int(2)},
+		{`foobar == 42 ? "yes" : "no"`, string("yes")},
+		{`false ? 1 : true ? 2 : 3`, int(2)},
+		{`(true ? 1 : 2) + 10`, int(11)},
+		{`true ? 1 : does_not_exist`, int(1)}, // Unselected branch is not evaluated
+
+		// Functions
+		{`(x => x * 2)(21)`, int(42)},
+		{`((a, b) => a + b)(1, 2)`, int(3)},
+		{`(() => "hello")()`, string("hello")},
+		{`(x => x + foobar)(1)`, int(43)},   // Captures outer scope
+		{`(foobar => foobar)(1)`, int(1)},   // Parameters shadow outer scope
+		{`(x => y => x + y)(1)(2)`, int(3)}, // Closures
+		{`(x => x > 0 ? "pos" : "neg")(-1)`, "neg"},
 	}
 
 	for _, tc := range tt {